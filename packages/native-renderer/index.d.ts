@@ -21,6 +21,14 @@ export interface PageResult {
   renderTime: number
   /** 编码耗时（毫秒） */
   encodeTime: number
+  /**
+   * 本页触发的流式加载增量统计（仅流式渲染时提供）
+   *
+   * 第一页包含打开文档时读取结构数据的开销，所有页面的增量之和等于总统计。
+   */
+  streamStats?: StreamStats
+  /** 实际使用的缩放比例（像素/点，受 max_scale 和尺寸上限约束），失败时为 0 */
+  scale: number
 }
 /** 原始位图结果（不编码） */
 export interface RawBitmapResult {
@@ -38,6 +46,8 @@ export interface RawBitmapResult {
   buffer: Buffer
   /** 渲染耗时（毫秒） */
   renderTime: number
+  /** 实际使用的缩放比例（像素/点，受 max_scale 和尺寸上限约束），DPI = scale * 72 */
+  scale: number
 }
 /** 批量渲染结果 */
 export interface RenderResult {
@@ -53,6 +63,13 @@ export interface RenderResult {
   totalTime: number
 }
 /** 渲染配置选项 */
+/** 页面裁剪区域（以页面比例 0-1 表示，原点在左上角） */
+export interface ClipRegion {
+  x: number
+  y: number
+  width: number
+  height: number
+}
 export interface RenderOptions {
   /** 目标渲染宽度（默认 1280） */
   targetWidth?: number
@@ -74,6 +91,12 @@ export interface RenderOptions {
   jpegQuality?: number
   /** PNG 压缩级别（0-9，默认 6） */
   pngCompression?: number
+  /** 流式加载的缓存块大小（字节，2 的幂，16KB-4MB，默认 256KB，仅用于 renderPagesFromStream） */
+  blockSize?: number
+  /** 流式加载时等待单个分片响应的超时（毫秒，0 或不设置表示不限制，由 fetcher 负责中止卡住的请求；仅用于 renderPagesFromStream） */
+  fetchTimeout?: number
+  /** 只渲染页面的局部区域，target_width 作用于裁剪后的宽度（仅用于 renderPageToRawBitmap*） */
+  clip?: ClipRegion
 }
 /**
  * 从 PDF Buffer 渲染指定页面
@@ -122,6 +145,142 @@ export declare function getPageCountFromFile(filePath: string): number
  * PDF 的总页数
  */
 export declare function getPageCount(pdfBuffer: Buffer): number
+/** 书签（目录）项 */
+export interface OutlineItem {
+  /** 书签标题 */
+  title: string
+  /** 目标页码（从 1 开始），书签不指向页面时为空 */
+  pageNum?: number
+  /** 子书签 */
+  children: Array<OutlineItem>
+}
+/**
+ * 获取 PDF 书签（目录）
+ *
+ * # Arguments
+ * * `pdf_buffer` - PDF 文件的二进制数据
+ *
+ * # Returns
+ * 顶层书签列表，没有书签时返回空数组
+ */
+export declare function getOutline(pdfBuffer: Buffer): Array<OutlineItem>
+/**
+ * 从文件路径获取 PDF 书签（目录）
+ *
+ * # Arguments
+ * * `file_path` - PDF 文件的路径
+ *
+ * # Returns
+ * 顶层书签列表，没有书签时返回空数组
+ */
+export declare function getOutlineFromFile(filePath: string): Array<OutlineItem>
+/** 命名目标（文档 /Dests 字典或 /Names 树中按名称定义的跳转目标） */
+export interface NamedDestination {
+  /** 目标页码（从 1 开始） */
+  pageNum: number
+  /** 目标位置的横坐标（点，页面坐标系，原点在左下角），目标未指定时为空 */
+  x?: number
+  /** 目标位置的纵坐标（点，页面坐标系，原点在左下角），目标未指定时为空 */
+  y?: number
+  /** 目标页面宽度（点） */
+  pageWidth: number
+  /** 目标页面高度（点） */
+  pageHeight: number
+}
+/**
+ * 按名称查找命名目标
+ *
+ * # Arguments
+ * * `pdf_buffer` - PDF 文件的二进制数据
+ * * `name` - 目标名称
+ *
+ * # Returns
+ * 命名目标，名称不存在时返回空
+ */
+export declare function getNamedDestination(pdfBuffer: Buffer, name: string): NamedDestination | null
+/**
+ * 从文件路径按名称查找命名目标
+ *
+ * # Arguments
+ * * `file_path` - PDF 文件的路径
+ * * `name` - 目标名称
+ *
+ * # Returns
+ * 命名目标，名称不存在时返回空
+ */
+export declare function getNamedDestinationFromFile(filePath: string, name: string): NamedDestination | null
+/** 页面尺寸（点，72 DPI） */
+export interface PageSize {
+  /** 页码（从 1 开始） */
+  pageNum: number
+  width: number
+  height: number
+}
+/** 文档页数与前若干页的尺寸 */
+export interface PageSizes {
+  /** PDF 总页数 */
+  numPages: number
+  /** 前 `limit` 页的尺寸，读取失败的页面被跳过 */
+  pages: Array<PageSize>
+}
+/**
+ * 获取 PDF 页数与前若干页的尺寸（不渲染）
+ *
+ * # Arguments
+ * * `pdf_buffer` - PDF 文件的二进制数据
+ * * `limit` - 最多读取尺寸的页数（默认 16）
+ */
+export declare function getPageSizes(pdfBuffer: Buffer, limit?: number | undefined | null): PageSizes
+/**
+ * 从文件路径获取 PDF 页数与前若干页的尺寸（不渲染）
+ *
+ * # Arguments
+ * * `file_path` - PDF 文件的路径
+ * * `limit` - 最多读取尺寸的页数（默认 16）
+ */
+export declare function getPageSizesFromFile(filePath: string, limit?: number | undefined | null): PageSizes
+/** 内嵌图片在页面中的位置（点，72 DPI，左上角为原点） */
+export interface ImageBounds {
+  x: number
+  y: number
+  width: number
+  height: number
+}
+/** 页面内嵌图片 */
+export interface ExtractedImage {
+  /** 图片在页面对象中的序号（从 0 开始） */
+  index: number
+  /** 图片原始像素宽度（与在页面上的显示尺寸无关） */
+  width: number
+  /** 图片原始像素高度 */
+  height: number
+  /** 原始 RGBA 像素数据 */
+  buffer: Buffer
+  /** 图片在页面中的边界框 */
+  bounds: ImageBounds
+}
+/**
+ * 提取页面内嵌图片
+ *
+ * # Arguments
+ * * `pdf_buffer` - PDF 文件的二进制数据
+ * * `page_num` - 页码（从 1 开始）
+ *
+ * # Returns
+ * 页面中的图片列表，没有图片时返回空数组
+ */
+export declare function extractImages(pdfBuffer: Buffer, pageNum: number): Array<ExtractedImage>
+/**
+ * 从文件路径提取页面内嵌图片
+ *
+ * # Arguments
+ * * `file_path` - PDF 文件的路径
+ * * `page_num` - 页码（从 1 开始）
+ *
+ * # Returns
+ * 页面中的图片列表，没有图片时返回空数组
+ */
+export declare function extractImagesFromFile(filePath: string, pageNum: number): Array<ExtractedImage>
 /**
  * 渲染单页到原始位图（不编码）
  *
@@ -178,6 +337,12 @@ export interface StreamStats {
   cacheMisses: number
   /** 总下载字节数 */
   totalBytesFetched: number
+  /** 由缓存提供的字节数（未产生网络请求） */
+  cacheBytes: number
+  /** PDFium 实际读取的字节数（缓存命中与未命中之和） */
+  requestedBytes: number
+  /** 下载过的不重复字节数，即文件被触及的部分 */
+  touchedBytes: number
 }
 /**
  * 从流式数据源渲染 PDF 页面（异步版本）
@@ -191,11 +356,13 @@ export interface StreamStats {
  * * `page_nums` - 要渲染的页码数组（从 1 开始）
  * * `options` - 渲染配置选项
  * * `fetcher` - JavaScript 回调函数，用于获取指定范围的数据
+ * * `on_page` - 可选，每页渲染完成后以该页结果调用（按页码请求顺序，均在 Promise 完成前调用）；
+ *   传入时图像数据只交给回调，Promise 结果中各页的 buffer 为空
  *
  * # Returns
  * Promise<StreamRenderResult>
  */
-export declare function renderPagesFromStream(pdfSize: number, pageNums: number[], options: RenderOptions | null | undefined, fetcher: (offset: number, size: number, requestId: number) => void): object
+export declare function renderPagesFromStream(pdfSize: number, pageNums: number[], options: RenderOptions | null | undefined, fetcher: (offset: number, size: number, requestId: number) => void, onPage?: (page: PageResult) => void): object
 /**
  * 完成流式请求
  *
@@ -206,4 +373,48 @@ export declare function renderPagesFromStream(pdfSize: number, pageNums: number[
  * * `data` - 获取到的数据
  * * `error` - 错误信息（如果获取失败）
  */
+/**
+ * 从流式数据源获取 PDF 书签（异步版本）
+ *
+ * 只按需读取书签所在的对象，下载量通常远小于整个文件。
+ *
+ * # Arguments
+ * * `env` - NAPI 环境
+ * * `pdf_size` - PDF 文件的总大小（字节）
+ * * `fetcher` - JavaScript 回调函数，用于获取指定范围的数据
+ *
+ * # Returns
+ * Promise<OutlineItem[]>
+ */
+export declare function getOutlineFromStream(pdfSize: number, fetcher: (offset: number, size: number, requestId: number) => void): Promise<Array<OutlineItem>>
+/**
+ * 从流式数据源提取页面内嵌图片（异步版本）
+ *
+ * 只按需读取文档结构和该页面引用的对象。
+ *
+ * # Arguments
+ * * `env` - NAPI 环境
+ * * `pdf_size` - PDF 文件的总大小（字节）
+ * * `page_num` - 页码（从 1 开始）
+ * * `fetcher` - JavaScript 回调函数，用于获取指定范围的数据
+ *
+ * # Returns
+ * Promise<ExtractedImage[]>
+ */
+export declare function extractImagesFromStream(pdfSize: number, pageNum: number, fetcher: (offset: number, size: number, requestId: number) => void): Promise<Array<ExtractedImage>>
+/**
+ * 从流式数据源获取 PDF 页数与前若干页的尺寸（异步版本）
+ *
+ * 只按需读取文档结构和前若干页的页面字典。
+ *
+ * # Arguments
+ * * `env` - NAPI 环境
+ * * `pdf_size` - PDF 文件的总大小（字节）
+ * * `limit` - 最多读取尺寸的页数（默认 16）
+ * * `fetcher` - JavaScript 回调函数，用于获取指定范围的数据
+ *
+ * # Returns
+ * Promise<PageSizes>
+ */
+export declare function getPageSizesFromStream(pdfSize: number, limit: number | null | undefined, fetcher: (offset: number, size: number, requestId: number) => void): Promise<PageSizes>
 export declare function completeStreamRequest(requestId: number, data?: Buffer | undefined | null, error?: string | undefined | null): void
@@ -310,16 +310,27 @@ if (!nativeBinding) {
   throw new Error(`Failed to load native binding`)
 }
 
-const { renderPages, renderPagesFromFile, getPageCountFromFile, getPageCount, renderPageToRawBitmap, renderPageToRawBitmapFromBuffer, isPdfiumAvailable, warmup, getVersion, renderPagesFromStream, completeStreamRequest } = nativeBinding
+const { renderPages, renderPagesFromFile, getPageCountFromFile, getPageCount, getOutline, getOutlineFromFile, getNamedDestination, getNamedDestinationFromFile, getPageSizes, getPageSizesFromFile, extractImages, extractImagesFromFile, renderPageToRawBitmap, renderPageToRawBitmapFromBuffer, isPdfiumAvailable, warmup, getVersion, renderPagesFromStream, getOutlineFromStream, extractImagesFromStream, getPageSizesFromStream, completeStreamRequest } = nativeBinding
 
 module.exports.renderPages = renderPages
 module.exports.renderPagesFromFile = renderPagesFromFile
 module.exports.getPageCountFromFile = getPageCountFromFile
 module.exports.getPageCount = getPageCount
+module.exports.getOutline = getOutline
+module.exports.getOutlineFromFile = getOutlineFromFile
+module.exports.getNamedDestination = getNamedDestination
+module.exports.getNamedDestinationFromFile = getNamedDestinationFromFile
+module.exports.getPageSizes = getPageSizes
+module.exports.getPageSizesFromFile = getPageSizesFromFile
+module.exports.extractImages = extractImages
+module.exports.extractImagesFromFile = extractImagesFromFile
 module.exports.renderPageToRawBitmap = renderPageToRawBitmap
 module.exports.renderPageToRawBitmapFromBuffer = renderPageToRawBitmapFromBuffer
 module.exports.isPdfiumAvailable = isPdfiumAvailable
 module.exports.warmup = warmup
 module.exports.getVersion = getVersion
 module.exports.renderPagesFromStream = renderPagesFromStream
+module.exports.getOutlineFromStream = getOutlineFromStream
+module.exports.extractImagesFromStream = extractImagesFromStream
+module.exports.getPageSizesFromStream = getPageSizesFromStream
 module.exports.completeStreamRequest = completeStreamRequest
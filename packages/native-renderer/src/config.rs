@@ -24,6 +24,8 @@ pub struct RenderConfig {
     pub jpeg_quality: u8,
     /// PNG 压缩级别（0-9，0不压缩，9最大压缩）
     pub png_compression: u8,
+    /// 裁剪区域 (x, y, width, height)，以页面比例表示
+    pub clip: Option<(f32, f32, f32, f32)>,
 }
 
 impl Default for RenderConfig {
@@ -38,6 +40,7 @@ impl Default for RenderConfig {
             webp_method: 4,  // 速度和压缩率的最佳平衡点
             jpeg_quality: 85,
             png_compression: 6,
+            clip: None,
         }
     }
 }
@@ -4,7 +4,7 @@
 //! 通过 NAPI-RS 暴露给 Node.js 调用
 
 use napi::bindgen_prelude::*;
-use napi::threadsafe_function::{ErrorStrategy, ThreadSafeCallContext, ThreadsafeFunction};
+use napi::threadsafe_function::{ErrorStrategy, ThreadSafeCallContext, ThreadsafeFunction, ThreadsafeFunctionCallMode};
 use napi::{Env, JsFunction};
 use napi_derive::napi;
 
@@ -15,7 +15,7 @@ mod stream_reader;
 
 use config::RenderConfig;
 use renderer::{PdfRenderer, OutputFormat};
-use stream_reader::{BlockRequest, JsFileStreamer};
+use stream_reader::{validate_block_size, BlockRequest, JsFileStreamer, StreamerStats, CACHE_BLOCK_SIZE};
 
 /// 创建 PDFium 实例
 fn create_pdfium() -> Result<pdfium_render::prelude::Pdfium> {
@@ -42,6 +42,12 @@ pub struct PageResult {
     pub render_time: u32,
     /// 编码耗时（毫秒）
     pub encode_time: u32,
+    /// 本页触发的流式加载增量统计（仅流式渲染时提供）
+    ///
+    /// 第一页包含打开文档时读取结构数据的开销，所有页面的增量之和等于总统计。
+    pub stream_stats: Option<StreamStats>,
+    /// 实际使用的缩放比例（像素/点，受 max_scale 和尺寸上限约束），失败时为 0
+    pub scale: f64,
 }
 
 /// 原始位图结果（不编码）
@@ -61,6 +67,8 @@ pub struct RawBitmapResult {
     pub buffer: Buffer,
     /// 渲染耗时（毫秒）
     pub render_time: u32,
+    /// 实际使用的缩放比例（像素/点，受 max_scale 和尺寸上限约束），DPI = scale * 72
+    pub scale: f64,
 }
 
 /// 批量渲染结果
@@ -78,6 +86,16 @@ pub struct RenderResult {
     pub total_time: u32,
 }
 
+/// 页面裁剪区域（以页面比例 0-1 表示，原点在左上角）
+#[napi(object)]
+#[derive(Clone, Copy)]
+pub struct ClipRegion {
+    pub x: f64,
+    pub y: f64,
+    pub width: f64,
+    pub height: f64,
+}
+
 /// 渲染配置选项
 #[napi(object)]
 pub struct RenderOptions {
@@ -101,6 +119,12 @@ pub struct RenderOptions {
     pub jpeg_quality: Option<u32>,
     /// PNG 压缩级别（0-9，默认 6）
     pub png_compression: Option<u32>,
+    /// 流式加载的缓存块大小（字节，2 的幂，16KB-4MB，默认 256KB，仅用于 renderPagesFromStream）
+    pub block_size: Option<u32>,
+    /// 流式加载时等待单个分片响应的超时（毫秒，默认不限制，由 fetcher 负责中止卡住的请求；仅用于 renderPagesFromStream）
+    pub fetch_timeout: Option<u32>,
+    /// 只渲染页面的局部区域，target_width 作用于裁剪后的宽度（仅用于 renderPageToRawBitmap*）
+    pub clip: Option<ClipRegion>,
 }
 
 impl Default for RenderOptions {
@@ -116,6 +140,9 @@ impl Default for RenderOptions {
             webp_method: Some(4),
             jpeg_quality: Some(85),
             png_compression: Some(6),
+            block_size: None,
+            fetch_timeout: None,
+            clip: None,
         }
     }
 }
@@ -137,6 +164,7 @@ fn build_config(opts: &RenderOptions) -> RenderConfig {
         webp_method: opts.webp_method.unwrap_or(4),
         jpeg_quality: opts.jpeg_quality.map(|q| q as u8).unwrap_or(legacy_quality),
         png_compression: opts.png_compression.unwrap_or(6) as u8,
+        clip: opts.clip.map(|c| (c.x as f32, c.y as f32, c.width as f32, c.height as f32)),
     }
 }
 
@@ -283,6 +311,394 @@ pub fn get_page_count(pdf_buffer: Buffer) -> Result<u32> {
     Ok(document.pages().len() as u32)
 }
 
+/// 书签（目录）项
+#[napi(object)]
+pub struct OutlineItem {
+    /// 书签标题
+    pub title: String,
+    /// 目标页码（从 1 开始），书签不指向页面时为空
+    pub page_num: Option<u32>,
+    /// 子书签
+    pub children: Vec<OutlineItem>,
+}
+
+/// 书签最大嵌套深度
+const MAX_OUTLINE_DEPTH: usize = 64;
+
+/// 书签最大总数，防止损坏文件中的循环引用导致无限遍历
+const MAX_OUTLINE_ITEMS: usize = 10_000;
+
+/// 读取文档书签树
+fn read_outline(document: &pdfium_render::prelude::PdfDocument) -> Vec<OutlineItem> {
+    let mut budget = MAX_OUTLINE_ITEMS;
+    collect_outline(document.bookmarks().root(), 0, &mut budget)
+}
+
+/// 从 `first` 开始收集同级书签及其子书签
+fn collect_outline(
+    first: Option<pdfium_render::prelude::PdfBookmark>,
+    depth: usize,
+    budget: &mut usize,
+) -> Vec<OutlineItem> {
+    let mut items = Vec::new();
+    if depth >= MAX_OUTLINE_DEPTH {
+        return items;
+    }
+
+    let mut current = first;
+    while let Some(bookmark) = current {
+        if *budget == 0 {
+            break;
+        }
+        *budget -= 1;
+
+        items.push(OutlineItem {
+            title: bookmark.title().unwrap_or_default(),
+            page_num: bookmark
+                .destination()
+                .and_then(|dest| dest.page_index().ok())
+                .map(|index| index as u32 + 1),
+            children: collect_outline(bookmark.first_child(), depth + 1, budget),
+        });
+        current = bookmark.next_sibling();
+    }
+    items
+}
+
+/// 获取 PDF 书签（目录）
+///
+/// # Arguments
+/// * `pdf_buffer` - PDF 文件的二进制数据
+///
+/// # Returns
+/// 顶层书签列表，没有书签时返回空数组
+#[napi]
+pub fn get_outline(pdf_buffer: Buffer) -> Result<Vec<OutlineItem>> {
+    let pdfium = create_pdfium()?;
+
+    let document = pdfium
+        .load_pdf_from_byte_slice(&pdf_buffer, None)
+        .map_err(|e| Error::from_reason(format!("Failed to load PDF: {}", e)))?;
+
+    Ok(read_outline(&document))
+}
+
+/// 从文件路径获取 PDF 书签（目录）
+///
+/// # Arguments
+/// * `file_path` - PDF 文件的路径
+///
+/// # Returns
+/// 顶层书签列表，没有书签时返回空数组
+#[napi]
+pub fn get_outline_from_file(file_path: String) -> Result<Vec<OutlineItem>> {
+    let pdfium = create_pdfium()?;
+
+    let document = pdfium
+        .load_pdf_from_file(&file_path, None)
+        .map_err(|e| Error::from_reason(format!("Failed to load PDF: {}", e)))?;
+
+    Ok(read_outline(&document))
+}
+
+/// 命名目标（文档 /Dests 字典或 /Names 树中按名称定义的跳转目标）
+#[napi(object)]
+pub struct NamedDestination {
+    /// 目标页码（从 1 开始）
+    pub page_num: u32,
+    /// 目标位置的横坐标（点，页面坐标系，原点在左下角），目标未指定时为空
+    pub x: Option<f64>,
+    /// 目标位置的纵坐标（点，页面坐标系，原点在左下角），目标未指定时为空
+    pub y: Option<f64>,
+    /// 目标页面宽度（点）
+    pub page_width: f64,
+    /// 目标页面高度（点）
+    pub page_height: f64,
+}
+
+/// 按名称查找命名目标
+///
+/// pdfium-render 没有封装命名目标，直接调用 PDFium 的 `FPDF_GetNamedDestByName`。
+fn find_named_destination(
+    bindings: &dyn pdfium_render::prelude::PdfiumLibraryBindings,
+    document: pdfium_render::bindgen::FPDF_DOCUMENT,
+    name: &str,
+) -> Option<NamedDestination> {
+    use pdfium_render::bindgen::{FPDF_BOOL, FS_FLOAT};
+
+    let dest = bindings.FPDF_GetNamedDestByName(document, name);
+    if dest.is_null() {
+        return None;
+    }
+    let index = bindings.FPDFDest_GetDestPageIndex(document, dest);
+    if index < 0 {
+        return None;
+    }
+
+    let (mut has_x, mut has_y, mut has_zoom): (FPDF_BOOL, FPDF_BOOL, FPDF_BOOL) = (0, 0, 0);
+    let (mut x, mut y, mut zoom): (FS_FLOAT, FS_FLOAT, FS_FLOAT) = (0.0, 0.0, 0.0);
+    let located = bindings.FPDFDest_GetLocationInPage(
+        dest,
+        &mut has_x,
+        &mut has_y,
+        &mut has_zoom,
+        &mut x,
+        &mut y,
+        &mut zoom,
+    ) != 0;
+
+    let (mut page_width, mut page_height) = (0.0f64, 0.0f64);
+    bindings.FPDF_GetPageSizeByIndex(document, index, &mut page_width, &mut page_height);
+
+    Some(NamedDestination {
+        page_num: index as u32 + 1,
+        x: (located && has_x != 0).then_some(x as f64),
+        y: (located && has_y != 0).then_some(y as f64),
+        page_width,
+        page_height,
+    })
+}
+
+/// 加载文档后查找命名目标，并关闭文档
+fn lookup_named_destination(
+    bindings: &dyn pdfium_render::prelude::PdfiumLibraryBindings,
+    document: pdfium_render::bindgen::FPDF_DOCUMENT,
+    name: &str,
+) -> Result<Option<NamedDestination>> {
+    if document.is_null() {
+        return Err(Error::from_reason(format!(
+            "Failed to load PDF: error code {}",
+            bindings.FPDF_GetLastError()
+        )));
+    }
+    let destination = find_named_destination(bindings, document, name);
+    bindings.FPDF_CloseDocument(document);
+    Ok(destination)
+}
+
+/// 按名称查找命名目标
+///
+/// # Arguments
+/// * `pdf_buffer` - PDF 文件的二进制数据
+/// * `name` - 目标名称
+///
+/// # Returns
+/// 命名目标，名称不存在时返回空
+#[napi]
+pub fn get_named_destination(pdf_buffer: Buffer, name: String) -> Result<Option<NamedDestination>> {
+    let pdfium = create_pdfium()?;
+    let bindings = pdfium.bindings();
+    let document = bindings.FPDF_LoadMemDocument(&pdf_buffer, None);
+    lookup_named_destination(bindings, document, &name)
+}
+
+/// 从文件路径按名称查找命名目标
+///
+/// # Arguments
+/// * `file_path` - PDF 文件的路径
+/// * `name` - 目标名称
+///
+/// # Returns
+/// 命名目标，名称不存在时返回空
+#[napi]
+pub fn get_named_destination_from_file(file_path: String, name: String) -> Result<Option<NamedDestination>> {
+    let pdfium = create_pdfium()?;
+    let bindings = pdfium.bindings();
+    let document = bindings.FPDF_LoadDocument(&file_path, None);
+    lookup_named_destination(bindings, document, &name)
+}
+
+/// 页面尺寸（点，72 DPI）
+#[napi(object)]
+pub struct PageSize {
+    /// 页码（从 1 开始）
+    pub page_num: u32,
+    pub width: f64,
+    pub height: f64,
+}
+
+/// 文档页数与前若干页的尺寸
+#[napi(object)]
+pub struct PageSizes {
+    /// PDF 总页数
+    pub num_pages: u32,
+    /// 前 `limit` 页的尺寸，读取失败的页面被跳过
+    pub pages: Vec<PageSize>,
+}
+
+/// 默认读取尺寸的页数
+const DEFAULT_PAGE_SIZE_LIMIT: u32 = 16;
+
+/// 读取前 `limit` 页的尺寸
+///
+/// 只读取页面字典中的 MediaBox/CropBox，不解析页面内容，开销远小于渲染。
+fn read_page_sizes(document: &pdfium_render::prelude::PdfDocument, limit: Option<u32>) -> PageSizes {
+    let pages = document.pages();
+    let num_pages = pages.len() as u32;
+    let count = num_pages.min(limit.unwrap_or(DEFAULT_PAGE_SIZE_LIMIT));
+
+    PageSizes {
+        num_pages,
+        pages: (0..count)
+            .filter_map(|index| {
+                pages
+                    .page_size(index as pdfium_render::prelude::PdfPageIndex)
+                    .ok()
+                    .map(|rect| PageSize {
+                        page_num: index + 1,
+                        width: rect.width().value as f64,
+                        height: rect.height().value as f64,
+                    })
+            })
+            .collect(),
+    }
+}
+
+/// 获取 PDF 页数与前若干页的尺寸（不渲染）
+///
+/// # Arguments
+/// * `pdf_buffer` - PDF 文件的二进制数据
+/// * `limit` - 最多读取尺寸的页数（默认 16）
+#[napi]
+pub fn get_page_sizes(pdf_buffer: Buffer, limit: Option<u32>) -> Result<PageSizes> {
+    let pdfium = create_pdfium()?;
+
+    let document = pdfium
+        .load_pdf_from_byte_slice(&pdf_buffer, None)
+        .map_err(|e| Error::from_reason(format!("Failed to load PDF: {}", e)))?;
+
+    Ok(read_page_sizes(&document, limit))
+}
+
+/// 从文件路径获取 PDF 页数与前若干页的尺寸（不渲染）
+///
+/// # Arguments
+/// * `file_path` - PDF 文件的路径
+/// * `limit` - 最多读取尺寸的页数（默认 16）
+#[napi]
+pub fn get_page_sizes_from_file(file_path: String, limit: Option<u32>) -> Result<PageSizes> {
+    let pdfium = create_pdfium()?;
+
+    let document = pdfium
+        .load_pdf_from_file(&file_path, None)
+        .map_err(|e| Error::from_reason(format!("Failed to load PDF: {}", e)))?;
+
+    Ok(read_page_sizes(&document, limit))
+}
+
+/// 内嵌图片在页面中的位置（点，72 DPI，左上角为原点）
+#[napi(object)]
+pub struct ImageBounds {
+    pub x: f64,
+    pub y: f64,
+    pub width: f64,
+    pub height: f64,
+}
+
+/// 页面内嵌图片
+#[napi(object)]
+pub struct ExtractedImage {
+    /// 图片在页面对象中的序号（从 0 开始）
+    pub index: u32,
+    /// 图片原始像素宽度（与在页面上的显示尺寸无关）
+    pub width: u32,
+    /// 图片原始像素高度
+    pub height: u32,
+    /// 原始 RGBA 像素数据
+    pub buffer: Buffer,
+    /// 图片在页面中的边界框
+    pub bounds: ImageBounds,
+}
+
+/// 读取指定页面的内嵌图片
+///
+/// 只返回图片对象本身的像素（解码后、未应用页面变换），不包含矢量图形和文字。
+/// 无法解码的图片对象会被跳过。
+fn read_images(
+    document: &pdfium_render::prelude::PdfDocument,
+    page_num: u32,
+) -> std::result::Result<Vec<ExtractedImage>, String> {
+    use pdfium_render::prelude::{PdfPageObjectCommon, PdfPageObjectsCommon};
+
+    let num_pages = document.pages().len() as u32;
+    if page_num < 1 || page_num > num_pages {
+        return Err(format!("Invalid page number: {} (total: {})", page_num, num_pages));
+    }
+
+    let page = document
+        .pages()
+        .get((page_num - 1) as u16)
+        .map_err(|e| format!("Failed to get page: {}", e))?;
+    let page_height = page.height().value as f64;
+
+    let mut images = Vec::new();
+    for (index, object) in page.objects().iter().enumerate() {
+        let Some(image_object) = object.as_image_object() else {
+            continue;
+        };
+        let Ok(bitmap) = image_object.get_raw_bitmap() else {
+            continue;
+        };
+        let Ok(quad) = object.bounds() else {
+            continue;
+        };
+
+        // PDF 坐标以左下角为原点，转换为左上角原点，与 clip 一致
+        let rect = quad.to_rect();
+        images.push(ExtractedImage {
+            index: index as u32,
+            width: bitmap.width() as u32,
+            height: bitmap.height() as u32,
+            buffer: Buffer::from(bitmap.as_rgba_bytes().to_vec()),
+            bounds: ImageBounds {
+                x: rect.left().value as f64,
+                y: page_height - rect.top().value as f64,
+                width: rect.width().value as f64,
+                height: rect.height().value as f64,
+            },
+        });
+    }
+    Ok(images)
+}
+
+/// 提取页面内嵌图片
+///
+/// # Arguments
+/// * `pdf_buffer` - PDF 文件的二进制数据
+/// * `page_num` - 页码（从 1 开始）
+///
+/// # Returns
+/// 页面中的图片列表，没有图片时返回空数组
+#[napi]
+pub fn extract_images(pdf_buffer: Buffer, page_num: u32) -> Result<Vec<ExtractedImage>> {
+    let pdfium = create_pdfium()?;
+
+    let document = pdfium
+        .load_pdf_from_byte_slice(&pdf_buffer, None)
+        .map_err(|e| Error::from_reason(format!("Failed to load PDF: {}", e)))?;
+
+    read_images(&document, page_num).map_err(Error::from_reason)
+}
+
+/// 从文件路径提取页面内嵌图片
+///
+/// # Arguments
+/// * `file_path` - PDF 文件的路径
+/// * `page_num` - 页码（从 1 开始）
+///
+/// # Returns
+/// 页面中的图片列表，没有图片时返回空数组
+#[napi]
+pub fn extract_images_from_file(file_path: String, page_num: u32) -> Result<Vec<ExtractedImage>> {
+    let pdfium = create_pdfium()?;
+
+    let document = pdfium
+        .load_pdf_from_file(&file_path, None)
+        .map_err(|e| Error::from_reason(format!("Failed to load PDF: {}", e)))?;
+
+    read_images(&document, page_num).map_err(Error::from_reason)
+}
+
 /// 渲染单页到原始位图（不编码）
 ///
 /// 这个函数只进行 PDFium 渲染，跳过图像编码步骤，
@@ -316,6 +732,7 @@ pub fn render_page_to_raw_bitmap(
                 channels: 4,
                 buffer: Buffer::from(vec![]),
                 render_time: render_start.elapsed().as_millis() as u32,
+                scale: 0.0,
             });
         }
     };
@@ -331,6 +748,7 @@ pub fn render_page_to_raw_bitmap(
                 channels: 4,
                 buffer: Buffer::from(vec![]),
                 render_time: render_start.elapsed().as_millis() as u32,
+                scale: 0.0,
             });
         }
     };
@@ -363,6 +781,7 @@ pub fn render_page_to_raw_bitmap_from_buffer(
                 channels: 4,
                 buffer: Buffer::from(vec![]),
                 render_time: render_start.elapsed().as_millis() as u32,
+                scale: 0.0,
             });
         }
     };
@@ -378,6 +797,7 @@ pub fn render_page_to_raw_bitmap_from_buffer(
                 channels: 4,
                 buffer: Buffer::from(vec![]),
                 render_time: render_start.elapsed().as_millis() as u32,
+                scale: 0.0,
             });
         }
     };
@@ -461,6 +881,26 @@ pub struct StreamStats {
     pub cache_misses: u32,
     /// 总下载字节数
     pub total_bytes_fetched: i64,
+    /// 由缓存提供的字节数（未产生网络请求）
+    pub cache_bytes: i64,
+    /// PDFium 实际读取的字节数（缓存命中与未命中之和）
+    pub requested_bytes: i64,
+    /// 下载过的不重复字节数，即文件被触及的部分
+    pub touched_bytes: i64,
+}
+
+impl From<&StreamerStats> for StreamStats {
+    fn from(stats: &StreamerStats) -> Self {
+        Self {
+            total_requests: stats.total_requests,
+            cache_hits: stats.cache_hits,
+            cache_misses: stats.cache_misses,
+            total_bytes_fetched: stats.total_bytes_fetched as i64,
+            cache_bytes: stats.cache_bytes as i64,
+            requested_bytes: stats.requested_bytes as i64,
+            touched_bytes: stats.touched_bytes as i64,
+        }
+    }
 }
 
 /// 从流式数据源渲染 PDF 页面（异步版本）
@@ -474,11 +914,13 @@ pub struct StreamStats {
 /// * `page_nums` - 要渲染的页码数组（从 1 开始）
 /// * `options` - 渲染配置选项
 /// * `fetcher` - JavaScript 回调函数，用于获取指定范围的数据
+/// * `on_page` - 可选，每页渲染完成后以该页结果调用（按页码请求顺序，均在 Promise 完成前调用）；
+///   传入时图像数据只交给回调，Promise 结果中各页的 buffer 为空
 ///
 /// # Returns
 /// Promise<StreamRenderResult>
 #[napi(
-    ts_args_type = "pdfSize: number, pageNums: number[], options: RenderOptions | null | undefined, fetcher: (offset: number, size: number, requestId: number) => void"
+    ts_args_type = "pdfSize: number, pageNums: number[], options: RenderOptions | null | undefined, fetcher: (offset: number, size: number, requestId: number) => void, onPage?: (page: PageResult) => void"
 )]
 pub fn render_pages_from_stream(
     env: Env,
@@ -486,26 +928,28 @@ pub fn render_pages_from_stream(
     page_nums: Vec<u32>,
     options: Option<RenderOptions>,
     fetcher: JsFunction,
+    on_page: Option<JsFunction>,
 ) -> napi::Result<napi::JsObject> {
     let start_time = std::time::Instant::now();
     let opts = options.unwrap_or_default();
     let pdf_size_u64 = pdf_size as u64;
 
     let config = build_config(&opts);
+    let block_size = validate_block_size(opts.block_size.map_or(CACHE_BLOCK_SIZE, u64::from))
+        .map_err(napi::Error::from_reason)?;
 
     let task_id = next_task_id();
-
-    let tsfn: ThreadsafeFunction<BlockRequest, ErrorStrategy::CalleeHandled> = fetcher
-        .create_threadsafe_function(0, |ctx: ThreadSafeCallContext<BlockRequest>| {
-            let mut obj = ctx.env.create_object()?;
-            obj.set("offset", ctx.value.offset as f64)?;
-            obj.set("size", ctx.value.size)?;
-            obj.set("requestId", ctx.value.request_id)?;
-            Ok(vec![obj])
-        })?;
-
-    let streamer = JsFileStreamer::new(pdf_size_u64, tsfn, task_id);
+    let fetch_timeout = opts
+        .fetch_timeout
+        .filter(|&ms| ms > 0)
+        .map(|ms| std::time::Duration::from_millis(u64::from(ms)));
+    let streamer = JsFileStreamer::new(pdf_size_u64, create_fetcher_tsfn(&fetcher)?, task_id, block_size)
+        .with_fetch_timeout(fetch_timeout);
     let shared_state = streamer.get_shared_state();
+    let page_state = shared_state.clone();
+    let page_callback: Option<ThreadsafeFunction<PageResult, ErrorStrategy::Fatal>> = on_page
+        .map(|callback| callback.create_threadsafe_function(0, |ctx: ThreadSafeCallContext<PageResult>| Ok(vec![ctx.value])))
+        .transpose()?;
 
     register_stream_state(task_id, shared_state.clone());
 
@@ -517,7 +961,31 @@ pub fn render_pages_from_stream(
                     .load_pdf_from_reader(streamer, None)
                     .map_err(|e| format!("Failed to load PDF from stream: {}", e))?;
                 let renderer = PdfRenderer::new(&pdfium, config);
-                renderer.render_document_pages(&document, &page_nums)
+
+                // 基线从零开始，这样第一页会计入文档加载的开销，
+                // 所有页面的增量之和与最终的 streamStats 一致
+                let mut last_stats = StreamerStats::default();
+                renderer.render_document_pages_with(&document, &page_nums, |page| {
+                    let current = page_state.stats.lock().unwrap().clone();
+                    page.stream_stats = Some(StreamStats::from(&current.delta_since(&last_stats)));
+                    last_stats = current;
+
+                    if let Some(callback) = &page_callback {
+                        // 等待 JS 收到该页后再继续：保证所有页面都在 Promise 完成前、按顺序送达
+                        let (tx, rx) = std::sync::mpsc::channel();
+                        let status = callback.call_with_return_value(
+                            take_page(page),
+                            ThreadsafeFunctionCallMode::Blocking,
+                            move |_: napi::JsUnknown| {
+                                let _ = tx.send(());
+                                Ok(())
+                            },
+                        );
+                        if status == napi::Status::Ok {
+                            let _ = rx.recv();
+                        }
+                    }
+                })
             })
             .await
             .map_err(|e| napi::Error::from_reason(format!("Task join error: {}", e)))?;
@@ -527,13 +995,7 @@ pub fn render_pages_from_stream(
         |env: &mut Env, (result, shared_state, start_time, task_id): (std::result::Result<(u32, Vec<PageResult>), String>, std::sync::Arc<SharedState>, std::time::Instant, u32)| {
             unregister_stream_state(task_id);
 
-            let stats = shared_state.stats.lock().unwrap();
-            let stream_stats = StreamStats {
-                total_requests: stats.total_requests,
-                cache_hits: stats.cache_hits,
-                cache_misses: stats.cache_misses,
-                total_bytes_fetched: stats.total_bytes_fetched as i64,
-            };
+            let stream_stats = StreamStats::from(&*shared_state.stats.lock().unwrap());
 
             match result {
                 Ok((num_pages, pages)) => {
@@ -561,6 +1023,148 @@ pub fn render_pages_from_stream(
     )
 }
 
+/// 从流式数据源获取 PDF 书签（异步版本）
+///
+/// 只按需读取书签所在的对象，下载量通常远小于整个文件。
+///
+/// # Arguments
+/// * `env` - NAPI 环境
+/// * `pdf_size` - PDF 文件的总大小（字节）
+/// * `fetcher` - JavaScript 回调函数，用于获取指定范围的数据
+///
+/// # Returns
+/// Promise<OutlineItem[]>
+#[napi(
+    ts_args_type = "pdfSize: number, fetcher: (offset: number, size: number, requestId: number) => void",
+    ts_return_type = "Promise<Array<OutlineItem>>"
+)]
+pub fn get_outline_from_stream(
+    env: Env,
+    pdf_size: f64,
+    fetcher: JsFunction,
+) -> napi::Result<napi::JsObject> {
+    with_stream_document(env, pdf_size, &fetcher, |document| Ok(read_outline(document)))
+}
+
+/// 从流式数据源提取页面内嵌图片（异步版本）
+///
+/// 只按需读取文档结构和该页面引用的对象。
+///
+/// # Arguments
+/// * `env` - NAPI 环境
+/// * `pdf_size` - PDF 文件的总大小（字节）
+/// * `page_num` - 页码（从 1 开始）
+/// * `fetcher` - JavaScript 回调函数，用于获取指定范围的数据
+///
+/// # Returns
+/// Promise<ExtractedImage[]>
+#[napi(
+    ts_args_type = "pdfSize: number, pageNum: number, fetcher: (offset: number, size: number, requestId: number) => void",
+    ts_return_type = "Promise<Array<ExtractedImage>>"
+)]
+pub fn extract_images_from_stream(
+    env: Env,
+    pdf_size: f64,
+    page_num: u32,
+    fetcher: JsFunction,
+) -> napi::Result<napi::JsObject> {
+    with_stream_document(env, pdf_size, &fetcher, move |document| read_images(document, page_num))
+}
+
+/// 从流式数据源获取 PDF 页数与前若干页的尺寸（异步版本）
+///
+/// 只按需读取文档结构和前若干页的页面字典。
+///
+/// # Arguments
+/// * `env` - NAPI 环境
+/// * `pdf_size` - PDF 文件的总大小（字节）
+/// * `limit` - 最多读取尺寸的页数（默认 16）
+/// * `fetcher` - JavaScript 回调函数，用于获取指定范围的数据
+///
+/// # Returns
+/// Promise<PageSizes>
+#[napi(
+    ts_args_type = "pdfSize: number, limit: number | null | undefined, fetcher: (offset: number, size: number, requestId: number) => void",
+    ts_return_type = "Promise<PageSizes>"
+)]
+pub fn get_page_sizes_from_stream(
+    env: Env,
+    pdf_size: f64,
+    limit: Option<u32>,
+    fetcher: JsFunction,
+) -> napi::Result<napi::JsObject> {
+    with_stream_document(env, pdf_size, &fetcher, move |document| Ok(read_page_sizes(document, limit)))
+}
+
+/// 创建供 Rust 端请求数据块的 ThreadsafeFunction
+/// 取出页面结果交给回调，原位置留下不含图像数据的占位（页码、状态等字段不变）
+fn take_page(page: &mut PageResult) -> PageResult {
+    let placeholder = PageResult {
+        page_num: page.page_num,
+        width: page.width,
+        height: page.height,
+        buffer: Vec::new().into(),
+        success: page.success,
+        error: page.error.clone(),
+        render_time: page.render_time,
+        encode_time: page.encode_time,
+        stream_stats: None,
+        scale: page.scale,
+    };
+    std::mem::replace(page, placeholder)
+}
+
+fn create_fetcher_tsfn(
+    fetcher: &JsFunction,
+) -> napi::Result<ThreadsafeFunction<BlockRequest, ErrorStrategy::CalleeHandled>> {
+    fetcher.create_threadsafe_function(0, |ctx: ThreadSafeCallContext<BlockRequest>| {
+        let mut obj = ctx.env.create_object()?;
+        obj.set("offset", ctx.value.offset as f64)?;
+        obj.set("size", ctx.value.size)?;
+        obj.set("requestId", ctx.value.request_id)?;
+        Ok(vec![obj])
+    })
+}
+
+/// 通过流式加载打开文档，在独立线程中执行 `task`，返回 Promise
+///
+/// 适用于只读取文档结构（书签等）而不渲染页面的场景。
+fn with_stream_document<T, F>(
+    env: Env,
+    pdf_size: f64,
+    fetcher: &JsFunction,
+    task: F,
+) -> napi::Result<napi::JsObject>
+where
+    T: ToNapiValue + Send + 'static,
+    F: FnOnce(&pdfium_render::prelude::PdfDocument) -> std::result::Result<T, String> + Send + 'static,
+{
+    let task_id = next_task_id();
+    let streamer = JsFileStreamer::new(pdf_size as u64, create_fetcher_tsfn(fetcher)?, task_id, CACHE_BLOCK_SIZE);
+
+    register_stream_state(task_id, streamer.get_shared_state());
+
+    env.execute_tokio_future(
+        async move {
+            let result = tokio::task::spawn_blocking(move || {
+                let pdfium = create_pdfium().map_err(|e| e.to_string())?;
+                let document = pdfium
+                    .load_pdf_from_reader(streamer, None)
+                    .map_err(|e| format!("Failed to load PDF from stream: {}", e))?;
+                task(&document)
+            })
+            .await;
+
+            unregister_stream_state(task_id);
+
+            result
+                .map_err(|e| napi::Error::from_reason(format!("Task join error: {}", e)))?
+                .map_err(napi::Error::from_reason)
+        },
+        |_env: &mut Env, value: T| Ok(value),
+    )
+}
+
 /// 完成流式请求
 ///
 /// 当 JS 端获取到数据后，调用这个函数将数据发送给 Rust 端。
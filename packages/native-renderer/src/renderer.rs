@@ -84,11 +84,28 @@ impl<'a> PdfRenderer<'a> {
         document: &PdfDocument,
         page_nums: &[u32],
     ) -> std::result::Result<(u32, Vec<PageResult>), String> {
+        self.render_document_pages_with(document, page_nums, |_| {})
+    }
+
+    /// 从已加载的 PdfDocument 渲染指定页面，每页完成后回调 `on_page`
+    ///
+    /// 回调在该页渲染结束、下一页开始之前执行，可用于附加逐页信息
+    /// （例如流式加载的增量统计）。
+    pub fn render_document_pages_with<F>(
+        &self,
+        document: &PdfDocument,
+        page_nums: &[u32],
+        mut on_page: F,
+    ) -> std::result::Result<(u32, Vec<PageResult>), String>
+    where
+        F: FnMut(&mut PageResult),
+    {
         let num_pages = document.pages().len() as u32;
         let mut results = Vec::with_capacity(page_nums.len());
 
         for &page_num in page_nums {
-            let result = self.render_single_page(document, page_num, num_pages);
+            let mut result = self.render_single_page(document, page_num, num_pages);
+            on_page(&mut result);
             results.push(result);
         }
 
@@ -115,6 +132,8 @@ impl<'a> PdfRenderer<'a> {
                 error: Some(format!("Invalid page number: {} (total: {})", page_num, num_pages)),
                 render_time: 0,
                 encode_time: 0,
+                stream_stats: None,
+                scale: 0.0,
             };
         }
 
@@ -133,6 +152,8 @@ impl<'a> PdfRenderer<'a> {
                     error: Some(format!("Failed to get page: {}", e)),
                     render_time: 0,
                     encode_time: 0,
+                    stream_stats: None,
+                    scale: 0.0,
                 };
             }
         };
@@ -200,6 +221,8 @@ impl<'a> PdfRenderer<'a> {
                     error: Some(format!("Failed to render page: {}", e)),
                     render_time: render_start.elapsed().as_millis() as u32,
                     encode_time: 0,
+                    stream_stats: None,
+                    scale: 0.0,
                 };
             }
         };
@@ -243,6 +266,8 @@ impl<'a> PdfRenderer<'a> {
                         error: Some("Failed to create image buffer for resize".to_string()),
                         render_time,
                         encode_time: 0,
+                        stream_stats: None,
+                        scale: 0.0,
                     };
                 }
             };
@@ -266,6 +291,8 @@ impl<'a> PdfRenderer<'a> {
                     error: Some(e),
                     render_time,
                     encode_time: 0,
+                    stream_stats: None,
+                    scale: 0.0,
                 };
             }
         };
@@ -281,6 +308,8 @@ impl<'a> PdfRenderer<'a> {
             error: None,
             render_time,
             encode_time,
+            stream_stats: None,
+            scale: final_width as f64 / original_width as f64,
         }
     }
 
@@ -413,6 +442,7 @@ impl<'a> PdfRenderer<'a> {
                 channels: 4,
                 buffer: Buffer::from(vec![]),
                 render_time: render_start.elapsed().as_millis() as u32,
+                scale: 0.0,
             };
         }
 
@@ -430,6 +460,7 @@ impl<'a> PdfRenderer<'a> {
                     channels: 4,
                     buffer: Buffer::from(vec![]),
                     render_time: render_start.elapsed().as_millis() as u32,
+                    scale: 0.0,
                 };
             }
         };
@@ -445,11 +476,22 @@ impl<'a> PdfRenderer<'a> {
             self.config.target_width as f32
         };
 
-        let mut scale = target_width / original_width;
+        // 裁剪区域（点，原点在左上角），未指定时为整页；target_width 作用于区域宽度
+        let (region_left, region_top, region_width, region_height) = match self.config.clip {
+            Some((x, y, w, h)) => (
+                x * original_width,
+                y * original_height,
+                w * original_width,
+                h * original_height,
+            ),
+            None => (0.0, 0.0, original_width, original_height),
+        };
+
+        let mut scale = target_width / region_width;
         scale = scale.min(self.config.max_scale);
 
-        let mut render_width = (original_width * scale).round() as u32;
-        let mut render_height = (original_height * scale).round() as u32;
+        let mut render_width = ((region_width * scale).round() as u32).max(1);
+        let mut render_height = ((region_height * scale).round() as u32).max(1);
 
         // 尺寸限制检查（为了内存安全）
         let max_dimension: u32 = 32767;
@@ -468,18 +510,42 @@ impl<'a> PdfRenderer<'a> {
             let limit_factor = width_factor.min(height_factor);
             
             scale *= limit_factor;
-            render_width = (original_width * scale).round() as u32;
-            render_height = (original_height * scale).round() as u32;
+            render_width = ((region_width * scale).round() as u32).max(1);
+            render_height = ((region_height * scale).round() as u32).max(1);
+        }
+
+        let mut render_config = PdfRenderConfig::new()
+            .set_target_width(render_width as i32)
+            .set_target_height(render_height as i32)
+            .render_form_data(true)
+            .render_annotations(true);
+
+        // 局部渲染：位图只有区域大小。PdfRenderConfig 先应用自定义变换，再把整页缩放到目标尺寸
+        // （render_width / original_width），因此先平移让区域左上角落在原点，再按页面/区域的比例放大，
+        // 合成后区域恰好铺满位图，区域外的内容不会被光栅化
+        if self.config.clip.is_some() {
+            render_config = match render_config
+                .translate(PdfPoints::new(-region_left), PdfPoints::new(-region_top))
+                .and_then(|c| c.scale(original_width / region_width, original_height / region_height))
+            {
+                Ok(c) => c.clip(0, 0, render_width as i32, render_height as i32),
+                Err(e) => {
+                    return RawBitmapResult {
+                        success: false,
+                        error: Some(format!("Failed to set clip transform: {}", e)),
+                        width: 0,
+                        height: 0,
+                        channels: 4,
+                        buffer: Buffer::from(vec![]),
+                        render_time: render_start.elapsed().as_millis() as u32,
+                        scale: 0.0,
+                    };
+                }
+            };
         }
 
         // 渲染页面为 RGBA 位图
-        let bitmap = match page.render_with_config(
-            &PdfRenderConfig::new()
-                .set_target_width(render_width as i32)
-                .set_target_height(render_height as i32)
-                .render_form_data(true)
-                .render_annotations(true)
-        ) {
+        let bitmap = match page.render_with_config(&render_config) {
             Ok(b) => b,
             Err(e) => {
                 return RawBitmapResult {
@@ -490,6 +556,7 @@ impl<'a> PdfRenderer<'a> {
                     channels: 4,
                     buffer: Buffer::from(vec![]),
                     render_time: render_start.elapsed().as_millis() as u32,
+                    scale: 0.0,
                 };
             }
         };
@@ -508,6 +575,7 @@ impl<'a> PdfRenderer<'a> {
             channels: 4,
             buffer: Buffer::from(rgba_data),
             render_time: render_start.elapsed().as_millis() as u32,
+            scale: actual_width as f64 / region_width as f64,
         }
     }
 }
@@ -9,7 +9,7 @@ use napi::bindgen_prelude::Buffer;
 use napi::threadsafe_function::{
     ErrorStrategy, ThreadsafeFunction, ThreadsafeFunctionCallMode,
 };
-use std::collections::HashMap;
+use std::collections::{HashMap, HashSet};
 use std::io::{self, Read, Seek, SeekFrom};
 use std::sync::{mpsc, Arc, Mutex};
 
@@ -25,11 +25,71 @@ pub struct BlockRequest {
 /// 用于接收 JS 响应的 channel sender
 type ResponseSender = mpsc::Sender<Result<Vec<u8>, String>>;
 
-/// 缓存块大小（256KB）
-const CACHE_BLOCK_SIZE: u64 = 256 * 1024;
+/// 默认缓存块大小（256KB）
+pub const CACHE_BLOCK_SIZE: u64 = 256 * 1024;
 
-/// 最大缓存块数量
-const MAX_CACHE_BLOCKS: usize = 64;
+/// 缓存块大小下限（16KB）
+const MIN_CACHE_BLOCK_SIZE: u64 = 16 * 1024;
+
+/// 缓存块大小上限（4MB）
+const MAX_CACHE_BLOCK_SIZE: u64 = 4 * 1024 * 1024;
+
+/// 缓存总容量（16MB，默认块大小下为 64 块）
+const MAX_CACHE_BYTES: u64 = 16 * 1024 * 1024;
+
+/// 顺序读取时单次请求最多预读的字节数（2MB，默认块大小下为 8 块）
+const MAX_READAHEAD_BYTES: u64 = 2 * 1024 * 1024;
+
+/// 校验缓存块大小
+///
+/// 块大小必须是 2 的幂且位于 [16KB, 4MB]：块偏移按块大小对齐，2 的幂保证块边界与
+/// 常见的存储/CDN 分片对齐；过小的块会放大请求次数，过大的块会放大缓存占用和单次下载量。
+pub fn validate_block_size(block_size: u64) -> Result<u64, String> {
+    if !block_size.is_power_of_two()
+        || !(MIN_CACHE_BLOCK_SIZE..=MAX_CACHE_BLOCK_SIZE).contains(&block_size)
+    {
+        return Err(format!(
+            "Invalid block size {}: must be a power of two between {} and {}",
+            block_size, MIN_CACHE_BLOCK_SIZE, MAX_CACHE_BLOCK_SIZE
+        ));
+    }
+    Ok(block_size)
+}
+
+/// 自适应预读状态
+///
+/// 缓存未命中的块恰好紧接在上一次请求的末尾时视为顺序读取，预读窗口翻倍（最多
+/// `MAX_READAHEAD_BYTES`），减少请求次数；否则视为随机访问，窗口回到 1 块，
+/// 避免下载用不到的数据。
+#[derive(Debug)]
+struct ReadAhead {
+    /// 上一次请求的结束偏移
+    next_offset: Option<u64>,
+    /// 当前预读窗口（块数）
+    window: u64,
+    /// 预读窗口上限（块数）
+    max_window: u64,
+}
+
+impl ReadAhead {
+    fn new(max_window: u64) -> Self {
+        Self {
+            next_offset: None,
+            window: 1,
+            max_window,
+        }
+    }
+
+    /// 记录一次从 `block_offset` 开始的未命中，返回本次应获取的块数上限
+    fn on_miss(&mut self, block_offset: u64) -> u64 {
+        self.window = if self.next_offset == Some(block_offset) {
+            (self.window * 2).min(self.max_window)
+        } else {
+            1
+        };
+        self.window
+    }
+}
 
 /// LRU 缓存条目
 struct CacheEntry {
@@ -48,36 +108,97 @@ pub struct StreamerStats {
     pub cache_misses: u32,
     /// 总下载字节数
     pub total_bytes_fetched: u64,
+    /// 由缓存提供的字节数（未产生网络请求）
+    pub cache_bytes: u64,
+    /// PDFium 实际读取的字节数（缓存命中与未命中之和）
+    pub requested_bytes: u64,
+    /// 下载过的不重复字节数（缓存淘汰后重新下载的块只计一次），即文件被触及的部分
+    pub touched_bytes: u64,
+}
+
+impl StreamerStats {
+    /// 计算相对于 `since` 的增量统计
+    ///
+    /// 用于逐页报告：每页完成后与上一页的快照相减，
+    /// 即可得到该页（含文档加载时的结构读取）触发的下载量。
+    pub fn delta_since(&self, since: &StreamerStats) -> StreamerStats {
+        StreamerStats {
+            total_requests: self.total_requests.saturating_sub(since.total_requests),
+            cache_hits: self.cache_hits.saturating_sub(since.cache_hits),
+            cache_misses: self.cache_misses.saturating_sub(since.cache_misses),
+            total_bytes_fetched: self
+                .total_bytes_fetched
+                .saturating_sub(since.total_bytes_fetched),
+            cache_bytes: self.cache_bytes.saturating_sub(since.cache_bytes),
+            requested_bytes: self.requested_bytes.saturating_sub(since.requested_bytes),
+            touched_bytes: self.touched_bytes.saturating_sub(since.touched_bytes),
+        }
+    }
 }
 
 /// 共享状态（用于在 streamer 被 move 后仍能获取统计信息）
 pub struct SharedState {
     /// 任务 ID（用于并发支持）
     task_id: u32,
+    /// 缓存块大小（已通过 `validate_block_size` 校验）
+    block_size: u64,
+    /// 最大缓存块数量
+    max_cache_blocks: usize,
     /// 数据缓存（LRU）
     cache: Mutex<HashMap<u64, CacheEntry>>,
     /// 缓存访问计数器
     access_counter: Mutex<u64>,
     /// 统计信息
+    ///
+    /// 同一事件的相关计数在一次加锁内更新，其他线程加锁后 clone 得到的快照不会包含更新到一半的计数。
     pub stats: Mutex<StreamerStats>,
+    /// `stats_delta` 上次调用时的统计快照
+    delta_baseline: Mutex<StreamerStats>,
     /// 待处理的请求（request_id -> sender）
     pending_requests: Mutex<HashMap<u32, ResponseSender>>,
     /// 下一个请求序号（16 位，会与 task_id 组合成完整的 request_id）
     next_request_seq: Mutex<u16>,
+    /// 自适应预读状态
+    readahead: Mutex<ReadAhead>,
+    /// 下载过的块偏移（用于统计 touched_bytes）
+    fetched_blocks: Mutex<HashSet<u64>>,
 }
 
 impl SharedState {
-    fn new(task_id: u32) -> Self {
+    fn new(task_id: u32, block_size: u64) -> Self {
+        debug_assert!(validate_block_size(block_size).is_ok());
         Self {
             task_id,
+            block_size,
+            max_cache_blocks: (MAX_CACHE_BYTES / block_size) as usize,
             cache: Mutex::new(HashMap::new()),
             access_counter: Mutex::new(0),
             stats: Mutex::new(StreamerStats::default()),
+            delta_baseline: Mutex::new(StreamerStats::default()),
             pending_requests: Mutex::new(HashMap::new()),
             next_request_seq: Mutex::new(0),
+            readahead: Mutex::new(ReadAhead::new((MAX_READAHEAD_BYTES / block_size).max(1))),
+            fetched_blocks: Mutex::new(HashSet::new()),
         }
     }
 
+    /// 获取自上次调用以来的增量统计，并将当前统计设为新的基线
+    ///
+    /// 用于长时间渲染中的实时吞吐量报告。先持有基线锁再读取统计快照，
+    /// 并发调用时各次增量不会重叠或遗漏，所有增量之和等于累计统计。
+    pub fn stats_delta(&self) -> StreamerStats {
+        let mut baseline = self.delta_baseline.lock().unwrap();
+        let current = self.stats.lock().unwrap().clone();
+        let delta = current.delta_since(&baseline);
+        *baseline = current;
+        delta
+    }
+
+    /// 计算缓存块的起始偏移量
+    fn cache_block_offset(&self, offset: u64) -> u64 {
+        offset & !(self.block_size - 1)
+    }
+
     /// 生成下一个请求 ID
     /// 格式：高 16 位是 task_id，低 16 位是请求序号
     fn next_id(&self) -> u32 {
@@ -96,72 +217,14 @@ impl SharedState {
             .insert(request_id, sender);
     }
 
-    /// 完成一个请求
-    pub fn complete_request(&self, request_id: u32, data: Result<Vec<u8>, String>) {
-        if let Some(sender) = self.pending_requests.lock().unwrap().remove(&request_id) {
-            let _ = sender.send(data);
-        }
-    }
-}
-
-/// 流式 PDF 读取器
-///
-/// 这个结构体实现了 `Read + Seek` trait，允许 PDFium 按需读取 PDF 数据。
-/// 当 PDFium 需要数据时，它会通过 NAPI-RS 回调到 JavaScript，
-/// JavaScript 使用 HTTP Range 请求获取数据并返回。
-///
-/// 关键技术：使用独立线程 + tokio runtime 来等待 async JS Promise。
-pub struct JsFileStreamer {
-    /// 文件总大小
-    file_size: u64,
-    /// 当前读取位置
-    position: u64,
-    /// 线程安全函数，用于回调 JavaScript
-    fetcher: ThreadsafeFunction<BlockRequest, ErrorStrategy::CalleeHandled>,
-    /// 共享状态
-    state: Arc<SharedState>,
-}
-
-impl JsFileStreamer {
-    /// 创建新的流式读取器
-    pub fn new(
-        file_size: u64,
-        fetcher: ThreadsafeFunction<BlockRequest, ErrorStrategy::CalleeHandled>,
-        task_id: u32,
-    ) -> Self {
-        Self {
-            file_size,
-            position: 0,
-            fetcher,
-            state: Arc::new(SharedState::new(task_id)),
-        }
-    }
-
-    /// 获取共享状态的引用（用于在 streamer 被 move 后获取统计信息）
-    #[allow(dead_code)]
-    pub fn get_shared_state(&self) -> Arc<SharedState> {
-        Arc::clone(&self.state)
-    }
-
-    /// 获取统计信息
-    #[allow(dead_code)]
-    pub fn get_stats(&self) -> StreamerStats {
-        self.state.stats.lock().unwrap().clone()
-    }
-
-    /// 计算缓存块的起始偏移量
-    fn cache_block_offset(offset: u64) -> u64 {
-        (offset / CACHE_BLOCK_SIZE) * CACHE_BLOCK_SIZE
-    }
-
     /// 从缓存中读取数据
     fn read_from_cache(&self, offset: u64, size: u32) -> Option<Vec<u8>> {
-        let block_offset = Self::cache_block_offset(offset);
-        let mut cache = self.state.cache.lock().unwrap();
+        let block_offset = self.cache_block_offset(offset);
+        let mut cache = self.cache.lock().unwrap();
 
         if let Some(entry) = cache.get_mut(&block_offset) {
             // 更新访问顺序
-            let mut counter = self.state.access_counter.lock().unwrap();
+            let mut counter = self.access_counter.lock().unwrap();
             *counter += 1;
             entry.access_order = *counter;
 
@@ -171,7 +234,10 @@ impl JsFileStreamer {
             let read_size = (size as usize).min(available);
 
             if read_size > 0 {
-                self.state.stats.lock().unwrap().cache_hits += 1;
+                let mut stats = self.stats.lock().unwrap();
+                stats.cache_hits += 1;
+                stats.cache_bytes += read_size as u64;
+                stats.requested_bytes += read_size as u64;
                 return Some(entry.data[offset_in_block..offset_in_block + read_size].to_vec());
             }
         }
@@ -181,11 +247,11 @@ impl JsFileStreamer {
 
     /// 将数据写入缓存
     fn write_to_cache(&self, offset: u64, data: Vec<u8>) {
-        let block_offset = Self::cache_block_offset(offset);
-        let mut cache = self.state.cache.lock().unwrap();
+        let block_offset = self.cache_block_offset(offset);
+        let mut cache = self.cache.lock().unwrap();
 
         // 如果缓存已满，删除最旧的条目
-        while cache.len() >= MAX_CACHE_BLOCKS {
+        while cache.len() >= self.max_cache_blocks {
             let oldest_key = cache
                 .iter()
                 .min_by_key(|(_, v)| v.access_order)
@@ -198,7 +264,7 @@ impl JsFileStreamer {
             }
         }
 
-        let mut counter = self.state.access_counter.lock().unwrap();
+        let mut counter = self.access_counter.lock().unwrap();
         *counter += 1;
 
         cache.insert(
@@ -210,31 +276,179 @@ impl JsFileStreamer {
         );
     }
 
-    /// 从 JavaScript 获取数据块
+    /// 读取 `offset` 起最多 `size` 字节（不跨缓存块）
     ///
-    /// 这个方法发送请求到 JS，然后阻塞等待响应。
-    /// JS 端需要在获取数据后调用 completeRequest 来发送响应。
-    fn fetch_block(&self, offset: u64, size: u32) -> io::Result<Vec<u8>> {
-        // 先检查缓存
+    /// - `size` 为 0 或 `offset` 位于文件末尾及之后时返回空数据，不发起请求
+    /// - 缓存未命中时通过 `fetch(block_offset, fetch_size)` 获取整个缓存块并写入缓存，
+    ///   起点恰好位于块边界的读取只会获取该块一次
+    /// - 顺序读取时一次获取多个连续块（见 `ReadAhead`），遇到已缓存的块或文件末尾即停止
+    /// - 获取到的数据不足以覆盖 `offset` 时返回 `UnexpectedEof`
+    fn read_block<F>(&self, file_size: u64, offset: u64, size: u32, fetch: F) -> io::Result<Vec<u8>>
+    where
+        F: FnOnce(u64, u32) -> io::Result<Vec<u8>>,
+    {
+        if size == 0 || offset >= file_size {
+            return Ok(Vec::new());
+        }
+
         if let Some(data) = self.read_from_cache(offset, size) {
             return Ok(data);
         }
 
-        self.state.stats.lock().unwrap().cache_misses += 1;
-        self.state.stats.lock().unwrap().total_requests += 1;
+        {
+            let mut stats = self.stats.lock().unwrap();
+            stats.cache_misses += 1;
+            stats.total_requests += 1;
+        }
+
+        // 计算要获取的块数（至少一个缓存块，顺序读取时向后预读未缓存的连续块，末尾截断到文件大小）
+        let block_offset = self.cache_block_offset(offset);
+        let window = self.readahead.lock().unwrap().on_miss(block_offset);
+        let mut blocks = 1;
+        {
+            let cache = self.cache.lock().unwrap();
+            while blocks < window {
+                let next = block_offset + blocks * self.block_size;
+                if next >= file_size || cache.contains_key(&next) {
+                    break;
+                }
+                blocks += 1;
+            }
+        }
+        let fetch_size = (blocks * self.block_size).min(file_size - block_offset) as u32;
 
-        // 计算要获取的块大小（至少获取一个缓存块大小）
-        let block_offset = Self::cache_block_offset(offset);
-        let remaining = self.file_size.saturating_sub(block_offset);
-        let fetch_size = CACHE_BLOCK_SIZE.min(remaining) as u32;
+        let data = fetch(block_offset, fetch_size)?;
+        self.stats.lock().unwrap().total_bytes_fetched += data.len() as u64;
+        self.readahead.lock().unwrap().next_offset = Some(block_offset + data.len() as u64);
 
-        if fetch_size == 0 {
+        // 返回请求的部分
+        let offset_in_block = (offset - block_offset) as usize;
+        if offset_in_block >= data.len() {
             return Err(io::Error::new(
                 io::ErrorKind::UnexpectedEof,
-                format!("fetch_size is 0: offset={}, block_offset={}, file_size={}", offset, block_offset, self.file_size),
+                format!(
+                    "Short block response: got {} bytes at {}, need data at offset {}",
+                    data.len(),
+                    block_offset,
+                    offset
+                ),
             ));
         }
+        // 不跨缓存块：只返回 offset 所在块内的部分
+        let block_end = data.len().min(self.block_size as usize);
+        let read_size = (size as usize).min(block_end - offset_in_block);
+        let result = data[offset_in_block..offset_in_block + read_size].to_vec();
+
+        // 按缓存块拆分写入缓存，首次下载的块计入 touched_bytes
+        let mut touched = 0;
+        {
+            let mut fetched_blocks = self.fetched_blocks.lock().unwrap();
+            for (i, chunk) in data.chunks(self.block_size as usize).enumerate() {
+                let chunk_offset = block_offset + i as u64 * self.block_size;
+                if fetched_blocks.insert(chunk_offset) {
+                    touched += chunk.len() as u64;
+                }
+                self.write_to_cache(chunk_offset, chunk.to_vec());
+            }
+        }
+        {
+            let mut stats = self.stats.lock().unwrap();
+            stats.requested_bytes += read_size as u64;
+            stats.touched_bytes += touched;
+        }
+
+        Ok(result)
+    }
+
+    /// 完成一个请求
+    pub fn complete_request(&self, request_id: u32, data: Result<Vec<u8>, String>) {
+        if let Some(sender) = self.pending_requests.lock().unwrap().remove(&request_id) {
+            let _ = sender.send(data);
+        }
+    }
+}
+
+/// 流式 PDF 读取器
+///
+/// 这个结构体实现了 `Read + Seek` trait，允许 PDFium 按需读取 PDF 数据。
+/// 当 PDFium 需要数据时，它会通过 NAPI-RS 回调到 JavaScript，
+/// JavaScript 使用 HTTP Range 请求获取数据并返回。
+///
+/// 关键技术：使用独立线程 + tokio runtime 来等待 async JS Promise。
+pub struct JsFileStreamer {
+    /// 文件总大小（构造后不再修改）
+    file_size: u64,
+    /// 当前读取位置
+    position: u64,
+    /// 线程安全函数，用于回调 JavaScript
+    fetcher: ThreadsafeFunction<BlockRequest, ErrorStrategy::CalleeHandled>,
+    /// 共享状态
+    state: Arc<SharedState>,
+    /// 等待单个块响应的超时，`None` 表示一直等待（由 JS 端保证每个请求最终完成）
+    fetch_timeout: Option<std::time::Duration>,
+}
+
+impl JsFileStreamer {
+    /// 创建新的流式读取器
+    ///
+    /// `block_size` 需先经过 `validate_block_size` 校验。
+    pub fn new(
+        file_size: u64,
+        fetcher: ThreadsafeFunction<BlockRequest, ErrorStrategy::CalleeHandled>,
+        task_id: u32,
+        block_size: u64,
+    ) -> Self {
+        Self {
+            file_size,
+            position: 0,
+            fetcher,
+            state: Arc::new(SharedState::new(task_id, block_size)),
+            fetch_timeout: None,
+        }
+    }
+
+    /// 设置等待单个块响应的超时
+    ///
+    /// 默认一直等待：JS 端的分片请求按停滞超时中止（慢速但持续有数据的请求不会被中断），
+    /// 这里再用固定超时会在 JS 仍在接收数据时提前放弃。仅在 fetcher 可能永不完成时作为兜底。
+    pub fn with_fetch_timeout(mut self, timeout: Option<std::time::Duration>) -> Self {
+        self.fetch_timeout = timeout;
+        self
+    }
+
+    /// 获取共享状态的引用（用于在 streamer 被 move 后获取统计信息）
+    #[allow(dead_code)]
+    pub fn get_shared_state(&self) -> Arc<SharedState> {
+        Arc::clone(&self.state)
+    }
+
+    /// 获取统计信息
+    #[allow(dead_code)]
+    pub fn get_stats(&self) -> StreamerStats {
+        self.state.stats.lock().unwrap().clone()
+    }
+
+    /// 获取自上次调用以来的增量统计（见 [`SharedState::stats_delta`]）
+    #[allow(dead_code)]
+    pub fn stats_delta(&self) -> StreamerStats {
+        self.state.stats_delta()
+    }
+
+    /// 读取数据（不跨缓存块，可能少于 `size`）
+    ///
+    /// 优先从缓存读取，未命中时从 JavaScript 获取整个缓存块。
+    fn fetch_block(&self, offset: u64, size: u32) -> io::Result<Vec<u8>> {
+        self.state
+            .read_block(self.file_size, offset, size, |block_offset, fetch_size| {
+                self.request_block(block_offset, fetch_size)
+            })
+    }
 
+    /// 从 JavaScript 获取数据块
+    ///
+    /// 这个方法发送请求到 JS，然后阻塞等待响应。
+    /// JS 端需要在获取数据后调用 completeRequest 来发送响应。
+    fn request_block(&self, block_offset: u64, fetch_size: u32) -> io::Result<Vec<u8>> {
         // 创建 channel 用于接收响应
         let (tx, rx) = mpsc::channel::<Result<Vec<u8>, String>>();
 
@@ -260,37 +474,30 @@ impl JsFileStreamer {
             ));
         }
 
-        // 阻塞等待响应（超时 30 秒）
-        let result = rx
-            .recv_timeout(std::time::Duration::from_secs(30))
-            .map_err(|e| {
-                // 移除待处理的请求
-                self.state.pending_requests.lock().unwrap().remove(&request_id);
+        // 阻塞等待响应（设置了 fetch_timeout 时超时返回）
+        let received = match self.fetch_timeout {
+            Some(timeout) => rx.recv_timeout(timeout).map_err(|e| {
                 io::Error::new(
                     io::ErrorKind::TimedOut,
                     format!("Timeout waiting for JS response: {}", e),
                 )
-            })?;
-
-        match result {
-            Ok(data) => {
-                self.state.stats.lock().unwrap().total_bytes_fetched += data.len() as u64;
-
-                // 写入缓存
-                self.write_to_cache(block_offset, data.clone());
-
-                // 返回请求的部分
-                let offset_in_block = (offset - block_offset) as usize;
-                let available = data.len().saturating_sub(offset_in_block);
-                let read_size = (size as usize).min(available);
+            }),
+            None => rx.recv().map_err(|e| {
+                io::Error::new(
+                    io::ErrorKind::Other,
+                    format!("JS response channel closed: {}", e),
+                )
+            }),
+        };
+        let result = received.map_err(|e| {
+            // 移除待处理的请求
+            self.state.pending_requests.lock().unwrap().remove(&request_id);
+            e
+        })?;
 
-                Ok(data[offset_in_block..offset_in_block + read_size].to_vec())
-            }
-            Err(e) => Err(io::Error::new(
-                io::ErrorKind::Other,
-                format!("Failed to fetch block: {}", e),
-            )),
-        }
+        result.map_err(|e| {
+            io::Error::new(io::ErrorKind::Other, format!("Failed to fetch block: {}", e))
+        })
     }
 }
 
@@ -317,22 +524,30 @@ impl Read for JsFileStreamer {
     }
 }
 
+/// 计算 seek 后的新位置
+///
+/// 语义与 `std::fs::File` 一致：
+/// - 允许定位到文件末尾之后，此后的 `read` 返回 `Ok(0)`（EOF），不会发起请求
+/// - `SeekFrom::End` 的正偏移同样定位到末尾之后
+/// - 结果为负或溢出时返回 `InvalidInput`，当前位置保持不变
+fn resolve_seek(pos: SeekFrom, current: u64, file_size: u64) -> io::Result<u64> {
+    let (base, offset) = match pos {
+        SeekFrom::Start(offset) => return Ok(offset),
+        SeekFrom::End(offset) => (file_size, offset),
+        SeekFrom::Current(offset) => (current, offset),
+    };
+
+    base.checked_add_signed(offset).ok_or_else(|| {
+        io::Error::new(
+            io::ErrorKind::InvalidInput,
+            format!("Invalid seek to a negative or overflowing position: {} + {}", base, offset),
+        )
+    })
+}
+
 impl Seek for JsFileStreamer {
     fn seek(&mut self, pos: SeekFrom) -> io::Result<u64> {
-        let new_pos = match pos {
-            SeekFrom::Start(offset) => offset as i64,
-            SeekFrom::End(offset) => self.file_size as i64 + offset,
-            SeekFrom::Current(offset) => self.position as i64 + offset,
-        };
-
-        if new_pos < 0 {
-            return Err(io::Error::new(
-                io::ErrorKind::InvalidInput,
-                "Seek to negative position",
-            ));
-        }
-
-        self.position = new_pos as u64;
+        self.position = resolve_seek(pos, self.position, self.file_size)?;
         Ok(self.position)
     }
 }
@@ -341,17 +556,467 @@ impl Seek for JsFileStreamer {
 mod tests {
     use super::*;
 
+    /// 参与属性测试的块大小
+    const BLOCK_SIZES: [u64; 4] = [16 * 1024, 64 * 1024, CACHE_BLOCK_SIZE, 1024 * 1024];
+
     #[test]
     fn test_cache_block_offset() {
-        assert_eq!(JsFileStreamer::cache_block_offset(0), 0);
-        assert_eq!(JsFileStreamer::cache_block_offset(100), 0);
-        assert_eq!(
-            JsFileStreamer::cache_block_offset(CACHE_BLOCK_SIZE),
-            CACHE_BLOCK_SIZE
-        );
-        assert_eq!(
-            JsFileStreamer::cache_block_offset(CACHE_BLOCK_SIZE + 100),
-            CACHE_BLOCK_SIZE
+        for block_size in BLOCK_SIZES {
+            let state = SharedState::new(0, block_size);
+            assert_eq!(state.cache_block_offset(0), 0);
+            assert_eq!(state.cache_block_offset(100), 0);
+            assert_eq!(state.cache_block_offset(block_size - 1), 0);
+            assert_eq!(state.cache_block_offset(block_size), block_size);
+            assert_eq!(state.cache_block_offset(3 * block_size + 100), 3 * block_size);
+        }
+    }
+
+    #[test]
+    fn test_validate_block_size() {
+        for block_size in BLOCK_SIZES {
+            assert_eq!(validate_block_size(block_size), Ok(block_size));
+        }
+        assert!(validate_block_size(MIN_CACHE_BLOCK_SIZE).is_ok());
+        assert!(validate_block_size(MAX_CACHE_BLOCK_SIZE).is_ok());
+
+        assert!(validate_block_size(0).is_err());
+        assert!(validate_block_size(MIN_CACHE_BLOCK_SIZE / 2).is_err());
+        assert!(validate_block_size(MAX_CACHE_BLOCK_SIZE * 2).is_err());
+        assert!(validate_block_size(100 * 1024).is_err(), "非 2 的幂应该被拒绝");
+    }
+
+    #[test]
+    fn test_seek_past_end() {
+        assert_eq!(resolve_seek(SeekFrom::Start(2000), 0, 1000).unwrap(), 2000);
+        assert_eq!(resolve_seek(SeekFrom::End(10), 0, 1000).unwrap(), 1010);
+        assert_eq!(resolve_seek(SeekFrom::End(0), 0, 1000).unwrap(), 1000);
+        assert_eq!(resolve_seek(SeekFrom::End(-1000), 0, 1000).unwrap(), 0);
+    }
+
+    #[test]
+    fn test_seek_negative_is_rejected() {
+        let err = resolve_seek(SeekFrom::End(-1001), 0, 1000).unwrap_err();
+        assert_eq!(err.kind(), io::ErrorKind::InvalidInput);
+
+        let err = resolve_seek(SeekFrom::Current(-11), 10, 1000).unwrap_err();
+        assert_eq!(err.kind(), io::ErrorKind::InvalidInput);
+    }
+
+    #[test]
+    fn test_seek_from_current() {
+        assert_eq!(resolve_seek(SeekFrom::Current(0), 500, 1000).unwrap(), 500);
+        assert_eq!(resolve_seek(SeekFrom::Current(-500), 500, 1000).unwrap(), 0);
+        assert_eq!(resolve_seek(SeekFrom::Current(600), 500, 1000).unwrap(), 1100);
+
+        let err = resolve_seek(SeekFrom::Current(i64::MAX), u64::MAX, 1000).unwrap_err();
+        assert_eq!(err.kind(), io::ErrorKind::InvalidInput);
+    }
+
+    #[test]
+    fn test_cache_bytes_counted_separately() {
+        let state = SharedState::new(0, CACHE_BLOCK_SIZE);
+        state.write_to_cache(0, vec![7u8; CACHE_BLOCK_SIZE as usize]);
+
+        assert_eq!(state.read_from_cache(100, 1000).unwrap().len(), 1000);
+        assert_eq!(state.read_from_cache(CACHE_BLOCK_SIZE - 24, 1000).unwrap().len(), 24);
+        assert!(state.read_from_cache(CACHE_BLOCK_SIZE, 1000).is_none());
+
+        let stats = state.stats.lock().unwrap();
+        assert_eq!(stats.cache_hits, 2);
+        assert_eq!(stats.cache_bytes, 1024);
+        assert_eq!(stats.total_bytes_fetched, 0);
+    }
+
+    /// 按 `Read` 语义循环读取 [offset, offset + len)，返回读取的数据
+    fn read_range(state: &SharedState, source: &[u8], offset: u64, len: usize, fetches: &mut Vec<u64>) -> Vec<u8> {
+        let file_size = source.len() as u64;
+        let mut out = Vec::new();
+        let mut position = offset;
+        while out.len() < len {
+            let want = (len - out.len()) as u32;
+            let chunk = state
+                .read_block(file_size, position, want, |block_offset, fetch_size| {
+                    fetches.push(block_offset);
+                    let start = block_offset as usize;
+                    Ok(source[start..start + fetch_size as usize].to_vec())
+                })
+                .unwrap();
+            if chunk.is_empty() {
+                break;
+            }
+            position += chunk.len() as u64;
+            out.extend_from_slice(&chunk);
+        }
+        out
+    }
+
+    /// 简单的 xorshift 伪随机数（固定种子，结果可复现）
+    fn xorshift(seed: &mut u64) -> u64 {
+        *seed ^= *seed << 13;
+        *seed ^= *seed >> 7;
+        *seed ^= *seed << 17;
+        *seed
+    }
+
+    #[test]
+    fn test_zero_length_and_eof_reads_do_not_fetch() {
+        let state = SharedState::new(0, CACHE_BLOCK_SIZE);
+        let fail = |_: u64, _: u32| -> io::Result<Vec<u8>> { panic!("should not fetch") };
+
+        assert!(state.read_block(1000, 0, 0, fail).unwrap().is_empty());
+        assert!(state.read_block(1000, 1000, 10, fail).unwrap().is_empty());
+        assert!(state.read_block(1000, 5000, 10, fail).unwrap().is_empty());
+        assert_eq!(state.stats.lock().unwrap().total_requests, 0);
+    }
+
+    #[test]
+    fn test_read_at_block_boundary_fetches_once() {
+        let source: Vec<u8> = (0..3 * CACHE_BLOCK_SIZE as usize).map(|i| (i % 251) as u8).collect();
+        let state = SharedState::new(0, CACHE_BLOCK_SIZE);
+        let mut fetches = Vec::new();
+
+        let data = read_range(&state, &source, CACHE_BLOCK_SIZE, 100, &mut fetches);
+        assert_eq!(data, &source[CACHE_BLOCK_SIZE as usize..CACHE_BLOCK_SIZE as usize + 100]);
+        let data = read_range(&state, &source, CACHE_BLOCK_SIZE + 100, 100, &mut fetches);
+        assert_eq!(data, &source[CACHE_BLOCK_SIZE as usize + 100..CACHE_BLOCK_SIZE as usize + 200]);
+
+        assert_eq!(fetches, vec![CACHE_BLOCK_SIZE]);
+    }
+
+    #[test]
+    fn test_short_block_response_is_an_error() {
+        let state = SharedState::new(0, CACHE_BLOCK_SIZE);
+        let err = state
+            .read_block(1000, 500, 10, |_, _| Ok(vec![0u8; 100]))
+            .unwrap_err();
+        assert_eq!(err.kind(), io::ErrorKind::UnexpectedEof);
+    }
+
+    #[test]
+    fn test_random_reads_match_reference() {
+        for block_size in BLOCK_SIZES {
+            check_random_reads(block_size);
+        }
+    }
+
+    fn check_random_reads(block_size: u64) {
+        // 文件大小不是块大小的整数倍，覆盖末尾不完整块
+        let file_size = 5 * block_size as usize + 12345;
+        let source: Vec<u8> = (0..file_size).map(|i| (i * 31 % 256) as u8).collect();
+        let state = SharedState::new(0, block_size);
+        let mut fetches = Vec::new();
+        let mut seed = 0x9E37_79B9_7F4A_7C15;
+
+        for _ in 0..2000 {
+            // 偏移偏向块边界附近和文件末尾
+            let offset = match xorshift(&mut seed) % 4 {
+                0 => (xorshift(&mut seed) % 7) * block_size,
+                1 => ((xorshift(&mut seed) % 6) * block_size).saturating_sub(xorshift(&mut seed) % 3),
+                2 => file_size as u64 - xorshift(&mut seed) % 4,
+                _ => xorshift(&mut seed) % (file_size as u64 + 10),
+            };
+            let len = match xorshift(&mut seed) % 3 {
+                0 => (xorshift(&mut seed) % 4) as usize,
+                1 => (xorshift(&mut seed) % (3 * block_size)) as usize,
+                _ => (xorshift(&mut seed) % 4096) as usize,
+            };
+
+            let actual = read_range(&state, &source, offset, len, &mut fetches);
+            let start = (offset as usize).min(file_size);
+            let end = (start + len).min(file_size);
+            assert_eq!(
+                actual,
+                &source[start..end],
+                "block_size={}, offset={}, len={}",
+                block_size,
+                offset,
+                len
+            );
+        }
+
+        // 缓存容量足够容纳整个文件，每个块最多获取一次
+        let mut unique = fetches.clone();
+        unique.sort_unstable();
+        unique.dedup();
+        assert_eq!(unique.len(), fetches.len(), "block_size={}", block_size);
+        assert!(fetches.iter().all(|offset| offset % block_size == 0), "请求起点应该按块对齐");
+    }
+
+    #[test]
+    fn test_stats_snapshots_while_fetching() {
+        let blocks = 64;
+        let source: Arc<Vec<u8>> = Arc::new((0..blocks * 16 * 1024).map(|i| (i % 241) as u8).collect());
+        let state = Arc::new(SharedState::new(0, 16 * 1024));
+        let done = Arc::new(std::sync::atomic::AtomicBool::new(false));
+
+        let readers: Vec<_> = (0..2)
+            .map(|_| {
+                let state = Arc::clone(&state);
+                let done = Arc::clone(&done);
+                std::thread::spawn(move || {
+                    let mut last = StreamerStats::default();
+                    loop {
+                        let finished = done.load(std::sync::atomic::Ordering::Acquire);
+                        let current = state.stats.lock().unwrap().clone();
+                        // 一次加锁内更新的计数必须同时可见
+                        assert_eq!(current.total_requests, current.cache_misses);
+                        assert!(current.requested_bytes >= current.cache_bytes);
+                        // 计数只增不减
+                        assert!(current.total_requests >= last.total_requests);
+                        assert!(current.cache_hits >= last.cache_hits);
+                        assert!(current.total_bytes_fetched >= last.total_bytes_fetched);
+                        assert!(current.requested_bytes >= last.requested_bytes);
+                        assert!(current.touched_bytes >= last.touched_bytes);
+                        last = current;
+                        if finished {
+                            break;
+                        }
+                    }
+                })
+            })
+            .collect();
+
+        let writers: Vec<_> = (0..4)
+            .map(|worker| {
+                let state = Arc::clone(&state);
+                let source = Arc::clone(&source);
+                std::thread::spawn(move || {
+                    let mut fetches = Vec::new();
+                    let mut seed = 0x2545_F491_4F6C_DD1D + worker as u64;
+                    for _ in 0..500 {
+                        let offset = xorshift(&mut seed) % source.len() as u64;
+                        let len = (xorshift(&mut seed) % 40_000) as usize;
+                        let data = read_range(&state, &source, offset, len, &mut fetches);
+                        let end = (offset as usize + len).min(source.len());
+                        assert_eq!(data, &source[offset as usize..end]);
+                    }
+                })
+            })
+            .collect();
+
+        for writer in writers {
+            writer.join().unwrap();
+        }
+        done.store(true, std::sync::atomic::Ordering::Release);
+        for reader in readers {
+            reader.join().unwrap();
+        }
+
+        let stats = state.stats.lock().unwrap();
+        assert_eq!(stats.total_requests, stats.cache_misses);
+        assert!(stats.touched_bytes <= source.len() as u64);
+    }
+
+    #[test]
+    fn test_readahead_grows_on_sequential_scan() {
+        let blocks = 32;
+        let source: Vec<u8> = (0..blocks * CACHE_BLOCK_SIZE as usize).map(|i| (i % 253) as u8).collect();
+        let state = SharedState::new(0, CACHE_BLOCK_SIZE);
+        let mut fetches = Vec::new();
+
+        // 顺序扫描整个文件，每次读取 64KB
+        let mut position = 0;
+        while position < source.len() as u64 {
+            let data = read_range(&state, &source, position, 64 * 1024, &mut fetches);
+            assert_eq!(data, &source[position as usize..position as usize + data.len()]);
+            position += data.len() as u64;
+        }
+
+        // 窗口 1, 2, 4, 8, 8, 8, 1（剩余 1 块）
+        let stats = state.stats.lock().unwrap();
+        assert_eq!(stats.total_requests, 7);
+        assert_eq!(stats.total_bytes_fetched, source.len() as u64);
+    }
+
+    #[test]
+    fn test_random_access_does_not_read_ahead() {
+        let blocks = 32u64;
+        let source: Vec<u8> = (0..blocks * CACHE_BLOCK_SIZE).map(|i| (i % 253) as u8).collect();
+        let state = SharedState::new(0, CACHE_BLOCK_SIZE);
+        let mut fetches = Vec::new();
+        let mut seed = 0x2545_F491_4F6C_DD1D;
+
+        // 间隔跳读：相邻两次未命中不连续，不应触发预读
+        let mut touched = Vec::new();
+        for _ in 0..16 {
+            let block = xorshift(&mut seed) % blocks;
+            let offset = block * CACHE_BLOCK_SIZE + xorshift(&mut seed) % 1000;
+            read_range(&state, &source, offset, 100, &mut fetches);
+            touched.push(block);
+        }
+        touched.sort_unstable();
+        touched.dedup();
+
+        let stats = state.stats.lock().unwrap();
+        assert!(
+            stats.total_bytes_fetched <= (touched.len() as u64 + 2) * CACHE_BLOCK_SIZE,
+            "随机访问只应获取被访问的块: {} bytes for {} blocks",
+            stats.total_bytes_fetched,
+            touched.len()
         );
+        assert!(stats.total_requests as usize >= touched.len() - 2);
+    }
+
+    #[test]
+    fn test_readahead_stops_at_cached_block() {
+        let source: Vec<u8> = (0..8 * CACHE_BLOCK_SIZE as usize).map(|i| (i % 251) as u8).collect();
+        let state = SharedState::new(0, CACHE_BLOCK_SIZE);
+        let mut fetches = Vec::new();
+        state.write_to_cache(3 * CACHE_BLOCK_SIZE, source[3 * CACHE_BLOCK_SIZE as usize..4 * CACHE_BLOCK_SIZE as usize].to_vec());
+
+        // 块 0、1 顺序读取后窗口为 2、4，块 1 起预读到已缓存的块 3 之前
+        read_range(&state, &source, 0, CACHE_BLOCK_SIZE as usize, &mut fetches);
+        read_range(&state, &source, CACHE_BLOCK_SIZE, CACHE_BLOCK_SIZE as usize, &mut fetches);
+        assert_eq!(fetches, vec![0, CACHE_BLOCK_SIZE]);
+        assert_eq!(state.stats.lock().unwrap().total_bytes_fetched, 3 * CACHE_BLOCK_SIZE);
+
+        let data = read_range(&state, &source, 2 * CACHE_BLOCK_SIZE, 2 * CACHE_BLOCK_SIZE as usize, &mut fetches);
+        assert_eq!(data, &source[2 * CACHE_BLOCK_SIZE as usize..4 * CACHE_BLOCK_SIZE as usize]);
+        assert_eq!(fetches.len(), 2, "已预读和已缓存的块不应再次请求");
+    }
+
+    #[test]
+    fn test_stats_delta_sums_to_total() {
+        let snapshots = [
+            StreamerStats { total_requests: 3, cache_hits: 5, cache_misses: 3, total_bytes_fetched: 3 * CACHE_BLOCK_SIZE, cache_bytes: 5000, requested_bytes: 8000, touched_bytes: 3 * CACHE_BLOCK_SIZE },
+            StreamerStats { total_requests: 3, cache_hits: 9, cache_misses: 3, total_bytes_fetched: 3 * CACHE_BLOCK_SIZE, cache_bytes: 9000, requested_bytes: 12000, touched_bytes: 3 * CACHE_BLOCK_SIZE },
+            StreamerStats { total_requests: 7, cache_hits: 12, cache_misses: 7, total_bytes_fetched: 7 * CACHE_BLOCK_SIZE - 100, cache_bytes: 12000, requested_bytes: 20000, touched_bytes: 6 * CACHE_BLOCK_SIZE - 100 },
+        ];
+
+        let mut last = StreamerStats::default();
+        let mut sum = StreamerStats::default();
+        for current in &snapshots {
+            let delta = current.delta_since(&last);
+            sum.total_requests += delta.total_requests;
+            sum.cache_hits += delta.cache_hits;
+            sum.cache_misses += delta.cache_misses;
+            sum.total_bytes_fetched += delta.total_bytes_fetched;
+            sum.cache_bytes += delta.cache_bytes;
+            sum.requested_bytes += delta.requested_bytes;
+            sum.touched_bytes += delta.touched_bytes;
+            last = current.clone();
+        }
+
+        let total = snapshots.last().unwrap();
+        assert_eq!(sum.total_requests, total.total_requests);
+        assert_eq!(sum.cache_hits, total.cache_hits);
+        assert_eq!(sum.cache_misses, total.cache_misses);
+        assert_eq!(sum.total_bytes_fetched, total.total_bytes_fetched);
+        assert_eq!(sum.cache_bytes, total.cache_bytes);
+        assert_eq!(sum.requested_bytes, total.requested_bytes);
+        assert_eq!(sum.touched_bytes, total.touched_bytes);
+    }
+
+    #[test]
+    fn test_stats_delta_resets_baseline() {
+        let source: Vec<u8> = (0..16 * CACHE_BLOCK_SIZE).map(|i| (i % 251) as u8).collect();
+        let state = SharedState::new(0, CACHE_BLOCK_SIZE);
+        let mut fetches = Vec::new();
+
+        read_range(&state, &source, 0, 2 * CACHE_BLOCK_SIZE as usize, &mut fetches);
+        let first = state.stats_delta();
+        let after_first = state.stats.lock().unwrap().clone();
+        assert_eq!(first.total_bytes_fetched, after_first.total_bytes_fetched);
+        assert!(first.total_bytes_fetched >= 2 * CACHE_BLOCK_SIZE);
+
+        // 再次读取已缓存的范围和新的范围，增量只应包含这次的读取
+        read_range(&state, &source, 0, 1000, &mut fetches);
+        read_range(&state, &source, 10 * CACHE_BLOCK_SIZE, CACHE_BLOCK_SIZE as usize, &mut fetches);
+        let second = state.stats_delta();
+        let total = state.stats.lock().unwrap().clone();
+        assert_eq!(second.requested_bytes, 1000 + CACHE_BLOCK_SIZE);
+        assert_eq!(second.total_bytes_fetched, total.total_bytes_fetched - after_first.total_bytes_fetched);
+        assert_eq!(second.cache_hits, total.cache_hits - after_first.cache_hits);
+        assert!(second.cache_hits >= 1);
+
+        // 没有新的读取时增量为 0
+        let third = state.stats_delta();
+        assert_eq!(third.requested_bytes, 0);
+        assert_eq!(third.total_bytes_fetched, 0);
+        assert_eq!(third.total_requests, 0);
+    }
+
+    #[test]
+    fn test_stats_delta_while_fetching() {
+        let source: Arc<Vec<u8>> = Arc::new((0..64 * 16 * 1024).map(|i| (i % 241) as u8).collect());
+        let state = Arc::new(SharedState::new(0, 16 * 1024));
+        let done = Arc::new(std::sync::atomic::AtomicBool::new(false));
+
+        // 两个线程并发取增量，所有增量之和应该等于累计统计
+        let pollers: Vec<_> = (0..2)
+            .map(|_| {
+                let state = Arc::clone(&state);
+                let done = Arc::clone(&done);
+                std::thread::spawn(move || {
+                    let mut sum = StreamerStats::default();
+                    while !done.load(std::sync::atomic::Ordering::Acquire) {
+                        let delta = state.stats_delta();
+                        sum.total_requests += delta.total_requests;
+                        sum.total_bytes_fetched += delta.total_bytes_fetched;
+                        sum.requested_bytes += delta.requested_bytes;
+                    }
+                    sum
+                })
+            })
+            .collect();
+
+        let writers: Vec<_> = (0..4)
+            .map(|worker| {
+                let state = Arc::clone(&state);
+                let source = Arc::clone(&source);
+                std::thread::spawn(move || {
+                    let mut fetches = Vec::new();
+                    let mut seed = 0x9E37_79B9_7F4A_7C15 + worker as u64;
+                    for _ in 0..300 {
+                        let offset = xorshift(&mut seed) % source.len() as u64;
+                        let len = (xorshift(&mut seed) % 40_000) as usize;
+                        read_range(&state, &source, offset, len, &mut fetches);
+                    }
+                })
+            })
+            .collect();
+
+        for writer in writers {
+            writer.join().unwrap();
+        }
+        done.store(true, std::sync::atomic::Ordering::Release);
+
+        let mut sum = state.stats_delta();
+        for poller in pollers {
+            let partial = poller.join().unwrap();
+            sum.total_requests += partial.total_requests;
+            sum.total_bytes_fetched += partial.total_bytes_fetched;
+            sum.requested_bytes += partial.requested_bytes;
+        }
+
+        let total = state.stats.lock().unwrap().clone();
+        assert_eq!(sum.total_requests, total.total_requests);
+        assert_eq!(sum.total_bytes_fetched, total.total_bytes_fetched);
+        assert_eq!(sum.requested_bytes, total.requested_bytes);
+    }
+
+    #[test]
+    fn test_stats_are_consistent_with_eviction() {
+        // 文件大于缓存容量，随机读取会淘汰并重新下载部分块
+        let state = SharedState::new(0, CACHE_BLOCK_SIZE);
+        let file_size = (state.max_cache_blocks + 16) * CACHE_BLOCK_SIZE as usize + 777;
+        let source: Vec<u8> = (0..file_size).map(|i| (i % 241) as u8).collect();
+        let mut fetches = Vec::new();
+        let mut seed = 0xDEAD_BEEF_CAFE_F00D;
+        let mut requested = 0u64;
+
+        for _ in 0..3000 {
+            let offset = xorshift(&mut seed) % file_size as u64;
+            let len = (xorshift(&mut seed) % 8192) as usize;
+            requested += read_range(&state, &source, offset, len, &mut fetches).len() as u64;
+        }
+
+        let stats = state.stats.lock().unwrap();
+        assert_eq!(stats.requested_bytes, requested, "requested_bytes 应该等于 PDFium 读到的字节数");
+        assert!(stats.cache_bytes <= stats.requested_bytes);
+        assert!(stats.touched_bytes <= file_size as u64);
+        assert!(stats.touched_bytes <= stats.total_bytes_fetched);
+        assert!(stats.total_bytes_fetched > stats.touched_bytes, "缓存淘汰后应该有重复下载");
+        assert_eq!(stats.cache_misses, stats.total_requests);
     }
 }
@@ -10,25 +10,36 @@
  *   pdf2img document.pdf -o ./output
  *   pdf2img https://example.com/doc.pdf -o ./output
  *   pdf2img document.pdf -p 1,2,3 -o ./output
+ *   pdf2img document.pdf -p -2,-1 -o ./output     # 最后两页
  *   pdf2img document.pdf --quality 90 --width 1920 -o ./output
  *   pdf2img document.pdf --format png -o ./output  # 输出 PNG 格式
  *   pdf2img document.pdf --cos --cos-prefix images/doc  # 上传到 COS
+ *   curl -s https://example.com/doc.pdf | pdf2img - -o ./output  # 从标准输入读取
  *
  * COS 环境变量：
  *   COS_SECRET_ID     - 腾讯云 SecretId
  *   COS_SECRET_KEY    - 腾讯云 SecretKey
  *   COS_BUCKET        - COS 存储桶名称
  *   COS_REGION        - COS 地域（如 ap-guangzhou）
+ *
+ * 退出码：
+ *   0 - 全部页面成功
+ *   1 - 转换失败或全部页面失败
+ *   2 - 部分页面失败
  */
 
 import { program } from 'commander';
 import path from 'path';
 import fs from 'fs';
 import { fileURLToPath } from 'url';
+import { parsePages, ALL_PAGES } from '../src/utils/pages.js';
 
 const __dirname = path.dirname(fileURLToPath(import.meta.url));
 const pkg = JSON.parse(fs.readFileSync(path.join(__dirname, '../package.json'), 'utf8'));
 
+// 部分页面失败时的退出码，脚本可以与全部成功（0）、失败（1）区分
+const EXIT_PARTIAL = 2;
+
 // 动态导入 ora（ESM 模块）
 let ora;
 try {
@@ -36,7 +47,7 @@ try {
 } catch {
     // 如果 ora 不可用，提供一个简单的替代
     ora = (text) => ({
-        start: () => ({ text, succeed: () => {}, fail: () => {}, stop: () => {} }),
+        start: () => ({ text, succeed: () => {}, warn: () => {}, fail: () => {}, stop: () => {} }),
     });
 }
 
@@ -53,15 +64,17 @@ program
     .name('pdf2img')
     .description('高性能 PDF 转图片工具，基于 PDFium')
     .version(pkg.version)
-    .argument('<input>', 'PDF 文件路径或 URL')
+    .argument('<input>', 'PDF 文件路径或 URL，- 表示从标准输入读取')
     .option('-o, --output <dir>', '输出目录（本地模式）', './output')
-    .option('-p, --pages <pages>', '要转换的页码（逗号分隔，如 1,2,3）')
+    .option('-p, --pages <pages>', '要转换的页码（逗号分隔，如 1,2,3；负数从末尾倒数，如 -1 为最后一页；all 表示全部页面）')
     .option('-w, --width <width>', '目标渲染宽度（像素）', '1920')
+    .option('--max-scale <scale>', '最大渲染缩放比例（默认 4，不超过 PDF2IMG_MAX_DPI / 72）')
     .option('-q, --quality <quality>', '图片质量（0-100，用于 webp/jpg）', '100')
-    .option('-f, --format <format>', '输出格式：webp, png, jpg', 'webp')
+    .option('-f, --format <format>', '输出格式：webp, png, jpg, auto（按页面内容选择 PNG 或 WebP）', 'webp')
     .option('--prefix <prefix>', '输出文件名前缀', 'page')
     .option('--info', '仅显示 PDF 信息（页数）')
     .option('--version-info', '显示原生渲染器版本')
+    .option('--temp-dir <dir>', '临时文件目录（优先于环境变量 PDF2IMG_TEMP_DIR）')
     .option('-v, --verbose', '详细输出')
     // COS 相关选项
     .option('--cos', '上传到腾讯云 COS（需配置环境变量）')
@@ -76,8 +89,13 @@ program
             process.env.PDF2IMG_DEBUG = 'true';
         }
 
+        // 配置在主模块加载时读取，需在导入前设置
+        if (options.tempDir) {
+            process.env.PDF2IMG_TEMP_DIR = options.tempDir;
+        }
+
         // 动态导入主模块
-        const { convert, getPageCount, isAvailable, getVersion } = await import('../src/index.js');
+        const { convert, countPages, isAvailable, getVersion } = await import('../src/index.js');
 
         // 显示版本信息
         if (options.versionInfo) {
@@ -95,33 +113,40 @@ program
         }
 
         // 验证格式
-        const supportedFormats = ['webp', 'png', 'jpg', 'jpeg'];
+        const supportedFormats = ['webp', 'png', 'jpg', 'jpeg', 'auto'];
         const format = options.format.toLowerCase();
         if (!supportedFormats.includes(format)) {
-            console.error(`错误：不支持的格式 "${options.format}"。支持的格式：webp, png, jpg`);
+            console.error(`错误：不支持的格式 "${options.format}"。支持的格式：webp, png, jpg, auto`);
             process.exit(1);
         }
 
         // 检查输入
+        const isStdin = input === '-';
         const isUrl = input.startsWith('http://') || input.startsWith('https://');
-        if (!isUrl && !fs.existsSync(input)) {
+        if (!isStdin && !isUrl && !fs.existsSync(input)) {
             console.error(`错误：文件不存在: ${input}`);
             process.exit(1);
         }
 
+        // 标准输入不可 seek，整体读入内存后按 Buffer 渲染
+        const source = isStdin ? await readStream(process.stdin) : input;
+        if (isStdin && source.length === 0) {
+            console.error('错误：标准输入为空');
+            process.exit(1);
+        }
+        const displayName = isStdin ? 'stdin' : isUrl ? input : path.basename(input);
+
         // 仅显示 PDF 信息
         if (options.info) {
-            if (isUrl) {
-                console.error('错误：--info 选项仅支持本地文件');
-                process.exit(1);
-            }
-
             try {
-                const pageCount = getPageCount(input);
-                const stat = fs.statSync(input);
-                console.log(`文件: ${path.basename(input)}`);
-                console.log(`大小: ${(stat.size / 1024 / 1024).toFixed(2)} MB`);
-                console.log(`页数: ${pageCount}`);
+                // URL 输入只下载文档结构数据
+                const info = await countPages(source);
+                console.log(`文件: ${displayName}`);
+                console.log(`大小: ${(info.fileSize / 1024 / 1024).toFixed(2)} MB`);
+                console.log(`页数: ${info.totalPages}`);
+                if (isUrl) {
+                    console.log(`下载: ${(info.bytesDownloaded / 1024).toFixed(1)} KB`);
+                }
             } catch (err) {
                 console.error(`错误: ${err.message}`);
                 process.exit(1);
@@ -129,11 +154,8 @@ program
             return;
         }
 
-        // 解析页码
-        let pages = [];
-        if (options.pages) {
-            pages = options.pages.split(',').map(p => parseInt(p.trim(), 10)).filter(p => !isNaN(p) && p > 0);
-        }
+        // 解析页码（负数从末尾倒数，-1 为最后一页；all 表示全部页面，不受 PDF2IMG_DEFAULT_PAGES 影响）
+        const pages = options.pages === ALL_PAGES ? ALL_PAGES : parsePages(options.pages);
 
         // 确定输出类型和配置
         let outputType = 'file';
@@ -141,6 +163,7 @@ program
             pages,
             prefix: options.prefix,
             targetWidth: parseInt(options.width, 10),
+            maxScale: options.maxScale !== undefined ? parseFloat(options.maxScale) : undefined,
             quality: parseInt(options.quality, 10),
             format: format,
         };
@@ -186,16 +209,22 @@ program
 
         // 开始转换
         const modeText = options.cos ? '转换并上传' : '转换';
-        const spinner = ora(`正在${modeText} ${path.basename(input)}...`).start();
+        const spinner = ora(`正在${modeText} ${isStdin ? displayName : path.basename(input)}...`).start();
 
         try {
             const startTime = Date.now();
 
-            const result = await convert(input, convertOptions);
+            const result = await convert(source, convertOptions);
 
             const duration = Date.now() - startTime;
 
-            spinner.succeed(`${modeText}完成 ${result.renderedPages}/${result.numPages} 页，格式: ${result.format.toUpperCase()}，耗时 ${duration}ms`);
+            if (!result.success) {
+                spinner.fail(`${modeText}失败: 所有 ${result.failedPages} 页均未成功`);
+            } else if (result.partial) {
+                spinner.warn(`${modeText}部分完成 ${result.renderedPages}/${result.numPages} 页（${result.failedPages} 页失败），格式: ${result.format.toUpperCase()}，耗时 ${duration}ms`);
+            } else {
+                spinner.succeed(`${modeText}完成 ${result.renderedPages}/${result.numPages} 页，格式: ${result.format.toUpperCase()}，耗时 ${duration}ms`);
+            }
 
             // 显示结果
             if (options.cos) {
@@ -222,7 +251,17 @@ program
             // 统计
             const totalSize = result.pages.reduce((sum, p) => sum + (p.size || 0), 0);
             console.log(`\n总输出大小: ${formatBytes(totalSize)}`);
-            console.log(`平均每页耗时: ${Math.round(duration / result.renderedPages)}ms`);
+            // 全部失败或全部作为空白页跳过时没有成功渲染的页面
+            if (result.renderedPages > 0) {
+                console.log(`平均每页耗时: ${Math.round(duration / result.renderedPages)}ms`);
+            }
+
+            if (!result.success) {
+                process.exit(1);
+            }
+            if (result.partial) {
+                process.exit(EXIT_PARTIAL);
+            }
 
         } catch (err) {
             spinner.fail(`${modeText}失败: ${err.message}`);
@@ -233,6 +272,20 @@ program
         }
     });
 
+/**
+ * 读取整个流
+ *
+ * @param {import('stream').Readable} stream
+ * @returns {Promise<Buffer>}
+ */
+async function readStream(stream) {
+    const chunks = [];
+    for await (const chunk of stream) {
+        chunks.push(chunk);
+    }
+    return Buffer.concat(chunks);
+}
+
 /**
  * 格式化字节数
  */
@@ -2,6 +2,13 @@
  * PDF2IMG 配置
  */
 
+import os from 'os';
+
+/**
+ * 默认的流式加载缓存块大小（字节）
+ */
+const DEFAULT_STREAM_BLOCK_SIZE = 256 * 1024;
+
 // ==================== 渲染配置 ====================
 export const RENDER_CONFIG = {
     // 目标渲染宽度（像素）
@@ -13,11 +20,44 @@ export const RENDER_CONFIG = {
     // 最大渲染缩放比例
     MAX_RENDER_SCALE: parseFloat(process.env.MAX_RENDER_SCALE) || 4.0,
 
+    // 渲染 DPI 上限：任何入口的缩放比例都不超过 MAX_DPI / 72（包括显式传入的 maxScale 和 exactWidth）
+    MAX_DPI: parseFloat(process.env.PDF2IMG_MAX_DPI) || 600,
+
+    // 渲染宽度上限（像素）：targetWidth、exactWidth、imageHeavyWidth 超过时截断为该值
+    MAX_RENDER_WIDTH: parseInt(process.env.PDF2IMG_MAX_RENDER_WIDTH) || 16383,
+
     // 默认输出格式：webp, png, jpg
     OUTPUT_FORMAT: process.env.OUTPUT_FORMAT || 'webp',
 
     // Native Stream 阈值（字节）- 大于此值使用流式加载
     NATIVE_STREAM_THRESHOLD: parseInt(process.env.NATIVE_STREAM_THRESHOLD) || 5 * 1024 * 1024, // 5MB
+
+    // 流式加载的缓存块大小（字节），必须是 2 的幂且在 16KB-4MB 之间，无效值回退为 256KB
+    // 高延迟的存储适合更大的块（减少请求次数），只渲染少数页面的大文件适合更小的块（减少多余下载）
+    STREAM_BLOCK_SIZE: parseBlockSize(process.env.PDF2IMG_STREAM_BLOCK_SIZE).blockSize,
+
+    // 单次转换最大页数，0 表示不限制
+    MAX_PAGES: parseInt(process.env.PDF2IMG_MAX_PAGES) || 0,
+
+    // 多页 TIFF 最大页数：所有页面的原始位图需要同时驻留内存，0 表示不限制
+    MAX_TIFF_PAGES: parseInt(process.env.PDF2IMG_MAX_TIFF_PAGES, 10) >= 0
+        ? parseInt(process.env.PDF2IMG_MAX_TIFF_PAGES, 10)
+        : 100,
+
+    // 未指定页码时默认渲染的页数（前 N 页），0 表示全部页面；渲染全部页面需要显式传入 pages: 'all'
+    DEFAULT_PAGES: parseInt(process.env.PDF2IMG_DEFAULT_PAGES) || 0,
+
+    // 线程池持续饱和（所有线程都在渲染）超过该时间（毫秒）后，getThreadPoolStats 的 status 报告为 degraded
+    POOL_DEGRADED_AFTER: parseInt(process.env.PDF2IMG_POOL_DEGRADED_AFTER) || 10000,
+
+    // 线程池累计完成该数量的页面任务后回收（下次转换使用新的线程池和 PDFium 实例），0 表示不回收
+    POOL_MAX_TASKS: parseInt(process.env.PDF2IMG_POOL_MAX_TASKS) || 0,
+
+    // 线程池创建超过该时间（毫秒）后回收，0 表示不回收
+    POOL_MAX_AGE: parseInt(process.env.PDF2IMG_POOL_MAX_AGE) || 0,
+
+    // 空白页检测阈值：RGB 各通道像素标准差均不超过该值的页面视为空白页（skipBlankPages）
+    BLANK_PAGE_THRESHOLD: parseFloat(process.env.PDF2IMG_BLANK_PAGE_THRESHOLD) || 3,
 };
 
 // ==================== 编码器配置 ====================
@@ -41,13 +81,189 @@ export const TIMEOUT_CONFIG = {
     // 分片请求超时
     RANGE_REQUEST_TIMEOUT: parseInt(process.env.RANGE_REQUEST_TIMEOUT) || 25000, // 25s
 
-    // 下载超时
+    // 下载总超时（停滞超时之外的额外上限）
     DOWNLOAD_TIMEOUT: parseInt(process.env.DOWNLOAD_TIMEOUT) || 60000, // 60s
+
+    // 连接停滞超时：超过该时间没有收到响应头或任何数据时中止（完整下载和流式加载的分片请求）
+    STALL_TIMEOUT: parseInt(process.env.PDF2IMG_STALL_TIMEOUT) || 30000, // 30s
+
+    // 单页渲染超时，0 表示不限制
+    RENDER_TIMEOUT: parseInt(process.env.RENDER_TIMEOUT) || 0,
+};
+
+// ==================== 临时文件配置 ====================
+export const TEMP_CONFIG = {
+    // 临时文件目录（远程文件完整下载时落盘）
+    DIR: process.env.PDF2IMG_TEMP_DIR || os.tmpdir(),
+
+    // 未在使用且超过该时间（毫秒）未修改的临时文件视为崩溃残留，启动时和定时清理
+    STALE_AFTER: parseInt(process.env.PDF2IMG_TEMP_STALE_AFTER) || 60 * 60 * 1000, // 1h
+
+    // 定时清理间隔（毫秒）
+    SWEEP_INTERVAL: parseInt(process.env.PDF2IMG_TEMP_SWEEP_INTERVAL) || 10 * 60 * 1000, // 10min
+};
+
+// ==================== 网络配置 ====================
+export const NETWORK_CONFIG = {
+    // 进程内同时进行的远程请求上限（分片请求、HEAD），所有转换共享
+    MAX_CONCURRENT_FETCHES: parseInt(process.env.PDF2IMG_MAX_CONCURRENT_FETCHES) || 32,
+
+    // 进程内同时进行的完整下载上限，与分片请求的额度相互独立
+    MAX_CONCURRENT_DOWNLOADS: parseInt(process.env.PDF2IMG_MAX_CONCURRENT_DOWNLOADS) || 8,
+
+    // 完整下载被截断（连接中断、长度与 Content-Length 不符）时的重试次数，0 表示不重试
+    DOWNLOAD_RETRIES: parseInt(process.env.PDF2IMG_DOWNLOAD_RETRIES, 10) >= 0
+        ? parseInt(process.env.PDF2IMG_DOWNLOAD_RETRIES, 10)
+        : 2,
+
+    // 重试退避基准时间（毫秒），第 n 次重试等待 基准 * 2^(n-1)，并加入 ±50% 随机抖动
+    DOWNLOAD_RETRY_DELAY: parseInt(process.env.PDF2IMG_DOWNLOAD_RETRY_DELAY) || 200,
+
+    // 同一主机连续失败（超时、连接错误、5xx）多少次后熔断，0 表示关闭熔断
+    CIRCUIT_BREAKER_THRESHOLD: parseInt(process.env.PDF2IMG_CIRCUIT_BREAKER_THRESHOLD, 10) >= 0
+        ? parseInt(process.env.PDF2IMG_CIRCUIT_BREAKER_THRESHOLD, 10)
+        : 5,
+
+    // 熔断后的冷却时间（毫秒），冷却结束后放行一个探测请求
+    CIRCUIT_BREAKER_COOLDOWN: parseInt(process.env.PDF2IMG_CIRCUIT_BREAKER_COOLDOWN) || 30000,
+
+    // 块大小校准结果按 origin 缓存的时间（毫秒），0 表示不缓存
+    AUTOTUNE_TTL: parseInt(process.env.PDF2IMG_AUTOTUNE_TTL, 10) >= 0
+        ? parseInt(process.env.PDF2IMG_AUTOTUNE_TTL, 10)
+        : 10 * 60 * 1000,
+};
+
+// ==================== 安全配置 ====================
+export const SECURITY_CONFIG = {
+    // 允许访问的远程主机（逗号分隔，支持 *.example.com 通配），为空表示不限制
+    ALLOWED_HOSTS: (process.env.PDF2IMG_ALLOWED_HOSTS || '')
+        .split(',')
+        .map(host => host.trim())
+        .filter(Boolean),
+
+    // 是否禁止访问内网、回环、链路本地地址（防止 SSRF，服务端场景建议开启）
+    BLOCK_PRIVATE_NETWORK: process.env.PDF2IMG_BLOCK_PRIVATE_NETWORK === 'true',
+
+    // 远程文件大小上限（字节），HEAD 返回的 Content-Length 超过时在下载前拒绝，0 表示不限制
+    MAX_FILE_SIZE: parseInt(process.env.PDF2IMG_MAX_FILE_SIZE) || 0,
+
+    // zip:// 输入中单个条目解压后的大小上限（字节），与 MAX_FILE_SIZE 同时生效，0 表示不限制
+    MAX_ZIP_ENTRY_SIZE: parseInt(process.env.PDF2IMG_MAX_ZIP_ENTRY_SIZE, 10) >= 0
+        ? parseInt(process.env.PDF2IMG_MAX_ZIP_ENTRY_SIZE, 10)
+        : 512 * 1024 * 1024,
+
+    // 最大重定向次数，0 表示不跟随重定向
+    MAX_REDIRECTS: parseInt(process.env.PDF2IMG_MAX_REDIRECTS, 10) >= 0
+        ? parseInt(process.env.PDF2IMG_MAX_REDIRECTS, 10)
+        : 10,
 };
 
 // ==================== 支持的输出格式 ====================
 export const SUPPORTED_FORMATS = ['webp', 'png', 'jpg', 'jpeg'];
 
+// ==================== 多页 TIFF 支持的压缩方式 ====================
+export const TIFF_COMPRESSIONS = ['none', 'lzw', 'deflate', 'packbits', 'jpeg', 'ccittfax4'];
+
+// ==================== 水印位置 ====================
+// center: 居中（文字默认斜向）；tile: 斜向平铺整页；其余为四角
+export const WATERMARK_POSITIONS = ['center', 'tile', 'top-left', 'top-right', 'bottom-left', 'bottom-right'];
+
+// ==================== 编码前的图像后处理 ====================
+// sharpen: 轻度锐化；autocontrast: 拉伸对比度；denoise: 3x3 中值滤波去噪
+export const POST_PROCESS_FILTERS = ['sharpen', 'autocontrast', 'denoise'];
+
+/**
+ * 解析工作线程数配置
+ *
+ * 每个工作线程持有独立的 PDFium 实例，线程数即可同时渲染的页面数。
+ * 未设置、非正整数或超过 CPU 核心数 4 倍时回退为 CPU 核心数。
+ *
+ * @param {string} [value] - 配置值（PDF2IMG_THREAD_COUNT）
+ * @param {number} cpuCount - CPU 核心数
+ * @returns {{ threadCount: number, valid: boolean }} valid 为 false 表示配置值无效（未设置时为 true）
+ */
+export function parseThreadCount(value, cpuCount) {
+    if (value === undefined || value === '') {
+        return { threadCount: cpuCount, valid: true };
+    }
+    const parsed = Number(value);
+    if (!Number.isInteger(parsed) || parsed < 1 || parsed > cpuCount * 4) {
+        return { threadCount: cpuCount, valid: false };
+    }
+    return { threadCount: parsed, valid: true };
+}
+
+/**
+ * 是否为合法的缓存块大小：2 的幂且在 16KB-4MB 之间
+ *
+ * @param {*} value - 块大小（字节）
+ * @returns {boolean}
+ */
+export function isValidBlockSize(value) {
+    return Number.isInteger(value) && value >= 16 * 1024 && value <= 4 * 1024 * 1024 && (value & (value - 1)) === 0;
+}
+
+/**
+ * 解析缓存块大小配置
+ *
+ * 未设置或无效时回退为 256KB。原生渲染器会拒绝不合法的块大小，
+ * 因此无效的环境变量必须在加载时回退，而不是让每次按需加载都失败。
+ *
+ * @param {string} [value] - 配置值（PDF2IMG_STREAM_BLOCK_SIZE）
+ * @returns {{ blockSize: number, valid: boolean }} valid 为 false 表示配置值无效（未设置时为 true）
+ */
+export function parseBlockSize(value) {
+    if (value === undefined || value === '') {
+        return { blockSize: DEFAULT_STREAM_BLOCK_SIZE, valid: true };
+    }
+    const parsed = Number(value);
+    if (!isValidBlockSize(parsed)) {
+        return { blockSize: DEFAULT_STREAM_BLOCK_SIZE, valid: false };
+    }
+    return { blockSize: parsed, valid: true };
+}
+
+/**
+ * 校验并规范化渲染尺寸参数
+ *
+ * 所有渲染入口（convert、pageOptions、renderMultiPageTiff、CLI 等）都经过这里，
+ * 保证 DPI 与宽度上限无法被绕过：非法值抛出异常，超出上限的值截断到上限。
+ * 返回的 maxScale 总是有值：exactWidth 不受 maxScale 限制，但仍不超过 DPI 上限。
+ *
+ * @param {Object} [options] - 渲染选项（targetWidth、exactWidth、imageHeavyWidth、maxScale，其余字段原样保留）
+ * @param {Object} [limits] - 上限
+ * @param {number} [limits.maxDpi] - DPI 上限（默认取 PDF2IMG_MAX_DPI）
+ * @param {number} [limits.maxWidth] - 宽度上限（像素，默认取 PDF2IMG_MAX_RENDER_WIDTH）
+ * @returns {Object} 规范化后的渲染选项
+ * @throws {Error} 宽度不是正整数或 maxScale 不是正数时抛出
+ */
+export function normalizeRenderOptions(options = {}, limits = {}) {
+    const { maxDpi = RENDER_CONFIG.MAX_DPI, maxWidth = RENDER_CONFIG.MAX_RENDER_WIDTH } = limits;
+    const scaleLimit = maxDpi / 72;
+    const normalized = { ...options };
+
+    for (const name of ['targetWidth', 'exactWidth', 'imageHeavyWidth']) {
+        const value = options[name];
+        if (value === undefined) {
+            continue;
+        }
+        if (!Number.isInteger(value) || value <= 0) {
+            throw new Error(`Invalid ${name}: ${value}. Must be a positive integer`);
+        }
+        normalized[name] = Math.min(value, maxWidth);
+    }
+
+    const { maxScale } = options;
+    if (maxScale !== undefined && !(typeof maxScale === 'number' && maxScale > 0)) {
+        throw new Error(`Invalid maxScale: ${maxScale}. Must be a positive number`);
+    }
+    normalized.maxScale = options.exactWidth
+        ? scaleLimit
+        : Math.min(maxScale ?? RENDER_CONFIG.MAX_RENDER_SCALE, scaleLimit);
+
+    return normalized;
+}
+
 /**
  * 合并用户配置与默认配置
  * @param {Object} userConfig - 用户配置
@@ -55,12 +271,14 @@ export const SUPPORTED_FORMATS = ['webp', 'png', 'jpg', 'jpeg'];
  */
 export function mergeConfig(userConfig = {}) {
     const format = userConfig.format ?? RENDER_CONFIG.OUTPUT_FORMAT;
+    // exactWidth：每页输出宽度恰好等于该值，不受 maxScale 限制（仍受 DPI 上限约束），也不做扫描件降级
+    const { exactWidth, targetWidth, imageHeavyWidth, maxScale } = normalizeRenderOptions(userConfig);
     
     return {
-        targetWidth: userConfig.targetWidth ?? RENDER_CONFIG.TARGET_RENDER_WIDTH,
-        imageHeavyWidth: userConfig.imageHeavyWidth ?? RENDER_CONFIG.IMAGE_HEAVY_TARGET_WIDTH,
-        maxScale: userConfig.maxScale ?? RENDER_CONFIG.MAX_RENDER_SCALE,
-        detectScan: userConfig.detectScan ?? true,
+        targetWidth: exactWidth ?? targetWidth ?? RENDER_CONFIG.TARGET_RENDER_WIDTH,
+        imageHeavyWidth: imageHeavyWidth ?? RENDER_CONFIG.IMAGE_HEAVY_TARGET_WIDTH,
+        maxScale,
+        detectScan: exactWidth ? false : (userConfig.detectScan ?? true),
         format,
         
         // WebP 编码配置
@@ -72,6 +290,9 @@ export function mergeConfig(userConfig = {}) {
         
         // PNG 编码配置
         pngCompression: userConfig.png?.compressionLevel ?? ENCODER_CONFIG.PNG_COMPRESSION,
+
+        // 流式加载的缓存块大小
+        blockSize: userConfig.blockSize ?? RENDER_CONFIG.STREAM_BLOCK_SIZE,
     };
 }
 
@@ -18,11 +18,29 @@ import fs from 'fs';
 import path from 'path';
 import os from 'os';
 import { pipeline } from 'stream/promises';
+import { setTimeout as sleep } from 'timers/promises';
 import { fileURLToPath } from 'url';
+import { MessageChannel } from 'worker_threads';
 import pLimit from 'p-limit';
 import Piscina from 'piscina';
+import sharp from 'sharp';
 import { createLogger } from '../utils/logger.js';
-import { RENDER_CONFIG, TIMEOUT_CONFIG, SUPPORTED_FORMATS, getExtension, getMimeType } from './config.js';
+import { RENDER_CONFIG, ENCODER_CONFIG, TIMEOUT_CONFIG, NETWORK_CONFIG, SECURITY_CONFIG, TEMP_CONFIG, SUPPORTED_FORMATS, TIFF_COMPRESSIONS, POST_PROCESS_FILTERS, WATERMARK_POSITIONS, getExtension, getMimeType, parseThreadCount, parseBlockSize, isValidBlockSize, normalizeRenderOptions } from './config.js';
+import { fetchWithPolicy, pickNetworkPolicy, stallSignal, timeoutSignal } from '../utils/http.js';
+import { limitFetch, limitDownload } from '../utils/limiter.js';
+import { resolvePages, applyDefaultPages, assertHasPages, assertPagesInRange, assertPageLimit, orderByRequest, expandToRequest, outOfRangePages } from '../utils/pages.js';
+import { isLinearized, LINEARIZATION_PROBE_SIZE } from '../utils/pdf.js';
+import { withSpan, collectTraceHeaders, SPAN_STATUS_ERROR } from '../utils/tracing.js';
+import { cacheKey, hashBuffer } from '../utils/render-cache.js';
+import { efficiencyReport } from '../utils/stream-stats.js';
+import { calibrate } from '../utils/autotune.js';
+import { RenderRate, estimateRenderMs, buildCalibrationPdf } from '../utils/render-estimate.js';
+import { PoolMonitor } from '../utils/pool-monitor.js';
+import { createTempStore } from '../utils/temp-store.js';
+import { buildManifest, MANIFEST_NAME } from '../utils/manifest.js';
+import { encodeImage } from '../utils/encode.js';
+import { packShelves } from '../utils/sprite.js';
+import { isZipUrl, readZipEntry } from '../utils/zip.js';
 import * as nativeRenderer from '../renderers/native.js';
 
 const logger = createLogger('Converter');
@@ -36,31 +54,139 @@ const workerPath = path.resolve(__dirname, '../worker.js');
 
 // 创建全局线程池实例
 // 线程数默认为 CPU 核心数，可通过环境变量调整
-const threadCount = parseInt(process.env.PDF2IMG_THREAD_COUNT, 10) || os.cpus().length;
+const { threadCount, valid: threadCountValid } = parseThreadCount(process.env.PDF2IMG_THREAD_COUNT, os.cpus().length);
+if (!threadCountValid) {
+    logger.warn(`Invalid PDF2IMG_THREAD_COUNT "${process.env.PDF2IMG_THREAD_COUNT}", falling back to ${threadCount} (CPU count)`);
+}
+if (!parseBlockSize(process.env.PDF2IMG_STREAM_BLOCK_SIZE).valid) {
+    logger.warn(`Invalid PDF2IMG_STREAM_BLOCK_SIZE "${process.env.PDF2IMG_STREAM_BLOCK_SIZE}", `
+        + `falling back to ${RENDER_CONFIG.STREAM_BLOCK_SIZE} (must be a power of two between 16384 and 4194304)`);
+}
 
 let piscina = null;
+// 当前线程池的创建时间与累计回收次数
+let piscinaCreatedAt = 0;
+let recycledPools = 0;
+// 线程池 -> 正在使用它的转换数；回收后的旧线程池在最后一个使用者释放时关闭
+const poolUsers = new Map();
+
+// 线程池饱和度监控（跨线程池重建保留）
+const poolMonitor = new PoolMonitor({ maxThreads: threadCount, degradedAfter: RENDER_CONFIG.POOL_DEGRADED_AFTER });
+
+// 临时文件（首次下载时创建目录并清理上次崩溃残留的文件）
+const tempStore = createTempStore({
+    dir: TEMP_CONFIG.DIR,
+    staleAfter: TEMP_CONFIG.STALE_AFTER,
+    sweepInterval: TEMP_CONFIG.SWEEP_INTERVAL,
+});
+
+/**
+ * 校准失败时使用的每百万像素渲染耗时（毫秒）
+ */
+const DEFAULT_MS_PER_MEGAPIXEL = 50;
+
+/**
+ * 在线程池中渲染一个合成页面，测量每百万像素的渲染耗时
+ *
+ * 与普通页面一样在工作线程中渲染（不编码），不阻塞主线程的事件循环。
+ */
+async function measureRenderRate() {
+    try {
+        const pool = acquireThreadPool();
+        const result = await runPageTask(pool, {
+            pdfBuffer: buildCalibrationPdf(),
+            pageNum: 1,
+            options: { format: 'raw', targetWidth: 2048, detectScan: false },
+        }).finally(() => releaseThreadPool(pool));
+        if (result.success && result.renderTime > 0 && result.width > 0 && result.height > 0) {
+            return result.renderTime / (result.width * result.height / 1e6);
+        }
+    } catch (err) {
+        logger.warn(`Render rate calibration failed: ${err.message}`);
+    }
+    return DEFAULT_MS_PER_MEGAPIXEL;
+}
+
+// 每百万像素渲染耗时（用于 countPages 的 estimatedRenderMs），首次估算时在线程池中校准，此后由实际渲染结果更新
+const renderRate = new RenderRate(measureRenderRate);
+
+/**
+ * 当前线程池是否达到回收条件（PDF2IMG_POOL_MAX_TASKS / PDF2IMG_POOL_MAX_AGE）
+ */
+function shouldRecyclePool() {
+    const { POOL_MAX_TASKS, POOL_MAX_AGE } = RENDER_CONFIG;
+    return (POOL_MAX_TASKS > 0 && piscina.completed >= POOL_MAX_TASKS)
+        || (POOL_MAX_AGE > 0 && Date.now() - piscinaCreatedAt >= POOL_MAX_AGE);
+}
+
+/**
+ * 关闭回收后的旧线程池：已提交的页面继续渲染完成，之后工作线程退出
+ */
+function closeRetiredPool(pool) {
+    pool.close().catch(err => logger.warn(`Failed to close recycled thread pool: ${err.message}`));
+}
 
 /**
- * 获取或创建线程池实例（懒加载）
+ * 获取线程池并登记为使用者（懒加载），用完后必须调用 releaseThreadPool
+ *
+ * 长时间运行的 PDFium 实例可能累积内存，达到回收条件时换用新的线程池，新的转换立即使用新线程池。
+ * 旧线程池在所有使用者释放后才关闭：逐步提交页面的转换（signal、maxPagesInFlight）可以继续
+ * 在旧线程池中提交剩余页面。回收期间新旧线程池同时存在，旧线程池只渲染这些转换剩余的页面，
+ * 空闲线程按 idleTimeout 退出。
+ *
+ * @returns {Piscina}
  */
-function getThreadPool() {
+function acquireThreadPool() {
+    if (piscina && shouldRecyclePool()) {
+        const retired = piscina;
+        piscina = null;
+        recycledPools++;
+        logger.info(`Recycling thread pool after ${retired.completed} tasks`);
+        if (!poolUsers.has(retired)) {
+            closeRetiredPool(retired);
+        }
+    }
     if (!piscina) {
         piscina = new Piscina({
             filename: workerPath,
             maxThreads: threadCount,
             idleTimeout: 30000, // 空闲 30 秒后销毁线程
         });
+        piscinaCreatedAt = Date.now();
         logger.info(`Thread pool initialized with ${threadCount} workers`);
     }
+    poolUsers.set(piscina, (poolUsers.get(piscina) ?? 0) + 1);
     return piscina;
 }
 
+/**
+ * 释放 acquireThreadPool 获取的线程池；已回收的线程池在最后一个使用者释放时关闭
+ *
+ * @param {Piscina} pool
+ */
+function releaseThreadPool(pool) {
+    const users = poolUsers.get(pool);
+    // 线程池已被 destroyThreadPool 销毁
+    if (users === undefined) {
+        return;
+    }
+    if (users > 1) {
+        poolUsers.set(pool, users - 1);
+        return;
+    }
+    poolUsers.delete(pool);
+    if (pool !== piscina) {
+        closeRetiredPool(pool);
+    }
+}
+
 /**
  * 默认并发限制
  */
 const DEFAULT_CONCURRENCY = {
     FILE_IO: 10,      // 文件写入并发数
     COS_UPLOAD: 8,    // COS 上传并发数
+    BATCH: 4,         // 批量转换时同时处理的文档数
 };
 
 /**
@@ -98,13 +224,19 @@ function detectInputType(input) {
 }
 
 /**
- * 从 URL 获取文件大小
+ * 从 URL 获取文件大小和版本标识
+ *
+ * @param {string} url - PDF URL
+ * @param {Object} [network] - 远程访问策略，maxFileSize 为文件大小上限（默认取 PDF2IMG_MAX_FILE_SIZE）
+ * @param {AbortSignal} [signal] - 取消信号，触发后立即以 AbortError 结束，不必等待超时
+ * @returns {Promise<{ size: number, etag: string|null }>} etag 取 ETag，没有时取 Last-Modified
+ * @throws {Error} 文件超过大小上限时 code 为 ERR_FILE_TOO_LARGE
  */
-async function getRemoteFileSize(url) {
-    const response = await fetch(url, {
+async function getRemoteFileInfo(url, network = {}, signal) {
+    const response = await limitFetch(() => fetchWithPolicy(url, {
         method: 'HEAD',
-        signal: AbortSignal.timeout(TIMEOUT_CONFIG.DOWNLOAD_TIMEOUT),
-    });
+        signal: timeoutSignal(TIMEOUT_CONFIG.STALL_TIMEOUT, signal),
+    }, network));
 
     if (!response.ok) {
         throw new Error(`Failed to get file size: ${response.status} ${response.statusText}`);
@@ -115,34 +247,156 @@ async function getRemoteFileSize(url) {
         throw new Error('Server did not return Content-Length header');
     }
 
-    return parseInt(contentLength, 10);
+    const size = parseInt(contentLength, 10);
+    const { maxFileSize = SECURITY_CONFIG.MAX_FILE_SIZE } = network;
+    if (maxFileSize > 0 && size > maxFileSize) {
+        const err = new Error(`File too large: ${size} bytes exceeds the limit of ${maxFileSize} bytes`);
+        err.code = 'ERR_FILE_TOO_LARGE';
+        err.size = size;
+        throw err;
+    }
+
+    return {
+        size,
+        etag: response.headers.get('etag') || response.headers.get('last-modified'),
+    };
 }
 
+/**
+ * 下载不完整（可重试）错误码
+ */
+const ERR_DOWNLOAD_TRUNCATED = 'ERR_DOWNLOAD_TRUNCATED';
+
 /**
  * 流式下载远程文件到临时文件
+ *
+ * 连接中途断开或下载长度与预期不符时抛出 err.code 为 'ERR_DOWNLOAD_TRUNCATED' 的错误，
+ * 与服务器错误状态、文件本身损坏区分开，由调用方决定是否重试。
+ * 实际收到的数据超过 network.maxFileSize 时立即中止并抛出 code 为 'ERR_FILE_TOO_LARGE' 的错误。
+ * 取消时立即中止连接、删除已写入的部分文件，并以 signal.reason 结束（不会被当作下载不完整重试）。
+ *
+ * @param {string} url - PDF URL
+ * @param {Object} [network] - 远程访问策略
+ * @param {number} [expectedSize] - 预期文件大小（HEAD 返回的 Content-Length）
+ * @param {Object} [control]
+ * @param {AbortSignal} [control.signal] - 取消信号，排队等待下载额度时同样生效
+ * @param {Function} [control.onProgress] - 每收到一个数据块调用，参数为 { bytes, total }
+ * @returns {Promise<string>} 临时文件路径
  */
-async function downloadToTempFile(url) {
-    const response = await fetch(url, {
-        signal: AbortSignal.timeout(TIMEOUT_CONFIG.DOWNLOAD_TIMEOUT),
-    });
+async function downloadToTempFile(url, network = {}, expectedSize, { signal, onProgress } = {}) {
+    return limitDownload(async () => {
+        // 总超时限制整个下载；停滞超时额外覆盖连接、响应头和每个数据块之间的间隔，尽早发现卡死的连接
+        const { DOWNLOAD_TIMEOUT, STALL_TIMEOUT } = TIMEOUT_CONFIG;
+        const deadline = DOWNLOAD_TIMEOUT > 0 ? AbortSignal.timeout(DOWNLOAD_TIMEOUT) : undefined;
+        const limits = [signal, deadline].filter(Boolean);
+        const stall = stallSignal(STALL_TIMEOUT, limits.length > 0 ? AbortSignal.any(limits) : undefined);
+        // 边下载边计数：超过预期大小或文件大小上限时立即中止，不把多余的数据写入磁盘
+        // （HEAD 返回的长度可能与实际响应不符，也可能没有 Content-Length 校验）
+        const { maxFileSize = SECURITY_CONFIG.MAX_FILE_SIZE } = network;
+        let bytes = 0;
+        let overflow = null;
+        const count = async function* (source) {
+            for await (const chunk of source) {
+                bytes += chunk.length;
+                if (maxFileSize > 0 && bytes > maxFileSize) {
+                    overflow = Object.assign(new Error(`File too large: received more than ${maxFileSize} bytes`), {
+                        code: 'ERR_FILE_TOO_LARGE',
+                        size: bytes,
+                    });
+                    throw overflow;
+                }
+                if (expectedSize && bytes > expectedSize) {
+                    overflow = Object.assign(new Error(`Download exceeds expected size: received more than ${expectedSize} bytes`), {
+                        code: ERR_DOWNLOAD_TRUNCATED,
+                    });
+                    throw overflow;
+                }
+                if (onProgress) {
+                    try {
+                        onProgress({ bytes, total: expectedSize ?? null });
+                    } catch (err) {
+                        logger.warn(`onDownloadProgress callback failed: ${err.message}`);
+                    }
+                }
+                yield chunk;
+            }
+        };
 
-    if (!response.ok) {
-        throw new Error(`Failed to download file: ${response.status} ${response.statusText}`);
-    }
+        try {
+            const response = await fetchWithPolicy(url, { signal: stall.signal }, network);
+            stall.touch();
 
-    const tempDir = os.tmpdir();
-    const tempFile = path.join(tempDir, `pdf2img_${Date.now()}_${Math.random().toString(36).slice(2)}.pdf`);
-    
-    const fileStream = fs.createWriteStream(tempFile);
-    
-    try {
-        await pipeline(response.body, fileStream);
-        return tempFile;
-    } catch (err) {
+            if (!response.ok) {
+                await response.body?.cancel();
+                throw new Error(`Failed to download file: ${response.status} ${response.statusText}`);
+            }
+
+            const tempFile = await tempStore.create('.pdf');
+
+            const fileStream = fs.createWriteStream(tempFile);
+
+            try {
+                try {
+                    await pipeline(response.body, stall.pipe, count, fileStream);
+                } catch (err) {
+                    // 取消和总超时不属于传输中断，原样抛出（TimeoutError），不再重试
+                    signal?.throwIfAborted();
+                    deadline?.throwIfAborted();
+                    if (overflow) {
+                        throw overflow;
+                    }
+                    // 响应体读取中断（连接重置、服务器提前关闭、连接停滞）
+                    throw Object.assign(new Error(`Download interrupted: ${err.message}`), {
+                        code: ERR_DOWNLOAD_TRUNCATED,
+                        cause: err,
+                    });
+                }
+
+                const { size } = await fs.promises.stat(tempFile);
+                if (expectedSize && size !== expectedSize) {
+                    throw Object.assign(new Error(`Download incomplete: received ${size} of ${expectedSize} bytes`), {
+                        code: ERR_DOWNLOAD_TRUNCATED,
+                    });
+                }
+                return tempFile;
+            } catch (err) {
+                await tempStore.release(tempFile);
+                throw err;
+            }
+        } finally {
+            stall.clear();
+        }
+    }, signal);
+}
+
+/**
+ * 下载远程文件，下载不完整时按指数退避（带随机抖动）重试
+ *
+ * 只重试 ERR_DOWNLOAD_TRUNCATED：服务器错误状态、访问策略拒绝、总超时、完整下载后仍无法打开的损坏文件都不重试。
+ *
+ * @param {string} url - PDF URL
+ * @param {Object} network - 远程访问策略
+ * @param {number} expectedSize - 预期文件大小
+ * @param {Object} [control] - 同 downloadToTempFile，取消后不再重试
+ * @returns {Promise<string>} 临时文件路径
+ */
+async function downloadWithRetry(url, network, expectedSize, control = {}) {
+    const { signal } = control;
+    const retries = NETWORK_CONFIG.DOWNLOAD_RETRIES;
+
+    for (let attempt = 0; ; attempt++) {
         try {
-            await fs.promises.unlink(tempFile);
-        } catch {}
-        throw err;
+            return await downloadToTempFile(url, network, expectedSize, control);
+        } catch (err) {
+            if (err.code !== ERR_DOWNLOAD_TRUNCATED || attempt >= retries) {
+                throw err;
+            }
+            signal?.throwIfAborted();
+
+            const delay = NETWORK_CONFIG.DOWNLOAD_RETRY_DELAY * 2 ** attempt * (0.5 + Math.random());
+            logger.warn(`${err.message}, retrying in ${Math.round(delay)}ms (${attempt + 1}/${retries})`);
+            await sleep(delay, undefined, { signal });
+        }
     }
 }
 
@@ -164,8 +418,12 @@ async function savePageToFile(page, outputDir, prefix, ext) {
             width: page.width,
             height: page.height,
             success: true,
+            format: page.format,
             outputPath,
             size: page.buffer.length,
+            avgColor: page.avgColor,
+            placeholder: page.placeholder,
+            effectiveOptions: page.effectiveOptions,
         };
     } catch (err) {
         return {
@@ -185,14 +443,16 @@ async function savePageToFile(page, outputDir, prefix, ext) {
 async function saveToFiles(pages, outputDir, prefix = 'page', format = 'webp', concurrency = DEFAULT_CONCURRENCY.FILE_IO) {
     await fs.promises.mkdir(outputDir, { recursive: true });
 
-    const ext = getExtension(format);
     const limit = pLimit(concurrency);
 
+    // 按页覆盖格式时，扩展名以页面实际格式为准
     const results = await Promise.all(
-        pages.map(page => limit(() => savePageToFile(page, outputDir, prefix, ext)))
+        pages.map(page => limit(() =>
+            savePageToFile(page, outputDir, prefix, getExtension(page.format ?? format))
+        ))
     );
 
-    return results.sort((a, b) => a.pageNum - b.pageNum);
+    return results;
 }
 
 /**
@@ -224,8 +484,12 @@ async function uploadPageToCos(page, cos, cosConfig, keyPrefix, ext, mimeType) {
             width: page.width,
             height: page.height,
             success: true,
+            format: page.format,
             cosKey: key,
             size: page.buffer.length,
+            avgColor: page.avgColor,
+            placeholder: page.placeholder,
+            effectiveOptions: page.effectiveOptions,
         };
     } catch (err) {
         return {
@@ -250,249 +514,1771 @@ async function uploadToCos(pages, cosConfig, keyPrefix, format = 'webp', concurr
         SecretKey: cosConfig.secretKey,
     });
 
-    const ext = getExtension(format);
-    const mimeType = getMimeType(format);
     const limit = pLimit(concurrency);
 
+    // 按页覆盖格式时，扩展名和 Content-Type 以页面实际格式为准
     const results = await Promise.all(
-        pages.map(page => limit(() => uploadPageToCos(page, cos, cosConfig, keyPrefix, ext, mimeType)))
+        pages.map(page => {
+            const pageFormat = page.format ?? format;
+            return limit(() => uploadPageToCos(
+                page, cos, cosConfig, keyPrefix, getExtension(pageFormat), getMimeType(pageFormat)
+            ));
+        })
     );
 
-    return results.sort((a, b) => a.pageNum - b.pageNum);
+    return results;
 }
 
 /**
- * 使用线程池渲染 PDF 页面
- * 
- * 主线程负责协调，工作线程负责 CPU 密集型任务
- * 
- * @param {string|Buffer} input - 输入
- * @param {string} inputType - 输入类型
- * @param {number[]} pages - 页码数组
- * @param {Object} options - 选项
- * @returns {Promise<Object>} 渲染结果
+ * 提交单页任务到线程池，可选超时
+ *
+ * 超时后 Piscina 会终止执行该任务的工作线程并补充新线程，页面记为失败。
+ * 注意：PDFium 渲染是同步的原生调用，无法被中途打断，
+ * 被放弃的线程会在当前原生调用返回后才真正退出并释放内存。
+ * 任务从提交到完成（包括排队）计入线程池饱和度监控。
+ *
+ * @param {Piscina} pool - 线程池
+ * @param {Object} task - 页面任务
+ * @param {number} [timeout] - 超时时间（毫秒），从工作线程开始处理该页时计时（排队时间不计入），0 表示不限制
+ * @returns {Promise<Object>} 页面结果
  */
-async function renderPages(input, inputType, pages, options) {
-    const startTime = Date.now();
-    let filePath = null;
-    let pdfBuffer = null;
-    let tempFile = null;
-    let numPages;
-
-    // 准备输入
-    if (inputType === InputType.FILE) {
-        try {
-            await fs.promises.access(input, fs.constants.R_OK);
-        } catch {
-            throw new Error(`File not found or not readable: ${input}`);
-        }
-        filePath = input;
-        numPages = nativeRenderer.getPageCountFromFile(filePath);
-    } else if (inputType === InputType.BUFFER) {
-        pdfBuffer = Buffer.isBuffer(input) ? input : Buffer.from(input);
-        numPages = nativeRenderer.getPageCount(pdfBuffer);
-    } else if (inputType === InputType.URL) {
-        const fileSize = await getRemoteFileSize(input);
-        logger.debug(`Remote file size: ${(fileSize / 1024 / 1024).toFixed(2)}MB, downloading...`);
-        tempFile = await downloadToTempFile(input);
-        filePath = tempFile;
-        numPages = nativeRenderer.getPageCountFromFile(filePath);
+async function runPageTask(pool, task, timeout) {
+    poolMonitor.start();
+    try {
+        return await runWithTimeout(pool, task, timeout);
+    } finally {
+        poolMonitor.finish();
     }
+}
 
-    // 确定目标页码
-    let targetPages;
-    if (pages.length === 0) {
-        targetPages = Array.from({ length: numPages }, (_, i) => i + 1);
-    } else {
-        targetPages = pages.filter(p => p >= 1 && p <= numPages);
+/**
+ * 在线程池中执行任务，超时后返回失败结果
+ *
+ * 页面可能在线程池中排队（所有页面一次提交、多个转换共用线程池），排队时间不应计入超时：
+ * 工作线程开始处理任务时通过 startPort 通知主线程，此时才开始计时。
+ */
+async function runWithTimeout(pool, task, timeout) {
+    if (!timeout) {
+        return pool.run(task);
     }
 
-    logger.debug(`Rendering ${targetPages.length} pages using thread pool (${threadCount} workers)`);
-
-    // 获取线程池
-    const pool = getThreadPool();
+    const controller = new AbortController();
+    const { port1, port2 } = new MessageChannel();
+    let timer = null;
+    port1.once('message', () => {
+        timer = setTimeout(() => controller.abort(), timeout);
+    });
 
     try {
-        // 为每一页创建任务并提交到线程池
-        const tasks = targetPages.map(pageNum => {
-            const task = {
-                pageNum,
-                options,
-            };
-            
-            if (filePath) {
-                task.filePath = filePath;
-            } else if (pdfBuffer) {
-                // 注意：Buffer 会被序列化传递给工作线程
-                // 对于大文件，建议先保存到临时文件再传递路径
-                task.pdfBuffer = pdfBuffer;
-            }
-            
-            // 提交任务到线程池
-            return pool.run(task);
-        });
-
-        // 等待所有页面的并行处理完成
-        const results = await Promise.all(tasks);
-
-        results.sort((a, b) => a.pageNum - b.pageNum);
-
+        return await pool.run({ ...task, startPort: port2 }, { signal: controller.signal, transferList: [port2] });
+    } catch (err) {
+        if (err.name !== 'AbortError' && err.name !== 'TimeoutError') {
+            throw err;
+        }
+        logger.warn(`Page ${task.pageNum} render timed out after ${timeout}ms`);
         return {
-            success: true,
-            numPages,
-            pages: results,
-            totalTime: Date.now() - startTime,
-            renderTime: results.reduce((sum, p) => sum + (p.renderTime || 0), 0),
-            encodeTime: results.reduce((sum, p) => sum + (p.encodeTime || 0), 0),
+            pageNum: task.pageNum,
+            success: false,
+            error: `Render timed out after ${timeout}ms`,
+            width: 0,
+            height: 0,
+            buffer: null,
+            renderTime: 0,
+            encodeTime: 0,
         };
     } finally {
-        // 清理临时文件
-        if (tempFile) {
-            try {
-                await fs.promises.unlink(tempFile);
-            } catch {}
-        }
+        clearTimeout(timer);
+        port1.close();
     }
 }
 
 /**
- * PDF 转图片
+ * 因取消而未渲染的页面结果
  *
- * @param {string|Buffer} input - PDF 输入（文件路径、URL 或 Buffer）
- * @param {Object} options - 转换选项
- * @param {number[]} [options.pages] - 要转换的页码（1-based），空数组表示全部
- * @param {string} [options.outputType='buffer'] - 输出类型：'file'、'buffer'、'cos'
- * @param {string} [options.outputDir] - 输出目录（outputType='file' 时必需）
- * @param {string} [options.prefix='page'] - 输出文件名前缀
- * @param {string} [options.format='webp'] - 输出格式：'webp'、'png'、'jpg'
- * @param {number} [options.quality] - 图片质量（0-100，用于 webp 和 jpg）
- * @param {Object} [options.webp] - WebP 编码配置
- * @param {number} [options.webp.quality] - WebP 质量（0-100，默认 80）
- * @param {number} [options.webp.method] - WebP 编码方法（0-6，默认 4，0最快6最慢）
- * @param {Object} [options.jpeg] - JPEG 编码配置
- * @param {number} [options.jpeg.quality] - JPEG 质量（0-100，默认 85）
- * @param {Object} [options.png] - PNG 编码配置
- * @param {number} [options.png.compressionLevel] - PNG 压缩级别（0-9，默认 6）
- * @param {Object} [options.cos] - COS 配置（outputType='cos' 时必需）
- * @param {string} [options.cosKeyPrefix] - COS key 前缀
- * @param {number} [options.targetWidth] - 目标渲染宽度（默认 1280）
- * @param {number} [options.concurrency] - 文件/上传并发数
- * @returns {Promise<Object>} 转换结果
+ * @param {number} pageNum - 页码
+ * @returns {Object} 失败的页面结果，aborted 为 true
  */
-export async function convert(input, options = {}) {
-    const startTime = Date.now();
-
-    const {
-        pages = [],
-        outputType = OutputType.BUFFER,
-        outputDir,
-        prefix = 'page',
-        format = RENDER_CONFIG.OUTPUT_FORMAT,
-        cos: cosConfig,
-        cosKeyPrefix = `pdf2img/${Date.now()}`,
-        concurrency,
-        ...renderOptions
-    } = options;
+function abortedPage(pageNum) {
+    return {
+        pageNum,
+        success: false,
+        aborted: true,
+        error: 'Aborted before rendering',
+        width: 0,
+        height: 0,
+        buffer: null,
+        renderTime: 0,
+        encodeTime: 0,
+    };
+}
 
-    // 验证格式
-    const normalizedFormat = format.toLowerCase();
-    if (!SUPPORTED_FORMATS.includes(normalizedFormat)) {
-        throw new Error(`Unsupported format: ${format}. Supported formats: ${SUPPORTED_FORMATS.join(', ')}`);
+/**
+ * 调用逐页回调并等待其完成，回调异常（或返回的 Promise 被拒绝）只记录日志，不影响转换
+ *
+ * @param {Function} onPage - 逐页回调
+ * @param {Object} page - 工作线程返回的页面结果
+ * @returns {Promise<Object>} 原样返回页面结果
+ */
+async function notifyPage(onPage, page) {
+    if (page.skipped) {
+        return page;
     }
-
-    // 检查渲染器可用性
-    if (!nativeRenderer.isNativeAvailable()) {
-        throw new Error('Native renderer is not available. Please ensure PDFium library is installed.');
+    try {
+        await onPage({
+            pageNum: page.pageNum,
+            width: page.width,
+            height: page.height,
+            success: page.success,
+            buffer: page.success ? page.buffer : null,
+            avgColor: page.avgColor,
+            placeholder: page.placeholder,
+            variants: page.variants,
+            effectiveOptions: page.effectiveOptions,
+            error: page.error,
+        });
+    } catch (err) {
+        logger.warn(`onPage callback failed: ${err.message}`);
     }
+    return page;
+}
 
-    // 检测输入类型
-    const inputType = detectInputType(input);
-    logger.debug(`Input type: ${inputType}`);
+/**
+ * 规范化并验证输出格式
+ *
+ * @param {string} format - 输出格式
+ * @param {boolean} [allowAuto=false] - 是否接受 'auto'（由工作线程按页面内容选择 PNG 或 WebP）
+ * @returns {string} 小写格式名
+ */
+function normalizeFormat(format, allowAuto = false) {
+    const normalized = format.toLowerCase();
+    if (allowAuto && normalized === 'auto') {
+        return normalized;
+    }
+    if (!SUPPORTED_FORMATS.includes(normalized)) {
+        const supported = allowAuto ? [...SUPPORTED_FORMATS, 'auto'] : SUPPORTED_FORMATS;
+        throw new Error(`Unsupported format: ${format}. Supported formats: ${supported.join(', ')}`);
+    }
+    return normalized;
+}
 
-    // 构建编码选项
-    const encodeOptions = {
-        format: normalizedFormat,
+/**
+ * 将用户选项转换为传给工作线程的编码选项
+ *
+ * 所有渲染都经过这里，尺寸参数统一由 normalizeRenderOptions 校验并按 DPI/宽度上限截断。
+ *
+ * @param {string} format - 已规范化的输出格式
+ * @param {Object} renderOptions - 用户渲染/编码选项
+ * @returns {Object} 编码选项
+ * @throws {Error} 尺寸参数非法时抛出
+ */
+function buildEncodeOptions(format, renderOptions) {
+    const dpis = renderOptions.dpis && normalizeDpis(renderOptions.dpis);
+    const { targetWidth, exactWidth, maxScale } = dpis
+        ? dpiRenderOptions(dpis)
+        : normalizeRenderOptions(renderOptions);
+    return {
+        format,
         quality: renderOptions.quality,
         webpQuality: renderOptions.webp?.quality,
         webpMethod: renderOptions.webp?.method,
         jpegQuality: renderOptions.jpeg?.quality,
         pngCompression: renderOptions.png?.compressionLevel,
-        targetWidth: renderOptions.targetWidth,
+        targetWidth,
+        exactWidth,
+        maxScale,
         detectScan: renderOptions.detectScan,
+        deterministic: renderOptions.deterministic,
+        clip: renderOptions.clip,
+        includePageColor: renderOptions.includePageColor,
+        includePlaceholder: renderOptions.includePlaceholder,
+        maxBytes: renderOptions.maxBytes,
+        postProcess: renderOptions.postProcess,
+        rotate: renderOptions.rotate,
+        watermark: renderOptions.watermark,
+        progressive: renderOptions.progressive,
+        skipBlankPages: renderOptions.skipBlankPages,
+        blankThreshold: renderOptions.blankThreshold ?? RENDER_CONFIG.BLANK_PAGE_THRESHOLD,
+        pageTimeout: renderOptions.pageTimeout ?? TIMEOUT_CONFIG.RENDER_TIMEOUT,
+        maxPages: renderOptions.maxPages ?? RENDER_CONFIG.MAX_PAGES,
+        blockSize: renderOptions.blockSize,
+        autoTune: renderOptions.autoTune,
+        dpis,
     };
+}
 
-    // 使用线程池渲染页面
-    const result = await renderPages(input, inputType, pages, encodeOptions);
-
-    // 处理输出
-    let outputResult;
-
-    if (outputType === OutputType.FILE) {
-        if (!outputDir) {
-            throw new Error('outputDir is required when outputType is "file"');
-        }
-        outputResult = await saveToFiles(result.pages, outputDir, prefix, normalizedFormat, concurrency);
-
-    } else if (outputType === OutputType.COS) {
-        if (!cosConfig) {
-            throw new Error('cos config is required when outputType is "cos"');
+/**
+ * 规范化 dpis 选项：统一为 { dpi, quality } 并截断到 DPI 上限，相同 DPI 只保留第一项
+ *
+ * @param {Array<number|Object>} dpis - DPI 或 { dpi, quality }
+ * @returns {Array<{ dpi: number, quality: number|undefined }>}
+ */
+function normalizeDpis(dpis) {
+    const tiers = new Map();
+    for (const entry of dpis) {
+        const { dpi, quality } = typeof entry === 'number' ? { dpi: entry } : entry;
+        const capped = Math.min(dpi, RENDER_CONFIG.MAX_DPI);
+        if (!tiers.has(capped)) {
+            tiers.set(capped, { dpi: capped, quality });
         }
-        outputResult = await uploadToCos(result.pages, cosConfig, cosKeyPrefix, normalizedFormat, concurrency);
-
-    } else {
-        // 返回 Buffer
-        outputResult = result.pages.map(page => ({
-            pageNum: page.pageNum,
-            width: page.width,
-            height: page.height,
-            success: page.success,
-            buffer: page.success ? page.buffer : null,
-            error: page.error,
-        })).sort((a, b) => a.pageNum - b.pageNum);
     }
+    return [...tiers.values()];
+}
 
-    return {
-        success: true,
-        numPages: result.numPages,
-        renderedPages: outputResult.filter(p => p.success).length,
-        format: normalizedFormat,
-        pages: outputResult,
-        timing: {
-            total: Date.now() - startTime,
-            render: result.renderTime,
-            encode: result.encodeTime,
-        },
-        threadPool: {
-            workers: threadCount,
-        },
-    };
+/**
+ * dpis 选项的渲染尺寸参数：整页按最高的 DPI 渲染一次（仍受宽度上限约束），
+ * 其余 DPI 由工作线程从同一位图缩小得到
+ *
+ * @param {Array<{ dpi: number }>} dpis - 规范化后的 DPI 列表
+ * @returns {{ targetWidth: number, maxScale: number }}
+ */
+function dpiRenderOptions(dpis) {
+    return normalizeRenderOptions({
+        targetWidth: RENDER_CONFIG.MAX_RENDER_WIDTH,
+        maxScale: Math.max(...dpis.map(tier => tier.dpi)) / 72,
+    });
 }
 
 /**
- * 获取 PDF 页数（异步版本）
+ * 验证裁剪区域
  *
- * @param {string|Buffer} input - PDF 输入（文件路径或 Buffer）
- * @returns {Promise<number>} 页数
+ * 裁剪区域以页面比例表示（0-1），左上角为原点，不能超出页面范围。
+ *
+ * @param {Object} clip - 裁剪区域 { x, y, width, height }
  */
-export async function getPageCount(input) {
-    if (!nativeRenderer.isNativeAvailable()) {
-        throw new Error('Native renderer is not available');
+function validateClip(clip) {
+    const { x, y, width, height } = clip;
+    const values = [x, y, width, height];
+    if (values.some(v => typeof v !== 'number' || !Number.isFinite(v))) {
+        throw new Error('Invalid clip: x, y, width and height must be numbers');
+    }
+    if (x < 0 || y < 0 || width <= 0 || height <= 0 || x + width > 1 || y + height > 1) {
+        throw new Error('Invalid clip: region must be within the page (fractions between 0 and 1)');
+    }
+}
+
+/**
+ * 验证后处理列表
+ *
+ * @param {string[]} postProcess - 后处理名称列表
+ */
+function validatePostProcess(postProcess) {
+    if (!Array.isArray(postProcess)) {
+        throw new Error('Invalid postProcess: must be an array of filter names');
+    }
+    for (const name of postProcess) {
+        if (!POST_PROCESS_FILTERS.includes(name)) {
+            throw new Error(`Unsupported postProcess filter: ${name}. Supported filters: ${POST_PROCESS_FILTERS.join(', ')}`);
+        }
+    }
+}
+
+/**
+ * 验证 DPI 列表（dpis 选项）
+ *
+ * @param {Array<number|Object>} dpis - DPI，或带该档质量的 { dpi, quality }
+ */
+function validateDpis(dpis) {
+    const isDpi = dpi => Number.isInteger(dpi) && dpi > 0;
+    if (!Array.isArray(dpis) || dpis.length === 0) {
+        throw new Error('Invalid dpis: must be a non-empty array of positive integers or { dpi, quality } objects');
+    }
+    for (const entry of dpis) {
+        const { dpi, quality } = typeof entry === 'object' && entry !== null ? entry : { dpi: entry };
+        if (!isDpi(dpi)) {
+            throw new Error('Invalid dpis: must be a non-empty array of positive integers or { dpi, quality } objects');
+        }
+        if (quality !== undefined && !(Number.isInteger(quality) && quality >= 1 && quality <= 100)) {
+            throw new Error(`Invalid dpis quality for ${dpi} DPI: ${quality}. Must be an integer between 1 and 100`);
+        }
+    }
+}
+
+/**
+ * 验证旋转角度（度，必须是 90 的整数倍，负数表示逆时针）
+ *
+ * @param {number} rotate - 旋转角度
+ */
+function validateRotate(rotate) {
+    if (!Number.isInteger(rotate) || rotate % 90 !== 0) {
+        throw new Error(`Invalid rotate: ${rotate}. Must be a multiple of 90`);
+    }
+}
+
+/**
+ * 按字段列表裁剪页面结果（始终保留 pageNum）
+ *
+ * @param {Object} page - 页面结果
+ * @param {string[]} fields - 需要保留的字段
+ * @returns {Object} 只包含指定字段的页面结果
+ */
+function projectPage(page, fields) {
+    const projected = { pageNum: page.pageNum };
+    for (const field of fields) {
+        if (field in page) {
+            projected[field] = page[field];
+        }
+    }
+    return projected;
+}
+
+/**
+ * 验证水印选项
+ *
+ * @param {Object} watermark - 水印 { text, image, opacity, position, fontSize, color, angle, width }
+ */
+function validateWatermark(watermark) {
+    const { text, image, opacity, position, fontSize, width } = watermark;
+    const hasText = typeof text === 'string' && text.length > 0;
+    const hasImage = image instanceof Uint8Array;
+    if (hasText === hasImage) {
+        throw new Error('Invalid watermark: exactly one of text or image (Buffer) is required');
+    }
+    if (opacity !== undefined && !(typeof opacity === 'number' && opacity >= 0 && opacity <= 1)) {
+        throw new Error(`Invalid watermark opacity: ${opacity}. Must be between 0 and 1`);
+    }
+    if (position !== undefined && !WATERMARK_POSITIONS.includes(position)) {
+        throw new Error(`Invalid watermark position: ${position}. Supported positions: ${WATERMARK_POSITIONS.join(', ')}`);
+    }
+    if (fontSize !== undefined && !(typeof fontSize === 'number' && fontSize > 0)) {
+        throw new Error(`Invalid watermark fontSize: ${fontSize}. Must be a positive number`);
+    }
+    if (width !== undefined && !(typeof width === 'number' && width > 0 && width <= 1)) {
+        throw new Error(`Invalid watermark width: ${width}. Must be a fraction of the page width (0-1]`);
+    }
+}
+
+/**
+ * 读取远程文件头，检测是否为线性化 PDF
+ *
+ * 服务器不支持 Range 请求时无法按需加载，直接返回 false。
+ *
+ * @param {string} url - PDF URL
+ * @param {number} fileSize - 文件大小
+ * @param {Object} network - 远程访问策略
+ * @param {AbortSignal} [signal] - 取消信号
+ * @returns {Promise<boolean>}
+ */
+async function probeLinearized(url, fileSize, network, signal) {
+    try {
+        return await limitFetch(async () => {
+            const response = await fetchWithPolicy(url, {
+                headers: { 'Range': `bytes=0-${LINEARIZATION_PROBE_SIZE - 1}` },
+                signal: timeoutSignal(TIMEOUT_CONFIG.RANGE_REQUEST_TIMEOUT, signal),
+            }, network);
+
+            if (response.status !== 206) {
+                await response.body?.cancel();
+                return false;
+            }
+            return isLinearized(Buffer.from(await response.arrayBuffer()), fileSize);
+        });
+    } catch (err) {
+        // 调用方取消时直接结束，而不是当作非线性化文件继续下载
+        signal?.throwIfAborted();
+        logger.debug(`Linearization probe failed: ${err.message}`);
+        return false;
+    }
+}
+
+/**
+ * 是否可以改用按需加载渲染
+ *
+ * 按需加载由原生渲染器直接编码，不经过工作线程，
+ * 需要工作线程处理的选项（原始位图、自动格式、裁剪、后处理、旋转、水印、JPEG/PNG 渐进式编码、空白页检测、页面颜色、大小上限、确定性输出、多 DPI 输出、按页选项、单页超时）会回退到完整下载。
+ *
+ * @param {Object} options - 编码选项
+ * @param {Object} pageOptions - 按页覆盖的编码选项
+ * @returns {boolean}
+ */
+function canRenderFromStream(options, pageOptions) {
+    return SUPPORTED_FORMATS.includes(options.format)
+        && !options.clip
+        && !options.includePageColor
+        && !options.includePlaceholder
+        && !options.maxBytes
+        && !options.postProcess?.length
+        && !options.rotate
+        && !options.skipBlankPages
+        && !options.watermark
+        && !(options.progressive && ['jpg', 'jpeg', 'png'].includes(options.format))
+        && !options.deterministic
+        && !options.pageTimeout
+        && !options.dpis
+        && Object.keys(pageOptions).length === 0;
+}
+
+/**
+ * 按需加载渲染线性化的远程 PDF
+ *
+ * 每页渲染完成后立即调用 onPage，不等待其余页面；取消时中止分片请求并以 signal.reason 结束。
+ *
+ * @returns {Promise<Object>} 与 renderPages 相同结构的渲染结果
+ */
+async function renderLinearized(url, fileSize, pages, options, network, { onPage, signal }, startTime) {
+    // 按需加载由原生渲染器直接编码，质量即配置值（不受 maxBytes 影响，该选项不走此路径）
+    const quality = options.format === 'png'
+        ? null
+        : options.format === 'webp'
+            ? options.webpQuality ?? options.quality ?? ENCODER_CONFIG.WEBP_QUALITY
+            : options.jpegQuality ?? options.quality ?? ENCODER_CONFIG.JPEG_QUALITY;
+    const decorate = page => ({
+        ...page,
+        format: options.format,
+        effectiveOptions: page.success
+            ? { format: options.format, scale: page.scale, dpi: Math.round(page.scale * 72), quality }
+            : undefined,
+    });
+    const notified = [];
+
+    const result = await nativeRenderer.renderFromStream(url, fileSize, pages, {
+        ...network,
+        signal,
+        onPage: onPage && (page => notified.push(notifyPage(onPage, decorate(page)))),
+        maxPages: options.maxPages,
+        targetWidth: options.targetWidth,
+        exactWidth: options.exactWidth,
+        maxScale: options.maxScale,
+        detectScan: options.detectScan,
+        format: options.format,
+        quality: options.quality,
+        webp: { quality: options.webpQuality, method: options.webpMethod },
+        jpeg: { quality: options.jpegQuality },
+        png: { compressionLevel: options.pngCompression },
+        blockSize: options.blockSize,
+    }).finally(() => Promise.all(notified));
+    const results = result.pages.map(decorate);
+
+    return {
+        success: true,
+        numPages: result.numPages,
+        pages: results,
+        linearized: true,
+        streamStats: result.streamStats,
+        efficiency: efficiencyReport(result.streamStats, fileSize),
+        totalTime: Date.now() - startTime,
+        renderTime: results.reduce((sum, p) => sum + (p.renderTime || 0), 0),
+        encodeTime: results.reduce((sum, p) => sum + (p.encodeTime || 0), 0),
+    };
+}
+
+/**
+ * 校准按需加载的块大小
+ *
+ * 校准只是优化，失败时记录警告并使用默认块大小；取消信号触发时照常抛出。
+ *
+ * @returns {Promise<Object|null>} calibrate 的结果，未校准时为 null
+ */
+async function autoTuneBlockSize(url, fileSize, network, signal) {
+    try {
+        const profile = await calibrate(url, fileSize, network, signal);
+        if (profile) {
+            logger.debug(`Auto-tuned block size: ${profile.blockSize} (latency ${profile.latency.toFixed(1)}ms, `
+                + `throughput ${(profile.throughput / 1024 / 1024).toFixed(2)}MB/s)`);
+        }
+        return profile;
+    } catch (err) {
+        signal?.throwIfAborted();
+        logger.warn(`Block size calibration failed, using the default: ${err.message}`);
+        return null;
+    }
+}
+
+/**
+ * 在追踪 span 中打开文档并获取页数
+ *
+ * @param {Object} [tracer] - OpenTelemetry 兼容的 tracer
+ * @param {Function} getCount - 获取页数的函数
+ * @returns {Promise<number>} 页数
+ */
+function openDocument(tracer, getCount) {
+    return withSpan(tracer, 'pdf2img.open', {}, async (span) => {
+        const numPages = getCount();
+        span.setAttribute('pdf2img.page_count', numPages);
+        return numPages;
+    });
+}
+
+/**
+ * 将单页渲染结果记录到 span，失败页面标记为错误
+ *
+ * @param {Object} span - 页面 span
+ * @param {Object} result - 工作线程返回的页面结果
+ */
+function recordPageSpan(span, result) {
+    span.setAttributes({
+        'pdf2img.width': result.width,
+        'pdf2img.height': result.height,
+        'pdf2img.render_time': result.renderTime,
+        'pdf2img.encode_time': result.encodeTime,
+    });
+    if (result.success) {
+        // format 为 'auto' 时以工作线程实际选择的格式覆盖
+        span.setAttributes({ 'pdf2img.size': result.size, 'pdf2img.format': result.format });
+    } else {
+        span.setStatus({ code: SPAN_STATUS_ERROR, message: result.error });
+    }
+}
+
+/**
+ * 上传输出清单到 COS
+ *
+ * @returns {Promise<string>} 清单的 COS key
+ */
+async function uploadManifestToCos(manifest, cosConfig, keyPrefix) {
+    const COS = (await import('cos-nodejs-sdk-v5')).default;
+
+    const cos = new COS({
+        SecretId: cosConfig.secretId,
+        SecretKey: cosConfig.secretKey,
+    });
+
+    const key = `${keyPrefix}/${MANIFEST_NAME}`;
+    await new Promise((resolve, reject) => {
+        cos.putObject({
+            Bucket: cosConfig.bucket,
+            Region: cosConfig.region,
+            Key: key,
+            Body: Buffer.from(JSON.stringify(manifest, null, 2)),
+            ContentType: 'application/json',
+        }, (err) => {
+            if (err) reject(err);
+            else resolve();
+        });
+    });
+    return key;
+}
+
+/**
+ * 使用线程池渲染 PDF 页面
+ * 
+ * 主线程负责协调，工作线程负责 CPU 密集型任务
+ * 
+ * @param {string|Buffer} input - 输入
+ * @param {string} inputType - 输入类型
+ * @param {number[]} pages - 页码数组
+ * @param {Object} options - 编码选项
+ * @param {Object} [extras] - 附加参数
+ * @param {Object} [extras.network] - 远程访问策略（见 pickNetworkPolicy）
+ * @param {Function} [extras.onPage] - 每页渲染完成后立即调用（按完成顺序），返回 Promise 时等待其完成
+ * @param {number} [extras.maxPagesInFlight] - 正在渲染和已渲染但 onPage 尚未完成的页面总数上限
+ * @param {Object} [extras.pageOptions] - 按页码覆盖的编码选项（页码 -> 编码选项）
+ * @param {Object} [extras.tracer] - OpenTelemetry 兼容的 tracer
+ * @param {Object} [extras.remote] - 已获取的远程文件信息 { size, etag }，URL 输入时避免重复请求
+ * @param {AbortSignal} [extras.signal] - 取消信号，触发后不再提交新页面；完整下载时立即中止下载
+ * @param {Function} [extras.onDownloadProgress] - 完整下载远程文件时的进度回调 { bytes, total }
+ * @returns {Promise<Object>} 渲染结果
+ */
+async function renderPages(input, inputType, pages, options, extras = {}) {
+    const { network = {}, onPage, maxPagesInFlight, pageOptions = {}, tracer, signal } = extras;
+    const startTime = Date.now();
+    let filePath = null;
+    let pdfBuffer = null;
+    let tempFile = null;
+    let pool = null;
+    let numPages;
+
+    // 准备输入
+    if (inputType === InputType.FILE) {
+        try {
+            await fs.promises.access(input, fs.constants.R_OK);
+        } catch {
+            throw new Error(`File not found or not readable: ${input}`);
+        }
+        filePath = input;
+        numPages = await openDocument(tracer, () => nativeRenderer.getPageCountFromFile(filePath));
+    } else if (inputType === InputType.BUFFER) {
+        pdfBuffer = Buffer.isBuffer(input) ? input : Buffer.from(input);
+        numPages = await openDocument(tracer, () => nativeRenderer.getPageCount(pdfBuffer));
+    } else if (inputType === InputType.URL) {
+        const { size: fileSize } = extras.remote ?? await getRemoteFileInfo(input, network, signal);
+        // 线性化文件按需加载，只下载目标页面需要的数据块；选项需要工作线程处理时不必探测
+        if (canRenderFromStream(options, pageOptions) && await probeLinearized(input, fileSize, network, signal)) {
+            logger.debug(`Linearized PDF detected (${(fileSize / 1024 / 1024).toFixed(2)}MB), rendering on demand`);
+            const autoTune = options.autoTune && options.blockSize === undefined
+                ? await autoTuneBlockSize(input, fileSize, network, signal)
+                : null;
+            const streamOptions = autoTune ? { ...options, blockSize: autoTune.blockSize } : options;
+            return withSpan(tracer, 'pdf2img.stream_render', {
+                'pdf2img.file_size': fileSize,
+                'pdf2img.format': options.format,
+            }, async (span) => {
+                const result = await renderLinearized(input, fileSize, pages, streamOptions, network, { onPage, signal }, startTime);
+                span.setAttributes({
+                    'pdf2img.page_count': result.numPages,
+                    'pdf2img.bytes_downloaded': result.streamStats?.totalBytesFetched ?? 0,
+                });
+                return autoTune ? { ...result, autoTune } : result;
+            });
+        }
+
+        logger.debug(`Remote file size: ${(fileSize / 1024 / 1024).toFixed(2)}MB, downloading...`);
+        tempFile = await withSpan(tracer, 'pdf2img.download', {
+            'pdf2img.file_size': fileSize,
+        }, async (span) => {
+            const file = await downloadWithRetry(input, network, fileSize, { signal, onProgress: extras.onDownloadProgress });
+            span.setAttribute('pdf2img.bytes_downloaded', (await fs.promises.stat(file)).size);
+            return file;
+        });
+        filePath = tempFile;
+        numPages = await openDocument(tracer, () => nativeRenderer.getPageCountFromFile(filePath));
+    }
+
+    // 确定目标页码（支持负数从末尾倒数）
+    const targetPages = resolvePages(pages, numPages);
+
+    try {
+        assertHasPages(numPages);
+        assertPagesInRange(pages, targetPages, numPages);
+        assertPageLimit(targetPages, numPages, options.maxPages);
+
+        logger.debug(`Rendering ${targetPages.length} pages using thread pool (${threadCount} workers)`);
+
+        // 获取线程池：转换期间一直使用同一个线程池，结束后释放
+        pool = acquireThreadPool();
+
+        // 传入取消信号时，同时提交的页面数不超过线程数，取消后在页面边界停止：
+        // 已在渲染的页面正常完成，其余页面不再提交。
+        // 设置 maxPagesInFlight 时，页面从提交渲染到 onPage 完成都占用名额，
+        // 消费者处理不过来时暂停提交，已渲染未消费的页面不会无限堆积在内存中
+        const inFlightLimit = maxPagesInFlight || (signal ? threadCount : 0);
+        const gate = inFlightLimit ? pLimit(inFlightLimit) : fn => fn();
+
+        // 为每一页创建任务并提交到线程池
+        const tasks = targetPages.map(pageNum => {
+            const task = {
+                pageNum,
+                options: pageOptions[pageNum] ?? options,
+            };
+            
+            if (filePath) {
+                task.filePath = filePath;
+            } else if (pdfBuffer) {
+                // 注意：Buffer 会被序列化传递给工作线程
+                // 对于大文件，建议先保存到临时文件再传递路径
+                task.pdfBuffer = pdfBuffer;
+            }
+            
+            // 提交任务到线程池；取消后尚未开始的页面直接跳过
+            return gate(async () => {
+                if (signal?.aborted) {
+                    return abortedPage(pageNum);
+                }
+                const page = await withSpan(tracer, 'pdf2img.render_page', {
+                    'pdf2img.page_num': pageNum,
+                    'pdf2img.format': task.options.format,
+                }, async (span) => {
+                    const result = await runPageTask(pool, task, task.options.pageTimeout);
+                    recordPageSpan(span, result);
+                    return result;
+                });
+                return onPage ? notifyPage(onPage, page) : page;
+            });
+        });
+
+        // 等待所有页面的并行处理完成，结果按请求顺序排列（与完成顺序无关）
+        const results = orderByRequest(await Promise.all(tasks), targetPages);
+
+        return {
+            success: true,
+            numPages,
+            pages: results,
+            aborted: results.some(p => p.aborted),
+            linearized: inputType === InputType.URL ? false : undefined,
+            totalTime: Date.now() - startTime,
+            renderTime: results.reduce((sum, p) => sum + (p.renderTime || 0), 0),
+            encodeTime: results.reduce((sum, p) => sum + (p.encodeTime || 0), 0),
+        };
+    } finally {
+        if (pool) {
+            releaseThreadPool(pool);
+        }
+        // 清理临时文件
+        if (tempFile) {
+            await tempStore.release(tempFile);
+        }
+    }
+}
+
+/**
+ * 获取用于缓存的文档标识
+ *
+ * URL 使用 ETag（或 Last-Modified），本地文件使用路径、修改时间和大小，Buffer 使用内容摘要。
+ * 无法可靠标识文档版本时返回 null（不缓存）。
+ *
+ * @returns {Promise<string|null>}
+ */
+async function getDocumentId(input, inputType, remote) {
+    if (inputType === InputType.URL) {
+        return remote.etag ? `${input}|${remote.etag}` : null;
+    }
+    if (inputType === InputType.FILE) {
+        try {
+            const stat = await fs.promises.stat(input);
+            return `${path.resolve(input)}|${stat.mtimeMs}|${stat.size}`;
+        } catch {
+            return null;
+        }
+    }
+    return hashBuffer(Buffer.isBuffer(input) ? input : Buffer.from(input));
+}
+
+/**
+ * 计算单页缓存 key：文档标识 + 页码 + 影响输出的编码选项
+ */
+function pageCacheKey(docId, pageNum, options) {
+    const { pageTimeout, maxPages, blockSize, autoTune, ...encodeOptions } = options;
+    // 图片水印按内容摘要参与计算，避免序列化整个 Buffer
+    if (encodeOptions.watermark?.image) {
+        encodeOptions.watermark = { ...encodeOptions.watermark, image: hashBuffer(encodeOptions.watermark.image) };
+    }
+    return cacheKey(docId, pageNum, encodeOptions);
+}
+
+/**
+ * 带缓存的页面渲染
+ *
+ * 所有目标页面都命中时直接返回，不下载也不渲染；部分命中时只渲染未命中的页面。
+ * 解析负数页码或全部页面需要总页数，总页数同样会被缓存。
+ *
+ * @param {Object} extras - 同 renderPages，另需 extras.cache；extras.documentId 可指定文档标识（默认按输入计算）
+ * @returns {Promise<Object>} 与 renderPages 相同结构的渲染结果
+ */
+async function renderPagesWithCache(input, inputType, pages, options, extras) {
+    const { cache, network = {}, onPage, pageOptions = {}, documentId, signal } = extras;
+    const startTime = Date.now();
+    const remote = inputType === InputType.URL ? await getRemoteFileInfo(input, network, signal) : undefined;
+    const docId = documentId ?? await getDocumentId(input, inputType, remote);
+
+    if (!docId) {
+        return renderPages(input, inputType, pages, options, { ...extras, remote });
+    }
+
+    const keyFor = pageNum => pageCacheKey(docId, pageNum, pageOptions[pageNum] ?? options);
+    const numPagesKey = cacheKey(docId, 'numPages');
+    const cachedNumPages = await cache.get(numPagesKey);
+    const cachedPages = [];
+    let pendingPages = pages;
+
+    if (cachedNumPages !== undefined) {
+        assertHasPages(cachedNumPages);
+        const targetPages = resolvePages(pages, cachedNumPages);
+        assertPagesInRange(pages, targetPages, cachedNumPages);
+        assertPageLimit(targetPages, cachedNumPages, options.maxPages);
+
+        pendingPages = [];
+        for (const pageNum of targetPages) {
+            const page = await cache.get(keyFor(pageNum));
+            if (page) {
+                cachedPages.push({ ...page, renderTime: 0, encodeTime: 0 });
+            } else {
+                pendingPages.push(pageNum);
+            }
+        }
+    }
+
+    if (onPage) {
+        for (const page of cachedPages) {
+            await notifyPage(onPage, page);
+        }
+    }
+
+    if (cachedNumPages !== undefined && pendingPages.length === 0) {
+        logger.debug(`All ${cachedPages.length} pages served from cache`);
+        return {
+            success: true,
+            numPages: cachedNumPages,
+            pages: cachedPages,
+            fromCache: true,
+            totalTime: Date.now() - startTime,
+            renderTime: 0,
+            encodeTime: 0,
+        };
+    }
+
+    const result = await renderPages(input, inputType, pendingPages, options, { ...extras, remote });
+
+    await cache.set(numPagesKey, result.numPages, 0);
+    for (const page of result.pages) {
+        if (page.success && page.buffer) {
+            await cache.set(keyFor(page.pageNum), {
+                pageNum: page.pageNum,
+                success: true,
+                format: page.format,
+                width: page.width,
+                height: page.height,
+                buffer: page.buffer,
+                size: page.buffer.length,
+                avgColor: page.avgColor,
+                placeholder: page.placeholder,
+                variants: page.variants,
+                effectiveOptions: page.effectiveOptions,
+            }, page.variants ? page.variants.reduce((sum, variant) => sum + variant.size, 0) : page.buffer.length);
+        }
+    }
+
+    return {
+        ...result,
+        pages: orderByRequest([...cachedPages, ...result.pages], resolvePages(pages, result.numPages)),
+    };
+}
+
+/**
+ * PDF 转图片
+ *
+ * @param {string|Buffer} input - PDF 输入（文件路径、URL 或 Buffer），
+ *   或 `zip://<归档 URL>!<条目路径>`：通过 Range 请求只下载远程 ZIP 中的目标条目
+ * @param {Object} options - 转换选项
+ * @param {number[]|string} [options.pages] - 要转换的页码（1-based，负数从末尾倒数，-1 为最后一页），空数组表示全部；
+ *   结果按该顺序返回。重复的页码只渲染一次，结果出现在每个请求位置。
+ *   设置 PDF2IMG_DEFAULT_PAGES 时，未指定或空数组只渲染前 N 页，'all' 表示全部页面
+ * @param {string} [options.outputType='buffer'] - 输出类型：'file'、'buffer'、'cos'
+ * @param {string} [options.outputDir] - 输出目录（outputType='file' 时必需）
+ * @param {string} [options.prefix='page'] - 输出文件名前缀
+ * @param {string} [options.format='webp'] - 输出格式：'webp'、'png'、'jpg'，'auto' 按页面内容选择（文字/线稿页面为 PNG，照片类页面为 WebP）
+ * @param {number} [options.quality] - 图片质量（0-100，用于 webp 和 jpg）
+ * @param {Object} [options.webp] - WebP 编码配置
+ * @param {number} [options.webp.quality] - WebP 质量（0-100，默认 80）
+ * @param {number} [options.webp.method] - WebP 编码方法（0-6，默认 4，0最快6最慢）
+ * @param {Object} [options.jpeg] - JPEG 编码配置
+ * @param {number} [options.jpeg.quality] - JPEG 质量（0-100，默认 85）
+ * @param {Object} [options.png] - PNG 编码配置
+ * @param {number} [options.png.compressionLevel] - PNG 压缩级别（0-9，默认 6）
+ * @param {Object} [options.cos] - COS 配置（outputType='cos' 时必需）
+ * @param {string} [options.cosKeyPrefix] - COS key 前缀
+ * @param {number} [options.targetWidth] - 目标渲染宽度（默认 1280）
+ * @param {number} [options.rotate] - 在页面自身旋转（/Rotate）之上额外顺时针旋转的角度（90 的整数倍，负数为逆时针），
+ *   90/270 度时返回的宽高互换
+ * @param {number} [options.exactWidth] - 精确输出宽度（像素），每页按自身尺寸计算缩放比例，输出宽度恰好为该值、
+ *   高度按比例；不受 maxScale 限制且不做扫描件降级，设置后忽略 targetWidth
+ * @param {number} [options.concurrency] - 文件/上传并发数
+ * @param {boolean} [options.progressive] - 渐进式 JPEG / 隔行扫描 PNG（默认 false），WebP 不支持，设置后忽略
+ * @param {Object} [options.watermark] - 水印 { text | image, opacity, position, fontSize, color, angle, width }，
+ *   position 可选 center（默认，斜向）、tile（斜向平铺）、top-left、top-right、bottom-left、bottom-right
+ * @param {boolean} [options.skipBlankPages] - 跳过空白页（默认 false），跳过的页码记录在结果的 skippedPages 中
+ * @param {number} [options.blankThreshold] - 空白页判定阈值，RGB 各通道像素标准差不超过该值视为空白（默认 3）
+ * @param {Object} [options.clip] - 裁剪区域 { x, y, width, height }（页面比例 0-1），只渲染、输出该区域，
+ *   targetWidth/exactWidth 作用于区域宽度
+ * @param {Object} [options.pageOptions] - 按页码覆盖渲染/编码选项，如 { 1: { targetWidth: 2560, format: 'png' } }，
+ *   可覆盖 targetWidth、exactWidth、format、quality、webp、jpeg、png、clip、maxBytes、postProcess、rotate，未指定的页面使用全局选项
+ * @param {Function} [options.onPage] - 每页渲染完成后立即调用（按完成顺序，早于文件写入/上传），
+ *   参数为 { pageNum, width, height, success, buffer, avgColor, placeholder, variants, effectiveOptions, error }；返回 Promise 时等待其完成
+ * @param {number} [options.maxPagesInFlight] - 正在渲染和已渲染但 onPage 尚未完成的页面总数上限（默认不限制），
+ *   onPage 处理较慢（如推送给慢速客户端）时暂停渲染，避免已完成的页面在内存中堆积
+ * @param {number} [options.maxPages] - 单次转换最大页数（默认取 PDF2IMG_MAX_PAGES，0 表示不限制），
+ *   解析页码后超过上限时抛出 err.code 为 'ERR_TOO_MANY_PAGES' 的错误
+ * @param {number} [options.pageTimeout] - 单页渲染超时（毫秒，默认取 RENDER_TIMEOUT，0 表示不限制），超时页面记为失败
+ * @param {string[]} [options.postProcess] - 编码前按顺序应用的内置后处理：'sharpen'（锐化）、'autocontrast'（拉伸对比度）、
+ *   'denoise'（中值滤波去噪），适合扫描件
+ * @param {number} [options.maxBytes] - 单页输出大小上限（字节，仅 webp/jpg），自动降低质量以满足上限；
+ *   最低质量仍超出时返回最低质量的结果。deterministic 模式的 WebP 为无损编码，不受此选项影响
+ * @param {boolean} [options.includePageColor] - 计算每页平均颜色（结果中的 avgColor，如 '#fafafa'），可用作加载占位背景色
+ * @param {boolean} [options.includePlaceholder] - 生成每页 16 像素宽的模糊缩略图（结果中的 placeholder，WebP data URI），
+ *   可在完整图片加载前内联显示
+ * @param {boolean} [options.deterministic] - 确定性输出（PNG、WebP 保证逐字节一致，WebP 改为无损编码）
+ * @param {Array<number|Object>} [options.dpis] - 每页按这些 DPI 各输出一张（如 [150, 300]，用于 srcset），只支持 buffer 输出；
+ *   项也可以是 { dpi, quality }，为该档单独指定 WebP/JPEG 质量（如低 DPI 用较低质量节省带宽）。
+ *   每页只下载、渲染一次（按最高 DPI），各 DPI 的结果按请求顺序放在页面的 variants 中，
+ *   页面本身的 buffer/width/height 为最高 DPI 的输出。设置后忽略 targetWidth、exactWidth、maxScale
+ * @param {string[]} [options.allowedHosts] - 允许访问的远程主机白名单（默认取 PDF2IMG_ALLOWED_HOSTS）
+ * @param {boolean} [options.blockPrivateNetwork] - 是否拦截内网地址（默认取 PDF2IMG_BLOCK_PRIVATE_NETWORK）
+ * @param {string[]} [options.fields] - 只在结果的 pages 中保留这些字段（pageNum 始终保留），
+ *   如 ['cosKey', 'width', 'height']，不影响 onPage 回调
+ * @param {number[]} [options.metadataPages] - 只返回尺寸、不渲染的页码（同 pages，空数组表示全部），
+ *   已渲染的页面不重复返回；结果的 pages 按页码排列，这些页面 metadataOnly 为 true、buffer 为 null
+ * @param {boolean} [options.manifest=false] - 生成输出清单（每页的页码、尺寸、格式、大小、文件名或 COS key）：
+ *   file 输出写入 outputDir/{prefix}_manifest.json，cos 输出上传到 {cosKeyPrefix}/manifest.json，均在结果的 manifest 中返回
+ * @param {number} [options.blockSize] - 按需加载时每次分片请求的缓存块大小（字节，2 的幂，16KB-4MB，
+ *   默认取 PDF2IMG_STREAM_BLOCK_SIZE 即 256KB），只影响下载粒度，不影响输出
+ * @param {boolean} [options.autoTune] - 按需加载前发送 3 个 Range 请求校准源站延迟与吞吐，自动选择 blockSize
+ *   （默认 false，显式设置 blockSize 时不校准），校准结果记录在结果的 autoTune 中
+ * @param {number} [options.maxFileSize] - 远程文件大小上限（字节，默认取 PDF2IMG_MAX_FILE_SIZE），
+ *   超过时在下载前抛出 code 为 ERR_FILE_TOO_LARGE 的错误
+ * @param {Function} [options.signRequest] - 请求签名钩子 ({ method, url, headers }) => void | Promise<void>，
+ *   在每个远程请求（HEAD、下载、分片请求）发出前调用，如 createSigV4Signer() 访问私有 S3
+ * @param {Object} [options.dispatcher] - 远程请求使用的 undici Dispatcher（如 new Agent({ allowH2: true })），
+ *   用于调整连接池、keep-alive 或按源站要求启用 HTTP/2，默认使用全局连接池
+ * @param {Object} [options.tracer] - OpenTelemetry 兼容的 tracer，产生 pdf2img.convert、pdf2img.download、
+ *   pdf2img.open、pdf2img.render_page 等 span
+ * @param {AbortSignal} [options.signal] - 取消信号（如进程退出时）。开始前已取消则抛出异常；
+ *   完整下载远程文件时取消立即中止下载、删除部分文件并抛出异常；
+ *   渲染中取消则在页面边界停止：已在渲染的页面正常完成，其余页面记为失败，结果中 aborted 为 true
+ * @param {Function} [options.onDownloadProgress] - 完整下载远程文件时每收到一个数据块调用，
+ *   参数为 { bytes, total }（已下载字节数、HEAD 返回的文件大小），按需加载时不调用
+ * @param {Object} [options.cache] - 渲染缓存（createRenderCache() 或实现 get/set 的自定义存储），
+ *   相同文档、页码和编码参数命中时跳过下载和渲染
+ * @param {Function} [options.injectTraceContext] - 追踪上下文注入钩子 (headers) => void，
+ *   在 pdf2img.convert span 内调用一次，注入的请求头附加到本次转换的所有出站请求
+ * @returns {Promise<Object>} 转换结果
+ */
+export async function convert(input, options = {}) {
+    const startTime = Date.now();
+
+    const {
+        outputType = OutputType.BUFFER,
+        outputDir,
+        prefix = 'page',
+        format = RENDER_CONFIG.OUTPUT_FORMAT,
+        cos: cosConfig,
+        cosKeyPrefix = `pdf2img/${Date.now()}`,
+        concurrency,
+        maxFileSize,
+        fields,
+        manifest = false,
+        metadataPages,
+        onPage,
+        onDownloadProgress,
+        maxPagesInFlight,
+        pageOptions = {},
+        tracer,
+        injectTraceContext,
+        cache,
+        signal,
+        ...renderOptions
+    } = options;
+
+    signal?.throwIfAborted();
+
+    // 未指定页码时按 PDF2IMG_DEFAULT_PAGES 取默认页码集合
+    const pages = applyDefaultPages(options.pages, RENDER_CONFIG.DEFAULT_PAGES);
+
+    if (maxFileSize !== undefined && !(Number.isInteger(maxFileSize) && maxFileSize > 0)) {
+        throw new Error(`Invalid maxFileSize: ${maxFileSize}. Must be a positive integer`);
+    }
+    const network = pickNetworkPolicy(options);
+
+    if (maxPagesInFlight !== undefined && !(Number.isInteger(maxPagesInFlight) && maxPagesInFlight > 0)) {
+        throw new Error(`Invalid maxPagesInFlight: ${maxPagesInFlight}. Must be a positive integer`);
+    }
+
+    if (metadataPages !== undefined && !(Array.isArray(metadataPages) && metadataPages.every(Number.isInteger))) {
+        throw new Error('Invalid metadataPages: must be an array of page numbers');
+    }
+
+    if (fields !== undefined && !(Array.isArray(fields) && fields.every(field => typeof field === 'string'))) {
+        throw new Error('Invalid fields: must be an array of page result field names');
+    }
+
+    // 验证格式
+    const normalizedFormat = normalizeFormat(format, true);
+
+    // 验证裁剪区域
+    if (renderOptions.clip) {
+        validateClip(renderOptions.clip);
+    }
+
+    if (renderOptions.postProcess) {
+        validatePostProcess(renderOptions.postProcess);
+    }
+
+    if (renderOptions.maxBytes !== undefined && !(Number.isInteger(renderOptions.maxBytes) && renderOptions.maxBytes > 0)) {
+        throw new Error(`Invalid maxBytes: ${renderOptions.maxBytes}. Must be a positive integer`);
+    }
+
+    if (renderOptions.rotate !== undefined) {
+        validateRotate(renderOptions.rotate);
+    }
+
+    if (renderOptions.watermark !== undefined) {
+        validateWatermark(renderOptions.watermark);
+    }
+
+    if (renderOptions.dpis !== undefined) {
+        validateDpis(renderOptions.dpis);
+        if (outputType !== OutputType.BUFFER) {
+            throw new Error('dpis is only supported with outputType "buffer"');
+        }
+    }
+
+    if (renderOptions.blankThreshold !== undefined && !(typeof renderOptions.blankThreshold === 'number' && renderOptions.blankThreshold >= 0)) {
+        throw new Error(`Invalid blankThreshold: ${renderOptions.blankThreshold}. Must be a non-negative number`);
+    }
+
+    const { blockSize } = renderOptions;
+    if (blockSize !== undefined && !isValidBlockSize(blockSize)) {
+        throw new Error(`Invalid blockSize: ${blockSize}. Must be a power of two between 16384 and 4194304`);
+    }
+
+    // 构建按页覆盖的编码选项，未指定的字段沿用全局选项
+    const pageEncodeOptions = {};
+    for (const [key, override] of Object.entries(pageOptions)) {
+        const pageNum = Number(key);
+        if (!Number.isInteger(pageNum) || pageNum < 1) {
+            throw new Error(`Invalid pageOptions key: ${key}. Keys must be 1-based page numbers`);
+        }
+        if (override.clip) {
+            validateClip(override.clip);
+        }
+        if (override.postProcess) {
+            validatePostProcess(override.postProcess);
+        }
+        if (override.rotate !== undefined) {
+            validateRotate(override.rotate);
+        }
+        pageEncodeOptions[pageNum] = buildEncodeOptions(
+            normalizeFormat(override.format ?? format, true),
+            { ...renderOptions, ...override }
+        );
+    }
+
+    // 检查渲染器可用性
+    if (!nativeRenderer.isNativeAvailable()) {
+        throw new Error('Native renderer is not available. Please ensure PDFium library is installed.');
+    }
+
+    // zip:// 输入：只下载归档中的目标条目，解压到临时文件后按本地文件渲染，转换结束后删除。
+    // 临时文件路径每次不同，缓存以条目地址和 CRC-32 标识文档
+    let zipEntryFile = null;
+    let documentId;
+    if (isZipUrl(input)) {
+        zipEntryFile = await tempStore.create('.pdf');
+        try {
+            const entry = await readZipEntry(input, zipEntryFile, network, signal);
+            documentId = `${input}|${entry.crc32}|${entry.size}`;
+        } catch (err) {
+            await tempStore.release(zipEntryFile);
+            throw err;
+        }
+        input = zipEntryFile;
+    }
+
+    // 检测输入类型
+    const inputType = detectInputType(input);
+    logger.debug(`Input type: ${inputType}`);
+
+    // 构建编码选项
+    const encodeOptions = buildEncodeOptions(normalizedFormat, renderOptions);
+
+    const converted = withSpan(tracer, 'pdf2img.convert', {
+        'pdf2img.input_type': inputType,
+        'pdf2img.format': normalizedFormat,
+        'pdf2img.output_type': outputType,
+    }, async (span) => {
+        // 使用线程池渲染页面，出站请求携带当前 span 的追踪上下文
+        const render = cache ? renderPagesWithCache : renderPages;
+        const result = await render(input, inputType, pages, encodeOptions, {
+            network: { ...network, headers: { ...network.headers, ...collectTraceHeaders(injectTraceContext) } },
+            onPage,
+            onDownloadProgress,
+            maxPagesInFlight,
+            pageOptions: pageEncodeOptions,
+            tracer,
+            cache,
+            documentId,
+            signal,
+        });
+
+        for (const page of result.pages) {
+            if (page.success && page.width && page.height) {
+                renderRate.record(page.renderTime, page.width * page.height / 1e6);
+            }
+        }
+
+        // skipBlankPages：空白页从结果中移除，只记录页码
+        const skippedPages = result.pages.filter(page => page.skipped).map(page => page.pageNum);
+        if (skippedPages.length > 0) {
+            result.pages = result.pages.filter(page => !page.skipped);
+        }
+
+        // 部分页码越界时，越界的页码作为失败页面排在最后，计入 failedPages
+        result.pages = [...result.pages, ...outOfRangePages(pages, result.numPages)];
+
+        const output = await writeOutput(result, {
+            outputType, outputDir, prefix, cosConfig, cosKeyPrefix, concurrency, normalizedFormat, manifest,
+        });
+
+        // 重复请求的页码只渲染、输出一次，结果映射回所有请求位置
+        output.pages = expandToRequest(output.pages, pages, result.numPages);
+
+        // metadataPages：其余页面只返回尺寸，与渲染的页面一起按页码排列
+        if (metadataPages) {
+            const metadata = await buildMetadataPages(
+                input, inputType, metadataPages, output.pages, result.numPages, network, renderOptions
+            );
+            output.pages = [...output.pages, ...metadata].sort((a, b) => a.pageNum - b.pageNum);
+        }
+
+        span.setAttributes({
+            'pdf2img.page_count': result.numPages,
+            'pdf2img.rendered_pages': output.renderedPages,
+            'pdf2img.failed_pages': output.failedPages,
+        });
+
+        return {
+            success: output.success,
+            partial: output.partial,
+            numPages: result.numPages,
+            renderedPages: output.renderedPages,
+            failedPages: output.failedPages,
+            format: normalizedFormat,
+            pages: fields ? output.pages.map(page => projectPage(page, fields)) : output.pages,
+            // manifest 开启时的输出清单，以及写入的文件路径（file 输出）或 COS key（cos 输出）
+            manifest: output.manifest,
+            manifestPath: output.manifestPath,
+            manifestKey: output.manifestKey,
+            // skipBlankPages 开启时被判定为空白而跳过的页码
+            skippedPages,
+            // 转换中途收到取消信号时为 true，未渲染的页面记为失败（error 为 'Aborted before rendering'）
+            aborted: Boolean(result.aborted),
+            // URL 输入时表示是否检测到线性化文件；线性化文件按需加载，streamStats 记录实际下载量
+            linearized: result.linearized,
+            streamStats: result.streamStats,
+            // 按需加载的读取量、下载量、缓存量与文件触及比例汇总
+            efficiency: result.efficiency,
+            // autoTune 校准结果（延迟、吞吐、选定的块大小），未校准时为 undefined
+            autoTune: result.autoTune,
+            // 便于从结果排查行为：是否全部来自渲染缓存、渲染后端、按需加载时读取量的缓存命中比例
+            stats: {
+                fromCache: Boolean(result.fromCache),
+                backend: nativeRenderer.RENDER_BACKEND,
+                cacheHitRatio: result.efficiency?.cacheHitRatio ?? null,
+            },
+            timing: {
+                total: Date.now() - startTime,
+                render: result.renderTime,
+                encode: result.encodeTime,
+            },
+            threadPool: {
+                workers: threadCount,
+            },
+        };
+    });
+    return zipEntryFile ? converted.finally(() => tempStore.release(zipEntryFile)) : converted;
+}
+
+/**
+ * 生成只含尺寸的页面结果（metadataPages）
+ *
+ * 只读取页面尺寸，不渲染；输出尺寸按 targetWidth/maxScale/exactWidth 与 rotate 估算，
+ * 不考虑扫描件降级，适合按比例预留滚动区域。已在 imagePages 中渲染的页面不重复返回。
+ *
+ * @param {string|Buffer} input - PDF 输入
+ * @param {string} inputType - 输入类型
+ * @param {number[]} metadataPages - 请求的页码（同 pages，空数组表示全部）
+ * @param {Object[]} imagePages - 已渲染的页面结果
+ * @param {number} numPages - PDF 总页数
+ * @param {Object} network - 远程访问策略
+ * @param {Object} renderOptions - 渲染选项
+ * @returns {Promise<Object[]>} [{ pageNum, width, height, success, metadataOnly: true, buffer: null }]
+ */
+async function buildMetadataPages(input, inputType, metadataPages, imagePages, numPages, network, renderOptions) {
+    const rendered = new Set(imagePages.map(page => page.pageNum));
+    const targetPages = resolvePages(metadataPages, numPages).filter(pageNum => !rendered.has(pageNum));
+    if (targetPages.length === 0) {
+        return [];
     }
 
-    if (Buffer.isBuffer(input)) {
-        return nativeRenderer.getPageCount(input);
+    const limit = Math.max(...targetPages);
+    let sizes;
+    if (inputType === InputType.BUFFER) {
+        sizes = nativeRenderer.getPageSizes(input, limit);
+    } else if (inputType === InputType.URL) {
+        const { size: fileSize } = await getRemoteFileInfo(input, network);
+        sizes = await nativeRenderer.getPageSizesFromStream(input, fileSize, limit, network);
+    } else {
+        sizes = nativeRenderer.getPageSizesFromFile(input, limit);
     }
-    
-    if (typeof input === 'string') {
+
+    const {
+        targetWidth = RENDER_CONFIG.TARGET_RENDER_WIDTH,
+        maxScale,
+        exactWidth,
+        rotate = 0,
+    } = normalizeRenderOptions(renderOptions);
+    const swapped = Math.abs(rotate) % 180 === 90;
+    const byPage = new Map(sizes.pages.map(size => [size.pageNum, size]));
+
+    return targetPages.map(pageNum => {
+        const size = byPage.get(pageNum);
+        if (!size) {
+            return { pageNum, width: 0, height: 0, success: false, metadataOnly: true, buffer: null, error: 'Failed to read page size' };
+        }
+        const scale = Math.min((exactWidth || targetWidth) / size.width, maxScale);
+        const width = Math.round(size.width * scale);
+        const height = Math.round(size.height * scale);
+        return {
+            pageNum,
+            width: swapped ? height : width,
+            height: swapped ? width : height,
+            success: true,
+            metadataOnly: true,
+            buffer: null,
+        };
+    });
+}
+
+/**
+ * 处理渲染结果的输出（写文件、上传 COS 或返回 Buffer）
+ *
+ * @param {Object} result - renderPages 的渲染结果
+ * @param {Object} output - 输出参数
+ * @returns {Promise<Object>} { success, partial, renderedPages, failedPages, pages }
+ */
+async function writeOutput(result, output) {
+    const { outputType, outputDir, prefix, cosConfig, cosKeyPrefix, concurrency, normalizedFormat, manifest } = output;
+    let outputResult;
+
+    if (outputType === OutputType.FILE) {
+        if (!outputDir) {
+            throw new Error('outputDir is required when outputType is "file"');
+        }
+        outputResult = await saveToFiles(result.pages, outputDir, prefix, normalizedFormat, concurrency);
+
+    } else if (outputType === OutputType.COS) {
+        if (!cosConfig) {
+            throw new Error('cos config is required when outputType is "cos"');
+        }
+        outputResult = await uploadToCos(result.pages, cosConfig, cosKeyPrefix, normalizedFormat, concurrency);
+
+    } else {
+        // 返回 Buffer
+        outputResult = result.pages.map(page => ({
+            pageNum: page.pageNum,
+            width: page.width,
+            height: page.height,
+            success: page.success,
+            format: page.format,
+            buffer: page.success ? page.buffer : null,
+            avgColor: page.avgColor,
+            placeholder: page.placeholder,
+            variants: page.variants,
+            effectiveOptions: page.effectiveOptions,
+            aborted: page.aborted,
+            error: page.error,
+        }));
+    }
+
+    const renderedPages = outputResult.filter(p => p.success).length;
+    const failedPages = outputResult.length - renderedPages;
+
+    // 清单随图片一起写入输出目录或上传到 COS，Buffer 输出时只在结果中返回
+    let manifestOutput = {};
+    if (manifest) {
+        const content = buildManifest({ numPages: result.numPages, format: normalizedFormat, pages: outputResult });
+        manifestOutput = { manifest: content };
+        if (outputType === OutputType.FILE) {
+            const manifestPath = path.join(outputDir, `${prefix}_${MANIFEST_NAME}`);
+            await fs.promises.writeFile(manifestPath, JSON.stringify(content, null, 2));
+            manifestOutput.manifestPath = manifestPath;
+        } else if (outputType === OutputType.COS) {
+            manifestOutput.manifestKey = await uploadManifestToCos(content, cosConfig, cosKeyPrefix);
+        }
+    }
+
+    return {
+        // 全部页面失败时 success 为 false；部分失败时 partial 为 true，调用方无需逐页检查即可感知
+        success: !(renderedPages === 0 && failedPages > 0),
+        partial: renderedPages > 0 && failedPages > 0,
+        renderedPages,
+        failedPages,
+        pages: outputResult,
+        ...manifestOutput,
+    };
+}
+
+/**
+ * 批量转换多个 PDF
+ *
+ * 以有限并发依次转换每个文档，单个文档失败不影响其他文档。
+ * 所有文档共享同一个线程池，页面级并行度仍由线程数决定。
+ *
+ * @param {Array<string|Buffer|Object>} items - 文档列表，每项为输入本身，或 { input, ...options }（覆盖公共选项）
+ * @param {Object} [options] - 公共转换选项（同 convert）
+ * @param {number} [options.batchConcurrency=4] - 同时转换的文档数
+ * @returns {Promise<Object[]>} 与 items 顺序一致的结果，每项为
+ *   { index, success: true, result } 或 { index, success: false, error, code }
+ */
+export async function convertBatch(items, options = {}) {
+    if (!Array.isArray(items)) {
+        throw new Error('Invalid items: must be an array');
+    }
+
+    const { batchConcurrency = DEFAULT_CONCURRENCY.BATCH, ...commonOptions } = options;
+    const limit = pLimit(batchConcurrency);
+
+    return Promise.all(items.map((item, index) => limit(async () => {
+        const { input, ...itemOptions } = isBatchItem(item) ? item : { input: item };
+        try {
+            const result = await convert(input, { ...commonOptions, ...itemOptions });
+            return { index, success: true, result };
+        } catch (err) {
+            logger.warn(`Batch item ${index} failed: ${err.message}`);
+            return { index, success: false, error: err.message, code: err.code };
+        }
+    })));
+}
+
+/**
+ * 是否为带选项的批量项（{ input, ...options }），而不是输入本身
+ */
+function isBatchItem(item) {
+    return item !== null && typeof item === 'object' && !Buffer.isBuffer(item) && !(item instanceof Uint8Array);
+}
+
+/**
+ * 获取 PDF 页数与下载量
+ *
+ * URL 输入使用流式加载，只下载解析文档结构所需的数据块（交叉引用表、页面树等），
+ * 适合在请求图片前先获取页数构建分页。
+ *
+ * @param {string|Buffer} input - PDF 输入（文件路径、URL 或 Buffer）
+ * @param {Object} [options] - 选项（URL 输入时可包含 pickNetworkPolicy 的访问策略字段和 onRangeRequest）
+ * @param {boolean} [options.estimate] - 同时估算渲染全部页面的耗时（estimatedRenderMs），按 targetWidth、
+ *   exactWidth、maxScale 计算输出像素。需要读取前 16 页的尺寸，URL 输入会额外发送少量分片请求
+ * @returns {Promise<{ totalPages: number, fileSize: number, bytesDownloaded: number, estimatedRenderMs?: number }>}
+ *   bytesDownloaded 为实际下载的字节数，本地文件和 Buffer 为 0
+ */
+export async function countPages(input, options = {}) {
+    if (!nativeRenderer.isNativeAvailable()) {
+        throw new Error('Native renderer is not available');
+    }
+
+    const inputType = detectInputType(input);
+    let info;
+    let readPageSizes;
+
+    if (inputType === InputType.BUFFER) {
+        info = {
+            totalPages: nativeRenderer.getPageCount(input),
+            fileSize: input.length,
+            bytesDownloaded: 0,
+        };
+        readPageSizes = () => nativeRenderer.getPageSizes(input);
+    } else if (inputType === InputType.URL) {
+        const network = pickNetworkPolicy(options);
+        const { size: fileSize } = await getRemoteFileInfo(input, network);
+        const { numPages, streamStats } = await nativeRenderer.getPageCountFromStream(input, fileSize, { ...options, ...network });
+        info = {
+            totalPages: numPages,
+            fileSize,
+            bytesDownloaded: streamStats?.totalBytesFetched ?? 0,
+        };
+        readPageSizes = () => nativeRenderer.getPageSizesFromStream(input, fileSize, undefined, { ...options, ...network });
+    } else {
+        let stat;
         try {
             await fs.promises.access(input, fs.constants.R_OK);
+            stat = await fs.promises.stat(input);
         } catch {
             throw new Error(`File not found or not readable: ${input}`);
         }
-        return nativeRenderer.getPageCountFromFile(input);
+        info = {
+            totalPages: nativeRenderer.getPageCountFromFile(input),
+            fileSize: stat.size,
+            bytesDownloaded: 0,
+        };
+        readPageSizes = () => nativeRenderer.getPageSizesFromFile(input);
+    }
+
+    if (options.estimate) {
+        const render = normalizeRenderOptions(options);
+        const { pages: pageSizes } = await readPageSizes();
+        info.estimatedRenderMs = estimateRenderMs({
+            numPages: info.totalPages,
+            pageSizes,
+            msPerMegapixel: await renderRate.get(),
+            threads: threadCount,
+            render: {
+                targetWidth: render.targetWidth ?? RENDER_CONFIG.TARGET_RENDER_WIDTH,
+                maxScale: render.maxScale,
+                exactWidth: render.exactWidth,
+            },
+        });
+    }
+
+    return info;
+}
+
+/**
+ * 获取 PDF 页数（异步版本）
+ *
+ * URL 输入使用流式加载，只下载解析页数所需的数据块。
+ *
+ * @param {string|Buffer} input - PDF 输入（文件路径、URL 或 Buffer）
+ * @param {Object} [options] - 选项（URL 输入时可包含 pickNetworkPolicy 的访问策略字段）
+ * @returns {Promise<number>} 页数
+ */
+export async function getPageCount(input, options = {}) {
+    const { totalPages } = await countPages(input, options);
+    return totalPages;
+}
+
+/**
+ * 渲染为单个多页 TIFF
+ *
+ * 每页在工作线程中渲染为原始位图，主线程按页堆叠后由 Sharp 一次写出多页 TIFF。
+ * TIFF 各页尺寸必须一致，尺寸不同的页面会以白色补齐到最大宽高（内容位于左上角）。
+ * 所有页面的位图需要同时驻留内存，页数超过 PDF2IMG_MAX_TIFF_PAGES（与 maxPages 取较小值）时
+ * 在渲染前抛出 code 为 ERR_TOO_MANY_PAGES 的错误。
+ *
+ * @param {string|Buffer} input - PDF 输入（文件路径、URL 或 Buffer）
+ * @param {Object} [options] - 选项
+ * @param {number[]|string} [options.pages] - 要渲染的页码（同 convert，包括 PDF2IMG_DEFAULT_PAGES 与 'all'）
+ * @param {number} [options.targetWidth] - 目标渲染宽度
+ * @param {string} [options.compression='lzw'] - 压缩方式：none、lzw、deflate、packbits、jpeg、ccittfax4
+ * @param {boolean} [options.grayscale=false] - 输出灰度图（ccittfax4 自动使用 1 位黑白）
+ * @param {number} [options.quality] - JPEG 压缩时的质量
+ * @returns {Promise<Object>} { buffer, numPages, pageCount, width, height }
+ */
+export async function renderMultiPageTiff(input, options = {}) {
+    const {
+        pages: requestedPages,
+        compression = 'lzw',
+        grayscale = false,
+        quality,
+        ...renderOptions
+    } = options;
+
+    const pages = applyDefaultPages(requestedPages, RENDER_CONFIG.DEFAULT_PAGES);
+
+    if (!TIFF_COMPRESSIONS.includes(compression)) {
+        throw new Error(`Unsupported TIFF compression: ${compression}. Supported: ${TIFF_COMPRESSIONS.join(', ')}`);
+    }
+
+    if (!nativeRenderer.isNativeAvailable()) {
+        throw new Error('Native renderer is not available. Please ensure PDFium library is installed.');
+    }
+
+    const tiffLimit = RENDER_CONFIG.MAX_TIFF_PAGES;
+    const maxPages = renderOptions.maxPages ?? RENDER_CONFIG.MAX_PAGES;
+    const encodeOptions = buildEncodeOptions('raw', {
+        ...renderOptions,
+        maxPages: maxPages > 0 && tiffLimit > 0 ? Math.min(maxPages, tiffLimit) : maxPages || tiffLimit,
+    });
+    const result = await renderPages(input, detectInputType(input), pages, encodeOptions, {
+        network: pickNetworkPolicy(options),
+    });
+
+    const failed = result.pages.find(page => !page.success);
+    if (failed) {
+        throw new Error(`Failed to render page ${failed.pageNum}: ${failed.error}`);
+    }
+    if (result.pages.length === 0) {
+        throw new Error('No pages to render');
+    }
+
+    // 补齐到统一尺寸后逐页复制到同一块缓冲区纵向堆叠（每页复制后即释放原位图，
+    // 峰值内存约为全部页面位图的一份，而不是拼接时的两份），pageHeight 告诉 libvips 按页拆分
+    const width = Math.max(...result.pages.map(page => page.width));
+    const height = Math.max(...result.pages.map(page => page.height));
+    const pageCount = result.pages.length;
+    const frameSize = width * height * 4;
+    const stacked = Buffer.allocUnsafe(frameSize * pageCount);
+    for (const [i, page] of result.pages.entries()) {
+        const frame = page.width === width && page.height === height
+            ? page.buffer
+            : await sharp(page.buffer, { raw: { width: page.width, height: page.height, channels: 4 } })
+                .extend({
+                    right: width - page.width,
+                    bottom: height - page.height,
+                    background: { r: 255, g: 255, b: 255, alpha: 1 },
+                })
+                .raw()
+                .toBuffer();
+        frame.copy(stacked, i * frameSize);
+        page.buffer = null;
+    }
+
+    // 堆叠后的总像素数可能超过 Sharp 默认的输入上限（约 2.68 亿像素，A4 默认宽度下约 115 页），
+    // 页数已由上面的上限约束，这里按实际大小放开
+    const bilevel = compression === 'ccittfax4';
+    let image = sharp(stacked, {
+        raw: { width, height: height * pageCount, channels: 4, pageHeight: height },
+        limitInputPixels: width * height * pageCount,
+    }).flatten({ background: { r: 255, g: 255, b: 255 } });
+
+    if (grayscale || bilevel) {
+        image = image.toColourspace('b-w');
+    }
+
+    const buffer = await image.tiff({
+        compression,
+        quality,
+        bitdepth: bilevel ? 1 : 8,
+    }).toBuffer();
+
+    return {
+        buffer,
+        numPages: result.numPages,
+        pageCount,
+        width,
+        height,
+    };
+}
+
+/**
+ * 默认缩略图宽度（像素）
+ */
+const DEFAULT_SPRITE_THUMB_WIDTH = 160;
+
+/**
+ * 默认精灵图最大宽度（像素）
+ */
+const DEFAULT_SPRITE_MAX_WIDTH = 2048;
+
+/**
+ * 将多个页面渲染为缩略图并合并成一张精灵图
+ *
+ * 缩略图宽度固定为 thumbWidth，按页面顺序逐行排列（货架布局），每行不超过 maxWidth。
+ * 返回的 pages 为每页在精灵图中的位置，客户端按坐标裁剪显示即可，一次请求得到所有缩略图。
+ *
+ * @param {string|Buffer} input - PDF 输入（文件路径、URL 或 Buffer）
+ * @param {Object} [options] - 选项（URL 输入时可包含 pickNetworkPolicy 的访问策略字段）
+ * @param {number[]|string} [options.pages] - 要渲染的页码（同 convert，包括 PDF2IMG_DEFAULT_PAGES 与 'all'）
+ * @param {number} [options.thumbWidth=160] - 缩略图宽度（像素）
+ * @param {number} [options.maxWidth=2048] - 精灵图最大宽度（像素）
+ * @param {number} [options.gap=0] - 缩略图之间的间距（像素）
+ * @param {string} [options.format='webp'] - 输出格式：webp、png、jpg
+ * @param {number} [options.quality] - WebP/JPEG 质量（默认 80/85）
+ * @param {AbortSignal} [options.signal] - 取消信号
+ * @returns {Promise<Object>} { buffer, format, width, height, numPages, pages }，
+ *   pages 为 [{ pageNum, x, y, width, height }]（像素，左上角为原点）
+ */
+export async function renderSprite(input, options = {}) {
+    const {
+        pages: requestedPages,
+        thumbWidth = DEFAULT_SPRITE_THUMB_WIDTH,
+        maxWidth = DEFAULT_SPRITE_MAX_WIDTH,
+        gap = 0,
+        format = 'webp',
+        quality,
+        signal,
+    } = options;
+
+    const pages = applyDefaultPages(requestedPages, RENDER_CONFIG.DEFAULT_PAGES);
+
+    if (!SUPPORTED_FORMATS.includes(format)) {
+        throw new Error(`Unsupported format: ${format}. Supported: ${SUPPORTED_FORMATS.join(', ')}`);
+    }
+    if (!Number.isInteger(maxWidth) || maxWidth <= 0) {
+        throw new Error('Invalid maxWidth: must be a positive integer');
+    }
+    if (!Number.isInteger(gap) || gap < 0) {
+        throw new Error('Invalid gap: must be a non-negative integer');
+    }
+
+    if (!nativeRenderer.isNativeAvailable()) {
+        throw new Error('Native renderer is not available. Please ensure PDFium library is installed.');
+    }
+
+    const encodeOptions = buildEncodeOptions('raw', { exactWidth: thumbWidth });
+    const result = await renderPages(input, detectInputType(input), pages, encodeOptions, {
+        network: pickNetworkPolicy(options),
+        signal,
+    });
+    signal?.throwIfAborted();
+
+    const failed = result.pages.find(page => !page.success);
+    if (failed) {
+        throw new Error(`Failed to render page ${failed.pageNum}: ${failed.error}`);
+    }
+    if (result.pages.length === 0) {
+        throw new Error('No pages to render');
+    }
+
+    const layout = packShelves(result.pages, Math.max(maxWidth, thumbWidth), gap);
+    const sprite = sharp({
+        create: {
+            width: layout.width,
+            height: layout.height,
+            channels: 4,
+            background: { r: 255, g: 255, b: 255, alpha: 0 },
+        },
+    }).composite(result.pages.map((page, i) => ({
+        input: page.buffer,
+        raw: { width: page.width, height: page.height, channels: 4 },
+        left: layout.rects[i].x,
+        top: layout.rects[i].y,
+    })));
+
+    const { buffer } = await encodeImage(sprite, format, { quality });
+
+    return {
+        buffer,
+        format,
+        width: layout.width,
+        height: layout.height,
+        numPages: result.numPages,
+        pages: result.pages.map((page, i) => ({ pageNum: page.pageNum, ...layout.rects[i] })),
+    };
+}
+
+/**
+ * 获取 PDF 书签（目录）
+ *
+ * URL 输入使用流式加载，只下载书签所在的数据块。
+ *
+ * @param {string|Buffer} input - PDF 输入（文件路径、URL 或 Buffer）
+ * @param {Object} [options] - 选项（URL 输入时可包含 pickNetworkPolicy 的访问策略字段）
+ * @returns {Promise<Object[]>} 顶层书签列表 [{ title, pageNum, children }]，没有书签时为空数组
+ */
+export async function getOutline(input, options = {}) {
+    if (!nativeRenderer.isNativeAvailable()) {
+        throw new Error('Native renderer is not available');
+    }
+
+    const inputType = detectInputType(input);
+
+    if (inputType === InputType.BUFFER) {
+        return nativeRenderer.getOutline(input);
+    }
+
+    if (inputType === InputType.URL) {
+        const network = pickNetworkPolicy(options);
+        const { size: fileSize } = await getRemoteFileInfo(input, network);
+        return nativeRenderer.getOutlineFromStream(input, fileSize, { ...options, ...network });
+    }
+
+    try {
+        await fs.promises.access(input, fs.constants.R_OK);
+    } catch {
+        throw new Error(`File not found or not readable: ${input}`);
+    }
+    return nativeRenderer.getOutlineFromFile(input);
+}
+
+/**
+ * 命名目标对应的裁剪区域：从目标位置裁剪到页面底部
+ *
+ * 目标未指定纵坐标或位于页面顶端时不裁剪。
+ *
+ * @param {Object} destination - 命名目标 { y, pageHeight }
+ * @returns {Object|undefined} 裁剪区域（页面比例）
+ */
+function destinationClip({ y, pageHeight }) {
+    if (typeof y !== 'number' || !(pageHeight > 0)) {
+        return undefined;
+    }
+    // PDF 坐标原点在左下角，目标下方的高度即裁剪高度
+    const height = Math.min(Math.max(y / pageHeight, 0), 1);
+    if (height === 0 || height === 1) {
+        return undefined;
+    }
+    return { x: 0, y: 1 - height, width: 1, height };
+}
+
+/**
+ * 渲染命名目标所在的页面
+ *
+ * 命名目标是文档中按名称定义的跳转目标（如 'chapter3'），指向某一页及页内位置。
+ * URL 输入先完整下载到临时文件，查找目标和渲染共用同一份数据。
+ *
+ * @param {string|Buffer} input - PDF 输入（文件路径、URL 或 Buffer）
+ * @param {string} name - 目标名称
+ * @param {Object} [options] - 转换选项（同 convert，pages 和 clip 被忽略）
+ * @param {boolean} [options.clipToDestination=false] - 从目标位置裁剪到页面底部（目标未指定纵坐标时输出整页）
+ * @returns {Promise<Object>} convert 的结果，另含 destination { name, pageNum, x, y, pageWidth, pageHeight }
+ * @throws {Error} 名称不存在时抛出，err.code 为 'ERR_DEST_NOT_FOUND'
+ */
+export async function renderNamedDestination(input, name, options = {}) {
+    if (!nativeRenderer.isNativeAvailable()) {
+        throw new Error('Native renderer is not available');
+    }
+    if (typeof name !== 'string' || name === '') {
+        throw new Error('Invalid name: must be a non-empty string');
+    }
+
+    // pages 和 clip 由命名目标决定
+    const { clipToDestination = false, pages, clip, ...convertOptions } = options;
+    const inputType = detectInputType(input);
+    let source = input;
+    let tempFile = null;
+
+    if (inputType === InputType.URL) {
+        const network = pickNetworkPolicy(options);
+        const { size: fileSize } = await getRemoteFileInfo(input, network, options.signal);
+        tempFile = await downloadWithRetry(input, network, fileSize, { signal: options.signal });
+        source = tempFile;
+    } else if (inputType === InputType.FILE) {
+        try {
+            await fs.promises.access(input, fs.constants.R_OK);
+        } catch {
+            throw new Error(`File not found or not readable: ${input}`);
+        }
+    } else {
+        source = Buffer.isBuffer(input) ? input : Buffer.from(input);
+    }
+
+    try {
+        const destination = typeof source === 'string'
+            ? nativeRenderer.getNamedDestinationFromFile(source, name)
+            : nativeRenderer.getNamedDestination(source, name);
+        if (!destination) {
+            const err = new Error(`Named destination not found: ${name}`);
+            err.code = 'ERR_DEST_NOT_FOUND';
+            throw err;
+        }
+
+        const destClip = clipToDestination ? destinationClip(destination) : undefined;
+        const result = await convert(source, {
+            ...convertOptions,
+            pages: [destination.pageNum],
+            ...(destClip && { clip: destClip }),
+        });
+        return { ...result, destination: { name, ...destination } };
+    } finally {
+        if (tempFile) {
+            await tempStore.release(tempFile);
+        }
     }
-    
-    throw new Error('Invalid input: must be a file path or Buffer');
+}
+
+/**
+ * 提取页面内嵌图片（照片、扫描图等），而不是渲染整页
+ *
+ * 返回图片对象自身的像素（原始分辨率），按 format 编码；没有图片的页面返回空数组。
+ * URL 输入使用流式加载，只下载文档结构和该页面引用的数据块。
+ *
+ * @param {string|Buffer} input - PDF 输入（文件路径、URL 或 Buffer）
+ * @param {number} pageNum - 页码（从 1 开始）
+ * @param {Object} [options] - 选项（URL 输入时可包含 pickNetworkPolicy 的访问策略字段）
+ * @param {string} [options.format='png'] - 输出格式：webp、png、jpg
+ * @param {number} [options.quality] - WebP/JPEG 质量（默认 80/85）
+ * @param {boolean} [options.grayscale=false] - 输出灰度图
+ * @returns {Promise<Object[]>} [{ index, width, height, format, buffer, bounds }]，
+ *   bounds 为图片在页面中的位置 { x, y, width, height }（点，左上角为原点）
+ */
+export async function extractImages(input, pageNum, options = {}) {
+    if (!nativeRenderer.isNativeAvailable()) {
+        throw new Error('Native renderer is not available');
+    }
+    if (!Number.isInteger(pageNum) || pageNum < 1) {
+        throw new Error(`Invalid pageNum: ${pageNum}. Must be a positive integer`);
+    }
+
+    const format = normalizeFormat(options.format ?? 'png');
+    const inputType = detectInputType(input);
+
+    let images;
+    if (inputType === InputType.BUFFER) {
+        images = nativeRenderer.extractImages(input, pageNum);
+    } else if (inputType === InputType.URL) {
+        const network = pickNetworkPolicy(options);
+        const { size: fileSize } = await getRemoteFileInfo(input, network);
+        images = await nativeRenderer.extractImagesFromStream(input, fileSize, pageNum, { ...options, ...network });
+    } else {
+        try {
+            await fs.promises.access(input, fs.constants.R_OK);
+        } catch {
+            throw new Error(`File not found or not readable: ${input}`);
+        }
+        images = nativeRenderer.extractImagesFromFile(input, pageNum);
+    }
+
+    return Promise.all(images.map(async image => {
+        const { buffer } = await encodeImage(
+            sharp(image.buffer, { raw: { width: image.width, height: image.height, channels: 4 } }),
+            format,
+            { quality: options.quality, grayscale: options.grayscale }
+        );
+        return {
+            index: image.index,
+            width: image.width,
+            height: image.height,
+            format,
+            buffer,
+            bounds: image.bounds,
+        };
+    }));
 }
 
 /**
@@ -505,16 +2291,14 @@ export function getPageCountSync(input) {
         throw new Error('Native renderer is not available');
     }
 
-    let buffer;
     if (Buffer.isBuffer(input)) {
-        buffer = input;
-    } else if (typeof input === 'string' && fs.existsSync(input)) {
-        buffer = fs.readFileSync(input);
-    } else {
-        throw new Error('Invalid input: must be a file path or Buffer');
+        return nativeRenderer.getPageCount(input);
     }
-
-    return nativeRenderer.getPageCount(buffer);
+    // 文件路径交给 PDFium 按需读取，不把整个文件读入内存
+    if (typeof input === 'string' && fs.existsSync(input)) {
+        return nativeRenderer.getPageCountFromFile(input);
+    }
+    throw new Error('Invalid input: must be a file path or Buffer');
 }
 
 /**
@@ -533,17 +2317,26 @@ export function getVersion() {
 
 /**
  * 获取线程池统计信息
+ *
+ * active/idle/queued 为正在渲染、空闲的线程数和排队的页面数；所有线程持续忙碌超过
+ * PDF2IMG_POOL_DEGRADED_AFTER 时 status 为 'degraded'，可用于健康检查。
+ * recycled 为达到回收条件后换用新线程池的次数，completed 只统计当前线程池。
  */
 export function getThreadPoolStats() {
+    const load = poolMonitor.getStats();
     if (!piscina) {
         return {
             initialized: false,
             workers: threadCount,
+            recycled: recycledPools,
+            ...load,
         };
     }
     return {
         initialized: true,
         workers: threadCount,
+        recycled: recycledPools,
+        ...load,
         completed: piscina.completed,
         waitTime: piscina.waitTime,
         runTime: piscina.runTime,
@@ -557,6 +2350,10 @@ export function getThreadPoolStats() {
  * 在应用关闭时调用，释放工作线程资源
  */
 export async function destroyThreadPool() {
+    // 仍有转换在使用的旧线程池一并销毁
+    const retired = [...poolUsers.keys()].filter(pool => pool !== piscina);
+    poolUsers.clear();
+    await Promise.all(retired.map(pool => pool.destroy()));
     if (piscina) {
         await piscina.destroy();
         piscina = null;
@@ -5,9 +5,14 @@
 export interface RenderOptions {
     /** 目标渲染宽度（像素），默认：1280 */
     targetWidth?: number;
+    /**
+     * 精确输出宽度（像素）。每页按自身尺寸计算缩放比例，输出宽度恰好为该值、高度按比例；
+     * 不受 maxScale 限制且不做扫描件降级，设置后忽略 targetWidth。仍受 PDF2IMG_MAX_DPI 上限约束
+     */
+    exactWidth?: number;
     /** 图片密集型页面的目标宽度（像素），默认：1024 */
     imageHeavyWidth?: number;
-    /** 最大渲染缩放比例，默认：4.0 */
+    /** 最大渲染缩放比例，默认：4.0；超过 PDF2IMG_MAX_DPI / 72 时截断 */
     maxScale?: number;
     /** WebP 质量 0-100，默认：70 */
     webpQuality?: number;
@@ -15,6 +20,39 @@ export interface RenderOptions {
     detectScan?: boolean;
 }
 
+export interface ClipRect {
+    /** 左上角横坐标（页面宽度比例 0-1） */
+    x: number;
+    /** 左上角纵坐标（页面高度比例 0-1） */
+    y: number;
+    /** 区域宽度（页面宽度比例 0-1） */
+    width: number;
+    /** 区域高度（页面高度比例 0-1） */
+    height: number;
+}
+
+/** 水印位置：center（居中）、tile（平铺整页）或四角 */
+export type WatermarkPosition = 'center' | 'tile' | 'top-left' | 'top-right' | 'bottom-left' | 'bottom-right';
+
+export interface Watermark {
+    /** 文字水印（与 image 二选一） */
+    text?: string;
+    /** 图片水印（PNG/JPEG/WebP 等 Buffer，与 text 二选一） */
+    image?: Buffer;
+    /** 不透明度 0-1，默认：0.3 */
+    opacity?: number;
+    /** 位置，默认：'center' */
+    position?: WatermarkPosition;
+    /** 文字字号（像素），默认：输出宽度的 1/12（平铺时 1/24） */
+    fontSize?: number;
+    /** 文字颜色，默认：'#888888' */
+    color?: string;
+    /** 文字逆时针旋转角度（度），默认：居中和平铺为 30，四角为 0 */
+    angle?: number;
+    /** 图片水印宽度（输出宽度的比例 0-1），默认：0.3 */
+    width?: number;
+}
+
 export interface CosConfig {
     /** 腾讯云 SecretId */
     secretId: string;
@@ -26,9 +64,66 @@ export interface CosConfig {
     region: string;
 }
 
+/** 按页覆盖的渲染/编码选项 */
+export interface PageRenderOptions {
+    /** 目标渲染宽度（像素） */
+    targetWidth?: number;
+    /** 精确输出宽度（像素） */
+    exactWidth?: number;
+    /** 输出格式；'auto' 按页面内容选择，文字/线稿页面为 PNG，照片类页面为 WebP */
+    format?: 'webp' | 'png' | 'jpg' | 'jpeg' | 'auto';
+    /** 图片质量 0-100 */
+    quality?: number;
+    /** WebP 编码配置 */
+    webp?: { quality?: number; method?: number };
+    /** JPEG 编码配置 */
+    jpeg?: { quality?: number };
+    /** PNG 编码配置 */
+    png?: { compressionLevel?: number };
+    /** 裁剪区域 */
+    clip?: ClipRect;
+    /** 输出大小上限（字节） */
+    maxBytes?: number;
+    /** 编码前的后处理 */
+    postProcess?: PostProcessFilter[];
+    /** 额外旋转角度 */
+    rotate?: number;
+}
+
+/** 内置后处理：sharpen（轻度锐化）、autocontrast（拉伸对比度）、denoise（3x3 中值滤波去噪） */
+export type PostProcessFilter = 'sharpen' | 'autocontrast' | 'denoise';
+
+/** OpenTelemetry Span 接口的子集 */
+export interface TraceSpan {
+    setAttribute(key: string, value: string | number | boolean): unknown;
+    setAttributes(attributes: Record<string, string | number | boolean>): unknown;
+    recordException(exception: Error): void;
+    setStatus(status: { code: number; message?: string }): unknown;
+    end(): void;
+}
+
+/**
+ * OpenTelemetry Tracer 接口的子集，可直接传入 trace.getTracer() 的返回值。
+ * 优先使用 startActiveSpan，使子 span 自动关联到父 span
+ */
+export interface Tracer {
+    startSpan(name: string, options?: { attributes?: Record<string, string | number | boolean> }): TraceSpan;
+    startActiveSpan?<T>(
+        name: string,
+        options: { attributes?: Record<string, string | number | boolean> },
+        fn: (span: TraceSpan) => T
+    ): T;
+}
+
 export interface ConvertOptions extends RenderOptions {
-    /** 要转换的页码（1-based），空数组表示全部页面 */
-    pages?: number[];
+    /**
+     * 要转换的页码（1-based），负数从末尾倒数（-1 为最后一页），空数组表示全部页面。
+     * 超出范围的页码被忽略；全部超出范围时抛出 code 为 'ERR_PAGE_OUT_OF_RANGE' 的错误
+     * 文档没有页面时抛出 code 为 'ERR_NO_PAGES' 的错误。
+     * 重复的页码（包括负数换算后重复的）只渲染、输出一次，结果出现在每个请求位置。
+     * 设置 PDF2IMG_DEFAULT_PAGES 时，未指定或空数组只渲染前 N 页，'all' 表示全部页面
+     */
+    pages?: number[] | 'all';
     /** 输出类型：'file'、'buffer' 或 'cos' */
     outputType?: 'file' | 'buffer' | 'cos';
     /** 输出目录（outputType 为 'file' 时必需） */
@@ -39,6 +134,164 @@ export interface ConvertOptions extends RenderOptions {
     cos?: CosConfig;
     /** COS key 前缀 */
     cosKeyPrefix?: string;
+    /** 允许访问的远程主机白名单（支持 *.example.com），默认取 PDF2IMG_ALLOWED_HOSTS */
+    allowedHosts?: string[];
+    /** 是否拦截内网/回环/链路本地地址，默认取 PDF2IMG_BLOCK_PRIVATE_NETWORK */
+    blockPrivateNetwork?: boolean;
+    /**
+     * 只在结果的 pages 中保留这些字段（pageNum 始终保留），如 ['cosKey', 'width', 'height']，
+     * 减小结果体积（如不需要 buffer 时）。不影响 onPage 回调
+     */
+    fields?: Array<keyof PageResult>;
+    /**
+     * 只返回尺寸、不渲染的页码（同 pages，空数组表示全部）。已在 pages 中渲染的页面不重复返回，
+     * 两类页面在结果的 pages 中按页码排列，只含尺寸的页面 metadataOnly 为 true、没有图片数据。
+     * 尺寸按页面大小与 targetWidth/maxScale/exactWidth、rotate 估算，适合按比例预留滚动区域
+     */
+    metadataPages?: number[];
+    /**
+     * 生成输出清单（每页的页码、尺寸、格式、大小、文件名或 COS key）。file 输出写入
+     * outputDir/{prefix}_manifest.json，cos 输出上传到 {cosKeyPrefix}/manifest.json，均在结果的 manifest 中返回。默认：false
+     */
+    manifest?: boolean;
+    /**
+     * 按需加载时的缓存块大小（字节），即单次分片请求的最小粒度。必须是 2 的幂且在 16KB-4MB 之间，
+     * 默认取 PDF2IMG_STREAM_BLOCK_SIZE（256KB）。只影响下载方式，不影响输出
+     */
+    blockSize?: number;
+    /**
+     * 按需加载前发送 3 个 Range 请求（2 个 16KB、1 个 1MB）校准源站的延迟与吞吐，
+     * 以带宽时延积自动选择 blockSize。显式设置 blockSize 或文件小于 2MB 时不校准。默认：false
+     */
+    autoTune?: boolean;
+    /**
+     * 远程文件大小上限（字节），默认取 PDF2IMG_MAX_FILE_SIZE。HEAD 返回的大小超过上限时
+     * 在下载任何数据前抛出 err.code 为 'ERR_FILE_TOO_LARGE' 的错误（服务端可映射为 413）；
+     * 下载过程中实际数据超过上限时同样立即中止
+     */
+    maxFileSize?: number;
+    /**
+     * 请求签名钩子，在每个远程请求（HEAD、下载、分片请求）发出前调用，直接修改 headers 添加签名。
+     * 分片请求的 Range 各不相同，每个请求都会单独调用；跳转到其他源后不再调用。
+     * 访问私有 S3 可使用 createSigV4Signer()
+     */
+    signRequest?: SignRequest;
+    /**
+     * 远程请求使用的 undici Dispatcher（如 new Agent({ allowH2: true })），
+     * 用于调整连接池、keep-alive 或按源站要求启用 HTTP/2。默认使用 fetch 的全局连接池（HTTP/1.1 keep-alive）。
+     * 开启 blockPrivateNetwork 时需要配置 connect: { lookup: privateNetworkLookup } 才能在连接时校验地址
+     */
+    dispatcher?: Dispatcher;
+    /**
+     * 确定性输出：相同输入产生逐字节相同的图片，便于内容寻址缓存和图片比对。
+     * PNG 与 WebP（改为无损编码）可以保证；JPEG 不保证。默认：false
+     */
+    deterministic?: boolean;
+    /**
+     * 按页码覆盖渲染/编码选项（如封面高清、其余缩略图），未指定的页面和字段使用全局选项。
+     * 所有页面共享同一次文档加载
+     */
+    pageOptions?: Record<number, PageRenderOptions>;
+    /**
+     * 单次转换最大页数，解析页码后超过上限时抛出 code 为 'ERR_TOO_MANY_PAGES' 的错误。
+     * 默认取 PDF2IMG_MAX_PAGES，0 表示不限制
+     */
+    maxPages?: number;
+    /**
+     * 单页渲染超时（毫秒），从工作线程开始处理该页时计时（排队时间不计入）。
+     * 超时页面记为失败，执行该页的工作线程会被替换；PDFium 原生调用无法中途打断，
+     * 被放弃的线程在原生调用返回后才退出。默认取 RENDER_TIMEOUT，0 表示不限制
+     */
+    pageTimeout?: number;
+    /**
+     * 逐页回调：每页渲染完成后立即调用（按完成顺序，早于文件写入/上传），
+     * 可用于在后续页面仍在渲染时先展示已完成的页面。返回 Promise 时等待其完成
+     */
+    onPage?: (page: PageResult) => void | Promise<void>;
+    /**
+     * 完整下载远程文件时每收到一个数据块调用（bytes 为已下载字节数，total 为 HEAD 返回的文件大小）。
+     * 线性化文件按需加载时不调用
+     */
+    onDownloadProgress?: (progress: { bytes: number; total: number | null }) => void;
+    /**
+     * 正在渲染和已渲染但 onPage 尚未完成的页面总数上限（默认不限制）。
+     * onPage 处理较慢时暂停渲染，避免已完成的页面在内存中堆积
+     */
+    maxPagesInFlight?: number;
+    /**
+     * 编码前按顺序应用的内置后处理（裁剪之后），适合扫描件的锐化/去噪。
+     * 后处理在工作线程中执行，不支持传入自定义函数
+     */
+    postProcess?: PostProcessFilter[];
+    /**
+     * 在页面自身的旋转（/Rotate）之上额外顺时针旋转的角度，必须是 90 的整数倍，负数为逆时针。
+     * 在裁剪之后应用，90/270 度时返回的宽高互换
+     */
+    rotate?: number;
+    /**
+     * 渐进式编码：JPEG 输出为渐进式，PNG 输出为 Adam7 隔行扫描，慢速网络下图片可以逐步显示。
+     * 线性化 URL 按需加载时原生渲染器输出基线 JPEG，设置后改为完整下载并在工作线程编码。
+     * WebP 不支持，设置后忽略。默认：false
+     */
+    progressive?: boolean;
+    /**
+     * 水印：编码前叠加到每页（裁剪、旋转之后），支持文字或图片，
+     * 文字水印居中和平铺时默认斜向
+     */
+    watermark?: Watermark;
+    /**
+     * 跳过空白页：渲染后像素标准差不超过 blankThreshold 的页面不编码、不输出，也不触发 onPage，
+     * 页码记录在 ConvertResult.skippedPages 中。默认：false
+     */
+    skipBlankPages?: boolean;
+    /** 空白页判定阈值（RGB 各通道像素标准差），默认取 PDF2IMG_BLANK_PAGE_THRESHOLD（3） */
+    blankThreshold?: number;
+    /**
+     * 单页输出大小上限（字节，仅 webp/jpg），超出时二分查找不超过上限的最高质量；
+     * 最低质量仍超出时返回最低质量的结果。deterministic 模式的 WebP 为无损编码，不受此选项影响
+     */
+    maxBytes?: number;
+    /**
+     * 取消信号（如收到 SIGTERM 时）。开始前已取消则抛出异常；完整下载远程文件时取消立即中止下载、
+     * 删除已下载的部分并抛出异常；渲染中取消则在页面边界停止：
+     * 已在渲染的页面正常完成，其余页面记为失败，结果中 aborted 为 true。
+     * 线性化 URL 的按需加载渲染在原生渲染器中一次完成，无法中途停止
+     */
+    signal?: AbortSignal;
+    /** 计算每页平均颜色（PageResult.avgColor），可用作图片加载前的占位背景色，默认：false */
+    includePageColor?: boolean;
+    /**
+     * 生成每页低分辨率模糊占位图（PageResult.placeholder）：由渲染结果缩小到 16 像素宽并模糊，
+     * 编码为 WebP data URI，可在完整图片加载前内联显示，默认：false
+     */
+    includePlaceholder?: boolean;
+    /**
+     * 裁剪区域：只输出页面的一部分（如深度缩放的瓦片）。
+     * 只光栅化该区域，targetWidth/exactWidth 作用于区域宽度，瓦片可比整页更清晰
+     */
+    clip?: ClipRect;
+    /**
+     * 每页按这些 DPI 各输出一张（如 [150, 300]），用于构建 srcset，只支持 outputType 'buffer'。
+     * 项也可以是 { dpi, quality }，为该档单独指定质量，如 [{ dpi: 150, quality: 60 }, { dpi: 300, quality: 85 }]。
+     * 每页只下载、渲染一次（按最高 DPI），较低的 DPI 由同一位图缩小得到，结果见 PageResult.variants。
+     * 超过 PDF2IMG_MAX_DPI 的值截断到上限；设置后忽略 targetWidth、exactWidth、maxScale
+     */
+    dpis?: Array<number | DpiTier>;
+    /**
+     * OpenTelemetry 兼容的 tracer，产生 pdf2img.convert（根）、pdf2img.download、pdf2img.stream_render、
+     * pdf2img.open、pdf2img.render_page 等 span，属性包括文件大小、下载字节数、页数和格式
+     */
+    tracer?: Tracer;
+    /**
+     * 追踪上下文注入钩子，在 pdf2img.convert span 内调用一次，注入的请求头（如 traceparent）
+     * 附加到本次转换的所有出站请求，例如 headers => propagation.inject(context.active(), headers)
+     */
+    injectTraceContext?: (headers: Record<string, string>) => void;
+    /**
+     * 渲染缓存，key 由文档标识（URL + ETag/Last-Modified、文件路径 + 修改时间、Buffer 内容摘要）、
+     * 页码和编码参数计算。所有目标页面命中时跳过下载和渲染（URL 仍会发送一次 HEAD 请求）
+     */
+    cache?: RenderCacheStore;
 }
 
 export interface PageResult {
@@ -50,6 +303,8 @@ export interface PageResult {
     height: number;
     /** 是否成功渲染 */
     success: boolean;
+    /** 图片格式（成功时；使用 pageOptions 或 format 为 'auto' 时各页可能不同） */
+    format?: string;
     /** 图片 Buffer（outputType 为 'buffer' 时） */
     buffer?: Buffer;
     /** 输出文件路径（outputType 为 'file' 时） */
@@ -58,19 +313,174 @@ export interface PageResult {
     cosKey?: string;
     /** 图片大小（字节） */
     size?: number;
+    /** 因取消信号未渲染（signal 触发后尚未开始的页面） */
+    aborted?: boolean;
+    /** 只含尺寸、未渲染的页面（metadataPages） */
+    metadataOnly?: boolean;
+    /** 页面平均颜色，如 '#fafafa'（includePageColor 为 true 时） */
+    avgColor?: string;
+    /** 低分辨率模糊占位图 data URI，如 'data:image/webp;base64,...'（includePlaceholder 为 true 时） */
+    placeholder?: string;
+    /**
+     * 各 DPI 的输出，按 dpis 的顺序（设置 dpis 时）。
+     * 页面本身的 buffer/width/height 与其中 DPI 最高的一项相同
+     */
+    variants?: DpiVariant[];
+    /** 实际生效的渲染参数（成功时） */
+    effectiveOptions?: EffectiveOptions;
+    /** 错误信息（失败时） */
+    error?: string;
+}
+
+/** 带质量的 DPI 档位（ConvertOptions.dpis） */
+export interface DpiTier {
+    /** DPI */
+    dpi: number;
+    /** 该档的 WebP/JPEG 质量（1-100），覆盖 quality、webp.quality、jpeg.quality */
+    quality?: number;
+}
+
+/** 页面在某个 DPI 下的输出（ConvertOptions.dpis） */
+export interface DpiVariant {
+    /** 实际输出的 DPI（渲染受宽度上限约束时可能低于请求值） */
+    dpi: number;
+    /** 图片宽度（像素） */
+    width: number;
+    /** 图片高度（像素） */
+    height: number;
+    /** 图片 Buffer */
+    buffer: Buffer;
+    /** 图片大小（字节） */
+    size: number;
+    /** 实际使用的编码质量（PNG、无损 WebP 为 null） */
+    quality: number | null;
+}
+
+/** 输出清单中的页面 */
+export interface ManifestPage {
+    pageNum: number;
+    success: boolean;
+    width: number;
+    height: number;
+    /** 实际输出格式（成功时） */
+    format?: string;
+    /** 图片大小（字节） */
+    size?: number;
+    /** 文件名，相对清单所在目录（file 输出） */
+    file?: string;
+    /** COS key（cos 输出） */
+    cosKey?: string;
+    /** 低分辨率模糊占位图 data URI（includePlaceholder 为 true 时） */
+    placeholder?: string;
     /** 错误信息（失败时） */
     error?: string;
 }
 
+/** 输出清单 */
+export interface Manifest {
+    /** 清单格式版本 */
+    version: number;
+    /** PDF 总页数 */
+    numPages: number;
+    /** 请求的输出格式（'auto' 时以每页的 format 为准） */
+    format: string;
+    pages: ManifestPage[];
+}
+
+/** 页面实际生效的渲染参数 */
+export interface EffectiveOptions {
+    /** 输出格式 */
+    format: string;
+    /** 缩放比例（像素/点），受 maxScale 和单边尺寸上限约束，可能小于 targetWidth 对应的比例 */
+    scale: number;
+    /** 等效 DPI（scale * 72，取整） */
+    dpi: number;
+    /** 实际使用的质量（webp/jpg），maxBytes 可能使其低于配置值；png 和无损 WebP 为 null */
+    quality: number | null;
+}
+
+/** 流式加载统计 */
+export interface StreamStats {
+    /** 总请求次数 */
+    totalRequests: number;
+    /** 缓存命中次数 */
+    cacheHits: number;
+    /** 缓存未命中次数 */
+    cacheMisses: number;
+    /** 总下载字节数 */
+    totalBytesFetched: number;
+    /** 由缓存提供的字节数 */
+    cacheBytes: number;
+    /** PDFium 实际读取的字节数（缓存命中与未命中之和） */
+    requestedBytes: number;
+    /** 下载过的不重复字节数，即文件被触及的部分 */
+    touchedBytes: number;
+}
+
+/** 按需加载效率报告 */
+export interface EfficiencyReport {
+    /** 文件大小（字节） */
+    fileSize: number;
+    /** PDFium 实际读取的字节数 */
+    requestedBytes: number;
+    /** 读取量中由缓存提供的字节数 */
+    cacheBytes: number;
+    /** 网络下载量（按块下载，包含预读和缓存淘汰后的重复下载） */
+    networkBytes: number;
+    /** 至少下载过一次的不重复字节数 */
+    touchedBytes: number;
+    /** 被重复下载的字节数 */
+    refetchedBytes: number;
+    /** 分片请求次数 */
+    requests: number;
+    /** 文件被触及的比例（touchedBytes / fileSize） */
+    touchedRatio: number;
+    /** 读取量中由缓存提供的比例（cacheBytes / requestedBytes） */
+    cacheHitRatio: number;
+    /** 网络下载量相对文件大小的比例（networkBytes / fileSize） */
+    networkRatio: number;
+}
+
 export interface ConvertResult {
-    /** 是否成功 */
+    /** 是否成功（所有请求的页面都失败时为 false） */
     success: boolean;
+    /** 是否部分成功（部分页面成功、部分页面失败） */
+    partial: boolean;
     /** PDF 总页数 */
     numPages: number;
     /** 成功渲染的页数 */
     renderedPages: number;
-    /** 页面结果数组 */
+    /** 失败的页数 */
+    failedPages: number;
+    /** 页面结果数组，按请求的页码顺序排列（与渲染完成顺序无关） */
     pages: PageResult[];
+    /** skipBlankPages 开启时被判定为空白而跳过的页码（不包含在 pages 中） */
+    skippedPages: number[];
+    /** 输出清单（manifest 为 true 时） */
+    manifest?: Manifest;
+    /** 清单文件路径（manifest 为 true 且 outputType 为 'file' 时） */
+    manifestPath?: string;
+    /** 清单的 COS key（manifest 为 true 且 outputType 为 'cos' 时） */
+    manifestKey?: string;
+    /** 是否因取消信号提前停止（未渲染的页面 aborted 为 true） */
+    aborted: boolean;
+    /** URL 输入时是否检测到线性化（Web 优化）文件，线性化文件按需加载而不完整下载 */
+    linearized?: boolean;
+    /** 按需加载时的下载统计 */
+    streamStats?: StreamStats;
+    /** 按需加载的效率报告（读取量、下载量、缓存量与文件触及比例） */
+    efficiency?: EfficiencyReport;
+    /** autoTune 的校准结果 */
+    autoTune?: AutoTuneResult;
+    /** 诊断信息 */
+    stats: {
+        /** 所有页面都由渲染缓存（cache）返回，未下载也未渲染 */
+        fromCache: boolean;
+        /** 渲染后端，目前总是 'pdfium' */
+        backend: string;
+        /** 按需加载时读取量中由分片缓存提供的比例（同 efficiency.cacheHitRatio），未按需加载时为 null */
+        cacheHitRatio: number | null;
+    };
     /** 耗时信息 */
     timing: {
         /** 总耗时（毫秒） */
@@ -83,19 +493,286 @@ export interface ConvertResult {
 /**
  * PDF 转图片
  *
- * @param input - PDF 文件路径、URL 或 Buffer
+ * @param input - PDF 文件路径、URL 或 Buffer；`zip://<归档 URL>!<条目路径>` 只下载远程 ZIP 中的目标条目
  * @param options - 转换选项
  * @returns 转换结果
  */
 export function convert(input: string | Buffer, options?: ConvertOptions): Promise<ConvertResult>;
 
+/** 批量转换项：输入本身，或输入加上覆盖公共选项的转换选项 */
+export type BatchItem = string | Buffer | (ConvertOptions & { input: string | Buffer });
+
+export interface BatchOptions extends ConvertOptions {
+    /** 同时转换的文档数，默认：4 */
+    batchConcurrency?: number;
+}
+
+/** 批量转换单项结果，index 对应 items 中的位置 */
+export type BatchItemResult =
+    | { index: number; success: true; result: ConvertResult }
+    | { index: number; success: false; error: string; code?: string };
+
+/**
+ * 批量转换多个 PDF，单个文档失败不影响其他文档
+ *
+ * @param items - 文档列表
+ * @param options - 公共转换选项
+ * @returns 与 items 顺序一致的结果
+ */
+export function convertBatch(items: BatchItem[], options?: BatchOptions): Promise<BatchItemResult[]>;
+
 /**
  * 获取 PDF 页数
  *
- * @param input - PDF 文件路径或 Buffer
+ * URL 输入使用流式加载，只下载解析页数所需的数据块。
+ *
+ * @param input - PDF 文件路径、URL 或 Buffer
+ * @param options - URL 输入时的访问策略
  * @returns 页数
  */
-export function getPageCount(input: string | Buffer): number;
+export function getPageCount(
+    input: string | Buffer,
+    options?: Pick<ConvertOptions, 'allowedHosts' | 'blockPrivateNetwork' | 'maxFileSize' | 'signRequest' | 'dispatcher'>
+): Promise<number>;
+
+/** countPages 结果 */
+export interface PageCountResult {
+    /** 总页数 */
+    totalPages: number;
+    /** 文件大小（字节） */
+    fileSize: number;
+    /** 实际下载的字节数，本地文件和 Buffer 为 0 */
+    bytesDownloaded: number;
+    /** 渲染全部页面的估算耗时（毫秒），仅 estimate 为 true 时返回 */
+    estimatedRenderMs?: number;
+}
+
+/**
+ * 获取 PDF 页数与下载量
+ *
+ * URL 输入使用流式加载，只下载交叉引用表、页面树等文档结构数据，
+ * 适合在请求图片前先获取页数构建分页。
+ *
+ * @param input - PDF 文件路径、URL 或 Buffer
+ * @param options - URL 输入时的访问策略
+ */
+export function countPages(
+    input: string | Buffer,
+    options?: Pick<ConvertOptions, 'allowedHosts' | 'blockPrivateNetwork' | 'maxFileSize' | 'signRequest' | 'dispatcher' | 'targetWidth' | 'exactWidth' | 'maxScale'>
+        & Pick<StreamRenderOptions, 'onRangeRequest'>
+        & {
+            /**
+             * 估算渲染全部页面的耗时（estimatedRenderMs）：按前 16 页的平均尺寸和 targetWidth、exactWidth、maxScale
+             * 计算输出像素，乘以每百万像素渲染耗时（进程内首次估算时校准，此后由实际渲染更新），按线程数并行折算。
+             * URL 输入会额外发送少量分片请求读取页面尺寸
+             */
+            estimate?: boolean;
+        }
+): Promise<PageCountResult>;
+
+/** 多页 TIFF 选项 */
+export interface MultiPageTiffOptions extends RenderOptions {
+    /** 要渲染的页码（同 convert），空数组表示全部页面（受 PDF2IMG_DEFAULT_PAGES 影响，'all' 始终表示全部） */
+    pages?: number[] | 'all';
+    /** 压缩方式，默认：'lzw'；'ccittfax4' 为传真常用的 1 位黑白压缩 */
+    compression?: 'none' | 'lzw' | 'deflate' | 'packbits' | 'jpeg' | 'ccittfax4';
+    /** 输出灰度图，默认：false */
+    grayscale?: boolean;
+    /** JPEG 压缩时的质量 */
+    quality?: number;
+    /**
+     * 最大页数，与 PDF2IMG_MAX_TIFF_PAGES（默认 100）取较小值；所有页面的位图需要同时驻留内存，
+     * 超过时在渲染前抛出 err.code 为 'ERR_TOO_MANY_PAGES' 的错误
+     */
+    maxPages?: number;
+    /** 允许访问的远程主机白名单 */
+    allowedHosts?: string[];
+    /** 是否拦截内网地址 */
+    blockPrivateNetwork?: boolean;
+    /** 远程文件大小上限（字节） */
+    maxFileSize?: number;
+    /** 请求签名钩子 */
+    signRequest?: SignRequest;
+    /** 远程请求使用的 undici Dispatcher */
+    dispatcher?: Dispatcher;
+}
+
+/** 多页 TIFF 结果 */
+export interface MultiPageTiffResult {
+    /** TIFF 文件数据 */
+    buffer: Buffer;
+    /** PDF 总页数 */
+    numPages: number;
+    /** TIFF 中的页数 */
+    pageCount: number;
+    /** 每页宽度（像素，尺寸不同的页面补齐到最大值） */
+    width: number;
+    /** 每页高度（像素） */
+    height: number;
+}
+
+/**
+ * 渲染为单个多页 TIFF（归档、传真场景）
+ *
+ * @param input - PDF 文件路径、URL 或 Buffer
+ * @param options - 渲染与 TIFF 编码选项
+ */
+export function renderMultiPageTiff(input: string | Buffer, options?: MultiPageTiffOptions): Promise<MultiPageTiffResult>;
+
+/** 精灵图选项 */
+export interface SpriteOptions {
+    /** 要渲染的页码（同 convert），空数组表示全部页面（受 PDF2IMG_DEFAULT_PAGES 影响，'all' 始终表示全部） */
+    pages?: number[] | 'all';
+    /** 缩略图宽度（像素），默认：160 */
+    thumbWidth?: number;
+    /** 精灵图最大宽度（像素），默认：2048 */
+    maxWidth?: number;
+    /** 缩略图之间的间距（像素），默认：0 */
+    gap?: number;
+    /** 输出格式，默认：'webp' */
+    format?: 'webp' | 'png' | 'jpg' | 'jpeg';
+    /** WebP/JPEG 质量 */
+    quality?: number;
+    /** 取消信号 */
+    signal?: AbortSignal;
+    /** 允许访问的远程主机白名单 */
+    allowedHosts?: string[];
+    /** 是否拦截内网地址 */
+    blockPrivateNetwork?: boolean;
+    /** 远程文件大小上限（字节） */
+    maxFileSize?: number;
+    /** 请求签名钩子 */
+    signRequest?: SignRequest;
+    /** 远程请求使用的 undici Dispatcher */
+    dispatcher?: Dispatcher;
+}
+
+/** 缩略图在精灵图中的位置（像素，左上角为原点） */
+export interface SpriteRect {
+    /** 页码 */
+    pageNum: number;
+    x: number;
+    y: number;
+    width: number;
+    height: number;
+}
+
+/** 精灵图结果 */
+export interface SpriteResult {
+    /** 精灵图数据 */
+    buffer: Buffer;
+    /** 输出格式 */
+    format: string;
+    /** 精灵图宽度（像素） */
+    width: number;
+    /** 精灵图高度（像素） */
+    height: number;
+    /** PDF 总页数 */
+    numPages: number;
+    /** 每页缩略图的位置，顺序同 pages */
+    pages: SpriteRect[];
+}
+
+/**
+ * 将多个页面的缩略图合并为一张精灵图（虚拟列表等场景减少请求数）
+ *
+ * @param input - PDF 文件路径、URL 或 Buffer
+ * @param options - 缩略图与布局选项
+ */
+export function renderSprite(input: string | Buffer, options?: SpriteOptions): Promise<SpriteResult>;
+
+/** 书签（目录）项 */
+export interface OutlineItem {
+    /** 书签标题 */
+    title: string;
+    /** 目标页码（1-based），书签不指向页面时为空 */
+    pageNum?: number;
+    /** 子书签 */
+    children: OutlineItem[];
+}
+
+/**
+ * 获取 PDF 书签（目录）
+ *
+ * URL 输入使用流式加载，只下载书签所需的数据块。
+ *
+ * @param input - PDF 文件路径、URL 或 Buffer
+ * @param options - URL 输入时的访问策略
+ * @returns 顶层书签列表，没有书签时为空数组
+ */
+export function getOutline(
+    input: string | Buffer,
+    options?: Pick<ConvertOptions, 'allowedHosts' | 'blockPrivateNetwork' | 'maxFileSize' | 'signRequest' | 'dispatcher'>
+): Promise<OutlineItem[]>;
+
+/** 命名目标（文档中按名称定义的跳转目标） */
+export interface NamedDestination {
+    /** 目标名称 */
+    name: string;
+    /** 目标页码（从 1 开始） */
+    pageNum: number;
+    /** 目标位置的横坐标（点，原点在页面左下角），目标未指定时为 undefined */
+    x?: number;
+    /** 目标位置的纵坐标（点，原点在页面左下角），目标未指定时为 undefined */
+    y?: number;
+    /** 目标页面宽度（点） */
+    pageWidth: number;
+    /** 目标页面高度（点） */
+    pageHeight: number;
+}
+
+export interface NamedDestinationOptions extends Omit<ConvertOptions, 'pages' | 'clip'> {
+    /** 从目标位置裁剪到页面底部（目标未指定纵坐标时输出整页），默认：false */
+    clipToDestination?: boolean;
+}
+
+/**
+ * 渲染命名目标（如 'chapter3'）所在的页面
+ *
+ * URL 输入先完整下载到临时文件。名称不存在时抛出 code 为 'ERR_DEST_NOT_FOUND' 的错误。
+ *
+ * @param input - PDF 文件路径、URL 或 Buffer
+ * @param name - 目标名称
+ * @param options - 转换选项
+ * @returns 转换结果，另含解析出的命名目标
+ */
+export function renderNamedDestination(
+    input: string | Buffer,
+    name: string,
+    options?: NamedDestinationOptions
+): Promise<ConvertResult & { destination: NamedDestination }>;
+
+/** 页面内嵌图片 */
+export interface ExtractedImage {
+    /** 图片在页面对象中的序号（从 0 开始） */
+    index: number;
+    /** 图片原始像素宽度（与在页面上的显示尺寸无关） */
+    width: number;
+    /** 图片原始像素高度 */
+    height: number;
+    /** 输出格式 */
+    format: string;
+    /** 编码后的图片数据 */
+    buffer: Buffer;
+    /** 图片在页面中的位置（点，72 DPI，左上角为原点） */
+    bounds: { x: number; y: number; width: number; height: number };
+}
+
+/**
+ * 提取页面内嵌图片（照片、扫描图等），而不是渲染整页
+ *
+ * URL 输入使用流式加载，只下载文档结构和该页面引用的数据块。
+ *
+ * @param input - PDF 文件路径、URL 或 Buffer
+ * @param pageNum - 页码（从 1 开始）
+ * @param options - 输出格式（默认 png）、编码质量、灰度与 URL 输入时的访问策略
+ * @returns 页面中的图片列表，没有图片时为空数组
+ */
+export function extractImages(
+    input: string | Buffer,
+    pageNum: number,
+    options?: { format?: 'webp' | 'png' | 'jpg' | 'jpeg'; quality?: number; grayscale?: boolean } & Pick<ConvertOptions, 'allowedHosts' | 'blockPrivateNetwork' | 'maxFileSize' | 'signRequest' | 'dispatcher'>
+): Promise<ExtractedImage[]>;
 
 /**
  * 检查原生渲染器是否可用
@@ -107,6 +784,40 @@ export function isAvailable(): boolean;
  */
 export function getVersion(): string;
 
+/** 线程池统计信息 */
+export interface ThreadPoolStats {
+    /** 线程池是否已初始化 */
+    initialized: boolean;
+    /** 工作线程数 */
+    workers: number;
+    /** 线程数上限（与 workers 相同） */
+    max: number;
+    /** 正在渲染的线程数 */
+    active: number;
+    /** 空闲线程数 */
+    idle: number;
+    /** 排队等待线程的页面数 */
+    queued: number;
+    /** 是否所有线程都在渲染 */
+    saturated: boolean;
+    /** 持续饱和的时长（毫秒），未饱和时为 0 */
+    saturatedFor: number;
+    /** 持续饱和超过 PDF2IMG_POOL_DEGRADED_AFTER 时为 'degraded'，可用于健康检查 */
+    status: 'ok' | 'degraded';
+    /** 达到 PDF2IMG_POOL_MAX_TASKS / PDF2IMG_POOL_MAX_AGE 后回收线程池的次数 */
+    recycled: number;
+    /** 当前线程池已完成任务数 */
+    completed?: number;
+    /** 线程利用率（0-1） */
+    utilization?: number;
+}
+
+/** 获取线程池统计信息 */
+export function getThreadPoolStats(): ThreadPoolStats;
+
+/** 销毁线程池，释放工作线程资源 */
+export function destroyThreadPool(): Promise<void>;
+
 /** 输入类型常量 */
 export const InputType: {
     FILE: 'file';
@@ -126,19 +837,283 @@ export const RENDER_CONFIG: {
     TARGET_RENDER_WIDTH: number;
     IMAGE_HEAVY_TARGET_WIDTH: number;
     MAX_RENDER_SCALE: number;
+    MAX_DPI: number;
+    MAX_RENDER_WIDTH: number;
     WEBP_QUALITY: number;
     NATIVE_STREAM_THRESHOLD: number;
+    STREAM_BLOCK_SIZE: number;
+    MAX_PAGES: number;
+    MAX_TIFF_PAGES: number;
+    DEFAULT_PAGES: number;
+    POOL_MAX_TASKS: number;
+    POOL_MAX_AGE: number;
 };
 
 /** 超时配置 */
 export const TIMEOUT_CONFIG: {
     RANGE_REQUEST_TIMEOUT: number;
     DOWNLOAD_TIMEOUT: number;
+    STALL_TIMEOUT: number;
+    RENDER_TIMEOUT: number;
+};
+
+/** 网络配置 */
+export const NETWORK_CONFIG: {
+    MAX_CONCURRENT_FETCHES: number;
+    MAX_CONCURRENT_DOWNLOADS: number;
+    DOWNLOAD_RETRIES: number;
+    DOWNLOAD_RETRY_DELAY: number;
+    CIRCUIT_BREAKER_THRESHOLD: number;
+    CIRCUIT_BREAKER_COOLDOWN: number;
+    AUTOTUNE_TTL: number;
+};
+
+/** 全局远程请求并发状态 */
+export interface FetchStats {
+    /** 并发上限（PDF2IMG_MAX_CONCURRENT_FETCHES） */
+    limit: number;
+    /** 正在进行的请求数 */
+    active: number;
+    /** 排队等待的请求数 */
+    pending: number;
+    /** 完整下载的并发状态（独立额度，PDF2IMG_MAX_CONCURRENT_DOWNLOADS） */
+    downloads: {
+        limit: number;
+        active: number;
+        pending: number;
+    };
+}
+
+/** 获取全局远程请求并发状态（所有转换共享） */
+export function getFetchStats(): FetchStats;
+
+/** 渲染尺寸上限 */
+export interface RenderLimits {
+    /** DPI 上限，默认取 PDF2IMG_MAX_DPI */
+    maxDpi?: number;
+    /** 宽度上限（像素），默认取 PDF2IMG_MAX_RENDER_WIDTH */
+    maxWidth?: number;
+}
+
+/**
+ * 校验并规范化渲染尺寸参数（targetWidth、exactWidth、imageHeavyWidth、maxScale）
+ *
+ * convert 等所有渲染入口内部都会调用；在此之上构建服务时，可以用它在接收请求时
+ * 按同样的规则校验参数。非法值抛出异常，超出上限的值截断到上限，返回的 maxScale 总是有值
+ */
+export function normalizeRenderOptions<T extends RenderOptions>(options?: T, limits?: RenderLimits): T & { maxScale: number };
+
+/** 单个主机的熔断状态 */
+export interface CircuitState {
+    /** closed：有失败但未熔断；open：熔断中，请求以 ERR_CIRCUIT_OPEN 快速失败；half-open：冷却结束，等待探测 */
+    state: 'closed' | 'open' | 'half-open';
+    /** 连续失败次数（超时、连接错误、5xx） */
+    failures: number;
+    /** 最近一次熔断的时间戳 */
+    openedAt: number | null;
+}
+
+/** 获取按主机（host:port）熔断的状态，只包含有失败记录的主机（所有转换共享） */
+export function getCircuitBreakerStats(): Record<string, CircuitState>;
+
+/** 根据流式加载统计生成效率报告 */
+export function efficiencyReport(streamStats: StreamStats, fileSize: number): EfficiencyReport;
+
+/** 远程 PDF 预检结果 */
+export interface ProbeResult {
+    /** 最终响应的 HTTP 状态码 */
+    statusCode: number;
+    /** 文件大小（字节），无法确定时为 null */
+    size: number | null;
+    /** 是否支持 Range 请求 */
+    acceptsRanges: boolean;
+    /** Content-Type */
+    contentType: string | null;
+    /** ETag */
+    etag: string | null;
+}
+
+export interface ProbeOptions {
+    /** 允许访问的远程主机白名单 */
+    allowedHosts?: string[];
+    /** 是否拦截内网地址 */
+    blockPrivateNetwork?: boolean;
+    /** 请求签名钩子 */
+    signRequest?: SignRequest;
+    /** 远程请求使用的 undici Dispatcher */
+    dispatcher?: Dispatcher;
+    /** 单次请求超时（毫秒），默认取 RANGE_REQUEST_TIMEOUT */
+    timeout?: number;
+    /** 取消信号，触发后立即以 AbortError 结束 */
+    signal?: AbortSignal;
+}
+
+/**
+ * 预检远程 PDF：确认可访问、是否支持 Range 请求，不下载文件内容。
+ * 先发送 HEAD，信息不足时改用 1 字节的 Range 请求；服务器返回错误状态时不抛出异常
+ */
+export function probeUrl(url: string, options?: ProbeOptions): Promise<ProbeResult>;
+
+/** 块大小校准结果 */
+export interface AutoTuneResult {
+    /** 单次请求的固定延迟（毫秒） */
+    latency: number;
+    /** 吞吐（字节/秒） */
+    throughput: number;
+    /** 选定的块大小（字节） */
+    blockSize: number;
+    /** 本次校准的请求数（使用缓存的结果时为 0） */
+    requests: number;
+    /** 本次校准下载的字节数（不计入 streamStats，使用缓存的结果时为 0） */
+    bytes: number;
+    /** 是否使用了同一 origin 缓存的校准结果（PDF2IMG_AUTOTUNE_TTL） */
+    cached: boolean;
+}
+
+/** 待签名的请求，签名钩子直接修改 headers（已包含 Range 等请求头） */
+export interface SignableRequest {
+    method: string;
+    url: URL;
+    headers: Headers;
+}
+
+/** 请求签名钩子，抛出异常即中止请求 */
+export type SignRequest = (request: SignableRequest) => void | Promise<void>;
+
+/** undici Dispatcher（如 undici 的 Agent、Pool），只声明 fetch 需要的方法，不依赖 undici 的类型定义 */
+export interface Dispatcher {
+    dispatch(options: unknown, handler: unknown): boolean;
+}
+
+export interface SigV4SignerOptions {
+    /** 默认取 AWS_ACCESS_KEY_ID */
+    accessKeyId?: string;
+    /** 默认取 AWS_SECRET_ACCESS_KEY */
+    secretAccessKey?: string;
+    /** STS 临时凭证的 token，默认取 AWS_SESSION_TOKEN */
+    sessionToken?: string;
+    /** 地域，默认取 AWS_REGION 或 AWS_DEFAULT_REGION */
+    region?: string;
+    /** 服务名，默认：'s3' */
+    service?: string;
+}
+
+/**
+ * 创建 AWS SigV4 签名钩子，用于直接读取私有 S3（及兼容 SigV4 的对象存储）中的 PDF。
+ * 缺少 accessKeyId、secretAccessKey 或 region 时抛出异常
+ */
+export function createSigV4Signer(options?: SigV4SignerOptions): SignRequest;
+
+/**
+ * 拒绝解析到内网地址的 DNS 查询（签名同 dns.lookup），用作自定义 undici Agent 的 connect.lookup，
+ * 在建立连接时校验实际使用的地址，防御 DNS rebinding
+ */
+export function privateNetworkLookup(
+    hostname: string,
+    options: object,
+    callback: (err: Error | null, address?: string | Array<{ address: string; family: number }>, family?: number) => void
+): void;
+
+/** 渲染缓存存储接口，get/set 可以是异步的（如 Redis） */
+export interface RenderCacheStore {
+    get(key: string): unknown | Promise<unknown>;
+    set(key: string, value: unknown, size: number): void | Promise<void>;
+}
+
+export interface RenderCacheOptions {
+    /** 缓存总字节数上限，超出时淘汰最久未使用的条目，默认：256MB */
+    maxBytes?: number;
+    /** 条目有效期（毫秒），0 表示不过期，默认：0 */
+    ttl?: number;
+}
+
+export interface RenderCacheStats {
+    /** 命中次数 */
+    hits: number;
+    /** 未命中次数（含过期） */
+    misses: number;
+    /** 因容量淘汰的条目数 */
+    evictions: number;
+    /** 当前条目数 */
+    entries: number;
+    /** 当前占用字节数 */
+    bytes: number;
+    /** 字节数上限 */
+    maxBytes: number;
+}
+
+export interface RenderCache extends RenderCacheStore {
+    get(key: string): unknown;
+    set(key: string, value: unknown, size?: number): void;
+    /** 清空缓存（统计计数保留） */
+    clear(): void;
+    getStats(): RenderCacheStats;
+}
+
+/** 创建内存 LRU 渲染缓存，通过 convert 的 cache 选项使用 */
+export function createRenderCache(options?: RenderCacheOptions): RenderCache;
+
+/** 安全配置 */
+export const SECURITY_CONFIG: {
+    ALLOWED_HOSTS: string[];
+    BLOCK_PRIVATE_NETWORK: boolean;
+    MAX_FILE_SIZE: number;
+    MAX_ZIP_ENTRY_SIZE: number;
+    MAX_REDIRECTS: number;
 };
 
 /** 检查原生渲染器是否可用 */
 export function isNativeAvailable(): boolean;
 
+/** 原始位图渲染结果 */
+export interface RawBitmapResult {
+    /** 是否成功 */
+    success: boolean;
+    /** 错误信息（失败时） */
+    error?: string;
+    /** 图像宽度（像素） */
+    width: number;
+    /** 图像高度（像素） */
+    height: number;
+    /** 通道数（固定为 4，RGBA） */
+    channels: number;
+    /** 原始 RGBA 像素数据（独立拷贝，可安全持有和修改） */
+    buffer: Buffer;
+    /** 渲染耗时（毫秒） */
+    renderTime: number;
+}
+
+/** 从 Buffer 获取 PDF 页数（原生同步接口） */
+export function getPageCountNative(pdfBuffer: Buffer): number;
+
+/** 从文件路径获取 PDF 页数（原生同步接口） */
+export function getPageCountFromFile(filePath: string): number;
+
+/** 流式获取远程 PDF 页数，streamStats 为分片加载统计 */
+export function getPageCountFromStream(
+    pdfUrl: string,
+    pdfSize: number,
+    options?: StreamRenderOptions
+): Promise<{ numPages: number; streamStats: StreamStats }>;
+
+/**
+ * 渲染单页到原始 RGBA 位图（不编码）
+ *
+ * 适合自行编码、OCR 或像素比对等场景。
+ */
+export function renderPageToRawBitmap(
+    filePath: string,
+    pageNum: number,
+    options?: RenderOptions
+): RawBitmapResult;
+
+/** 从 Buffer 渲染单页到原始 RGBA 位图（不编码） */
+export function renderPageToRawBitmapFromBuffer(
+    pdfBuffer: Buffer,
+    pageNum: number,
+    options?: RenderOptions
+): RawBitmapResult;
+
 /** 从 Buffer 渲染 PDF */
 export function renderFromBuffer(
     pdfBuffer: Buffer,
@@ -161,12 +1136,53 @@ export function renderFromBuffer(
     nativeTime: number;
 }>;
 
+/** 分片请求追踪信息 */
+export interface RangeRequestTrace {
+    /** 请求 ID */
+    requestId: number;
+    /** 起始偏移（字节） */
+    offset: number;
+    /** 请求长度（字节） */
+    size: number;
+    /** 实际收到的字节数 */
+    bytes: number;
+    /** HTTP 状态码（网络错误时为 0） */
+    status: number;
+    /** 耗时（毫秒） */
+    elapsed: number;
+    /** 错误信息（失败时） */
+    error?: string;
+}
+
+/** 流式渲染选项 */
+export interface StreamRenderOptions extends RenderOptions {
+    /** 允许访问的远程主机白名单 */
+    allowedHosts?: string[];
+    /** 是否拦截内网地址 */
+    blockPrivateNetwork?: boolean;
+    /** 请求签名钩子，每个分片请求单独调用 */
+    signRequest?: SignRequest;
+    /** 远程请求使用的 undici Dispatcher */
+    dispatcher?: Dispatcher;
+    /** 缓存块大小（字节，2 的幂，16KB-4MB），默认取 PDF2IMG_STREAM_BLOCK_SIZE */
+    blockSize?: number;
+    /** 每个分片请求完成后的追踪回调（默认关闭） */
+    onRangeRequest?: (trace: RangeRequestTrace) => void;
+}
+
+/** 从文件路径渲染 PDF */
+export function renderFromFile(
+    filePath: string,
+    pages?: number[],
+    options?: RenderOptions
+): ReturnType<typeof renderFromBuffer>;
+
 /** 从流渲染 PDF（用于远程 URL） */
 export function renderFromStream(
     pdfUrl: string,
     pdfSize: number,
     pages?: number[],
-    options?: RenderOptions
+    options?: StreamRenderOptions
 ): Promise<{
     success: boolean;
     numPages: number;
@@ -179,8 +1195,10 @@ export function renderFromStream(
         error?: string;
         renderTime: number;
         encodeTime: number;
+        /** 本页触发的增量加载统计，所有页面之和等于 streamStats */
+        streamStats?: StreamStats;
     }>;
     totalTime: number;
     nativeTime: number;
-    streamStats?: object;
+    streamStats?: StreamStats;
 }>;
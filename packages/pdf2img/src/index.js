@@ -36,8 +36,15 @@
 
 export {
     convert,
+    convertBatch,
     getPageCount,
     getPageCountSync,
+    countPages,
+    getOutline,
+    renderNamedDestination,
+    extractImages,
+    renderMultiPageTiff,
+    renderSprite,
     isAvailable,
     getVersion,
     getThreadPoolStats,
@@ -46,13 +53,31 @@ export {
     OutputType,
 } from './core/converter.js';
 
-export { RENDER_CONFIG, TIMEOUT_CONFIG } from './core/config.js';
+export { RENDER_CONFIG, TIMEOUT_CONFIG, NETWORK_CONFIG, SECURITY_CONFIG, normalizeRenderOptions } from './core/config.js';
+
+export { getFetchStats } from './utils/limiter.js';
+
+export { getCircuitBreakerStats } from './utils/circuit-breaker.js';
+
+export { efficiencyReport } from './utils/stream-stats.js';
+
+export { createRenderCache } from './utils/render-cache.js';
+
+export { probeUrl } from './utils/probe.js';
+
+export { createSigV4Signer } from './utils/sigv4.js';
+
+export { privateNetworkLookup } from './utils/http.js';
 
 // 导出原生渲染器工具供高级用法
 export {
     isNativeAvailable,
     getPageCount as getPageCountNative,
     getPageCountFromFile,
+    getPageCountFromStream,
     renderPageToRawBitmap,
     renderPageToRawBitmapFromBuffer,
+    renderFromBuffer,
+    renderFromFile,
+    renderFromStream,
 } from './renderers/native.js';
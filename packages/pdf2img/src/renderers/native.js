@@ -7,7 +7,11 @@
  */
 
 import { createLogger } from '../utils/logger.js';
-import { mergeConfig, TIMEOUT_CONFIG } from '../core/config.js';
+import { mergeConfig, TIMEOUT_CONFIG, NETWORK_CONFIG } from '../core/config.js';
+import { assertUrlAllowed, fetchWithPolicy, pickNetworkPolicy, stallSignal, validateContentRange } from '../utils/http.js';
+import { limitFetch } from '../utils/limiter.js';
+import { BufferPool, readIntoPool } from '../utils/buffer-pool.js';
+import { needsPageCount, resolvePages, assertHasPages, assertPagesInRange, assertPageLimit } from '../utils/pages.js';
 
 const logger = createLogger('NativeRenderer');
 
@@ -38,6 +42,11 @@ try {
     nativeAvailable = false;
 }
 
+/**
+ * 渲染后端名称，记录在转换结果的 stats.backend 中
+ */
+export const RENDER_BACKEND = 'pdfium';
+
 /**
  * 检查 Native Renderer 是否可用
  */
@@ -72,6 +81,198 @@ export function getPageCountFromFile(filePath) {
     return nativeRenderer.getPageCountFromFile(filePath);
 }
 
+/**
+ * 获取 PDF 书签（从 Buffer）
+ * @param {Buffer} pdfBuffer - PDF 文件数据
+ * @returns {Object[]} 顶层书签列表 [{ title, pageNum, children }]，没有书签时为空数组
+ */
+export function getOutline(pdfBuffer) {
+    if (!nativeAvailable) {
+        throw new Error('Native renderer not available');
+    }
+    return nativeRenderer.getOutline(pdfBuffer);
+}
+
+/**
+ * 获取 PDF 书签（从文件路径）
+ * @param {string} filePath - PDF 文件路径
+ * @returns {Object[]} 顶层书签列表 [{ title, pageNum, children }]，没有书签时为空数组
+ */
+export function getOutlineFromFile(filePath) {
+    if (!nativeAvailable) {
+        throw new Error('Native renderer not available');
+    }
+    return nativeRenderer.getOutlineFromFile(filePath);
+}
+
+/**
+ * 按名称查找命名目标（从 Buffer）
+ * @param {Buffer} pdfBuffer - PDF 文件数据
+ * @param {string} name - 目标名称
+ * @returns {Object|null} { pageNum, x, y, pageWidth, pageHeight }，名称不存在时为 null
+ */
+export function getNamedDestination(pdfBuffer, name) {
+    if (!nativeAvailable) {
+        throw new Error('Native renderer not available');
+    }
+    return nativeRenderer.getNamedDestination(pdfBuffer, name);
+}
+
+/**
+ * 按名称查找命名目标（从文件路径）
+ * @param {string} filePath - PDF 文件路径
+ * @param {string} name - 目标名称
+ * @returns {Object|null} { pageNum, x, y, pageWidth, pageHeight }，名称不存在时为 null
+ */
+export function getNamedDestinationFromFile(filePath, name) {
+    if (!nativeAvailable) {
+        throw new Error('Native renderer not available');
+    }
+    return nativeRenderer.getNamedDestinationFromFile(filePath, name);
+}
+
+/**
+ * 提取页面内嵌图片（从 Buffer）
+ * @param {Buffer} pdfBuffer - PDF 文件数据
+ * @param {number} pageNum - 页码（从 1 开始）
+ * @returns {Object[]} 图片列表 [{ index, width, height, buffer, bounds }]，buffer 为原始 RGBA 像素
+ */
+export function extractImages(pdfBuffer, pageNum) {
+    if (!nativeAvailable) {
+        throw new Error('Native renderer not available');
+    }
+    return nativeRenderer.extractImages(pdfBuffer, pageNum);
+}
+
+/**
+ * 提取页面内嵌图片（从文件路径）
+ * @param {string} filePath - PDF 文件路径
+ * @param {number} pageNum - 页码（从 1 开始）
+ * @returns {Object[]} 图片列表，同 extractImages
+ */
+export function extractImagesFromFile(filePath, pageNum) {
+    if (!nativeAvailable) {
+        throw new Error('Native renderer not available');
+    }
+    return nativeRenderer.extractImagesFromFile(filePath, pageNum);
+}
+
+/**
+ * 提取远程 PDF 页面内嵌图片（流式加载，只下载该页面引用的数据块）
+ *
+ * @param {string} pdfUrl - PDF 文件 URL
+ * @param {number} pdfSize - PDF 文件大小
+ * @param {number} pageNum - 页码（从 1 开始）
+ * @param {Object} options - 选项（可包含 pickNetworkPolicy 的访问策略字段和 onRangeRequest）
+ * @returns {Promise<Object[]>} 图片列表，同 extractImages
+ */
+export async function extractImagesFromStream(pdfUrl, pdfSize, pageNum, options = {}) {
+    if (!nativeAvailable) {
+        throw new Error('Native renderer not available');
+    }
+
+    const network = pickNetworkPolicy(options);
+    await assertUrlAllowed(pdfUrl, network);
+
+    return nativeRenderer.extractImagesFromStream(pdfSize, pageNum, createStreamFetcher(pdfUrl, network, options));
+}
+
+/**
+ * 获取远程 PDF 书签（流式加载，只下载书签所需的数据块）
+ *
+ * @param {string} pdfUrl - PDF 文件 URL
+ * @param {number} pdfSize - PDF 文件大小
+ * @param {Object} options - 选项（可包含 pickNetworkPolicy 的访问策略字段和 onRangeRequest）
+ * @returns {Promise<Object[]>} 顶层书签列表
+ */
+export async function getOutlineFromStream(pdfUrl, pdfSize, options = {}) {
+    if (!nativeAvailable) {
+        throw new Error('Native renderer not available');
+    }
+
+    const network = pickNetworkPolicy(options);
+    await assertUrlAllowed(pdfUrl, network);
+
+    return nativeRenderer.getOutlineFromStream(pdfSize, createStreamFetcher(pdfUrl, network, options));
+}
+
+/**
+ * 获取远程 PDF 页数（流式加载，只下载解析文档结构所需的数据块）
+ *
+ * @param {string} pdfUrl - PDF 文件 URL
+ * @param {number} pdfSize - PDF 文件大小
+ * @param {Object} options - 选项（可包含 pickNetworkPolicy 的访问策略字段和 onRangeRequest）
+ * @returns {Promise<{ numPages: number, streamStats: Object }>} 页数与分片加载统计
+ */
+export async function getPageCountFromStream(pdfUrl, pdfSize, options = {}) {
+    if (!nativeAvailable) {
+        throw new Error('Native renderer not available');
+    }
+
+    const network = pickNetworkPolicy(options);
+    await assertUrlAllowed(pdfUrl, network);
+
+    // 不传页码时只打开文档读取页数，不渲染任何页面
+    const result = await nativeRenderer.renderPagesFromStream(
+        pdfSize,
+        [],
+        mergeConfig(options),
+        createStreamFetcher(pdfUrl, network, options)
+    );
+
+    if (!result.success) {
+        throw new Error(result.error || 'Native stream renderer failed');
+    }
+
+    return { numPages: result.numPages, streamStats: result.streamStats };
+}
+
+/**
+ * 获取 PDF 页数与前若干页的尺寸（从 Buffer）
+ * @param {Buffer} pdfBuffer - PDF 文件数据
+ * @param {number} [limit] - 最多读取尺寸的页数（默认 16）
+ * @returns {{ numPages: number, pages: Object[] }} pages 为 [{ pageNum, width, height }]（点）
+ */
+export function getPageSizes(pdfBuffer, limit) {
+    if (!nativeAvailable) {
+        throw new Error('Native renderer not available');
+    }
+    return nativeRenderer.getPageSizes(pdfBuffer, limit);
+}
+
+/**
+ * 获取 PDF 页数与前若干页的尺寸（从文件路径）
+ * @param {string} filePath - PDF 文件路径
+ * @param {number} [limit] - 最多读取尺寸的页数（默认 16）
+ * @returns {{ numPages: number, pages: Object[] }} 同 getPageSizes
+ */
+export function getPageSizesFromFile(filePath, limit) {
+    if (!nativeAvailable) {
+        throw new Error('Native renderer not available');
+    }
+    return nativeRenderer.getPageSizesFromFile(filePath, limit);
+}
+
+/**
+ * 获取远程 PDF 页数与前若干页的尺寸（流式加载，只下载文档结构和页面字典所需的数据块）
+ *
+ * @param {string} pdfUrl - PDF 文件 URL
+ * @param {number} pdfSize - PDF 文件大小
+ * @param {number} [limit] - 最多读取尺寸的页数（默认 16）
+ * @param {Object} options - 选项（可包含 pickNetworkPolicy 的访问策略字段和 onRangeRequest）
+ * @returns {Promise<{ numPages: number, pages: Object[] }>} 同 getPageSizes
+ */
+export async function getPageSizesFromStream(pdfUrl, pdfSize, limit, options = {}) {
+    if (!nativeAvailable) {
+        throw new Error('Native renderer not available');
+    }
+
+    const network = pickNetworkPolicy(options);
+    await assertUrlAllowed(pdfUrl, network);
+
+    return nativeRenderer.getPageSizesFromStream(pdfSize, limit, createStreamFetcher(pdfUrl, network, options));
+}
+
 /**
  * 渲染单页到原始位图（不编码）
  * 
@@ -122,7 +323,7 @@ export function getVersion() {
  * 使用 Native Renderer 渲染 PDF Buffer
  *
  * @param {Buffer} pdfBuffer - PDF 文件数据
- * @param {number[]} pages - 要渲染的页码数组（1-based），空数组表示全部页面
+ * @param {number[]} pages - 要渲染的页码数组（1-based，负数从末尾倒数），空数组表示全部页面
  * @param {Object} options - 渲染选项
  * @returns {Promise<Object>} 渲染结果
  */
@@ -135,13 +336,10 @@ export async function renderFromBuffer(pdfBuffer, pages = [], options = {}) {
     const buffer = Buffer.isBuffer(pdfBuffer) ? pdfBuffer : Buffer.from(pdfBuffer);
     const numPages = nativeRenderer.getPageCount(buffer);
 
-    // 确定目标页码
-    let targetPages;
-    if (pages.length === 0) {
-        targetPages = Array.from({ length: numPages }, (_, i) => i + 1);
-    } else {
-        targetPages = pages.filter(p => p >= 1 && p <= numPages);
-    }
+    // 确定目标页码（支持负数从末尾倒数）
+    const targetPages = resolvePages(pages, numPages);
+    assertPagesInRange(pages, targetPages, numPages);
+    assertPageLimit(targetPages, numPages, options.maxPages);
 
     logger.debug(`Rendering ${targetPages.length} pages from buffer (${(buffer.length / 1024 / 1024).toFixed(2)}MB)`);
 
@@ -164,6 +362,7 @@ export async function renderFromBuffer(pdfBuffer, pages = [], options = {}) {
             error: page.error,
             renderTime: page.renderTime,
             encodeTime: page.encodeTime,
+            scale: page.scale,
         })),
         totalTime: Date.now() - startTime,
         nativeTime: result.totalTime,
@@ -177,7 +376,7 @@ export async function renderFromBuffer(pdfBuffer, pages = [], options = {}) {
  * 这是处理本地文件的最高效方式。
  *
  * @param {string} filePath - PDF 文件路径
- * @param {number[]} pages - 要渲染的页码数组（1-based），空数组表示全部页面
+ * @param {number[]} pages - 要渲染的页码数组（1-based，负数从末尾倒数），空数组表示全部页面
  * @param {Object} options - 渲染选项
  * @returns {Promise<Object>} 渲染结果
  */
@@ -189,13 +388,10 @@ export async function renderFromFile(filePath, pages = [], options = {}) {
     const config = mergeConfig(options);
     const numPages = nativeRenderer.getPageCountFromFile(filePath);
 
-    // 确定目标页码
-    let targetPages;
-    if (pages.length === 0) {
-        targetPages = Array.from({ length: numPages }, (_, i) => i + 1);
-    } else {
-        targetPages = pages.filter(p => p >= 1 && p <= numPages);
-    }
+    // 确定目标页码（支持负数从末尾倒数）
+    const targetPages = resolvePages(pages, numPages);
+    assertPagesInRange(pages, targetPages, numPages);
+    assertPageLimit(targetPages, numPages, options.maxPages);
 
     logger.debug(`Rendering ${targetPages.length} pages from file: ${filePath}`);
 
@@ -218,6 +414,7 @@ export async function renderFromFile(filePath, pages = [], options = {}) {
             error: page.error,
             renderTime: page.renderTime,
             encodeTime: page.encodeTime,
+            scale: page.scale,
         })),
         totalTime: Date.now() - startTime,
         nativeTime: result.totalTime,
@@ -225,33 +422,40 @@ export async function renderFromFile(filePath, pages = [], options = {}) {
 }
 
 /**
- * 使用 Native Stream 渲染远程 PDF
+ * 分片下载缓冲区池（进程级共享，同时进行的分片请求数不超过全局请求额度）
+ */
+const rangeBufferPool = new BufferPool({ maxBuffers: NETWORK_CONFIG.MAX_CONCURRENT_FETCHES });
+
+/**
+ * 创建流式加载的分片获取回调
  *
- * 通过回调按需获取 PDF 数据，避免一次性下载整个文件
+ * 返回的回调由 Rust 通过 ThreadsafeFunction 调用，获取数据后经 completeStreamRequest 回传。
+ * Rust 端等待响应时不设超时，每个请求都必须经 completeStreamRequest 结束（成功、失败、停滞或取消）。
  *
  * @param {string} pdfUrl - PDF 文件 URL
- * @param {number} pdfSize - PDF 文件大小
- * @param {number[]} pages - 要渲染的页码数组（1-based），空数组表示全部页面
- * @param {Object} options - 渲染选项
- * @returns {Promise<Object>} 渲染结果
+ * @param {Object} network - 远程访问策略（见 pickNetworkPolicy）
+ * @param {Object} options - 选项（可包含 onRangeRequest 追踪回调、signal 取消信号）
+ * @returns {Function} fetcher 回调
  */
-export async function renderFromStream(pdfUrl, pdfSize, pages = [], options = {}) {
-    if (!nativeAvailable) {
-        throw new Error('Native renderer not available');
-    }
-
-    if (!pdfUrl || !pdfSize) {
-        throw new Error('pdfUrl and pdfSize are required for stream mode');
-    }
-
-    const config = mergeConfig(options);
-
-    logger.debug(`Stream rendering from ${pdfUrl} (${(pdfSize / 1024 / 1024).toFixed(2)}MB)`);
+function createStreamFetcher(pdfUrl, network, options = {}) {
+    /**
+     * 记录单个分片请求（调试日志 + 可选的追踪回调）
+     */
+    const traceRequest = (trace) => {
+        logger.debug('Range request', trace);
+        if (options.onRangeRequest) {
+            try {
+                options.onRangeRequest(trace);
+            } catch (err) {
+                logger.warn(`onRangeRequest callback failed: ${err.message}`);
+            }
+        }
+    };
 
     /**
      * fetcher 回调函数 - 被 Rust 通过 ThreadsafeFunction 调用
      */
-    const fetcher = (error, req) => {
+    return (error, req) => {
         if (error) {
             logger.error(`Fetcher received error: ${error.message}`);
             return;
@@ -260,52 +464,148 @@ export async function renderFromStream(pdfUrl, pdfSize, pages = [], options = {}
         const { offset, size, requestId } = req;
         const start = Number(offset);
         const end = start + size - 1;
-
-        fetch(pdfUrl, {
-            headers: { 'Range': `bytes=${start}-${end}` },
-            signal: AbortSignal.timeout(TIMEOUT_CONFIG.RANGE_REQUEST_TIMEOUT),
-        })
-            .then(response => {
-                if (!response.ok && response.status !== 206) {
+        const fetchStart = Date.now();
+        let status = 0;
+        let contentRange = null;
+
+        limitFetch(async () => {
+            // 与完整下载一致按停滞超时中止：大块在慢速链路上持续有数据时不会被固定超时打断
+            const stall = stallSignal(TIMEOUT_CONFIG.STALL_TIMEOUT, options.signal);
+            try {
+                const response = await fetchWithPolicy(pdfUrl, {
+                    headers: { 'Range': `bytes=${start}-${end}` },
+                    signal: stall.signal,
+                }, network);
+                stall.touch();
+                status = response.status;
+                if (response.status !== 206) {
+                    await response.body?.cancel();
                     throw new Error(`Range request failed with status ${response.status}`);
                 }
-                return response.arrayBuffer();
-            })
-            .then(data => {
-                nativeRenderer.completeStreamRequest(requestId, Buffer.from(data), null);
+                contentRange = response.headers.get('content-range');
+                return await readIntoPool(response.body && stall.pipe(response.body), rangeBufferPool, size);
+            } finally {
+                stall.clear();
+            }
+        }, options.signal)
+            .then(({ data, release }) => {
+                // completeStreamRequest 同步复制数据，返回后即可归还缓冲区
+                try {
+                    // 源站返回的范围与请求不一致时，数据会被当作请求的块拼入文档
+                    validateContentRange(contentRange, data.length, start, end);
+                    nativeRenderer.completeStreamRequest(requestId, data, null);
+                    traceRequest({ requestId, offset: start, size, bytes: data.length, status, elapsed: Date.now() - fetchStart });
+                } finally {
+                    release();
+                }
             })
             .catch(err => {
                 logger.error(`Fetcher failed (offset=${start}, size=${size}): ${err.message}`);
                 nativeRenderer.completeStreamRequest(requestId, null, err.message);
+                traceRequest({ requestId, offset: start, size, bytes: 0, status, elapsed: Date.now() - fetchStart, error: err.message });
             });
     };
+}
+
+/**
+ * 使用 Native Stream 渲染远程 PDF
+ *
+ * 通过回调按需获取 PDF 数据，避免一次性下载整个文件
+ *
+ * @param {string} pdfUrl - PDF 文件 URL
+ * @param {number} pdfSize - PDF 文件大小
+ * @param {number[]} pages - 要渲染的页码数组（1-based，负数从末尾倒数），空数组表示全部页面
+ * @param {Object} options - 渲染选项（可包含 pickNetworkPolicy 的访问策略字段）
+ * @param {Object} [options.headers] - 附加到每个分片请求的请求头（如追踪上下文）
+ * @param {number} [options.maxPages] - 最大渲染页数，0 表示不限制
+ * @param {Function} [options.onRangeRequest] - 每个分片请求完成后的追踪回调，
+ *   参数为 { requestId, offset, size, bytes, status, elapsed, error }，默认关闭
+ * @param {AbortSignal} [options.signal] - 取消信号，触发后中止进行中和排队中的分片请求，并以 signal.reason 结束
+ * @param {Function} [options.onPage] - 每页渲染完成后立即以该页结果调用（同步调用，返回值被忽略），
+ *   所有调用都在返回前完成
+ * @returns {Promise<Object>} 渲染结果
+ */
+export async function renderFromStream(pdfUrl, pdfSize, pages = [], options = {}) {
+    if (!nativeAvailable) {
+        throw new Error('Native renderer not available');
+    }
+
+    if (!pdfUrl || !pdfSize) {
+        throw new Error('pdfUrl and pdfSize are required for stream mode');
+    }
+
+    const config = mergeConfig(options);
+    const network = pickNetworkPolicy(options);
+
+    await assertUrlAllowed(pdfUrl, network);
+
+    logger.debug(`Stream rendering from ${pdfUrl} (${(pdfSize / 1024 / 1024).toFixed(2)}MB)`);
+
+    const fetcher = createStreamFetcher(pdfUrl, network, options);
+
+    const toPage = page => ({
+        pageNum: page.pageNum,
+        width: page.width,
+        height: page.height,
+        buffer: page.success ? page.buffer : undefined,
+        success: page.success,
+        error: page.error,
+        renderTime: page.renderTime,
+        encodeTime: page.encodeTime,
+        scale: page.scale,
+        streamStats: page.streamStats,
+    });
+
+    // 传入 onPage 时原生渲染器每完成一页就回调（图像数据只随回调送达），不必等全部页面完成
+    const delivered = [];
+    const onNativePage = options.onPage && (page => {
+        const result = toPage(page);
+        delivered.push(result);
+        try {
+            options.onPage(result);
+        } catch (err) {
+            logger.warn(`onPage callback failed: ${err.message}`);
+        }
+    });
 
     const startTime = Date.now();
 
-    // 首次调用获取页数
+    // 首次调用获取页数（渲染全部页面或包含负数页码时，需要先知道总页数）
+    // 请求页数超过上限时也先获取总页数，以便在报错信息中给出文档页数
+    // 重复的页码只渲染一次（同 resolvePages）
+    const uniquePages = [...new Set(pages)];
+    const countFirst = needsPageCount(pages) || (options.maxPages > 0 && uniquePages.length > options.maxPages);
     let result = await nativeRenderer.renderPagesFromStream(
         pdfSize,
-        pages,
+        countFirst ? [] : uniquePages,
         config,
-        fetcher
+        fetcher,
+        onNativePage
     );
 
+    // 取消后分片请求全部失败，以取消原因结束而不是渲染错误
+    options.signal?.throwIfAborted();
     if (!result.success) {
         throw new Error(result.error || 'Native stream renderer failed');
     }
 
     const numPages = result.numPages;
+    assertHasPages(numPages);
 
-    // 如果需要渲染所有页面但之前不知道页数
-    if (pages.length === 0 && numPages > 0 && result.pages.length === 0) {
-        const allPages = Array.from({ length: numPages }, (_, i) => i + 1);
+    // 已知页数后解析目标页码再渲染
+    if (countFirst && numPages > 0) {
+        const targetPages = resolvePages(pages, numPages);
+        assertPagesInRange(pages, targetPages, numPages);
+        assertPageLimit(targetPages, numPages, options.maxPages);
         result = await nativeRenderer.renderPagesFromStream(
             pdfSize,
-            allPages,
+            targetPages,
             config,
-            fetcher
+            fetcher,
+            onNativePage
         );
 
+        options.signal?.throwIfAborted();
         if (!result.success) {
             throw new Error(result.error || 'Native stream renderer failed');
         }
@@ -314,16 +614,7 @@ export async function renderFromStream(pdfUrl, pdfSize, pages = [], options = {}
     return {
         success: true,
         numPages,
-        pages: result.pages.map(page => ({
-            pageNum: page.pageNum,
-            width: page.width,
-            height: page.height,
-            buffer: page.success ? page.buffer : undefined,
-            success: page.success,
-            error: page.error,
-            renderTime: page.renderTime,
-            encodeTime: page.encodeTime,
-        })),
+        pages: onNativePage ? delivered : result.pages.map(toPage),
         totalTime: Date.now() - startTime,
         nativeTime: result.totalTime,
         streamStats: result.streamStats,
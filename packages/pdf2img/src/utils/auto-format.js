@@ -0,0 +1,82 @@
+/**
+ * 按页面内容自动选择输出格式
+ *
+ * 文字、线稿页面由少量颜色（背景、文字色及其抗锯齿过渡）构成，无损 PNG 体积小且没有压缩伪影；
+ * 照片、扫描件颜色分布分散，有损 WebP 体积远小于 PNG。
+ * 按固定步长采样像素，统计量化后出现最多的若干种颜色覆盖的像素比例，以此区分两类页面。
+ */
+
+/**
+ * 每个通道量化保留的位数（4 位即每通道 16 级，合并抗锯齿和轻微色差）
+ */
+const QUANTIZE_BITS = 4;
+
+/**
+ * 统计覆盖率时取出现最多的颜色数
+ */
+const DOMINANT_COLORS = 16;
+
+/**
+ * 主要颜色覆盖率不低于该值时判定为文字/线稿页面
+ */
+const DOMINANT_COVERAGE = 0.9;
+
+/**
+ * 最多采样的像素数
+ */
+const MAX_SAMPLES = 65536;
+
+/**
+ * 统计位图（或裁剪区域）中主要颜色覆盖的像素比例
+ *
+ * 透明像素按白色背景混合。
+ *
+ * @param {Buffer} rawBitmap - 原始 RGBA 像素数据
+ * @param {number} width - 图像宽度
+ * @param {number} height - 图像高度
+ * @param {Object} [region] - 像素裁剪区域 { left, top, width, height }
+ * @returns {number} 覆盖率（0-1）
+ */
+export function dominantColorCoverage(rawBitmap, width, height, region) {
+    const { left = 0, top = 0, width: w = width, height: h = height } = region ?? {};
+    const step = Math.max(1, Math.ceil(Math.sqrt((w * h) / MAX_SAMPLES)));
+    const shift = 8 - QUANTIZE_BITS;
+    const counts = new Map();
+    let samples = 0;
+
+    for (let y = top; y < top + h; y += step) {
+        for (let x = left; x < left + w; x += step) {
+            const i = (y * width + x) * 4;
+            const alpha = rawBitmap[i + 3];
+            let key = 0;
+            for (let c = 0; c < 3; c++) {
+                const value = Math.round((rawBitmap[i + c] * alpha + 255 * (255 - alpha)) / 255);
+                key = (key << QUANTIZE_BITS) | (value >> shift);
+            }
+            counts.set(key, (counts.get(key) ?? 0) + 1);
+            samples++;
+        }
+    }
+
+    if (samples === 0) {
+        return 1;
+    }
+    const dominant = [...counts.values()]
+        .sort((a, b) => b - a)
+        .slice(0, DOMINANT_COLORS)
+        .reduce((sum, count) => sum + count, 0);
+    return dominant / samples;
+}
+
+/**
+ * 为页面选择输出格式
+ *
+ * @param {Buffer} rawBitmap - 原始 RGBA 像素数据
+ * @param {number} width - 图像宽度
+ * @param {number} height - 图像高度
+ * @param {Object} [region] - 像素裁剪区域
+ * @returns {'png'|'webp'} 文字/线稿页面为 'png'，照片类页面为 'webp'
+ */
+export function chooseAutoFormat(rawBitmap, width, height, region) {
+    return dominantColorCoverage(rawBitmap, width, height, region) >= DOMINANT_COVERAGE ? 'png' : 'webp';
+}
@@ -0,0 +1,151 @@
+/**
+ * 按需加载的块大小自动调优
+ *
+ * 不同源站的延迟和吞吐差异很大：高延迟源站适合大块（减少往返次数），低延迟、低吞吐的源站适合小块
+ * （减少用不到的下载）。校准时发送几个不同大小的 Range 请求，估算单次请求的固定延迟和吞吐，
+ * 以带宽时延积（一次往返时间内能传输的数据量）作为块大小：此时每个请求的传输时间与往返时间相当，
+ * 既不会被延迟主导，也不会过度预读。
+ *
+ * 原生渲染器逐块同步读取，请求并发由 PDFium 的读取顺序决定，这里只调整块大小。
+ *
+ * 延迟和吞吐是源站的属性，与具体文件无关：校准结果按 origin 缓存（PDF2IMG_AUTOTUNE_TTL），
+ * 有效期内同一源站的后续转换不再发送校准请求。
+ */
+
+import { performance } from 'perf_hooks';
+import { TIMEOUT_CONFIG, NETWORK_CONFIG } from '../core/config.js';
+import { fetchWithPolicy, timeoutSignal } from './http.js';
+import { limitFetch } from './limiter.js';
+
+/**
+ * 块大小范围（与原生渲染器的校验一致）
+ */
+const MIN_BLOCK_SIZE = 16 * 1024;
+const MAX_BLOCK_SIZE = 4 * 1024 * 1024;
+
+/**
+ * 校准请求大小：小请求的耗时近似为固定延迟，大请求用于估算吞吐
+ */
+const SMALL_PROBE_SIZE = 16 * 1024;
+const LARGE_PROBE_SIZE = 1024 * 1024;
+
+/**
+ * 小请求的次数，取最小耗时以排除连接建立等偶发开销
+ */
+const SMALL_PROBES = 2;
+
+/**
+ * 最多缓存的源站数，超出时淘汰最早写入的
+ */
+const MAX_CACHED_ORIGINS = 256;
+
+/**
+ * 校准结果缓存：origin -> { profile, expiresAt }
+ */
+const profiles = new Map();
+
+/**
+ * 根据延迟和吞吐选择块大小
+ *
+ * 取带宽时延积向上对齐到 2 的幂，并限制在 [16KB, 4MB]。
+ *
+ * @param {Object} profile
+ * @param {number} profile.latency - 单次请求的固定延迟（毫秒）
+ * @param {number} profile.throughput - 吞吐（字节/秒）
+ * @returns {number} 块大小（字节）
+ */
+export function chooseBlockSize({ latency, throughput }) {
+    const bandwidthDelay = (latency / 1000) * throughput;
+    let blockSize = MIN_BLOCK_SIZE;
+    while (blockSize < bandwidthDelay && blockSize < MAX_BLOCK_SIZE) {
+        blockSize *= 2;
+    }
+    return blockSize;
+}
+
+/**
+ * 发送一个 Range 请求并计时（包含读取完整响应体的时间）
+ */
+function timedRange(url, offset, size, network, signal) {
+    return limitFetch(async () => {
+        const start = performance.now();
+        const response = await fetchWithPolicy(url, {
+            headers: { 'Range': `bytes=${offset}-${offset + size - 1}` },
+            signal: timeoutSignal(TIMEOUT_CONFIG.RANGE_REQUEST_TIMEOUT, signal),
+        }, network);
+
+        if (response.status !== 206) {
+            await response.body?.cancel();
+            throw new Error(`Range request failed with status ${response.status}`);
+        }
+
+        const bytes = (await response.arrayBuffer()).byteLength;
+        return { bytes, elapsed: performance.now() - start };
+    });
+}
+
+/**
+ * 校准远程文件的块大小
+ *
+ * 依次发送 2 个 16KB 和 1 个 1MB（不超过文件大小）的 Range 请求。
+ * 文件小于两倍大请求时大小请求区分度不足，不做校准，返回 null。
+ * 同一 origin 在 PDF2IMG_AUTOTUNE_TTL 内已校准过时直接返回缓存的结果（cached 为 true，requests、bytes 为 0）。
+ *
+ * @param {string} url - PDF URL
+ * @param {number} fileSize - 文件大小
+ * @param {Object} [network] - 远程访问策略
+ * @param {AbortSignal} [signal] - 取消信号
+ * @returns {Promise<{ latency: number, throughput: number, blockSize: number, requests: number, bytes: number, cached: boolean }|null>}
+ *   latency 单位毫秒，throughput 单位字节/秒，requests、bytes 为本次校准发送的请求数和下载的字节数
+ */
+export async function calibrate(url, fileSize, network = {}, signal) {
+    if (fileSize < 2 * LARGE_PROBE_SIZE) {
+        return null;
+    }
+
+    const { origin } = new URL(url);
+    const entry = profiles.get(origin);
+    if (entry && entry.expiresAt > Date.now()) {
+        return { ...entry.profile, requests: 0, bytes: 0, cached: true };
+    }
+    profiles.delete(origin);
+
+    const small = [];
+    for (let i = 0; i < SMALL_PROBES; i++) {
+        small.push(await timedRange(url, 0, SMALL_PROBE_SIZE, network, signal));
+    }
+    const large = await timedRange(url, 0, LARGE_PROBE_SIZE, network, signal);
+
+    const fastest = small.reduce((a, b) => (a.elapsed <= b.elapsed ? a : b));
+    const latency = fastest.elapsed;
+    // 大请求多出的耗时即多出字节的传输时间；耗时差不可测时按大请求的平均速率估算
+    const transfer = large.elapsed - fastest.elapsed;
+    const throughput = transfer > 0
+        ? ((large.bytes - fastest.bytes) / transfer) * 1000
+        : (large.bytes / Math.max(large.elapsed, 1)) * 1000;
+
+    const profile = {
+        latency,
+        throughput,
+        blockSize: chooseBlockSize({ latency, throughput }),
+        requests: small.length + 1,
+        bytes: small.reduce((sum, probe) => sum + probe.bytes, large.bytes),
+        cached: false,
+    };
+
+    const ttl = NETWORK_CONFIG.AUTOTUNE_TTL;
+    if (ttl > 0) {
+        if (profiles.size >= MAX_CACHED_ORIGINS) {
+            profiles.delete(profiles.keys().next().value);
+        }
+        profiles.set(origin, { profile, expiresAt: Date.now() + ttl });
+    }
+    return profile;
+}
+
+/**
+ * 清空校准结果缓存
+ */
+export function clearCalibrationCache() {
+    profiles.clear();
+}
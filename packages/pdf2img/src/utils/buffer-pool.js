@@ -0,0 +1,105 @@
+/**
+ * 分片下载缓冲区复用
+ *
+ * 流式加载时每个分片请求都会下载一个固定大小的块（默认 256KB），大文件会产生大量请求。
+ * response.arrayBuffer() 每次都分配新的缓冲区，这里改为把响应体读入复用的缓冲区：
+ * 原生层收到数据后会立即复制一份（completeStreamRequest 同步返回），之后缓冲区即可归还。
+ */
+
+export class BufferPool {
+    /**
+     * @param {Object} [options]
+     * @param {number} [options.maxBuffers=16] - 每种大小最多保留的空闲缓冲区数量，超出的直接丢弃
+     */
+    constructor({ maxBuffers = 16 } = {}) {
+        this.maxBuffers = maxBuffers;
+        this.free = new Map();
+        this.allocated = 0;
+        this.reused = 0;
+    }
+
+    /**
+     * 获取指定大小的缓冲区（内容未初始化）
+     *
+     * @param {number} size - 字节数
+     * @returns {Buffer}
+     */
+    acquire(size) {
+        const buffer = this.free.get(size)?.pop();
+        if (buffer) {
+            this.reused++;
+            return buffer;
+        }
+        this.allocated++;
+        return Buffer.allocUnsafeSlow(size);
+    }
+
+    /**
+     * 归还缓冲区，归还后调用方不能再使用它
+     *
+     * @param {Buffer} buffer - acquire 返回的缓冲区
+     */
+    release(buffer) {
+        let list = this.free.get(buffer.length);
+        if (!list) {
+            list = [];
+            this.free.set(buffer.length, list);
+        }
+        if (list.length < this.maxBuffers) {
+            list.push(buffer);
+        }
+    }
+
+    /**
+     * 获取分配统计
+     *
+     * @returns {{ allocated: number, reused: number, pooled: number }} 新分配次数、复用次数和当前空闲的缓冲区数
+     */
+    getStats() {
+        let pooled = 0;
+        for (const list of this.free.values()) {
+            pooled += list.length;
+        }
+        return { allocated: this.allocated, reused: this.reused, pooled };
+    }
+}
+
+/**
+ * 将响应体读入池中的缓冲区
+ *
+ * 响应体超过 size（如服务器忽略 Range 返回了完整文件）时改为拼接成新的 Buffer，
+ * 与 response.arrayBuffer() 的结果一致，缓冲区立即归还。
+ *
+ * @param {ReadableStream} body - fetch 响应体
+ * @param {BufferPool} pool - 缓冲区池
+ * @param {number} size - 预期的最大字节数（请求的范围大小）
+ * @returns {Promise<{ data: Buffer, release: Function }>} data 为实际读到的数据；
+ *   使用完 data 后必须调用 release()，之后不能再访问 data
+ */
+export async function readIntoPool(body, pool, size) {
+    const buffer = pool.acquire(size);
+    let length = 0;
+    let overflow = null;
+
+    try {
+        for await (const chunk of body ?? []) {
+            if (overflow) {
+                overflow.push(chunk);
+            } else if (length + chunk.length > size) {
+                overflow = [Buffer.from(buffer.subarray(0, length)), chunk];
+            } else {
+                buffer.set(chunk, length);
+                length += chunk.length;
+            }
+        }
+    } catch (err) {
+        pool.release(buffer);
+        throw err;
+    }
+
+    if (overflow) {
+        pool.release(buffer);
+        return { data: Buffer.concat(overflow), release: () => {} };
+    }
+    return { data: buffer.subarray(0, length), release: () => pool.release(buffer) };
+}
@@ -0,0 +1,140 @@
+/**
+ * 按主机熔断
+ *
+ * 某个源站持续失败（超时、5xx、连接错误）时，每个请求都要耗尽超时才失败，并占用全局请求额度。
+ * 熔断器按主机（host:port）记录连续失败次数：
+ * - closed：正常放行，连续失败达到阈值后进入 open
+ * - open：直接以 ERR_CIRCUIT_OPEN 失败，冷却时间过后进入 half-open
+ * - half-open：只放行一个探测请求，成功则恢复 closed，失败则重新 open
+ *
+ * 与全局并发限制一样是进程级的，所有转换共享同一份状态。
+ */
+
+import { NETWORK_CONFIG } from '../core/config.js';
+
+export const ERR_CIRCUIT_OPEN = 'ERR_CIRCUIT_OPEN';
+
+/**
+ * 创建熔断错误
+ */
+function circuitOpenError(host, retryAfter) {
+    const err = new Error(`Circuit open for ${host}, retry after ${retryAfter}ms`);
+    err.code = ERR_CIRCUIT_OPEN;
+    err.host = host;
+    err.retryAfter = retryAfter;
+    return err;
+}
+
+export class CircuitBreaker {
+    /**
+     * @param {Object} [options]
+     * @param {number} [options.threshold] - 连续失败多少次后熔断（默认取 PDF2IMG_CIRCUIT_BREAKER_THRESHOLD，0 表示关闭熔断）
+     * @param {number} [options.cooldown] - 熔断后的冷却时间（毫秒，默认取 PDF2IMG_CIRCUIT_BREAKER_COOLDOWN）
+     */
+    constructor(options = {}) {
+        this.threshold = options.threshold ?? NETWORK_CONFIG.CIRCUIT_BREAKER_THRESHOLD;
+        this.cooldown = options.cooldown ?? NETWORK_CONFIG.CIRCUIT_BREAKER_COOLDOWN;
+        // host -> { state, failures, openedAt, probing }
+        this.circuits = new Map();
+    }
+
+    /**
+     * 请求前检查，熔断中直接抛出错误
+     *
+     * @param {string} host - 主机（host:port）
+     * @throws {Error} code 为 ERR_CIRCUIT_OPEN，retryAfter 为距离下次探测的毫秒数
+     */
+    acquire(host) {
+        const circuit = this.circuits.get(host);
+        if (this.threshold <= 0 || !circuit || circuit.state === 'closed') {
+            return;
+        }
+
+        const elapsed = Date.now() - circuit.openedAt;
+        if (circuit.state === 'open' && elapsed >= this.cooldown) {
+            circuit.state = 'half-open';
+            circuit.probing = false;
+        }
+
+        if (circuit.state === 'half-open' && !circuit.probing) {
+            circuit.probing = true;
+            return;
+        }
+
+        throw circuitOpenError(host, Math.max(0, this.cooldown - elapsed));
+    }
+
+    /**
+     * 记录请求成功，恢复 closed
+     * @param {string} host - 主机（host:port）
+     */
+    onSuccess(host) {
+        this.circuits.delete(host);
+    }
+
+    /**
+     * 记录请求失败，连续失败达到阈值或探测失败时熔断
+     * @param {string} host - 主机（host:port）
+     */
+    onFailure(host) {
+        if (this.threshold <= 0) {
+            return;
+        }
+        let circuit = this.circuits.get(host);
+        if (!circuit) {
+            circuit = { state: 'closed', failures: 0, openedAt: null, probing: false };
+            this.circuits.set(host, circuit);
+        }
+        circuit.failures++;
+        if (circuit.state === 'half-open' || circuit.failures >= this.threshold) {
+            circuit.state = 'open';
+            circuit.openedAt = Date.now();
+            circuit.probing = false;
+        }
+    }
+
+    /**
+     * 请求被调用方取消，不计入成功或失败；若是探测请求则允许下一个请求继续探测
+     * @param {string} host - 主机（host:port）
+     */
+    onCancel(host) {
+        const circuit = this.circuits.get(host);
+        if (circuit) {
+            circuit.probing = false;
+        }
+    }
+
+    /**
+     * 获取各主机的熔断状态（只包含有失败记录的主机）
+     *
+     * @returns {Object<string, { state: string, failures: number, openedAt: number|null }>}
+     */
+    getStats() {
+        const stats = {};
+        for (const [host, { state, failures, openedAt }] of this.circuits) {
+            // 冷却已结束但还没有请求触发探测时，对外报告为 half-open
+            const reported = state === 'open' && Date.now() - openedAt >= this.cooldown ? 'half-open' : state;
+            stats[host] = { state: reported, failures, openedAt };
+        }
+        return stats;
+    }
+}
+
+const sharedBreaker = new CircuitBreaker();
+
+/**
+ * 获取进程级共享的熔断器
+ * @returns {CircuitBreaker}
+ */
+export function getCircuitBreaker() {
+    return sharedBreaker;
+}
+
+/**
+ * 获取各主机的熔断状态
+ *
+ * @returns {Object<string, { state: string, failures: number, openedAt: number|null }>}
+ */
+export function getCircuitBreakerStats() {
+    return sharedBreaker.getStats();
+}
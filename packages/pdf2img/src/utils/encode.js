@@ -0,0 +1,122 @@
+/**
+ * 图像编码
+ *
+ * 所有输出格式的编码参数集中在这里（WebP 有损/无损、PNG 隔行、JPEG 质量与背景混合、大小上限），
+ * 工作线程渲染页面和主线程导出内嵌图片共用，新增格式或编码选项只需修改这一处。
+ */
+
+/**
+ * 透明像素混合使用的背景色（不支持透明通道的格式）
+ */
+const WHITE = { r: 255, g: 255, b: 255 };
+
+/**
+ * 有损编码，可选限制输出大小
+ *
+ * 指定 maxBytes 且初始质量的结果超出上限时，在 [1, quality) 内二分查找不超过上限的最高质量；
+ * 最低质量仍超出上限时返回最低质量的结果。
+ *
+ * @param {Function} encode - (quality) => Promise<Buffer>
+ * @param {number} quality - 初始（最高）质量
+ * @param {number} [maxBytes] - 输出大小上限（字节）
+ * @returns {Promise<{ buffer: Buffer, quality: number }>} 编码结果与实际使用的质量
+ */
+export async function encodeLossy(encode, quality, maxBytes) {
+    const initial = await encode(quality);
+    if (!maxBytes || initial.length <= maxBytes) {
+        return { buffer: initial, quality };
+    }
+
+    let low = 1;
+    let high = quality - 1;
+    let best = null;
+    let smallest = { buffer: initial, quality };
+
+    while (low <= high) {
+        const mid = Math.floor((low + high) / 2);
+        const buffer = await encode(mid);
+        if (buffer.length <= maxBytes) {
+            best = { buffer, quality: mid };
+            low = mid + 1;
+        } else {
+            smallest = { buffer, quality: mid };
+            high = mid - 1;
+        }
+    }
+
+    return best ?? smallest;
+}
+
+/**
+ * 将 sharp 图像编码为指定格式
+ *
+ * Sharp 默认不写入任何元数据（EXIF、时间戳等），PNG 编码本身是确定的；
+ * 确定性模式只需将 WebP 固定为无损编码。
+ *
+ * @param {import('sharp').Sharp} image - 已完成裁剪、旋转等处理的 sharp 图像
+ * @param {string} format - 输出格式：'webp'、'png'、'jpg'/'jpeg'
+ * @param {Object} [options] - 编码选项
+ * @param {number} [options.quality] - 通用质量（WebP/JPEG），被格式专用质量覆盖
+ * @param {number} [options.webpQuality=80] - WebP 质量
+ * @param {number} [options.webpMethod=4] - WebP 编码方法（0-6）
+ * @param {number} [options.jpegQuality=85] - JPEG 质量
+ * @param {number} [options.pngCompression=6] - PNG 压缩级别（0-9）
+ * @param {boolean} [options.progressive] - JPEG 使用渐进式编码，PNG 使用 Adam7 隔行扫描（默认分别为基线 JPEG 和非隔行 PNG）
+ * @param {boolean} [options.deterministic] - 确定性输出：相同输入产生相同字节
+ * @param {boolean} [options.grayscale] - 输出灰度图
+ * @param {number} [options.maxBytes] - WebP/JPEG 输出大小上限（字节），自动降低质量以满足上限
+ * @returns {Promise<{ buffer: Buffer, quality: number|null }>} 编码后的图像数据与实际使用的质量
+ *   （PNG 和无损 WebP 为 null）
+ */
+export async function encodeImage(image, format, options = {}) {
+    if (options.grayscale) {
+        image = image.toColourspace('b-w');
+    }
+
+    if (format === 'webp') {
+        if (options.deterministic) {
+            // 有损 WebP 不保证逐字节一致，确定性模式下改用无损编码
+            const buffer = await image.webp({
+                lossless: true,
+                effort: options.webpMethod ?? 4,
+            }).toBuffer();
+            return { buffer, quality: null };
+        }
+        return encodeLossy(
+            quality => image.clone().webp({
+                quality,
+                effort: options.webpMethod ?? 4,
+            }).toBuffer(),
+            options.webpQuality || options.quality || 80,
+            options.maxBytes
+        );
+    } else if (format === 'png') {
+        const buffer = await image.png({
+            compressionLevel: options.pngCompression ?? 6,
+            adaptiveFiltering: true,
+            // Adam7 隔行扫描
+            progressive: Boolean(options.progressive),
+        }).toBuffer();
+        return { buffer, quality: null };
+    } else if (format === 'jpeg' || format === 'jpg') {
+        // 移除 alpha 通道，与白色背景混合
+        const flattened = image.flatten({ background: WHITE });
+        const progressive = Boolean(options.progressive);
+        return encodeLossy(
+            quality => flattened.clone().jpeg({
+                quality,
+                // 展开 mozjpeg 预设：预设会强制渐进式编码，覆盖 progressive 选项
+                trellisQuantisation: true,
+                overshootDeringing: true,
+                quantisationTable: 3,
+                // 扫描优化只对渐进式 JPEG 有效
+                optimiseScans: progressive,
+                progressive,
+            }).toBuffer(),
+            options.jpegQuality || options.quality || 85,
+            options.maxBytes
+        );
+    }
+
+    throw new Error(`Unsupported format: ${format}`);
+}
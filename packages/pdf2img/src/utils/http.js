@@ -0,0 +1,459 @@
+/**
+ * HTTP 工具
+ *
+ * 所有访问远程 PDF 的请求都经过这里，统一处理：
+ * - 目标地址校验（主机白名单 + 内网地址拦截，防止 SSRF）
+ * - 手动跟随重定向，每一跳都重新校验目标地址，并限制次数、禁止 https → http 降级
+ * - 按主机熔断，持续失败的源站直接快速失败
+ * - 请求签名钩子（如访问私有 S3 的 SigV4），每个请求、每一跳单独签名
+ *
+ * 拦截内网地址时，除请求前校验外，连接也经过校验 DNS 解析结果的 undici Agent 建立：
+ * 校验的是实际连接的地址，DNS rebinding（校验时返回公网地址、连接时返回内网地址）同样会被拦截。
+ */
+
+import dns from 'dns';
+import net from 'net';
+import { Agent } from 'undici';
+import { SECURITY_CONFIG } from '../core/config.js';
+import { getCircuitBreaker } from './circuit-breaker.js';
+
+/**
+ * 重定向状态码
+ */
+const REDIRECT_STATUSES = new Set([301, 302, 303, 307, 308]);
+
+/**
+ * 跨域重定向时需要移除的凭证类请求头
+ */
+const CREDENTIAL_HEADERS = ['authorization', 'cookie', 'proxy-authorization'];
+
+/**
+ * 合并调用方的取消信号与请求超时
+ *
+ * @param {number} timeout - 超时（毫秒）
+ * @param {AbortSignal} [signal] - 调用方的取消信号
+ * @returns {AbortSignal} 任一触发即中止的信号
+ */
+export function timeoutSignal(timeout, signal) {
+    const timer = AbortSignal.timeout(timeout);
+    return signal ? AbortSignal.any([signal, timer]) : timer;
+}
+
+/**
+ * 解析 Content-Range 响应头
+ *
+ * 支持 `bytes start-end/size`、总大小未知（size 为星号）的形式，以及 416 响应中范围为星号、只有总大小的形式。
+ * 格式错误、数值超出安全整数范围、start > end 或 end 不小于 size 时返回 null，
+ * 调用方不会拿到无法用于计算偏移的值。
+ *
+ * @param {string|null} value - Content-Range 响应头
+ * @returns {{ start: number|null, end: number|null, size: number|null }|null}
+ *   start/end 为闭区间，范围为星号时为 null；size 未知时为 null
+ */
+export function parseContentRange(value) {
+    const match = /^bytes (?:(\d+)-(\d+)|\*)\/(\d+|\*)$/.exec((value ?? '').trim());
+    if (!match || (match[1] === undefined && match[3] === '*')) {
+        return null;
+    }
+
+    const start = match[1] === undefined ? null : Number(match[1]);
+    const end = match[2] === undefined ? null : Number(match[2]);
+    const size = match[3] === '*' ? null : Number(match[3]);
+
+    if ([start, end, size].some(n => n !== null && !Number.isSafeInteger(n))) {
+        return null;
+    }
+    if (start !== null && (start > end || (size !== null && end >= size))) {
+        return null;
+    }
+    return { start, end, size };
+}
+
+/**
+ * 分片响应与请求的范围不一致的错误码
+ */
+export const ERR_RANGE_MISMATCH = 'ERR_RANGE_MISMATCH';
+
+/**
+ * 校验 206 响应确实返回了请求的范围
+ *
+ * 配置错误的源站可能返回其他范围或更少的字节，拼接后的 PDF 会被悄悄破坏。
+ * 响应范围必须从请求的起点开始、在请求的终点结束（文件在请求终点之前结束时，到文件末尾为止），
+ * 响应体长度必须与 Content-Range 一致。
+ *
+ * @param {string|null} value - Content-Range 响应头
+ * @param {number} length - 实际收到的响应体字节数
+ * @param {number} [start] - 请求的起点（后缀范围请求不传，只校验长度）
+ * @param {number} [end] - 请求的终点（闭区间）
+ * @returns {{ start: number, end: number, size: number|null }} 解析后的 Content-Range
+ * @throws {Error} 不一致时 code 为 ERR_RANGE_MISMATCH
+ */
+export function validateContentRange(value, length, start, end) {
+    const mismatch = (message) => {
+        const err = new Error(message);
+        err.code = ERR_RANGE_MISMATCH;
+        return err;
+    };
+
+    const range = parseContentRange(value);
+    if (!range || range.start === null) {
+        throw mismatch(`Range response has a missing or invalid Content-Range header: ${value}`);
+    }
+    if (start !== undefined) {
+        const endsAtEof = range.end < end && range.size !== null && range.end === range.size - 1;
+        if (range.start !== start || (range.end !== end && !endsAtEof)) {
+            throw mismatch(`Range response mismatch: requested bytes ${start}-${end}, got ${range.start}-${range.end}`);
+        }
+    }
+    const expected = range.end - range.start + 1;
+    if (length !== expected) {
+        throw mismatch(`Range response body has ${length} bytes, Content-Range declares ${expected}`);
+    }
+    return range;
+}
+
+/**
+ * 连接停滞错误码
+ */
+export const ERR_STALLED = 'ERR_STALLED';
+
+/**
+ * 连接停滞检测：超过 timeout 毫秒没有任何进展（响应头或数据）时中止
+ *
+ * 与总超时不同，只要数据还在持续到达，慢速传输不会被中断；只有连接真正卡住才超时。
+ * 创建时即开始计时（覆盖建立连接、等待响应头），收到响应头后调用 touch()，
+ * 响应体经过 pipe 时每个数据块都会重新计时。用完后必须调用 clear()。
+ *
+ * @param {number} timeout - 停滞超时（毫秒）
+ * @param {AbortSignal} [signal] - 调用方的取消信号（如总超时）
+ * @returns {{ signal: AbortSignal, touch: Function, pipe: Function, clear: Function }}
+ *   pipe 为 stream.pipeline 可用的转换函数，透传数据并在每个数据块到达时 touch
+ */
+export function stallSignal(timeout, signal) {
+    const controller = new AbortController();
+    let timer = null;
+
+    const touch = () => {
+        clearTimeout(timer);
+        timer = setTimeout(() => {
+            controller.abort(Object.assign(new Error(`Connection stalled: no data received for ${timeout}ms`), {
+                code: ERR_STALLED,
+            }));
+        }, timeout);
+        timer.unref();
+    };
+    touch();
+
+    return {
+        signal: signal ? AbortSignal.any([signal, controller.signal]) : controller.signal,
+        touch,
+        async *pipe(source) {
+            for await (const chunk of source) {
+                touch();
+                yield chunk;
+            }
+        },
+        clear() {
+            clearTimeout(timer);
+        },
+    };
+}
+
+/**
+ * 内网、回环、链路本地、组播、保留等不允许访问的地址段
+ */
+const PRIVATE_NETWORKS = new net.BlockList();
+PRIVATE_NETWORKS.addSubnet('0.0.0.0', 8, 'ipv4');
+PRIVATE_NETWORKS.addSubnet('10.0.0.0', 8, 'ipv4');
+PRIVATE_NETWORKS.addSubnet('100.64.0.0', 10, 'ipv4');
+PRIVATE_NETWORKS.addSubnet('127.0.0.0', 8, 'ipv4');
+PRIVATE_NETWORKS.addSubnet('169.254.0.0', 16, 'ipv4');
+PRIVATE_NETWORKS.addSubnet('172.16.0.0', 12, 'ipv4');
+PRIVATE_NETWORKS.addSubnet('192.168.0.0', 16, 'ipv4');
+// 基准测试网段
+PRIVATE_NETWORKS.addSubnet('198.18.0.0', 15, 'ipv4');
+// 组播与保留地址（含 255.255.255.255）
+PRIVATE_NETWORKS.addSubnet('224.0.0.0', 4, 'ipv4');
+PRIVATE_NETWORKS.addSubnet('240.0.0.0', 4, 'ipv4');
+PRIVATE_NETWORKS.addAddress('::', 'ipv6');
+PRIVATE_NETWORKS.addAddress('::1', 'ipv6');
+// NAT64（64:ff9b::a.b.c.d 会被网关转换为 IPv4 地址 a.b.c.d，可能指向内网）
+PRIVATE_NETWORKS.addSubnet('64:ff9b::', 96, 'ipv6');
+PRIVATE_NETWORKS.addSubnet('fc00::', 7, 'ipv6');
+PRIVATE_NETWORKS.addSubnet('fe80::', 10, 'ipv6');
+
+/**
+ * 创建地址不允许访问的错误
+ */
+function urlNotAllowedError(message) {
+    const err = new Error(message);
+    err.code = 'ERR_URL_NOT_ALLOWED';
+    return err;
+}
+
+/**
+ * 判断 IP 是否属于内网/回环/链路本地地址
+ * @param {string} address - IPv4 或 IPv6 地址
+ * @returns {boolean}
+ */
+export function isPrivateAddress(address) {
+    // IPv4-mapped IPv6（::ffff:127.0.0.1）按 IPv4 处理
+    const lower = address.toLowerCase();
+    if (lower.startsWith('::ffff:') && net.isIPv4(lower.slice(7))) {
+        return PRIVATE_NETWORKS.check(lower.slice(7), 'ipv4');
+    }
+    return PRIVATE_NETWORKS.check(address, net.isIPv6(address) ? 'ipv6' : 'ipv4');
+}
+
+/**
+ * 拒绝解析到内网地址的 DNS 查询，签名与 dns.lookup 相同
+ *
+ * 用作 undici Agent 的 connect.lookup，在建立连接时校验实际使用的地址。
+ * 传入自定义 dispatcher 且需要拦截内网地址时，应在其中配置：
+ * `new Agent({ connect: { lookup: privateNetworkLookup } })`
+ *
+ * @param {string} hostname - 主机名
+ * @param {Object} options - dns.lookup 选项（undici 会传入 all: true）
+ * @param {Function} callback - 同 dns.lookup；解析到内网地址时以 code 为 ERR_URL_NOT_ALLOWED 的错误回调
+ */
+export function privateNetworkLookup(hostname, options, callback) {
+    dns.lookup(hostname, options, (err, address, family) => {
+        if (err) {
+            callback(err);
+            return;
+        }
+        const addresses = Array.isArray(address) ? address : [{ address, family }];
+        const blocked = addresses.find(entry => isPrivateAddress(entry.address));
+        if (blocked) {
+            callback(urlNotAllowedError(`Host resolves to a private address: ${hostname} (${blocked.address})`));
+            return;
+        }
+        callback(null, address, family);
+    });
+}
+
+/**
+ * 拦截内网地址时默认使用的连接池（懒加载），连接前校验 DNS 解析结果
+ */
+let guardedAgent = null;
+
+function getGuardedAgent() {
+    guardedAgent ??= new Agent({ connect: { lookup: privateNetworkLookup } });
+    return guardedAgent;
+}
+
+/**
+ * 判断主机名是否在白名单内
+ */
+function isHostAllowed(hostname, allowedHosts) {
+    if (!allowedHosts || allowedHosts.length === 0) {
+        return true;
+    }
+    const host = hostname.toLowerCase();
+    return allowedHosts.some(pattern => {
+        const p = pattern.toLowerCase();
+        if (p.startsWith('*.')) {
+            return host.endsWith(p.slice(1));
+        }
+        return host === p;
+    });
+}
+
+/**
+ * 校验远程地址是否允许访问
+ *
+ * @param {string} url - 目标地址
+ * @param {Object} [policy] - 访问策略
+ * @param {string[]} [policy.allowedHosts] - 主机白名单，为空表示不限制
+ * @param {boolean} [policy.blockPrivateNetwork] - 是否拦截内网地址
+ * @returns {Promise<URL>} 解析后的 URL
+ * @throws {Error} code 为 ERR_URL_NOT_ALLOWED
+ */
+export async function assertUrlAllowed(url, policy = {}) {
+    const {
+        allowedHosts = SECURITY_CONFIG.ALLOWED_HOSTS,
+        blockPrivateNetwork = SECURITY_CONFIG.BLOCK_PRIVATE_NETWORK,
+    } = policy;
+
+    let parsed;
+    try {
+        parsed = new URL(url);
+    } catch {
+        throw urlNotAllowedError(`Invalid URL: ${url}`);
+    }
+
+    if (parsed.protocol !== 'http:' && parsed.protocol !== 'https:') {
+        throw urlNotAllowedError(`URL scheme not allowed: ${parsed.protocol}`);
+    }
+
+    // IPv6 地址在 URL 中带方括号
+    const hostname = parsed.hostname.replace(/^\[|\]$/g, '');
+
+    if (!isHostAllowed(hostname, allowedHosts)) {
+        throw urlNotAllowedError(`Host not allowed: ${hostname}`);
+    }
+
+    if (blockPrivateNetwork) {
+        const addresses = net.isIP(hostname)
+            ? [{ address: hostname }]
+            : await dns.promises.lookup(hostname, { all: true });
+
+        for (const { address } of addresses) {
+            if (isPrivateAddress(address)) {
+                throw urlNotAllowedError(`Host resolves to a private address: ${hostname} (${address})`);
+            }
+        }
+    }
+
+    return parsed;
+}
+
+/**
+ * 从调用方选项中取出远程访问策略
+ *
+ * 下载、流式加载和 ZIP 条目读取共用同一组字段，各入口都经由这里取出，避免遗漏。
+ *
+ * @param {Object} [options] - 调用方选项
+ * @returns {{ allowedHosts, blockPrivateNetwork, maxFileSize, headers, signRequest, dispatcher }}
+ */
+export function pickNetworkPolicy(options = {}) {
+    return {
+        allowedHosts: options.allowedHosts,
+        blockPrivateNetwork: options.blockPrivateNetwork,
+        maxFileSize: options.maxFileSize,
+        headers: options.headers,
+        signRequest: options.signRequest,
+        dispatcher: options.dispatcher,
+    };
+}
+
+/**
+ * 经过熔断器发起单次请求
+ *
+ * 超时、连接错误和 5xx 计为失败；调用方主动取消（AbortError）不计入。
+ */
+async function fetchWithBreaker(breaker, url, init) {
+    const host = url.host;
+    breaker.acquire(host);
+
+    let response;
+    try {
+        response = await fetch(url, init);
+    } catch (err) {
+        // 连接时的地址校验拒绝访问：属于访问策略错误而非源站故障，原样抛出
+        if (err.cause?.code === 'ERR_URL_NOT_ALLOWED') {
+            breaker.onCancel(host);
+            throw err.cause;
+        }
+        if (err.name === 'AbortError') {
+            breaker.onCancel(host);
+        } else {
+            breaker.onFailure(host);
+        }
+        throw err;
+    }
+
+    if (response.status >= 500) {
+        breaker.onFailure(host);
+    } else {
+        breaker.onSuccess(host);
+    }
+    return response;
+}
+
+/**
+ * 按访问策略发起请求
+ *
+ * 与 fetch 用法一致，但会在请求前和每次重定向后校验目标地址。
+ * 重定向时保留 Range 等请求头；跳转到其他源时移除 Authorization/Cookie，
+ * 避免凭证泄露给第三方。
+ *
+ * 默认发送 Accept-Encoding: identity：Range 偏移和 Content-Length 都以原始字节计算，
+ * 服务器对响应压缩后两者都会失效（调用方显式设置 Accept-Encoding 时不覆盖）。
+ *
+ * @param {string} url - 目标地址
+ * @param {RequestInit} [init] - fetch 参数
+ * @param {Object} [policy] - 访问策略，除 assertUrlAllowed 的字段外还支持：
+ * @param {number} [policy.maxRedirects] - 最大重定向次数（默认取 PDF2IMG_MAX_REDIRECTS）
+ * @param {Function} [policy.onRedirect] - 重定向检查钩子 (from: URL, to: URL) => void，抛出异常即拒绝
+ * @param {Object} [policy.headers] - 附加到每个请求的请求头（如追踪上下文），不覆盖 init.headers 中的同名头
+ * @param {Function} [policy.signRequest] - 请求签名钩子 ({ method, url, headers }) => void | Promise<void>，
+ *   在每个请求发出前调用，直接修改 headers（Headers 实例）添加签名；headers 已包含 Range 等请求头，
+ *   每次调用拿到的都是未签名的副本。同源重定向会重新签名，跳转到其他源后不再签名（与凭证头的处理一致）。
+ *   抛出异常即中止请求
+ * @param {Object} [policy.dispatcher] - undici Dispatcher（如 new Agent({ allowH2: true })），
+ *   控制连接复用、keep-alive 与 HTTP 版本；默认使用 fetch 的全局连接池（HTTP/1.1 keep-alive），
+ *   拦截内网地址时默认使用连接前校验地址的连接池。自定义 dispatcher 不会被替换，
+ *   需要自行配置 connect.lookup 为 privateNetworkLookup 才能防御 DNS rebinding
+ * @param {CircuitBreaker} [policy.circuitBreaker] - 熔断器（默认使用进程级共享实例）
+ * @returns {Promise<Response>}
+ * @throws {Error} 目标主机熔断中时 code 为 ERR_CIRCUIT_OPEN
+ */
+export async function fetchWithPolicy(url, init = {}, policy = {}) {
+    const {
+        maxRedirects = SECURITY_CONFIG.MAX_REDIRECTS,
+        onRedirect,
+        signRequest,
+        circuitBreaker = getCircuitBreaker(),
+        blockPrivateNetwork = SECURITY_CONFIG.BLOCK_PRIVATE_NETWORK,
+    } = policy;
+    // 每一跳（包括重定向）都经过该连接池，连接时再次校验实际使用的地址
+    const dispatcher = policy.dispatcher ?? (blockPrivateNetwork ? getGuardedAgent() : undefined);
+    const method = (init.method ?? 'GET').toUpperCase();
+    let currentUrl = await assertUrlAllowed(url, policy);
+    const origin = currentUrl.origin;
+    let headers = new Headers(policy.headers);
+    for (const [name, value] of new Headers(init.headers)) {
+        headers.set(name, value);
+    }
+    if (!headers.has('accept-encoding')) {
+        headers.set('accept-encoding', 'identity');
+    }
+
+    for (let redirects = 0; ; redirects++) {
+        let requestHeaders = headers;
+        if (signRequest && currentUrl.origin === origin) {
+            requestHeaders = new Headers(headers);
+            await signRequest({ method, url: currentUrl, headers: requestHeaders });
+        }
+
+        const response = await fetchWithBreaker(circuitBreaker, currentUrl, {
+            ...init,
+            headers: requestHeaders,
+            redirect: 'manual',
+            ...(dispatcher && { dispatcher }),
+        });
+        const location = response.headers.get('location');
+
+        if (!REDIRECT_STATUSES.has(response.status) || !location) {
+            return response;
+        }
+
+        await response.body?.cancel();
+
+        if (redirects >= maxRedirects) {
+            throw urlNotAllowedError(`Too many redirects (max ${maxRedirects})`);
+        }
+
+        const nextUrl = new URL(location, currentUrl);
+
+        if (currentUrl.protocol === 'https:' && nextUrl.protocol === 'http:') {
+            throw urlNotAllowedError(`Redirect downgrade not allowed: ${currentUrl.origin} -> ${nextUrl.origin}`);
+        }
+
+        await assertUrlAllowed(nextUrl.toString(), policy);
+
+        if (onRedirect) {
+            onRedirect(currentUrl, nextUrl);
+        }
+
+        if (nextUrl.origin !== currentUrl.origin) {
+            headers = new Headers(headers);
+            for (const name of CREDENTIAL_HEADERS) {
+                headers.delete(name);
+            }
+        }
+
+        currentUrl = nextUrl;
+    }
+}
@@ -0,0 +1,83 @@
+/**
+ * 全局并发限制
+ *
+ * p-limit 在单次转换内限制并发；这里的限制器是进程级的，
+ * 所有转换共享同一份额度，避免高并发时同时发起成百上千个远程请求。
+ *
+ * 完整下载可能持续数十秒，使用独立的额度：否则少量大文件下载就能长时间占满
+ * 分片请求的额度，让同时进行的按需加载渲染全部排队。
+ */
+
+import pLimit from 'p-limit';
+import { NETWORK_CONFIG } from '../core/config.js';
+
+const fetchLimit = pLimit(NETWORK_CONFIG.MAX_CONCURRENT_FETCHES);
+const downloadLimit = pLimit(NETWORK_CONFIG.MAX_CONCURRENT_DOWNLOADS);
+
+/**
+ * 在指定额度内执行 fn，排队期间响应取消
+ */
+function runLimited(limit, fn, signal) {
+    if (!signal) {
+        return limit(fn);
+    }
+    if (signal.aborted) {
+        return Promise.reject(signal.reason);
+    }
+
+    return new Promise((resolve, reject) => {
+        // 只在排队期间提前结束；fn 开始后由它自己清理并结束
+        const onAbort = () => reject(signal.reason);
+        signal.addEventListener('abort', onAbort, { once: true });
+        limit(() => {
+            signal.removeEventListener('abort', onAbort);
+            return signal.aborted ? undefined : fn();
+        }).then(resolve, reject);
+    });
+}
+
+/**
+ * 在全局远程请求额度内执行 fn
+ *
+ * fn 应覆盖完整的请求过程（包括读取响应体），额度在 fn 结束后才释放。
+ * 传入 signal 时，排队期间取消会立即以 signal.reason 结束，轮到时也不再执行 fn；
+ * 已经开始的 fn 需要自行响应 signal，额度在它结束后释放。
+ *
+ * @param {Function} fn - 返回 Promise 的请求函数
+ * @param {AbortSignal} [signal] - 取消信号
+ * @returns {Promise<*>} fn 的结果
+ */
+export function limitFetch(fn, signal) {
+    return runLimited(fetchLimit, fn, signal);
+}
+
+/**
+ * 在全局完整下载额度内执行 fn（不占用 limitFetch 的额度）
+ *
+ * 取消行为同 limitFetch。
+ *
+ * @param {Function} fn - 返回 Promise 的下载函数
+ * @param {AbortSignal} [signal] - 取消信号
+ * @returns {Promise<*>} fn 的结果
+ */
+export function limitDownload(fn, signal) {
+    return runLimited(downloadLimit, fn, signal);
+}
+
+/**
+ * 获取全局远程请求的并发状态
+ *
+ * @returns {{ limit: number, active: number, pending: number, downloads: { limit: number, active: number, pending: number } }}
+ */
+export function getFetchStats() {
+    return {
+        limit: fetchLimit.concurrency,
+        active: fetchLimit.activeCount,
+        pending: fetchLimit.pendingCount,
+        downloads: {
+            limit: downloadLimit.concurrency,
+            active: downloadLimit.activeCount,
+            pending: downloadLimit.pendingCount,
+        },
+    };
+}
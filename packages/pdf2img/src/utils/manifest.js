@@ -0,0 +1,47 @@
+/**
+ * 输出清单（manifest）
+ *
+ * 描述一次转换输出的每个页面（页码、尺寸、格式、大小、文件名或 COS key），
+ * 字段与 convert 结果中的页面结果一致，客户端无需逐个读取图片即可构建分页、选择 MIME 类型。
+ */
+
+import path from 'path';
+
+/**
+ * 清单格式版本，字段有不兼容变化时递增
+ */
+export const MANIFEST_VERSION = 1;
+
+/**
+ * 清单文件名（COS 输出时位于 key 前缀下；文件输出时加上文件名前缀，如 page_manifest.json）
+ */
+export const MANIFEST_NAME = 'manifest.json';
+
+/**
+ * 生成输出清单
+ *
+ * @param {Object} params
+ * @param {number} params.numPages - PDF 总页数
+ * @param {string} params.format - 请求的输出格式（'auto' 时以每页的 format 为准）
+ * @param {Object[]} params.pages - 输出后的页面结果
+ * @returns {Object} { version, numPages, format, pages }，文件输出时 file 为相对清单所在目录的文件名
+ */
+export function buildManifest({ numPages, format, pages }) {
+    return {
+        version: MANIFEST_VERSION,
+        numPages,
+        format,
+        pages: pages.map(page => ({
+            pageNum: page.pageNum,
+            success: page.success,
+            width: page.width,
+            height: page.height,
+            format: page.success ? page.format : undefined,
+            size: page.size ?? page.buffer?.length,
+            file: page.outputPath ? path.basename(page.outputPath) : undefined,
+            cosKey: page.cosKey ?? undefined,
+            placeholder: page.placeholder,
+            error: page.error,
+        })),
+    };
+}
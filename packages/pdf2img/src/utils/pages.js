@@ -0,0 +1,235 @@
+/**
+ * 页码工具
+ *
+ * 页码约定：
+ * - 正数按 1-based 处理：1 表示第一页
+ * - 负数从末尾倒数：-1 表示最后一页，-2 表示倒数第二页
+ * - 0 没有对应页面，视为无效
+ * - 空数组表示全部页面
+ */
+
+/**
+ * 解析命令行页码参数（逗号分隔，如 "1,2,-1"）
+ *
+ * 每一项必须是完整的整数：`1.5`、`2abc`、`1e3` 等部分可解析的写法与 `abc` 一样被忽略，
+ * 超出安全整数范围的数值也被忽略，不会被截断或变成不精确的页码。
+ *
+ * @param {string} spec - 页码字符串
+ * @returns {number[]} 页码数组（未结合总页数解析负数）
+ */
+export function parsePages(spec) {
+    if (!spec) {
+        return [];
+    }
+    return spec
+        .split(',')
+        .map(p => p.trim())
+        .filter(p => /^-?\d+$/.test(p))
+        .map(Number)
+        .filter(p => Number.isSafeInteger(p) && p !== 0);
+}
+
+/**
+ * 显式请求全部页面的取值（PDF2IMG_DEFAULT_PAGES 生效时，空数组不再表示全部页面）
+ */
+export const ALL_PAGES = 'all';
+
+/**
+ * 应用默认页码集合
+ *
+ * 未指定页码（undefined 或空数组）时：defaultPages 为 0 表示全部页面（兼容原有行为），
+ * 大于 0 时只取前 defaultPages 页，避免大文档在调用方遗漏 pages 时被整体渲染。
+ * 传入 'all' 始终表示全部页面。
+ *
+ * @param {number[]|string|undefined} pages - 请求的页码
+ * @param {number} defaultPages - 未指定页码时默认渲染的页数，0 表示全部
+ * @returns {number[]} 页码数组，空数组表示全部
+ * @throws {Error} pages 既不是数组也不是 'all' 时抛出
+ */
+export function applyDefaultPages(pages, defaultPages) {
+    if (pages === ALL_PAGES) {
+        return [];
+    }
+    if (pages !== undefined && !Array.isArray(pages)) {
+        throw new Error(`Invalid pages: ${pages}. Must be an array of page numbers or '${ALL_PAGES}'`);
+    }
+    if ((!pages || pages.length === 0) && defaultPages > 0) {
+        return Array.from({ length: defaultPages }, (_, i) => i + 1);
+    }
+    return pages ?? [];
+}
+
+/**
+ * 负数页码换算为实际页码并过滤超出范围的页码，保留重复
+ */
+function resolveEach(pages, numPages) {
+    return pages
+        .map(p => (p < 0 ? numPages + 1 + p : p))
+        .filter(p => p >= 1 && p <= numPages);
+}
+
+/**
+ * 超出范围的页码对应的失败结果
+ *
+ * 部分页码越界时，越界的页码不渲染，作为失败页面返回（按请求的写法，如 -10、9999），
+ * 计入 failedPages，使 partial 能反映请求中的无效页码。重复的越界页码只返回一次。
+ *
+ * @param {number[]} pages - 请求的页码，空数组表示全部
+ * @param {number} numPages - PDF 总页数
+ * @returns {Object[]} [{ pageNum, success: false, error: 'Page N out of range', ... }]
+ */
+export function outOfRangePages(pages, numPages) {
+    if (!pages || pages.length === 0) {
+        return [];
+    }
+    const invalid = pages.filter(p => resolveEach([p], numPages).length === 0);
+    return [...new Set(invalid)].map(pageNum => ({
+        pageNum,
+        success: false,
+        error: `Page ${pageNum} out of range`,
+        width: 0,
+        height: 0,
+        buffer: null,
+        renderTime: 0,
+        encodeTime: 0,
+    }));
+}
+
+/**
+ * 结合总页数解析请求的页码
+ *
+ * 负数页码换算为实际页码，超出范围的页码被过滤（convert 通过 outOfRangePages 将其作为失败页面返回）。
+ * 重复的页码（包括负数换算后重复的，如 5 页文档的 [5, -1]）只保留第一次出现，每页只渲染一次；
+ * convert 通过 expandToRequest 将结果映射回所有请求位置。
+ *
+ * @param {number[]} pages - 请求的页码，空数组表示全部
+ * @param {number} numPages - PDF 总页数
+ * @returns {number[]} 不重复的实际页码（1-based），按第一次出现的顺序
+ */
+export function resolvePages(pages, numPages) {
+    if (!pages || pages.length === 0) {
+        return Array.from({ length: numPages }, (_, i) => i + 1);
+    }
+    return [...new Set(resolveEach(pages, numPages))];
+}
+
+/**
+ * 将页面结果映射回请求的所有位置
+ *
+ * 重复请求的页码只渲染一次（见 resolvePages），这里按请求顺序为每个位置放置对应结果，
+ * 重复位置使用结果的浅拷贝（共享图片数据）。没有结果的页码（如被 skipBlankPages 跳过）不占位置，
+ * 不在请求中的结果按原顺序排在最后。没有重复页码时原样返回。
+ *
+ * @param {Object[]} results - 页面结果（含 pageNum，每页最多一个）
+ * @param {number[]} pages - 请求的页码，空数组表示全部
+ * @param {number} numPages - PDF 总页数
+ * @returns {Object[]} 与请求位置一一对应的页面结果
+ */
+export function expandToRequest(results, pages, numPages) {
+    if (!pages || pages.length === 0) {
+        return results;
+    }
+    const requested = resolveEach(pages, numPages);
+    if (new Set(requested).size === requested.length) {
+        return results;
+    }
+
+    const byPage = new Map(results.map(page => [page.pageNum, page]));
+    const placed = new Set();
+    const expanded = [];
+    for (const pageNum of requested) {
+        const page = byPage.get(pageNum);
+        if (!page) {
+            continue;
+        }
+        expanded.push(placed.has(pageNum) ? { ...page } : page);
+        placed.add(pageNum);
+    }
+    return [...expanded, ...results.filter(page => !placed.has(page.pageNum))];
+}
+
+/**
+ * 是否需要先获取总页数才能确定目标页码
+ *
+ * @param {number[]} pages - 请求的页码
+ * @returns {boolean}
+ */
+export function needsPageCount(pages) {
+    return !pages || pages.length === 0 || pages.some(p => p < 0);
+}
+
+/**
+ * 检查文档是否有页面
+ *
+ * 没有页面的 PDF 结构合法但无可渲染内容，直接报错，避免调用方拿到空结果却无法区分原因。
+ *
+ * @param {number} numPages - PDF 总页数
+ * @throws {Error} 页数为 0 时抛出，err.code 为 'ERR_NO_PAGES'
+ */
+export function assertHasPages(numPages) {
+    if (numPages === 0) {
+        const err = new Error('PDF has no pages');
+        err.code = 'ERR_NO_PAGES';
+        throw err;
+    }
+}
+
+/**
+ * 检查请求的页码是否全部超出范围
+ *
+ * 部分页码越界时只渲染范围内的页码，越界的页码作为失败页面返回（见 outOfRangePages）；全部越界说明请求本身有误（如 3 页文档请求第 9999 页），
+ * 在渲染前直接报错，避免逐页失败。
+ *
+ * @param {number[]} pages - 请求的页码，空数组表示全部
+ * @param {number[]} targetPages - 解析后的目标页码
+ * @param {number} numPages - PDF 总页数
+ * @throws {Error} 全部越界时抛出，err.code 为 'ERR_PAGE_OUT_OF_RANGE'
+ */
+export function assertPagesInRange(pages, targetPages, numPages) {
+    if (pages && pages.length > 0 && targetPages.length === 0 && numPages > 0) {
+        const err = new Error(
+            `Requested pages out of range: ${pages.join(', ')} (document has ${numPages} pages)`
+        );
+        err.code = 'ERR_PAGE_OUT_OF_RANGE';
+        throw err;
+    }
+}
+
+/**
+ * 检查目标页数是否超过上限
+ *
+ * @param {number[]} targetPages - 解析后的目标页码
+ * @param {number} numPages - PDF 总页数
+ * @param {number} maxPages - 单次转换最大页数，0 表示不限制
+ * @throws {Error} 超过上限时抛出，err.code 为 'ERR_TOO_MANY_PAGES'
+ */
+export function assertPageLimit(targetPages, numPages, maxPages) {
+    if (maxPages > 0 && targetPages.length > maxPages) {
+        const err = new Error(
+            `Too many pages requested: ${targetPages.length} (document has ${numPages} pages, max ${maxPages} per conversion)`
+        );
+        err.code = 'ERR_TOO_MANY_PAGES';
+        throw err;
+    }
+}
+
+/**
+ * 按请求顺序排列页面结果
+ *
+ * 页面并发渲染、完成顺序不确定，结果统一按目标页码的顺序返回：results[i] 对应 targetPages[i]。
+ * 重复请求的页码保持结果中的相对顺序；不在 targetPages 中的结果按页码升序排在最后。
+ *
+ * @param {Object[]} results - 页面结果（含 pageNum）
+ * @param {number[]} targetPages - 解析后的目标页码
+ * @returns {Object[]} 排序后的新数组
+ */
+export function orderByRequest(results, targetPages) {
+    const order = new Map();
+    targetPages.forEach((pageNum, index) => {
+        if (!order.has(pageNum)) {
+            order.set(pageNum, index);
+        }
+    });
+    const rank = page => order.get(page.pageNum) ?? targetPages.length + page.pageNum;
+    return [...results].sort((a, b) => rank(a) - rank(b));
+}
@@ -0,0 +1,36 @@
+/**
+ * PDF 文件结构工具
+ */
+
+/**
+ * 线性化检测需要读取的文件头长度
+ *
+ * PDF 规范要求线性化参数字典是文件的第一个间接对象，位于前 1024 字节内。
+ */
+export const LINEARIZATION_PROBE_SIZE = 1024;
+
+/**
+ * 判断 PDF 是否为线性化（Web 优化）文件
+ *
+ * 线性化文件的首页数据和交叉引用位于文件开头，按需加载时只需少量字节即可渲染首页。
+ * 如果文件在线性化之后被增量修改，参数字典中的 /L（文件长度）会与实际大小不符，
+ * 此时线性化信息已失效，视为非线性化。
+ *
+ * @param {Buffer} header - 文件开头的数据（至少 LINEARIZATION_PROBE_SIZE 字节，文件更小时为全部数据）
+ * @param {number} [fileSize] - 文件实际大小，提供时校验 /L
+ * @returns {boolean}
+ */
+export function isLinearized(header, fileSize) {
+    const text = header.subarray(0, LINEARIZATION_PROBE_SIZE).toString('latin1');
+    const start = text.search(/\/Linearized\b/);
+    if (start === -1) {
+        return false;
+    }
+
+    if (fileSize === undefined) {
+        return true;
+    }
+
+    const length = /\/L\s+(\d+)/.exec(text.slice(start));
+    return !length || Number(length[1]) === fileSize;
+}
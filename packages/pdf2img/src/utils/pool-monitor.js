@@ -0,0 +1,61 @@
+/**
+ * 线程池饱和度监控
+ *
+ * Piscina 不区分正在执行和排队的任务数，这里在提交和完成时计数：
+ * 进行中的任务数达到线程数即为饱和，新任务只能排队。
+ * 短暂饱和是正常的批量渲染，持续饱和超过阈值说明请求正在堆积，健康状态报告为 degraded。
+ */
+
+export class PoolMonitor {
+    /**
+     * @param {Object} options
+     * @param {number} options.maxThreads - 线程数
+     * @param {number} options.degradedAfter - 持续饱和多久（毫秒）后报告为 degraded
+     */
+    constructor({ maxThreads, degradedAfter }) {
+        this.maxThreads = maxThreads;
+        this.degradedAfter = degradedAfter;
+        this.inFlight = 0;
+        this.saturatedSince = null;
+    }
+
+    /**
+     * 记录任务提交
+     */
+    start() {
+        this.inFlight++;
+        if (this.inFlight >= this.maxThreads && this.saturatedSince === null) {
+            this.saturatedSince = Date.now();
+        }
+    }
+
+    /**
+     * 记录任务完成（成功、失败或超时）
+     */
+    finish() {
+        this.inFlight = Math.max(0, this.inFlight - 1);
+        if (this.inFlight < this.maxThreads) {
+            this.saturatedSince = null;
+        }
+    }
+
+    /**
+     * 获取当前状态
+     *
+     * @returns {{ max: number, active: number, idle: number, queued: number, saturated: boolean,
+     *   saturatedFor: number, status: 'ok'|'degraded' }}
+     */
+    getStats() {
+        const active = Math.min(this.inFlight, this.maxThreads);
+        const saturatedFor = this.saturatedSince === null ? 0 : Date.now() - this.saturatedSince;
+        return {
+            max: this.maxThreads,
+            active,
+            idle: this.maxThreads - active,
+            queued: this.inFlight - active,
+            saturated: this.saturatedSince !== null,
+            saturatedFor,
+            status: this.saturatedSince !== null && saturatedFor >= this.degradedAfter ? 'degraded' : 'ok',
+        };
+    }
+}
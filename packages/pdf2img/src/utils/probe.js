@@ -0,0 +1,68 @@
+/**
+ * 远程 PDF 预检
+ *
+ * 在正式转换前快速确认 URL 可访问、是否支持 Range 请求，不下载文件内容。
+ */
+
+import { TIMEOUT_CONFIG } from '../core/config.js';
+import { fetchWithPolicy, parseContentRange, timeoutSignal } from './http.js';
+import { limitFetch } from './limiter.js';
+
+/**
+ * 整理预检结果
+ */
+function toProbeResult(response, size, acceptsRanges) {
+    return {
+        statusCode: response.status,
+        size: Number.isFinite(size) ? size : null,
+        acceptsRanges,
+        contentType: response.headers.get('content-type'),
+        etag: response.headers.get('etag'),
+    };
+}
+
+/**
+ * 预检远程 PDF
+ *
+ * 先发送 HEAD 请求；HEAD 不可用（如 405）、缺少 Content-Length 或未声明 Accept-Ranges 时，
+ * 改用 1 字节的 Range 请求确认（响应 206 即支持 Range），不会读取完整响应体。
+ *
+ * @param {string} url - PDF URL
+ * @param {Object} [options] - 远程访问策略（allowedHosts、blockPrivateNetwork 等）
+ * @param {number} [options.timeout] - 单次请求超时（毫秒，默认取 RANGE_REQUEST_TIMEOUT）
+ * @param {AbortSignal} [options.signal] - 取消信号，触发后立即以 AbortError 结束预检
+ * @returns {Promise<{ statusCode: number, size: number|null, acceptsRanges: boolean, contentType: string|null, etag: string|null }>}
+ *   服务器返回错误状态时不抛出异常，由调用方根据 statusCode 判断
+ */
+export async function probeUrl(url, options = {}) {
+    const { timeout = TIMEOUT_CONFIG.RANGE_REQUEST_TIMEOUT, signal, ...policy } = options;
+
+    const head = await limitFetch(() => fetchWithPolicy(url, {
+        method: 'HEAD',
+        signal: timeoutSignal(timeout, signal),
+    }, policy));
+
+    const acceptRanges = head.headers.get('accept-ranges')?.toLowerCase();
+    const contentLength = parseInt(head.headers.get('content-length'), 10);
+
+    if (head.ok && acceptRanges && Number.isFinite(contentLength)) {
+        return toProbeResult(head, contentLength, acceptRanges === 'bytes');
+    }
+
+    return limitFetch(async () => {
+        const response = await fetchWithPolicy(url, {
+            headers: { 'Range': 'bytes=0-0' },
+            signal: timeoutSignal(timeout, signal),
+        }, policy);
+        // 服务器不支持 Range 时会返回完整文件，只读取响应头
+        await response.body?.cancel();
+
+        if (response.status === 206) {
+            return toProbeResult(response, parseContentRange(response.headers.get('content-range'))?.size ?? null, true);
+        }
+        if (response.ok) {
+            return toProbeResult(response, parseInt(response.headers.get('content-length'), 10), false);
+        }
+        return toProbeResult(response, head.ok ? contentLength : null, false);
+    });
+}
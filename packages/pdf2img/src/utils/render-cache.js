@@ -0,0 +1,120 @@
+/**
+ * 渲染结果缓存
+ *
+ * 同一页面以相同参数被反复请求时，直接返回缓存的编码结果，跳过下载和渲染。
+ * 内置实现为按字节数限制容量的内存 LRU，条目超过 TTL 后失效。
+ *
+ * 也可以传入自定义存储（如 Redis），只需实现：
+ * - get(key) => value | undefined（可返回 Promise）
+ * - set(key, value, size)（可返回 Promise）
+ */
+
+import crypto from 'crypto';
+
+/**
+ * 计算缓存 key
+ *
+ * @param {...*} parts - 参与计算的字段（文档标识、页码、编码参数等）
+ * @returns {string} sha256 十六进制摘要
+ */
+export function cacheKey(...parts) {
+    return crypto.createHash('sha256').update(JSON.stringify(parts)).digest('hex');
+}
+
+/**
+ * 计算 Buffer 内容摘要，用作 Buffer 输入的文档标识
+ *
+ * @param {Buffer} buffer - PDF 数据
+ * @returns {string} sha256 十六进制摘要
+ */
+export function hashBuffer(buffer) {
+    return crypto.createHash('sha256').update(buffer).digest('hex');
+}
+
+/**
+ * 创建内存 LRU 渲染缓存
+ *
+ * @param {Object} [options]
+ * @param {number} [options.maxBytes=268435456] - 缓存总字节数上限（默认 256MB），超出时淘汰最久未使用的条目
+ * @param {number} [options.ttl=0] - 条目有效期（毫秒），0 表示不过期
+ * @returns {Object} 缓存实例 { get, set, clear, getStats }
+ */
+export function createRenderCache(options = {}) {
+    const maxBytes = options.maxBytes ?? 256 * 1024 * 1024;
+    const ttl = options.ttl ?? 0;
+    // Map 按插入顺序迭代，第一个即最久未使用的条目
+    const entries = new Map();
+    let bytes = 0;
+    let hits = 0;
+    let misses = 0;
+    let evictions = 0;
+
+    const remove = (key, entry) => {
+        entries.delete(key);
+        bytes -= entry.size;
+    };
+
+    return {
+        /**
+         * 读取缓存，命中时将条目移到最近使用的位置；未命中或已过期时返回 undefined
+         */
+        get(key) {
+            const entry = entries.get(key);
+            if (!entry || (entry.expiresAt && entry.expiresAt <= Date.now())) {
+                if (entry) {
+                    remove(key, entry);
+                }
+                misses++;
+                return undefined;
+            }
+
+            entries.delete(key);
+            entries.set(key, entry);
+            hits++;
+            return entry.value;
+        },
+
+        /**
+         * 写入缓存，size 为条目占用的字节数；单个条目超过容量上限时不缓存
+         */
+        set(key, value, size = 0) {
+            const existing = entries.get(key);
+            if (existing) {
+                remove(key, existing);
+            }
+            if (size > maxBytes) {
+                return;
+            }
+
+            entries.set(key, {
+                value,
+                size,
+                expiresAt: ttl > 0 ? Date.now() + ttl : 0,
+            });
+            bytes += size;
+
+            for (const [oldestKey, oldest] of entries) {
+                if (bytes <= maxBytes) {
+                    break;
+                }
+                remove(oldestKey, oldest);
+                evictions++;
+            }
+        },
+
+        /**
+         * 清空缓存（统计计数保留）
+         */
+        clear() {
+            entries.clear();
+            bytes = 0;
+        },
+
+        /**
+         * 获取缓存统计
+         */
+        getStats() {
+            return { hits, misses, evictions, entries: entries.size, bytes, maxBytes };
+        },
+    };
+}
@@ -0,0 +1,138 @@
+/**
+ * 渲染耗时估算
+ *
+ * 渲染耗时近似与输出像素数成正比：按与渲染相同的缩放规则（targetWidth、maxScale、exactWidth）
+ * 由页面尺寸计算每页输出像素，乘以每百万像素的渲染耗时，再按线程池并行度折算。
+ * 只用于进度条等场景的预估，不考虑页面内容复杂度的差异。
+ */
+
+/**
+ * 没有页面尺寸时假定为 A4（点）
+ */
+const DEFAULT_PAGE_SIZE = { width: 595, height: 842 };
+
+/**
+ * 计算页面的输出像素数（百万像素）
+ *
+ * @param {{ width: number, height: number }} pageSize - 页面尺寸（点）
+ * @param {Object} options
+ * @param {number} options.targetWidth - 目标渲染宽度
+ * @param {number} options.maxScale - 最大缩放比例
+ * @param {number} [options.exactWidth] - 精确输出宽度，设置后忽略 targetWidth 和 maxScale
+ * @returns {number} 百万像素
+ */
+export function outputMegapixels({ width, height }, { targetWidth, maxScale, exactWidth }) {
+    const scale = exactWidth ? exactWidth / width : Math.min(targetWidth / width, maxScale);
+    return (width * scale * height * scale) / 1e6;
+}
+
+/**
+ * 估算渲染全部页面的耗时
+ *
+ * 以采样页面的平均输出像素代表所有页面，页面按线程数分批并行渲染。
+ *
+ * @param {Object} params
+ * @param {number} params.numPages - 页数
+ * @param {Array<{ width: number, height: number }>} params.pageSizes - 采样页面的尺寸（点），为空时假定为 A4
+ * @param {number} params.msPerMegapixel - 每百万像素的渲染耗时（毫秒）
+ * @param {number} params.threads - 渲染线程数
+ * @param {Object} params.render - 缩放参数 { targetWidth, maxScale, exactWidth }，同 outputMegapixels
+ * @returns {number} 估算耗时（毫秒）
+ */
+export function estimateRenderMs({ numPages, pageSizes, msPerMegapixel, threads, render }) {
+    if (numPages <= 0) {
+        return 0;
+    }
+    const samples = pageSizes.length > 0 ? pageSizes : [DEFAULT_PAGE_SIZE];
+    const avgMegapixels = samples.reduce((sum, size) => sum + outputMegapixels(size, render), 0) / samples.length;
+    const batches = Math.ceil(numPages / Math.max(1, Math.min(threads, numPages)));
+    return Math.round(batches * avgMegapixels * msPerMegapixel);
+}
+
+/**
+ * 每百万像素渲染耗时
+ *
+ * 首次读取时调用 calibrate 校准一次（进程内只校准一次，并发读取共享同一次校准），
+ * 此后由实际渲染结果按指数移动平均更新，逐渐贴近真实文档的复杂度。
+ */
+export class RenderRate {
+    /**
+     * @param {Function} calibrate - 校准函数，返回（或以 Promise 返回）每百万像素耗时（毫秒）
+     * @param {number} [smoothing=0.2] - 新样本的权重
+     */
+    constructor(calibrate, smoothing = 0.2) {
+        this.calibrate = calibrate;
+        this.smoothing = smoothing;
+        this.msPerMegapixel = null;
+        this.calibrating = null;
+    }
+
+    /**
+     * @returns {Promise<number>} 每百万像素耗时（毫秒）
+     */
+    async get() {
+        if (this.msPerMegapixel === null) {
+            this.calibrating ??= Promise.resolve()
+                .then(() => this.calibrate())
+                .then(value => {
+                    // 校准期间已有实际渲染样本时以样本为准
+                    this.msPerMegapixel ??= value;
+                })
+                .finally(() => {
+                    this.calibrating = null;
+                });
+            await this.calibrating;
+        }
+        return this.msPerMegapixel;
+    }
+
+    /**
+     * 记录一次实际渲染
+     *
+     * @param {number} renderMs - 渲染耗时（毫秒）
+     * @param {number} megapixels - 输出像素数（百万像素）
+     */
+    record(renderMs, megapixels) {
+        if (!(renderMs > 0 && megapixels > 0)) {
+            return;
+        }
+        const sample = renderMs / megapixels;
+        this.msPerMegapixel = this.msPerMegapixel === null
+            ? sample
+            : this.msPerMegapixel + this.smoothing * (sample - this.msPerMegapixel);
+    }
+}
+
+/**
+ * 生成用于校准的单页 PDF（A4，满页曲线和填充矩形，近似普通矢量页面的渲染开销）
+ *
+ * @returns {Buffer}
+ */
+export function buildCalibrationPdf() {
+    const ops = [];
+    for (let i = 0; i < 400; i++) {
+        const x = (i * 37) % 560;
+        const y = (i * 53) % 800;
+        ops.push(`${(i % 10) / 10} g ${x} ${y} 24 16 re f`);
+        ops.push(`${x} ${y} m ${x + 80} ${y + 120} ${x + 160} ${y - 60} ${x + 240} ${y + 40} c S`);
+    }
+    const content = ops.join('\n');
+    const objects = [
+        '<</Type/Catalog/Pages 2 0 R>>',
+        '<</Type/Pages/Kids[3 0 R]/Count 1>>',
+        '<</Type/Page/Parent 2 0 R/MediaBox[0 0 595 842]/Contents 4 0 R>>',
+        `<</Length ${content.length}>>\nstream\n${content}\nendstream`,
+    ];
+
+    let pdf = '%PDF-1.4\n';
+    const offsets = objects.map((obj, i) => {
+        const offset = pdf.length;
+        pdf += `${i + 1} 0 obj\n${obj}\nendobj\n`;
+        return offset;
+    });
+    const xref = pdf.length;
+    pdf += `xref\n0 ${objects.length + 1}\n0000000000 65535 f \n`;
+    pdf += offsets.map(offset => `${String(offset).padStart(10, '0')} 00000 n \n`).join('');
+    pdf += `trailer\n<</Size ${objects.length + 1}/Root 1 0 R>>\nstartxref\n${xref}\n%%EOF\n`;
+    return Buffer.from(pdf, 'latin1');
+}
@@ -0,0 +1,154 @@
+/**
+ * AWS Signature Version 4 请求签名
+ *
+ * 用于通过 HTTP 直接读取私有 S3（及兼容 SigV4 的对象存储）中的 PDF，配合 signRequest 钩子使用。
+ * 签名覆盖 Range 请求头，按需加载时每个分片请求的 Range 都不同，因此每个请求都单独签名。
+ * 只发送 GET/HEAD 请求，请求体始终为空。
+ */
+
+import crypto from 'crypto';
+
+const ALGORITHM = 'AWS4-HMAC-SHA256';
+
+function sha256(data) {
+    return crypto.createHash('sha256').update(data).digest('hex');
+}
+
+function hmac(key, data) {
+    return crypto.createHmac('sha256', key).update(data).digest();
+}
+
+/**
+ * 空请求体的 SHA256
+ */
+const EMPTY_PAYLOAD_HASH = sha256('');
+
+/**
+ * RFC 3986 编码（encodeURIComponent 不编码 !'()*）
+ */
+function encodeRfc3986(str) {
+    return encodeURIComponent(str).replace(/[!'()*]/g, c => `%${c.charCodeAt(0).toString(16).toUpperCase()}`);
+}
+
+function safeDecode(str) {
+    try {
+        return decodeURIComponent(str);
+    } catch {
+        return str;
+    }
+}
+
+/**
+ * 规范化路径：每段按 RFC 3986 编码一次，S3 以外的服务再编码一次
+ */
+function canonicalPath(url, service) {
+    const path = url.pathname
+        .split('/')
+        .map(segment => {
+            const encoded = encodeRfc3986(safeDecode(segment));
+            return service === 's3' ? encoded : encodeRfc3986(encoded);
+        })
+        .join('/');
+    return path || '/';
+}
+
+/**
+ * 规范化查询串：编码后按参数名、参数值排序
+ */
+function canonicalQuery(url) {
+    return [...url.searchParams]
+        .map(([name, value]) => [encodeRfc3986(name), encodeRfc3986(value)])
+        .sort(([a, av], [b, bv]) => (a === b ? (av < bv ? -1 : av > bv ? 1 : 0) : a < b ? -1 : 1))
+        .map(([name, value]) => `${name}=${value}`)
+        .join('&');
+}
+
+/**
+ * 为请求添加 SigV4 签名
+ *
+ * 设置 x-amz-date、x-amz-content-sha256、x-amz-security-token（有临时凭证时）和 authorization，
+ * 签名覆盖 host、range 和所有 x-amz-* 请求头。
+ *
+ * @param {Object} request - signRequest 钩子的参数
+ * @param {string} request.method - 请求方法
+ * @param {URL} request.url - 请求地址
+ * @param {Headers} request.headers - 请求头，签名直接写入
+ * @param {Object} credentials - 凭证与作用域
+ * @param {string} credentials.accessKeyId
+ * @param {string} credentials.secretAccessKey
+ * @param {string} [credentials.sessionToken] - STS 临时凭证的 token
+ * @param {string} credentials.region - 地域，如 us-east-1
+ * @param {string} [credentials.service='s3'] - 服务名
+ * @param {Date} [date] - 签名时间（默认当前时间）
+ */
+export function signSigV4({ method, url, headers }, credentials, date = new Date()) {
+    const { accessKeyId, secretAccessKey, sessionToken, region, service = 's3' } = credentials;
+    const amzDate = date.toISOString().replace(/[:-]|\.\d{3}/g, '');
+    const dateStamp = amzDate.slice(0, 8);
+
+    headers.set('x-amz-date', amzDate);
+    headers.set('x-amz-content-sha256', EMPTY_PAYLOAD_HASH);
+    if (sessionToken) {
+        headers.set('x-amz-security-token', sessionToken);
+    }
+
+    // Host 由 fetch 根据 URL 设置（不含默认端口），签名时按 URL 计算
+    const signed = new Map([['host', url.host]]);
+    for (const [name, value] of headers) {
+        if (name === 'range' || name.startsWith('x-amz-')) {
+            signed.set(name, value.trim().replace(/\s+/g, ' '));
+        }
+    }
+    const names = [...signed.keys()].sort();
+    const signedHeaders = names.join(';');
+
+    const canonicalRequest = [
+        method,
+        canonicalPath(url, service),
+        canonicalQuery(url),
+        names.map(name => `${name}:${signed.get(name)}\n`).join(''),
+        signedHeaders,
+        EMPTY_PAYLOAD_HASH,
+    ].join('\n');
+
+    const scope = `${dateStamp}/${region}/${service}/aws4_request`;
+    const stringToSign = [ALGORITHM, amzDate, scope, sha256(canonicalRequest)].join('\n');
+
+    const signingKey = hmac(hmac(hmac(hmac(`AWS4${secretAccessKey}`, dateStamp), region), service), 'aws4_request');
+    const signature = crypto.createHmac('sha256', signingKey).update(stringToSign).digest('hex');
+
+    headers.set(
+        'authorization',
+        `${ALGORITHM} Credential=${accessKeyId}/${scope}, SignedHeaders=${signedHeaders}, Signature=${signature}`,
+    );
+}
+
+/**
+ * 创建 SigV4 签名钩子，作为 signRequest 选项传入
+ *
+ * 未指定的凭证从 AWS_ACCESS_KEY_ID、AWS_SECRET_ACCESS_KEY、AWS_SESSION_TOKEN、
+ * AWS_REGION（或 AWS_DEFAULT_REGION）环境变量读取。
+ *
+ * @param {Object} [options]
+ * @param {string} [options.accessKeyId]
+ * @param {string} [options.secretAccessKey]
+ * @param {string} [options.sessionToken]
+ * @param {string} [options.region]
+ * @param {string} [options.service='s3']
+ * @returns {Function} signRequest 钩子
+ */
+export function createSigV4Signer(options = {}) {
+    const credentials = {
+        accessKeyId: options.accessKeyId ?? process.env.AWS_ACCESS_KEY_ID,
+        secretAccessKey: options.secretAccessKey ?? process.env.AWS_SECRET_ACCESS_KEY,
+        sessionToken: options.sessionToken ?? process.env.AWS_SESSION_TOKEN,
+        region: options.region ?? process.env.AWS_REGION ?? process.env.AWS_DEFAULT_REGION,
+        service: options.service ?? 's3',
+    };
+
+    if (!credentials.accessKeyId || !credentials.secretAccessKey || !credentials.region) {
+        throw new Error('SigV4 signer requires accessKeyId, secretAccessKey and region');
+    }
+
+    return request => signSigV4(request, credentials);
+}
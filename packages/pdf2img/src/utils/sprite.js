@@ -0,0 +1,38 @@
+/**
+ * 缩略图精灵图布局
+ *
+ * 虚拟列表等场景把所有缩略图合并成一张图片，再按坐标裁剪显示，减少 HTTP 请求数。
+ * 布局使用简单的货架（shelf）算法：按页面顺序从左到右排列，放不下时另起一行，
+ * 行高取该行最高的缩略图。页面顺序与坐标顺序一致，客户端按行滚动时无需额外排序。
+ */
+
+/**
+ * 计算精灵图布局
+ *
+ * @param {Array<{ width: number, height: number }>} sizes - 各缩略图尺寸（像素）
+ * @param {number} maxWidth - 精灵图最大宽度（像素），宽于该值的缩略图单独占一行
+ * @param {number} [gap=0] - 缩略图之间的间距（像素）
+ * @returns {{ width: number, height: number, rects: Array<{ x: number, y: number, width: number, height: number }> }}
+ *   精灵图尺寸与每个缩略图的位置（与 sizes 顺序一致）
+ */
+export function packShelves(sizes, maxWidth, gap = 0) {
+    const rects = [];
+    let width = 0;
+    let shelfY = 0;
+    let shelfHeight = 0;
+    let x = 0;
+
+    for (const size of sizes) {
+        if (x > 0 && x + size.width > maxWidth) {
+            shelfY += shelfHeight + gap;
+            shelfHeight = 0;
+            x = 0;
+        }
+        rects.push({ x, y: shelfY, width: size.width, height: size.height });
+        width = Math.max(width, x + size.width);
+        shelfHeight = Math.max(shelfHeight, size.height);
+        x += size.width + gap;
+    }
+
+    return { width, height: shelfY + shelfHeight, rects };
+}
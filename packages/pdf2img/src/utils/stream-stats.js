@@ -0,0 +1,44 @@
+/**
+ * 按需加载统计汇总
+ *
+ * streamStats 中的 totalBytesFetched 只统计网络下载量：缓存命中越多，它相对文件大小的比例越低，
+ * 但 PDFium 实际读取的数据量（requestedBytes）可能远高于此；缓存淘汰后重新下载的块又会让
+ * 下载量高于文件被触及的部分。这里把三者放在一起，给出真实的"触及文件比例"。
+ */
+
+/**
+ * 生成按需加载的效率报告
+ *
+ * @param {Object} streamStats - 原生渲染器返回的流式加载统计
+ * @param {number} fileSize - 文件大小（字节）
+ * @returns {Object} 效率报告
+ */
+export function efficiencyReport(streamStats, fileSize) {
+    const {
+        totalRequests = 0,
+        totalBytesFetched = 0,
+        cacheBytes = 0,
+        requestedBytes = 0,
+        touchedBytes = 0,
+    } = streamStats ?? {};
+
+    return {
+        fileSize,
+        // PDFium 读取的字节数，其中 cacheBytes 由缓存提供，其余来自本次请求下载的块
+        requestedBytes,
+        cacheBytes,
+        // 网络下载量（按块下载，包含预读和缓存淘汰后的重复下载）
+        networkBytes: totalBytesFetched,
+        // 至少下载过一次的不重复字节数
+        touchedBytes,
+        // 被重复下载的字节数
+        refetchedBytes: Math.max(0, totalBytesFetched - touchedBytes),
+        requests: totalRequests,
+        // 文件被触及（至少下载过一次）的比例
+        touchedRatio: fileSize > 0 ? touchedBytes / fileSize : 0,
+        // 读取量中由缓存提供的比例
+        cacheHitRatio: requestedBytes > 0 ? cacheBytes / requestedBytes : 0,
+        // 网络下载量相对文件大小的比例（旧的 bytesRatio）
+        networkRatio: fileSize > 0 ? totalBytesFetched / fileSize : 0,
+    };
+}
@@ -0,0 +1,139 @@
+/**
+ * 临时文件管理
+ *
+ * 远程文件完整下载等场景需要落盘的临时文件统一放在同一目录，按引用计数管理：
+ * 最后一个使用者释放时删除文件。进程崩溃或被强制结束时来不及删除的文件，
+ * 在下次初始化时以及之后定时按修改时间清理（只清理本工具前缀的文件，正在使用的文件不清理）。
+ */
+
+import fs from 'fs';
+import path from 'path';
+import crypto from 'crypto';
+
+/**
+ * 临时文件名前缀，清理时只处理带该前缀的文件
+ */
+const TEMP_PREFIX = 'pdf2img_';
+
+/**
+ * 创建临时文件存储
+ *
+ * @param {Object} options
+ * @param {string} options.dir - 临时目录，不存在时自动创建
+ * @param {number} options.staleAfter - 未在使用且超过该时间（毫秒）未修改的文件视为残留
+ * @param {number} [options.sweepInterval=0] - 定时清理间隔（毫秒），0 表示只在初始化时清理
+ * @returns {Object} { dir, init, create, acquire, release, sweep, close }
+ */
+export function createTempStore({ dir, staleAfter, sweepInterval = 0 }) {
+    // 文件路径 -> 引用计数
+    const refs = new Map();
+    let ready = null;
+    let timer = null;
+
+    /**
+     * 清理残留文件
+     *
+     * @returns {Promise<number>} 删除的文件数
+     */
+    async function sweep() {
+        let names;
+        try {
+            names = await fs.promises.readdir(dir);
+        } catch {
+            return 0;
+        }
+
+        const now = Date.now();
+        let removed = 0;
+        for (const name of names) {
+            const file = path.join(dir, name);
+            if (!name.startsWith(TEMP_PREFIX) || refs.has(file)) {
+                continue;
+            }
+            try {
+                const { mtimeMs } = await fs.promises.stat(file);
+                if (now - mtimeMs >= staleAfter) {
+                    await fs.promises.unlink(file);
+                    removed++;
+                }
+            } catch {
+                // 文件已被其他进程删除
+            }
+        }
+        return removed;
+    }
+
+    /**
+     * 初始化：创建目录、清理残留文件并启动定时清理（只执行一次）
+     */
+    function init() {
+        ready ??= (async () => {
+            await fs.promises.mkdir(dir, { recursive: true });
+            await sweep();
+            if (sweepInterval > 0) {
+                timer = setInterval(sweep, sweepInterval);
+                // 定时清理不阻止进程退出
+                timer.unref();
+            }
+        })().catch(err => {
+            ready = null;
+            throw err;
+        });
+        return ready;
+    }
+
+    return {
+        dir,
+        init,
+        sweep,
+
+        /**
+         * 分配一个临时文件路径（不创建文件），引用计数为 1
+         *
+         * @param {string} [ext=''] - 扩展名，如 '.pdf'
+         * @returns {Promise<string>} 文件路径
+         */
+        async create(ext = '') {
+            await init();
+            const file = path.join(dir, `${TEMP_PREFIX}${Date.now()}_${crypto.randomBytes(6).toString('hex')}${ext}`);
+            refs.set(file, 1);
+            return file;
+        },
+
+        /**
+         * 增加引用计数
+         *
+         * @param {string} file - create 返回的路径
+         */
+        acquire(file) {
+            refs.set(file, (refs.get(file) ?? 0) + 1);
+        },
+
+        /**
+         * 减少引用计数，降为 0 时删除文件
+         *
+         * @param {string} file - create 返回的路径
+         */
+        async release(file) {
+            const count = (refs.get(file) ?? 1) - 1;
+            if (count > 0) {
+                refs.set(file, count);
+                return;
+            }
+            refs.delete(file);
+            try {
+                await fs.promises.unlink(file);
+            } catch {
+                // 文件未创建或已删除
+            }
+        },
+
+        /**
+         * 停止定时清理
+         */
+        close() {
+            clearInterval(timer);
+            timer = null;
+        },
+    };
+}
@@ -0,0 +1,73 @@
+/**
+ * 分布式追踪工具
+ *
+ * 不依赖 @opentelemetry/api，只要求传入的 tracer 实现 OpenTelemetry Tracer 接口的子集：
+ * - startActiveSpan(name, options, fn)（优先使用，子 span 自动挂到当前 span 下）
+ * - 或 startSpan(name, options)
+ *
+ * 返回的 span 需实现 setAttribute、setAttributes、recordException、setStatus、end。
+ * 未传入 tracer 时所有操作都是空操作。
+ */
+
+/** OpenTelemetry SpanStatusCode.ERROR */
+export const SPAN_STATUS_ERROR = 2;
+
+const NOOP_SPAN = {
+    setAttribute() { return this; },
+    setAttributes() { return this; },
+    recordException() {},
+    setStatus() { return this; },
+    end() {},
+};
+
+/**
+ * 在 span 中执行函数
+ *
+ * 函数抛出异常时记录到 span 并标记为错误；无论成功与否都会结束 span。
+ *
+ * @param {Object} [tracer] - OpenTelemetry 兼容的 tracer
+ * @param {string} name - span 名称
+ * @param {Object} attributes - 初始属性
+ * @param {Function} fn - (span) => Promise<any>
+ * @returns {Promise<any>} fn 的返回值
+ */
+export async function withSpan(tracer, name, attributes, fn) {
+    if (!tracer) {
+        return fn(NOOP_SPAN);
+    }
+
+    const run = async (span) => {
+        try {
+            return await fn(span);
+        } catch (err) {
+            span.recordException(err);
+            span.setStatus({ code: SPAN_STATUS_ERROR, message: err.message });
+            throw err;
+        } finally {
+            span.end();
+        }
+    };
+
+    if (typeof tracer.startActiveSpan === 'function') {
+        return tracer.startActiveSpan(name, { attributes }, run);
+    }
+    return run(tracer.startSpan(name, { attributes }));
+}
+
+/**
+ * 收集需要注入到出站请求的追踪上下文请求头
+ *
+ * 应在 span 内调用，注入的是当前活动 span 的上下文。
+ *
+ * @param {Function} [injectTraceContext] - 注入钩子 (headers: Object) => void，
+ *   例如 headers => propagation.inject(context.active(), headers)
+ * @returns {Object|undefined} 追踪请求头（如 traceparent），未配置钩子时返回 undefined
+ */
+export function collectTraceHeaders(injectTraceContext) {
+    if (!injectTraceContext) {
+        return undefined;
+    }
+    const headers = {};
+    injectTraceContext(headers);
+    return headers;
+}
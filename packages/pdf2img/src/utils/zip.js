@@ -0,0 +1,346 @@
+/**
+ * 远程 ZIP 归档条目读取
+ *
+ * 批处理流水线常把多个 PDF 打包成一个 ZIP。输入写作 `zip://<归档 URL>!<条目路径>`，
+ * 如 `zip://https://example.com/batch.zip!docs/a.pdf`，通过 Range 请求只下载：
+ * 1. 归档末尾（End of Central Directory 记录）
+ * 2. 中央目录（所有条目的名称、大小、偏移）
+ * 3. 目标条目的本地文件头和数据
+ *
+ * 其余条目不会被下载。条目数据边下载边解压写入文件，不在内存中保留整个条目。
+ * 支持 stored（不压缩）和 deflate 两种压缩方式；ZIP64、加密条目不支持。服务器必须支持 Range 请求。
+ */
+
+import fs from 'fs';
+import zlib from 'zlib';
+import { pipeline } from 'stream/promises';
+import { TIMEOUT_CONFIG, SECURITY_CONFIG } from '../core/config.js';
+import { fetchWithPolicy, validateContentRange, timeoutSignal, stallSignal } from './http.js';
+import { limitFetch, limitDownload } from './limiter.js';
+
+const ZIP_PREFIX = 'zip://';
+
+const EOCD_SIGNATURE = 0x06054b50;
+const CENTRAL_SIGNATURE = 0x02014b50;
+const LOCAL_SIGNATURE = 0x04034b50;
+
+/**
+ * End of Central Directory 记录的固定长度（不含注释）
+ */
+const EOCD_SIZE = 22;
+
+/**
+ * 归档注释的最大长度，EOCD 一定位于文件最后 EOCD_SIZE + MAX_COMMENT 字节内
+ */
+const MAX_COMMENT = 0xffff;
+
+const LOCAL_HEADER_SIZE = 30;
+
+const METHOD_STORED = 0;
+const METHOD_DEFLATE = 8;
+
+/**
+ * 创建 ZIP 相关错误
+ */
+function zipError(code, message) {
+    const err = new Error(message);
+    err.code = code;
+    return err;
+}
+
+/**
+ * 是否为 zip:// 输入
+ *
+ * @param {*} input - convert 的输入
+ * @returns {boolean}
+ */
+export function isZipUrl(input) {
+    return typeof input === 'string' && input.startsWith(ZIP_PREFIX);
+}
+
+/**
+ * 解析 zip:// 输入
+ *
+ * 条目路径以最后一个 `!` 分隔（归档 URL 的查询参数中可以包含 `!`，条目路径中不能）。
+ *
+ * @param {string} input - `zip://<归档 URL>!<条目路径>`
+ * @returns {{ archiveUrl: string, entryPath: string }}
+ * @throws {Error} 格式错误或归档地址不是 http(s) URL 时抛出
+ */
+export function parseZipUrl(input) {
+    const rest = input.slice(ZIP_PREFIX.length);
+    const separator = rest.lastIndexOf('!');
+    const archiveUrl = separator > 0 ? rest.slice(0, separator) : '';
+    const entryPath = separator > 0 ? rest.slice(separator + 1).replace(/^\/+/, '') : '';
+
+    if (!/^https?:\/\//.test(archiveUrl) || !entryPath) {
+        throw new Error(`Invalid zip input: ${input}. Expected zip://<http(s) archive URL>!<entry path>`);
+    }
+    return { archiveUrl, entryPath };
+}
+
+/**
+ * 获取归档的一段字节
+ *
+ * @param {string} url - 归档 URL
+ * @param {string} range - Range 取值（不含 `bytes=`），如 `0-99` 或后缀形式 `-100`
+ * @param {Object} network - 远程访问策略
+ * @param {AbortSignal} [signal] - 取消信号
+ * @returns {Promise<{ data: Buffer, start: number, total: number }>}
+ * @throws {Error} 响应范围或长度与请求不一致时 code 为 ERR_RANGE_MISMATCH
+ */
+async function fetchRange(url, range, network, signal) {
+    const [start, end] = range.startsWith('-') ? [] : range.split('-').map(Number);
+
+    return limitFetch(async () => {
+        const response = await fetchWithPolicy(url, {
+            headers: { Range: `bytes=${range}` },
+            signal: timeoutSignal(TIMEOUT_CONFIG.RANGE_REQUEST_TIMEOUT, signal),
+        }, network);
+
+        if (response.status !== 206) {
+            await response.body?.cancel();
+            if (!response.ok) {
+                throw new Error(`Failed to read zip archive: ${response.status} ${response.statusText}`);
+            }
+            throw zipError('ERR_ZIP_RANGE_UNSUPPORTED', 'Zip archive server does not support range requests');
+        }
+
+        const data = Buffer.from(await response.arrayBuffer());
+        const contentRange = validateContentRange(response.headers.get('content-range'), data.length, start, end);
+        if (contentRange.size === null) {
+            throw new Error('Zip archive server returned a Content-Range header without the archive size');
+        }
+
+        return { data, start: contentRange.start, total: contentRange.size };
+    });
+}
+
+/**
+ * 在归档末尾的数据中查找 EOCD 记录
+ *
+ * @returns {{ entries: number, centralSize: number, centralOffset: number }}
+ */
+function parseEndOfCentralDirectory(tail) {
+    for (let i = tail.length - EOCD_SIZE; i >= 0; i--) {
+        if (tail.readUInt32LE(i) !== EOCD_SIGNATURE) {
+            continue;
+        }
+        const entries = tail.readUInt16LE(i + 10);
+        const centralSize = tail.readUInt32LE(i + 12);
+        const centralOffset = tail.readUInt32LE(i + 16);
+        if (entries === 0xffff || centralSize === 0xffffffff || centralOffset === 0xffffffff) {
+            throw zipError('ERR_ZIP_UNSUPPORTED', 'ZIP64 archives are not supported');
+        }
+        return { entries, centralSize, centralOffset };
+    }
+    throw zipError('ERR_ZIP_INVALID', 'Not a zip archive: end of central directory not found');
+}
+
+/**
+ * 在中央目录中查找条目
+ *
+ * @returns {Object|null} { method, flags, crc32, compressedSize, size, localOffset }
+ */
+function findCentralEntry(central, entries, entryPath) {
+    let offset = 0;
+    for (let i = 0; i < entries; i++) {
+        if (offset + 46 > central.length || central.readUInt32LE(offset) !== CENTRAL_SIGNATURE) {
+            throw zipError('ERR_ZIP_INVALID', 'Corrupt zip central directory');
+        }
+        const nameLength = central.readUInt16LE(offset + 28);
+        const extraLength = central.readUInt16LE(offset + 30);
+        const commentLength = central.readUInt16LE(offset + 32);
+        const name = central.toString('utf8', offset + 46, offset + 46 + nameLength);
+
+        if (name === entryPath) {
+            return {
+                flags: central.readUInt16LE(offset + 8),
+                method: central.readUInt16LE(offset + 10),
+                crc32: central.readUInt32LE(offset + 16),
+                compressedSize: central.readUInt32LE(offset + 20),
+                size: central.readUInt32LE(offset + 24),
+                localOffset: central.readUInt32LE(offset + 42),
+            };
+        }
+        offset += 46 + nameLength + extraLength + commentLength;
+    }
+    return null;
+}
+
+/**
+ * 下载条目数据并写入文件，deflate 条目边下载边解压
+ *
+ * 与完整下载一样受总超时（DOWNLOAD_TIMEOUT）和停滞超时（PDF2IMG_STALL_TIMEOUT）限制，
+ * 慢速但持续传输的大条目不会因单个分片请求的超时而失败。
+ *
+ * @param {string} url - 归档 URL
+ * @param {Object} entry - findCentralEntry 返回的条目
+ * @param {number} dataStart - 条目数据在归档中的起点
+ * @param {string} destination - 写入的文件路径
+ * @param {Object} network - 远程访问策略
+ * @param {AbortSignal} [signal] - 取消信号
+ * @param {string} entryPath - 条目路径（用于错误信息）
+ */
+async function extractEntry(url, entry, dataStart, destination, network, signal, entryPath) {
+    const { method, compressedSize, size, crc32 } = entry;
+    const end = dataStart + compressedSize - 1;
+
+    return limitDownload(async () => {
+        const { DOWNLOAD_TIMEOUT, STALL_TIMEOUT } = TIMEOUT_CONFIG;
+        const deadline = DOWNLOAD_TIMEOUT > 0 ? AbortSignal.timeout(DOWNLOAD_TIMEOUT) : undefined;
+        const limits = [signal, deadline].filter(Boolean);
+        const stall = stallSignal(STALL_TIMEOUT, limits.length > 0 ? AbortSignal.any(limits) : undefined);
+        let failure = null;
+
+        try {
+            const response = compressedSize === 0 ? null : await fetchWithPolicy(url, {
+                headers: { Range: `bytes=${dataStart}-${end}` },
+                signal: stall.signal,
+            }, network);
+            stall.touch();
+
+            if (response && response.status !== 206) {
+                await response.body?.cancel();
+                if (!response.ok) {
+                    throw new Error(`Failed to read zip archive: ${response.status} ${response.statusText}`);
+                }
+                throw zipError('ERR_ZIP_RANGE_UNSUPPORTED', 'Zip archive server does not support range requests');
+            }
+
+            // 先按条目的压缩大小校验响应范围，实际收到的字节数在传输过程中和结束后再次校验
+            const contentRange = response?.headers.get('content-range');
+            if (response) {
+                validateContentRange(contentRange, compressedSize, dataStart, end);
+            }
+
+            let received = 0;
+            const countCompressed = async function* (source) {
+                for await (const chunk of source) {
+                    received += chunk.length;
+                    if (received > compressedSize) {
+                        try {
+                            validateContentRange(contentRange, received, dataStart, end);
+                        } catch (err) {
+                            failure = err;
+                            throw err;
+                        }
+                    }
+                    yield chunk;
+                }
+            };
+
+            // 解压后的数据不能超过中央目录记录的大小（防止压缩炸弹），同时计算 CRC-32
+            let written = 0;
+            let checksum = 0;
+            const verify = async function* (source) {
+                for await (const chunk of source) {
+                    written += chunk.length;
+                    if (written > size) {
+                        failure = zipError('ERR_ZIP_INVALID', `Zip entry is corrupt: ${entryPath}`);
+                        throw failure;
+                    }
+                    if (zlib.crc32) {
+                        checksum = zlib.crc32(chunk, checksum);
+                    }
+                    yield chunk;
+                }
+            };
+
+            try {
+                await pipeline(
+                    response?.body ?? [],
+                    stall.pipe,
+                    countCompressed,
+                    ...(method === METHOD_DEFLATE ? [zlib.createInflateRaw()] : []),
+                    verify,
+                    fs.createWriteStream(destination)
+                );
+            } catch (err) {
+                signal?.throwIfAborted();
+                deadline?.throwIfAborted();
+                if (failure) {
+                    throw failure;
+                }
+                if (stall.signal.aborted) {
+                    throw stall.signal.reason;
+                }
+                // 压缩数据损坏
+                if (err.code?.startsWith('Z_')) {
+                    throw zipError('ERR_ZIP_INVALID', `Zip entry is corrupt: ${entryPath}`);
+                }
+                throw err;
+            }
+
+            if (response) {
+                validateContentRange(contentRange, received, dataStart, end);
+            }
+            if (written !== size || (zlib.crc32 && checksum !== crc32)) {
+                throw zipError('ERR_ZIP_INVALID', `Zip entry is corrupt: ${entryPath}`);
+            }
+        } finally {
+            stall.clear();
+        }
+    }, signal);
+}
+
+/**
+ * 读取远程 ZIP 归档中的一个条目并写入文件
+ *
+ * 条目大小受 maxFileSize 和 PDF2IMG_MAX_ZIP_ENTRY_SIZE 共同限制，超过时在下载条目数据前拒绝。
+ * 失败时 destination 可能留有部分数据，由调用方删除。
+ *
+ * @param {string} input - `zip://<归档 URL>!<条目路径>`
+ * @param {string} destination - 写入条目内容（已解压）的文件路径
+ * @param {Object} [network] - 远程访问策略（同 fetchWithPolicy），maxFileSize 限制条目解压后的大小
+ * @param {AbortSignal} [signal] - 取消信号
+ * @returns {Promise<{ size: number, crc32: number }>} 条目解压后的大小和 CRC-32
+ * @throws {Error} 条目不存在时 code 为 ERR_ZIP_ENTRY_NOT_FOUND；ZIP64、加密条目或不支持的压缩方式
+ *   code 为 ERR_ZIP_UNSUPPORTED；服务器不支持 Range 请求时 code 为 ERR_ZIP_RANGE_UNSUPPORTED；
+ *   条目超过大小上限时 code 为 ERR_FILE_TOO_LARGE；服务器返回的范围与请求不一致时 code 为 ERR_RANGE_MISMATCH；
+ *   条目数据损坏时 code 为 ERR_ZIP_INVALID
+ */
+export async function readZipEntry(input, destination, network = {}, signal) {
+    const { archiveUrl, entryPath } = parseZipUrl(input);
+
+    // 归档末尾：EOCD 记录，小归档时通常同时包含整个中央目录
+    const tail = await fetchRange(archiveUrl, `-${EOCD_SIZE + MAX_COMMENT}`, network, signal);
+    const { entries, centralSize, centralOffset } = parseEndOfCentralDirectory(tail.data);
+
+    const central = centralOffset >= tail.start
+        ? tail.data.subarray(centralOffset - tail.start, centralOffset - tail.start + centralSize)
+        : (await fetchRange(archiveUrl, `${centralOffset}-${centralOffset + centralSize - 1}`, network, signal)).data;
+
+    const entry = findCentralEntry(central, entries, entryPath);
+    if (!entry) {
+        throw zipError('ERR_ZIP_ENTRY_NOT_FOUND', `Zip entry not found: ${entryPath}`);
+    }
+    if (entry.flags & 0x1) {
+        throw zipError('ERR_ZIP_UNSUPPORTED', `Encrypted zip entries are not supported: ${entryPath}`);
+    }
+    if (entry.method !== METHOD_STORED && entry.method !== METHOD_DEFLATE) {
+        throw zipError('ERR_ZIP_UNSUPPORTED', `Unsupported zip compression method ${entry.method}: ${entryPath}`);
+    }
+
+    // 压缩后的数据同样计入上限（stored 条目两者相等，不可压缩的 deflate 条目压缩后略大）
+    const { maxFileSize = SECURITY_CONFIG.MAX_FILE_SIZE } = network;
+    const limit = Math.min(...[maxFileSize, SECURITY_CONFIG.MAX_ZIP_ENTRY_SIZE].filter(value => value > 0));
+    const entrySize = Math.max(entry.size, entry.compressedSize);
+    if (entrySize > limit) {
+        const err = new Error(`File too large: ${entrySize} bytes exceeds the limit of ${limit} bytes`);
+        err.code = 'ERR_FILE_TOO_LARGE';
+        err.size = entrySize;
+        throw err;
+    }
+
+    // 本地文件头的扩展字段长度可能与中央目录不同，先读取文件头确定数据起点
+    const { localOffset } = entry;
+    const { data: header } = await fetchRange(archiveUrl, `${localOffset}-${localOffset + LOCAL_HEADER_SIZE - 1}`, network, signal);
+    if (header.length < LOCAL_HEADER_SIZE || header.readUInt32LE(0) !== LOCAL_SIGNATURE) {
+        throw zipError('ERR_ZIP_INVALID', `Corrupt zip local header: ${entryPath}`);
+    }
+    const dataStart = localOffset + LOCAL_HEADER_SIZE + header.readUInt16LE(26) + header.readUInt16LE(28);
+
+    await extractEntry(archiveUrl, entry, dataStart, destination, network, signal, entryPath);
+    return { size: entry.size, crc32: entry.crc32 };
+}
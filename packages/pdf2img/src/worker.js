@@ -9,6 +9,8 @@
  */
 
 import sharp from 'sharp';
+import { chooseAutoFormat } from './utils/auto-format.js';
+import { encodeImage } from './utils/encode.js';
 
 // ==================== Native Renderer 懒加载 ====================
 
@@ -50,21 +52,215 @@ async function initNativeRenderer() {
  * 合并配置
  */
 function mergeConfig(options = {}) {
+    // exactWidth：按每页自身尺寸计算缩放比例，输出宽度恰好等于该值，不做扫描件降级；
+    // maxScale 由主线程的 normalizeRenderOptions 给出（exactWidth 时为 DPI 上限），
+    // 未经规范化时页面宽度至少 1pt，缩放上限取 exactWidth 即不会截断。
+    // clip 交给原生渲染器只光栅化该区域，宽度参数作用于裁剪后的宽度
+    if (options.exactWidth) {
+        return {
+            targetWidth: options.exactWidth,
+            detectScan: false,
+            maxScale: options.maxScale ?? options.exactWidth,
+            clip: options.clip,
+        };
+    }
     return {
         targetWidth: options.targetWidth ?? 1280,
         detectScan: options.detectScan ?? false,
+        maxScale: options.maxScale,
+        clip: options.clip,
     };
 }
 
+/**
+ * 低分辨率占位图（LQIP）宽度（像素）
+ */
+const PLACEHOLDER_WIDTH = 16;
+
+/**
+ * 编码前的图像后处理（名称见 config.js 的 POST_PROCESS_FILTERS）
+ *
+ * 函数无法传递到工作线程，因此只支持内置的具名处理。
+ */
+const POST_PROCESSORS = {
+    sharpen: image => image.sharpen({ sigma: 0.5 }),
+    autocontrast: image => image.normalise(),
+    denoise: image => image.median(3),
+};
+
+/**
+ * 将旋转角度规范化到 [0, 360)，如 -90 -> 270
+ */
+function normalizeRotation(rotate) {
+    return ((rotate ?? 0) % 360 + 360) % 360;
+}
+
+/**
+ * 计算位图的平均颜色
+ *
+ * 透明像素按白色背景混合，与 JPEG 输出的背景一致。
+ *
+ * @param {Buffer} rawBitmap - 原始 RGBA 像素数据
+ * @param {number} width - 图像宽度
+ * @param {number} height - 图像高度
+ * @returns {Promise<string>} 十六进制颜色，如 '#fafafa'
+ */
+async function computeAverageColor(rawBitmap, width, height) {
+    const image = sharp(rawBitmap, { raw: { width, height, channels: 4 } });
+    const { channels } = await image.flatten({ background: { r: 255, g: 255, b: 255 } }).stats();
+    return '#' + channels
+        .slice(0, 3)
+        .map(channel => Math.round(channel.mean).toString(16).padStart(2, '0'))
+        .join('');
+}
+
+/**
+ * 生成低分辨率模糊占位图（LQIP）
+ *
+ * 由已渲染的位图（旋转后）缩小到 PLACEHOLDER_WIDTH 宽并模糊，编码为 WebP data URI，
+ * 通常只有一两百字节，可直接内联在页面中，完整图片加载前先显示。
+ *
+ * @param {Buffer} rawBitmap - 原始 RGBA 像素数据
+ * @param {number} width - 图像宽度
+ * @param {number} height - 图像高度
+ * @param {number} [rotation] - 顺时针旋转角度
+ * @returns {Promise<string>} data:image/webp;base64,...
+ */
+async function computePlaceholder(rawBitmap, width, height, rotation) {
+    let image = sharp(rawBitmap, { raw: { width, height, channels: 4 } });
+    if (rotation) {
+        image = image.rotate(rotation);
+    }
+    const buffer = await image
+        .resize({ width: PLACEHOLDER_WIDTH })
+        .flatten({ background: { r: 255, g: 255, b: 255 } })
+        .blur(1)
+        .webp({ quality: 40 })
+        .toBuffer();
+    return `data:image/webp;base64,${buffer.toString('base64')}`;
+}
+
+/**
+ * 水印位置到 sharp gravity 的映射
+ */
+const WATERMARK_GRAVITY = {
+    'center': 'centre',
+    'top-left': 'northwest',
+    'top-right': 'northeast',
+    'bottom-left': 'southwest',
+    'bottom-right': 'southeast',
+};
+
+/**
+ * 转义 SVG 文本和属性中的特殊字符
+ */
+function escapeXml(value) {
+    return String(value).replace(/[&<>"']/g, ch => `&#${ch.charCodeAt(0)};`);
+}
+
+/**
+ * 生成文字水印的 SVG
+ *
+ * SVG 尺寸为文字旋转后的外接矩形，平铺时四周额外留出与字号相同的间距。
+ * 文字宽度按字符估算（CJK 字符按全角计算）。
+ */
+function buildTextWatermark(watermark, fontSize, angle, gap) {
+    const textWidth = [...watermark.text]
+        .reduce((sum, ch) => sum + (ch.codePointAt(0) >= 0x2e80 ? 1 : 0.6), 0) * fontSize;
+    const radians = Math.abs(angle) * Math.PI / 180;
+    const width = Math.ceil(textWidth * Math.cos(radians) + fontSize * Math.sin(radians)) + gap;
+    const height = Math.ceil(textWidth * Math.sin(radians) + fontSize * Math.cos(radians)) + gap;
+    const svg = `<svg xmlns="http://www.w3.org/2000/svg" width="${width}" height="${height}">`
+        + `<text x="50%" y="50%" text-anchor="middle" dominant-baseline="central" `
+        + `font-family="sans-serif" font-size="${fontSize}" fill="${escapeXml(watermark.color ?? '#888888')}" `
+        + `fill-opacity="${watermark.opacity ?? 0.3}" transform="rotate(${-angle} ${width / 2} ${height / 2})">`
+        + `${escapeXml(watermark.text)}</text></svg>`;
+    return Buffer.from(svg);
+}
+
+/**
+ * 生成图片水印：按页面宽度比例缩放，并将 alpha 乘以不透明度
+ */
+async function buildImageWatermark(watermark, width) {
+    const opacity = Math.round((watermark.opacity ?? 0.3) * 255);
+    return sharp(Buffer.from(watermark.image))
+        .resize({ width: Math.max(1, Math.round(width * (watermark.width ?? 0.3))) })
+        .ensureAlpha()
+        .composite([{
+            input: Buffer.from([255, 255, 255, opacity]),
+            raw: { width: 1, height: 1, channels: 4 },
+            tile: true,
+            blend: 'dest-in',
+        }])
+        .png()
+        .toBuffer();
+}
+
+/**
+ * 生成水印叠加层（sharp composite 参数）
+ *
+ * @param {Object} watermark - 水印选项 { text, image, opacity, position, fontSize, color, angle, width }
+ * @param {number} width - 输出图像宽度（旋转之后）
+ * @param {number} height - 输出图像高度
+ * @returns {Promise<Object>} composite 参数
+ */
+async function buildWatermarkOverlay(watermark, width, height) {
+    const position = watermark.position ?? 'center';
+    const tile = position === 'tile';
+    let overlay;
+    if (watermark.image) {
+        overlay = await buildImageWatermark(watermark, width);
+    } else {
+        // 居中和平铺默认斜向，角落默认水平
+        const angle = watermark.angle ?? (tile || position === 'center' ? 30 : 0);
+        const fontSize = watermark.fontSize ?? Math.max(8, Math.round(width / (tile ? 24 : 12)));
+        overlay = buildTextWatermark(watermark, fontSize, angle, tile ? fontSize * 2 : Math.round(fontSize / 2));
+    }
+
+    // 叠加层不能大于输出图像，超出时等比缩小
+    const { width: overlayWidth, height: overlayHeight } = await sharp(overlay).metadata();
+    if (overlayWidth > width || overlayHeight > height) {
+        overlay = await sharp(overlay).resize({ width, height, fit: 'inside' }).png().toBuffer();
+    }
+
+    return tile
+        ? { input: overlay, tile: true, gravity: 'northwest' }
+        : { input: overlay, gravity: WATERMARK_GRAVITY[position] };
+}
+
+/**
+ * 判断位图是否为空白页
+ *
+ * 透明像素按白色背景混合后计算 RGB 各通道的标准差，均不超过阈值即视为空白（接近单一颜色）。
+ *
+ * @param {Buffer} rawBitmap - 原始 RGBA 像素数据
+ * @param {number} width - 图像宽度
+ * @param {number} height - 图像高度
+ * @param {number} threshold - 标准差阈值
+ * @returns {Promise<boolean>}
+ */
+async function isBlankPage(rawBitmap, width, height, threshold) {
+    const image = sharp(rawBitmap, { raw: { width, height, channels: 4 } });
+    const { channels } = await image.flatten({ background: { r: 255, g: 255, b: 255 } }).stats();
+    return channels.slice(0, 3).every(channel => channel.stdev <= threshold);
+}
+
 /**
  * 使用 Sharp 编码原始位图
  * 
  * @param {Buffer} rawBitmap - 原始 RGBA 像素数据
  * @param {number} width - 图像宽度
  * @param {number} height - 图像高度
- * @param {string} format - 输出格式
+ * @param {string} format - 输出格式（'raw' 表示不编码）
  * @param {Object} options - 编码选项
- * @returns {Promise<Buffer>} 编码后的图像数据
+ * @param {boolean} [options.deterministic] - 确定性输出：相同输入产生相同字节
+ * @param {number} [options.maxBytes] - WebP/JPEG 输出大小上限（字节），自动降低质量以满足上限
+ * @param {string[]} [options.postProcess] - 按顺序应用的后处理（编码之前）
+ * @param {number} [options.rotation] - 顺时针旋转角度（0/90/180/270），在后处理之后应用
+ * @returns {Promise<{ buffer: Buffer, quality: number|null }>} 编码后的图像数据与实际使用的质量
+ *   （PNG、无损 WebP 和原始位图为 null）
+ *
+ * 格式相关的编码参数见 utils/encode.js。
  */
 async function encodeWithSharp(rawBitmap, width, height, format, options = {}) {
     let sharpInstance = sharp(rawBitmap, {
@@ -75,26 +271,74 @@ async function encodeWithSharp(rawBitmap, width, height, format, options = {}) {
         }
     });
 
-    if (format === 'webp') {
-        return sharpInstance.webp({
-            quality: options.webpQuality || options.quality || 80,
-            effort: options.webpMethod ?? 4,
-        }).toBuffer();
-    } else if (format === 'png') {
-        return sharpInstance.png({
-            compressionLevel: options.pngCompression ?? 6,
-            adaptiveFiltering: true,
-        }).toBuffer();
-    } else if (format === 'jpeg' || format === 'jpg') {
-        // 移除 alpha 通道，与白色背景混合
-        sharpInstance = sharpInstance.flatten({ background: { r: 255, g: 255, b: 255 } });
-        return sharpInstance.jpeg({
-            quality: options.jpegQuality || options.quality || 85,
-            mozjpeg: true,
-        }).toBuffer();
+    const postProcess = options.postProcess ?? [];
+    for (const name of postProcess) {
+        sharpInstance = POST_PROCESSORS[name](sharpInstance);
     }
-    
-    throw new Error(`Unsupported format: ${format}`);
+
+    if (options.rotation) {
+        sharpInstance = sharpInstance.rotate(options.rotation);
+    }
+
+    if (options.watermark) {
+        // composite 总是在同一管道的旋转之后执行，叠加层按最终尺寸生成
+        const swapped = options.rotation === 90 || options.rotation === 270;
+        sharpInstance = sharpInstance.composite([await buildWatermarkOverlay(
+            options.watermark,
+            swapped ? height : width,
+            swapped ? width : height,
+        )]);
+    }
+
+    if (format === 'raw') {
+        // 不编码，返回（后处理、旋转、水印后的）RGBA 像素数据，供主线程合成多页文档
+        const buffer = postProcess.length > 0 || options.rotation || options.watermark
+            ? await sharpInstance.raw().toBuffer()
+            : rawBitmap;
+        return { buffer, quality: null };
+    }
+
+    return encodeImage(sharpInstance, format, options);
+}
+
+/**
+ * 由整页位图生成指定 DPI 的输出（dpis 选项）
+ *
+ * 位图已按 dpis 中最高的 DPI 渲染，较低的 DPI 由它缩小得到，不再重新渲染；
+ * 渲染受尺寸上限约束时，超过实际缩放比例的 DPI 按实际比例输出。
+ *
+ * @param {Object} rawResult - 原生渲染结果 { width, height, scale, buffer }
+ * @param {Object} tier - 请求的 { dpi, quality }，quality 覆盖该档的 WebP/JPEG 质量
+ * @param {string} format - 输出格式
+ * @param {Object} options - 编码选项
+ * @param {number} rotation - 顺时针旋转角度
+ * @returns {Promise<Object>} { dpi, width, height, buffer, size, quality }
+ */
+async function encodeDpiVariant(rawResult, { dpi, quality }, format, options, rotation) {
+    const scale = Math.min(dpi / 72, rawResult.scale);
+    const width = Math.max(1, Math.round(rawResult.width * scale / rawResult.scale));
+    const height = Math.max(1, Math.round(rawResult.height * scale / rawResult.scale));
+    const bitmap = width === rawResult.width && height === rawResult.height
+        ? rawResult.buffer
+        : await sharp(rawResult.buffer, { raw: { width: rawResult.width, height: rawResult.height, channels: 4 } })
+            .resize(width, height, { fit: 'fill' })
+            .raw()
+            .toBuffer();
+
+    const encodeOptions = quality === undefined
+        ? options
+        : { ...options, quality, webpQuality: quality, jpegQuality: quality };
+    const encoded = await encodeWithSharp(bitmap, width, height, format, { ...encodeOptions, rotation });
+    const swapped = rotation === 90 || rotation === 270;
+
+    return {
+        dpi: Math.round(scale * 72),
+        width: swapped ? height : width,
+        height: swapped ? width : height,
+        buffer: encoded.buffer,
+        size: encoded.buffer.length,
+        quality: encoded.quality,
+    };
 }
 
 /**
@@ -107,10 +351,16 @@ async function encodeWithSharp(rawBitmap, width, height, format, options = {}) {
  * @param {Buffer} [task.pdfBuffer] - PDF Buffer（Buffer 输入时）
  * @param {number} task.pageNum - 要处理的页码（1-based）
  * @param {Object} task.options - 转换选项
+ * @param {MessagePort} [task.startPort] - 设置 pageTimeout 时传入，开始处理时通知主线程开始计时
  * @returns {Promise<Object>} 处理结果
  */
 export default async function processPage(task) {
-    const { filePath, pdfBuffer, pageNum, options = {} } = task;
+    const { filePath, pdfBuffer, pageNum, options = {}, startPort } = task;
+
+    if (startPort) {
+        startPort.postMessage('started');
+        startPort.close();
+    }
     
     // 确保原生渲染器已初始化
     await initNativeRenderer();
@@ -168,25 +418,91 @@ export default async function processPage(task) {
         const renderTime = rawResult.renderTime || 0;
         const encodeStart = Date.now();
         
-        // 步骤 2: Sharp 编码
-        const format = options.format || 'webp';
-        const encodedBuffer = await encodeWithSharp(
-            rawResult.buffer,
-            rawResult.width,
-            rawResult.height,
-            format,
-            options
-        );
+        // 步骤 2: 用 Sharp 编码（指定 clip 时位图已只包含该区域）
+        // 'auto' 按页面内容选择：文字/线稿页面用 PNG，照片类页面用 WebP
+        const format = options.format === 'auto'
+            ? chooseAutoFormat(rawResult.buffer, rawResult.width, rawResult.height)
+            : options.format || 'webp';
+        // 在页面自身的 /Rotate 之上再旋转，90/270 度时输出宽高互换
+        const rotation = normalizeRotation(options.rotate);
+        const swapped = rotation === 90 || rotation === 270;
+
+        // 空白页直接跳过编码，由主线程从结果中移除
+        if (options.skipBlankPages
+            && await isBlankPage(rawResult.buffer, rawResult.width, rawResult.height, options.blankThreshold)) {
+            return {
+                pageNum,
+                success: true,
+                skipped: true,
+                width: 0,
+                height: 0,
+                buffer: null,
+                renderTime,
+                encodeTime: Date.now() - encodeStart,
+            };
+        }
+        const [encoded, avgColor, placeholder] = await Promise.all([
+            options.dpis
+                ? Promise.all(options.dpis.map(tier => encodeDpiVariant(rawResult, tier, format, options, rotation)))
+                : encodeWithSharp(
+                    rawResult.buffer,
+                    rawResult.width,
+                    rawResult.height,
+                    format,
+                    { ...options, rotation }
+                ),
+            options.includePageColor
+                ? computeAverageColor(rawResult.buffer, rawResult.width, rawResult.height)
+                : undefined,
+            options.includePlaceholder
+                ? computePlaceholder(rawResult.buffer, rawResult.width, rawResult.height, rotation)
+                : undefined,
+        ]);
         
         const encodeTime = Date.now() - encodeStart;
+
+        if (options.dpis) {
+            // 页面本身的字段取最高 DPI 的输出，各 DPI 的输出按请求顺序放在 variants 中
+            const largest = encoded.reduce((max, variant) => (variant.dpi > max.dpi ? variant : max));
+            return {
+                pageNum,
+                success: true,
+                format,
+                width: largest.width,
+                height: largest.height,
+                buffer: largest.buffer,
+                size: largest.size,
+                variants: encoded,
+                avgColor,
+                placeholder,
+                effectiveOptions: {
+                    format,
+                    scale: rawResult.scale,
+                    dpi: Math.round(rawResult.scale * 72),
+                    quality: largest.quality,
+                },
+                renderTime,
+                encodeTime,
+            };
+        }
         
         return {
             pageNum,
             success: true,
-            width: rawResult.width,
-            height: rawResult.height,
-            buffer: encodedBuffer,
-            size: encodedBuffer.length,
+            format,
+            width: swapped ? rawResult.height : rawResult.width,
+            height: swapped ? rawResult.width : rawResult.height,
+            buffer: encoded.buffer,
+            size: encoded.buffer.length,
+            avgColor,
+            placeholder,
+            // 实际生效的渲染参数（缩放比例受 maxScale 和尺寸上限约束，质量可能因 maxBytes 降低）
+            effectiveOptions: {
+                format,
+                scale: rawResult.scale,
+                dpi: Math.round(rawResult.scale * 72),
+                quality: encoded.quality,
+            },
             renderTime,
             encodeTime,
         };
@@ -9,7 +9,16 @@ import { describe, it, before, after } from 'node:test';
 import assert from 'node:assert';
 import path from 'path';
 import fs from 'fs';
+import http from 'http';
+import crypto from 'crypto';
+import os from 'os';
+import { execFile, execFileSync } from 'child_process';
+import { promisify } from 'util';
 import { fileURLToPath } from 'url';
+import sharp from 'sharp';
+import { createMemoryTracer } from './helpers/memory-tracer.js';
+import { buildZip } from './helpers/zip-archive.js';
+import { serveRange } from './helpers/range.js';
 
 const __dirname = path.dirname(fileURLToPath(import.meta.url));
 const PROJECT_ROOT = path.join(__dirname, '../../..');
@@ -26,6 +35,105 @@ const TEST_PDF_1M = path.join(STATIC_DIR, '1M.pdf');
 // 动态导入模块
 let pdf2img;
 
+/**
+ * 将 PDF 对象按顺序写出并生成交叉引用表（对象 1 为 Catalog）
+ */
+function assemblePdf(objects) {
+    const chunks = [Buffer.from('%PDF-1.4\n', 'latin1')];
+    let length = chunks[0].length;
+    const offsets = objects.map((obj, i) => {
+        const offset = length;
+        const chunk = Buffer.concat([
+            Buffer.from(`${i + 1} 0 obj\n`, 'latin1'),
+            Buffer.isBuffer(obj) ? obj : Buffer.from(obj, 'latin1'),
+            Buffer.from('\nendobj\n', 'latin1'),
+        ]);
+        chunks.push(chunk);
+        length += chunk.length;
+        return offset;
+    });
+    let trailer = `xref\n0 ${objects.length + 1}\n0000000000 65535 f \n`;
+    trailer += offsets.map(offset => `${String(offset).padStart(10, '0')} 00000 n \n`).join('');
+    trailer += `trailer\n<</Size ${objects.length + 1}/Root 1 0 R>>\nstartxref\n${length}\n%%EOF\n`;
+    chunks.push(Buffer.from(trailer, 'latin1'));
+    return Buffer.concat(chunks);
+}
+
+/**
+ * 生成指定页面尺寸（点）的最小 PDF，页面内容为空白
+ */
+function buildPdf(pageSizes) {
+    const pageIds = pageSizes.map((_, i) => i + 3);
+    return assemblePdf([
+        '<</Type/Catalog/Pages 2 0 R>>',
+        `<</Type/Pages/Kids[${pageIds.map(id => `${id} 0 R`).join(' ')}]/Count ${pageSizes.length}>>`,
+        ...pageSizes.map(([w, h]) => `<</Type/Page/Parent 2 0 R/MediaBox[0 0 ${w} ${h}]>>`),
+    ]);
+}
+
+/**
+ * 生成渲染较慢的 PDF：每页绘制大量贝塞尔曲线（所有页面共用同一个内容流）
+ */
+function buildSlowPdf(pageCount, curves = 60000) {
+    const ops = [];
+    for (let i = 0; i < curves; i++) {
+        const x = (i * 37) % 580;
+        const y = (i * 91) % 830;
+        ops.push(`${x} ${y} m ${x + 9} ${y + 13} ${x + 3} ${y - 7} ${x + 11} ${y + 2} c ${i % 2 ? 'f' : 'S'}`);
+    }
+    const content = ops.join('\n');
+    const pageIds = Array.from({ length: pageCount }, (_, i) => i + 4);
+    return assemblePdf([
+        '<</Type/Catalog/Pages 2 0 R>>',
+        `<</Type/Pages/Kids[${pageIds.map(id => `${id} 0 R`).join(' ')}]/Count ${pageCount}>>`,
+        `<</Length ${content.length}>>\nstream\n${content}\nendstream`,
+        ...pageIds.map(() => '<</Type/Page/Parent 2 0 R/MediaBox[0 0 595 842]/Contents 3 0 R>>'),
+    ]);
+}
+
+/**
+ * 生成第 1 页内嵌一张 JPEG 图片的 PDF（A4，图片绘制在 rect [x, y, width, height] 处，左上角为原点）
+ */
+function buildImagePdf(jpeg, imageWidth, imageHeight, [x, y, width, height]) {
+    const content = `q ${width} 0 0 ${height} ${x} ${842 - y - height} cm /Im0 Do Q`;
+    return assemblePdf([
+        '<</Type/Catalog/Pages 2 0 R>>',
+        '<</Type/Pages/Kids[3 0 R 6 0 R]/Count 2>>',
+        '<</Type/Page/Parent 2 0 R/MediaBox[0 0 595 842]/Resources<</XObject<</Im0 4 0 R>>>>/Contents 5 0 R>>',
+        Buffer.concat([
+            Buffer.from(`<</Type/XObject/Subtype/Image/Width ${imageWidth}/Height ${imageHeight}/ColorSpace/DeviceRGB/BitsPerComponent 8/Filter/DCTDecode/Length ${jpeg.length}>>\nstream\n`, 'latin1'),
+            jpeg,
+            Buffer.from('\nendstream', 'latin1'),
+        ]),
+        `<</Length ${content.length}>>\nstream\n${content}\nendstream`,
+        '<</Type/Page/Parent 2 0 R/MediaBox[0 0 595 842]>>',
+    ]);
+}
+
+/**
+ * 启动支持 Range 请求的本地静态文件服务
+ */
+async function startRangeServer(filePath) {
+    const data = fs.readFileSync(filePath);
+    const server = http.createServer((req, res) => {
+        const match = /^bytes=(\d+)-(\d+)$/.exec(req.headers.range || '');
+        if (!match) {
+            res.writeHead(200, { 'Content-Length': data.length, 'Accept-Ranges': 'bytes', 'ETag': `"${data.length}"` });
+            res.end(req.method === 'HEAD' ? undefined : data);
+            return;
+        }
+        const start = Number(match[1]);
+        const end = Math.min(Number(match[2]), data.length - 1);
+        res.writeHead(206, {
+            'Content-Length': end - start + 1,
+            'Content-Range': `bytes ${start}-${end}/${data.length}`,
+        });
+        res.end(data.subarray(start, end + 1));
+    });
+    await new Promise(resolve => server.listen(0, '127.0.0.1', resolve));
+    return { server, url: `http://127.0.0.1:${server.address().port}/test.pdf`, size: data.length };
+}
+
 describe('PDF2IMG API 测试', () => {
     before(async () => {
         // 导入模块
@@ -103,6 +211,56 @@ describe('PDF2IMG API 测试', () => {
             assert.ok(typeof count === 'number', '应该返回数字');
             assert.ok(count > 0, '页数应该大于 0');
         });
+
+        it('URL 输入应该只下载文件的一小部分', async () => {
+            const largePdf = path.join(STATIC_DIR, '80M.pdf');
+            if (!fs.existsSync(largePdf)) {
+                console.log(`跳过测试：测试文件不存在 ${largePdf}`);
+                return;
+            }
+
+            const { server, url, size } = await startRangeServer(largePdf);
+            try {
+                const info = await pdf2img.countPages(url);
+                assert.strictEqual(info.totalPages, await pdf2img.getPageCount(largePdf));
+                assert.strictEqual(info.fileSize, size);
+                assert.ok(info.bytesDownloaded > 0, '应该记录下载字节数');
+                assert.ok(info.bytesDownloaded / size < 0.1,
+                    `下载比例过高: ${info.bytesDownloaded}/${size}`);
+
+                assert.strictEqual(await pdf2img.getPageCount(url), info.totalPages);
+            } finally {
+                server.close();
+            }
+        });
+
+        it('URL 输入的下载字节数应该与实际分片请求一致', async () => {
+            const largePdf = path.join(STATIC_DIR, '50M.pdf');
+            if (!fs.existsSync(largePdf)) {
+                console.log(`跳过测试：测试文件不存在 ${largePdf}`);
+                return;
+            }
+
+            const { server, url, size } = await startRangeServer(largePdf);
+            try {
+                const requests = [];
+                const info = await pdf2img.countPages(url, { onRangeRequest: trace => requests.push(trace) });
+                const fetched = requests.reduce((sum, r) => sum + r.bytes, 0);
+                assert.strictEqual(info.bytesDownloaded, fetched);
+                assert.ok(fetched / size < 0.1, `下载比例过高: ${fetched}/${size}`);
+            } finally {
+                server.close();
+            }
+        });
+
+        it('getPageCountSync 文件路径输入应该与异步版本一致', async () => {
+            if (!fs.existsSync(TEST_PDF)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF}`);
+                return;
+            }
+
+            assert.strictEqual(pdf2img.getPageCountSync(TEST_PDF), await pdf2img.getPageCount(TEST_PDF));
+        });
     });
 
     describe('convert', () => {
@@ -120,6 +278,45 @@ describe('PDF2IMG API 测试', () => {
             assert.ok(result.pages, '应该包含 pages');
             assert.ok(result.pages.length > 0, '应该有页面数据');
             assert.ok(Buffer.isBuffer(result.pages[0].buffer), '页面数据应该是 Buffer');
+            assert.strictEqual(result.failedPages, 0, '不应该有失败页面');
+            assert.strictEqual(result.partial, false, '全部成功时 partial 应该为 false');
+        });
+
+        it('部分页码越界时应该作为失败页面返回并标记 partial', async () => {
+            const result = await pdf2img.convert(buildPdf([[200, 300], [300, 200]]), {
+                pages: [1, 5, -9],
+            });
+
+            assert.strictEqual(result.success, true);
+            assert.strictEqual(result.partial, true);
+            assert.strictEqual(result.renderedPages, 1);
+            assert.strictEqual(result.failedPages, 2);
+            assert.deepStrictEqual(
+                result.pages.map(p => [p.pageNum, p.success, p.error]),
+                [[1, true, undefined], [5, false, 'Page 5 out of range'], [-9, false, 'Page -9 out of range']]
+            );
+        });
+
+        it('全部页面失败时 success 应该为 false', async () => {
+            const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'pdf2img-failed-'));
+            try {
+                // 与输出文件同名的目录使写入失败
+                fs.mkdirSync(path.join(dir, 'page_1.webp'));
+                fs.mkdirSync(path.join(dir, 'page_2.webp'));
+
+                const result = await pdf2img.convert(buildPdf([[200, 300], [300, 200]]), {
+                    outputType: 'file',
+                    outputDir: dir,
+                });
+
+                assert.strictEqual(result.success, false);
+                assert.strictEqual(result.partial, false);
+                assert.strictEqual(result.renderedPages, 0);
+                assert.strictEqual(result.failedPages, 2);
+                assert.ok(result.pages.every(p => /File save failed/.test(p.error)));
+            } finally {
+                fs.rmSync(dir, { recursive: true, force: true });
+            }
         });
 
         it('应该转换 PDF 为文件', async () => {
@@ -172,27 +369,1618 @@ describe('PDF2IMG API 测试', () => {
             assert.ok(result, '应该返回结果');
             assert.ok(result.pages.length > 0, '应该有页面数据');
         });
-    });
 
-    describe('错误处理', () => {
-        it('文件不存在时应该抛出错误', async () => {
+        it('应该支持负数页码从末尾倒数', async () => {
+            if (!fs.existsSync(TEST_PDF)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF}`);
+                return;
+            }
+
+            const count = await pdf2img.getPageCount(TEST_PDF);
+            const result = await pdf2img.convert(TEST_PDF, {
+                pages: [-1],
+            });
+
+            assert.strictEqual(result.pages.length, 1, '应该只渲染一页');
+            assert.strictEqual(result.pages[0].pageNum, count, '-1 应该对应最后一页');
+        });
+
+        it('重复的页码应该只渲染一次并出现在所有请求位置', async () => {
+            const tracer = createMemoryTracer();
+            const result = await pdf2img.convert(buildPdf([[200, 300], [300, 200]]), {
+                pages: [1, 1, 2, -2],
+                format: 'png',
+                tracer,
+            });
+
+            const rendered = tracer.spans.filter(span => span.name === 'pdf2img.render_page');
+            assert.deepStrictEqual(rendered.map(span => span.attributes['pdf2img.page_num']).sort(), [1, 2], '每页应该只渲染一次');
+            assert.deepStrictEqual(result.pages.map(p => p.pageNum), [1, 1, 2, 1]);
+            assert.ok(result.pages[1].buffer.equals(result.pages[0].buffer));
+            assert.ok(result.pages[3].buffer.equals(result.pages[0].buffer));
+            assert.strictEqual(result.renderedPages, 2);
+        });
+
+        it('请求页数超过 maxPages 时应该拒绝', async () => {
+            if (!fs.existsSync(TEST_PDF_1M)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF_1M}`);
+                return;
+            }
+
+            const numPages = await pdf2img.getPageCount(TEST_PDF_1M);
             await assert.rejects(
-                async () => {
-                    await pdf2img.convert('/nonexistent/file.pdf');
-                },
-                /不存在|not found|ENOENT/i,
-                '应该抛出文件不存在错误'
+                pdf2img.convert(TEST_PDF_1M, { maxPages: 2 }),
+                err => err.code === 'ERR_TOO_MANY_PAGES' && err.message.includes(`${numPages} pages`)
             );
         });
 
-        it('无效输入应该抛出错误', async () => {
+        it('请求的页码全部超出范围时应该在渲染前拒绝', async () => {
+            if (!fs.existsSync(TEST_PDF)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF}`);
+                return;
+            }
+
+            const tracer = createMemoryTracer();
             await assert.rejects(
-                async () => {
-                    await pdf2img.convert(null);
+                pdf2img.convert(TEST_PDF, { pages: [9999], tracer }),
+                err => err.code === 'ERR_PAGE_OUT_OF_RANGE'
+            );
+            assert.strictEqual(tracer.spans.filter(span => span.name === 'pdf2img.render_page').length, 0, '不应该提交渲染任务');
+        });
+
+        it('manifest 应该与写出的文件一致', async () => {
+            const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'pdf2img-manifest-'));
+            try {
+                const result = await pdf2img.convert(buildPdf([[400, 600], [600, 400]]), {
+                    outputType: 'file',
+                    outputDir: dir,
+                    prefix: 'doc',
+                    format: 'png',
+                    exactWidth: 300,
+                    manifest: true,
+                });
+
+                assert.strictEqual(result.manifestPath, path.join(dir, 'doc_manifest.json'));
+                const manifest = JSON.parse(fs.readFileSync(result.manifestPath, 'utf8'));
+                assert.deepStrictEqual(manifest, result.manifest);
+                assert.strictEqual(manifest.numPages, 2);
+                assert.deepStrictEqual(manifest.pages.map(p => [p.pageNum, p.width, p.height, p.format, p.file]), [
+                    [1, 300, 450, 'png', 'doc_1.png'],
+                    [2, 300, 200, 'png', 'doc_2.png'],
+                ]);
+                for (const page of manifest.pages) {
+                    assert.strictEqual(fs.statSync(path.join(dir, page.file)).size, page.size);
+                }
+            } finally {
+                fs.rmSync(dir, { recursive: true, force: true });
+            }
+        });
+
+        it('应该渲染命名目标所在的页面', async () => {
+            const pdf = assemblePdf([
+                '<</Type/Catalog/Pages 2 0 R/Dests<</chapter2[4 0 R/XYZ 0 600 null]>>>>',
+                '<</Type/Pages/Kids[3 0 R 4 0 R]/Count 2>>',
+                '<</Type/Page/Parent 2 0 R/MediaBox[0 0 400 800]>>',
+                '<</Type/Page/Parent 2 0 R/MediaBox[0 0 400 800]>>',
+            ]);
+
+            const full = await pdf2img.renderNamedDestination(pdf, 'chapter2', { outputType: 'buffer', exactWidth: 400 });
+            assert.strictEqual(full.destination.pageNum, 2);
+            assert.strictEqual(full.destination.y, 600);
+            assert.deepStrictEqual(full.pages.map(p => p.pageNum), [2]);
+            assert.strictEqual(full.pages[0].height, 800);
+
+            const clipped = await pdf2img.renderNamedDestination(pdf, 'chapter2', {
+                outputType: 'buffer',
+                exactWidth: 400,
+                clipToDestination: true,
+            });
+            assert.strictEqual(clipped.pages[0].height, 600, '应该从目标位置裁剪到页面底部');
+
+            await assert.rejects(
+                pdf2img.renderNamedDestination(pdf, 'missing', { outputType: 'buffer' }),
+                err => err.code === 'ERR_DEST_NOT_FOUND'
+            );
+        });
+
+        it('没有页面的 PDF 应该抛出 ERR_NO_PAGES', async () => {
+            const empty = buildPdf([]);
+            await assert.rejects(
+                pdf2img.convert(empty, { outputType: 'buffer' }),
+                err => err.code === 'ERR_NO_PAGES'
+            );
+            await assert.rejects(
+                pdf2img.convert(empty, { outputType: 'buffer', pages: [1] }),
+                err => err.code === 'ERR_NO_PAGES',
+                '指定页码时同样应该区分于页码越界'
+            );
+        });
+
+        it('deterministic 模式下两次渲染应该逐字节一致', async () => {
+            if (!fs.existsSync(TEST_PDF)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF}`);
+                return;
+            }
+
+            for (const format of ['png', 'webp']) {
+                const first = await pdf2img.convert(TEST_PDF, { pages: [1], format, deterministic: true });
+                const second = await pdf2img.convert(TEST_PDF, { pages: [1], format, deterministic: true });
+
+                assert.ok(
+                    first.pages[0].buffer.equals(second.pages[0].buffer),
+                    `${format} 输出应该逐字节一致`
+                );
+            }
+        });
+
+        it('应该支持按页覆盖渲染选项', async () => {
+            if (!fs.existsSync(TEST_PDF_1M)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF_1M}`);
+                return;
+            }
+
+            const result = await pdf2img.convert(TEST_PDF_1M, {
+                pages: [1, 2],
+                targetWidth: 400,
+                pageOptions: {
+                    1: { targetWidth: 800, format: 'png' },
                 },
-                Error,
-                '应该抛出错误'
+            });
+
+            const [cover, thumb] = result.pages;
+            assert.strictEqual(cover.width, 800, '第 1 页应该使用覆盖的宽度');
+            assert.strictEqual(cover.format, 'png', '第 1 页应该使用覆盖的格式');
+            assert.strictEqual(thumb.width, 400, '第 2 页应该使用全局宽度');
+            assert.strictEqual(thumb.format, 'webp', '第 2 页应该使用全局格式');
+        });
+
+        it('onPage 应该在每页完成时被调用', async () => {
+            if (!fs.existsSync(TEST_PDF_1M)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF_1M}`);
+                return;
+            }
+
+            const received = [];
+            const result = await pdf2img.convert(TEST_PDF_1M, {
+                pages: [1, 2, 3],
+                onPage: (page) => received.push(page),
+            });
+
+            assert.strictEqual(received.length, result.pages.length, '每页都应该触发回调');
+            assert.deepStrictEqual(
+                received.map(p => p.pageNum).sort((a, b) => a - b),
+                result.pages.map(p => p.pageNum)
+            );
+            for (const page of received) {
+                assert.ok(Buffer.isBuffer(page.buffer), '回调中应该包含页面数据');
+            }
+        });
+
+        it('metadataPages 应该只返回尺寸不渲染', async () => {
+            const pdf = buildPdf([[400, 600], [600, 400], [300, 300], [200, 800]]);
+            const result = await pdf2img.convert(pdf, {
+                pages: [2],
+                metadataPages: [],
+                targetWidth: 200,
+            });
+
+            assert.deepStrictEqual(result.pages.map(p => p.pageNum), [1, 2, 3, 4], '应该按页码排列');
+            assert.strictEqual(result.renderedPages, 1, '只含尺寸的页面不计入渲染页数');
+
+            const [first, image, third, fourth] = result.pages;
+            assert.strictEqual(image.metadataOnly, undefined);
+            assert.ok(Buffer.isBuffer(image.buffer) && image.buffer.length > 0, '图片页应该有数据');
+            assert.strictEqual(image.width, 200);
+
+            for (const page of [first, third, fourth]) {
+                assert.strictEqual(page.metadataOnly, true);
+                assert.strictEqual(page.success, true);
+                assert.strictEqual(page.buffer, null, '只含尺寸的页面不应该有数据');
+            }
+            assert.deepStrictEqual([first.width, first.height], [200, 300]);
+            assert.deepStrictEqual([fourth.width, fourth.height], [200, 800]);
+        });
+
+        it('maxPagesInFlight 应该在消费者较慢时限制已渲染未消费的页面数', async () => {
+            const pdf = buildPdf(Array.from({ length: 12 }, () => [200, 200]));
+            let pending = 0;
+            let peak = 0;
+            const consumed = [];
+
+            const result = await pdf2img.convert(pdf, {
+                targetWidth: 100,
+                maxPagesInFlight: 2,
+                onPage: async (page) => {
+                    pending++;
+                    peak = Math.max(peak, pending);
+                    await new Promise(resolve => setTimeout(resolve, 30));
+                    consumed.push(page.pageNum);
+                    pending--;
+                },
+            });
+
+            assert.strictEqual(result.pages.length, 12);
+            assert.strictEqual(consumed.length, 12, 'convert 应该等待所有 onPage 完成');
+            assert.ok(peak <= 2, `同时缓冲的页面数 ${peak} 不应该超过上限`);
+        });
+
+        it('maxPagesInFlight 不是正整数时应该报错', async () => {
+            await assert.rejects(
+                pdf2img.convert(buildPdf([[200, 200]]), { maxPagesInFlight: 0 }),
+                /Invalid maxPagesInFlight/
+            );
+        });
+
+        it('单页渲染超时后应该记为失败', async () => {
+            const slowPdf = buildSlowPdf(1);
+            const measured = await pdf2img.convert(slowPdf, { targetWidth: 2560 });
+            const renderTime = measured.pages[0].renderTime;
+            assert.ok(renderTime >= 20, `测试页面渲染过快（${renderTime}ms），无法观察超时`);
+
+            // 超时短于页面实际的渲染耗时：渲染进行中超时
+            const result = await pdf2img.convert(slowPdf, {
+                targetWidth: 2560,
+                pageTimeout: Math.floor(renderTime / 4),
+            });
+
+            assert.strictEqual(result.success, false, '唯一的页面超时，整体应该失败');
+            assert.strictEqual(result.pages[0].success, false);
+            assert.match(result.pages[0].error, /timed out/);
+
+            // 超时的线程被替换后，线程池应该仍然可用
+            const next = await pdf2img.convert(buildPdf([[200, 300]]));
+            assert.strictEqual(next.pages[0].success, true, '超时后线程池应该仍可用');
+        });
+
+        it('应该支持裁剪页面局部区域', async () => {
+            if (!fs.existsSync(TEST_PDF)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF}`);
+                return;
+            }
+
+            const clip = { x: 0, y: 0, width: 0.5, height: 0.5 };
+            const full = await pdf2img.convert(TEST_PDF, { pages: [1], targetWidth: 800 });
+            const { width, height } = full.pages[0];
+
+            // targetWidth 作用于区域宽度：整页一半的宽度即整页的像素比例
+            const quadrant = await pdf2img.convert(TEST_PDF, { pages: [1], targetWidth: width / 2, clip });
+            assert.ok(Math.abs(quadrant.pages[0].width - width / 2) <= 1, '宽度应该约为整页的一半');
+            assert.ok(Math.abs(quadrant.pages[0].height - height / 2) <= 1, '高度应该约为整页的一半');
+
+            // 同样的 targetWidth 下瓦片的像素比例是整页的 2 倍，只光栅化该区域
+            const tile = await pdf2img.convert(TEST_PDF, { pages: [1], targetWidth: width, clip });
+            assert.ok(Math.abs(tile.pages[0].width - width) <= 1, `瓦片宽度应该为 ${width}，实际 ${tile.pages[0].width}`);
+            assert.ok(Math.abs(tile.pages[0].height - height) <= 1, `瓦片高度应该为 ${height}，实际 ${tile.pages[0].height}`);
+        });
+
+        it('maxBytes 应该自动降低质量使输出不超过上限', async () => {
+            if (!fs.existsSync(TEST_PDF_1M)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF_1M}`);
+                return;
+            }
+
+            const full = await pdf2img.convert(TEST_PDF_1M, { pages: [1], targetWidth: 1920 });
+            const lowest = await pdf2img.convert(TEST_PDF_1M, { pages: [1], targetWidth: 1920, quality: 1 });
+            const fullSize = full.pages[0].buffer.length;
+            const lowestSize = lowest.pages[0].buffer.length;
+            // 上限取最低质量与默认质量之间，保证可以达到
+            const maxBytes = Math.floor((fullSize + lowestSize) / 2);
+
+            const limited = await pdf2img.convert(TEST_PDF_1M, { pages: [1], targetWidth: 1920, maxBytes });
+            assert.ok(limited.pages[0].buffer.length <= maxBytes, `${limited.pages[0].buffer.length} > ${maxBytes}`);
+
+            // 无法达到时返回最低质量的结果
+            const unreachable = await pdf2img.convert(TEST_PDF_1M, { pages: [1], targetWidth: 1920, maxBytes: 1 });
+            assert.strictEqual(unreachable.pages[0].buffer.length, lowestSize);
+        });
+
+        it('postProcess 应该在编码前处理图像', async () => {
+            if (!fs.existsSync(TEST_PDF)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF}`);
+                return;
+            }
+
+            const options = { pages: [1], format: 'png' };
+            const plain = await pdf2img.convert(TEST_PDF, options);
+            const sharpened = await pdf2img.convert(TEST_PDF, { ...options, postProcess: ['sharpen'] });
+            const denoised = await pdf2img.convert(TEST_PDF, { ...options, postProcess: ['denoise', 'autocontrast'] });
+
+            assert.strictEqual(sharpened.pages[0].success, true);
+            assert.ok(!sharpened.pages[0].buffer.equals(plain.pages[0].buffer), '锐化后输出应该不同');
+            assert.ok(!denoised.pages[0].buffer.equals(plain.pages[0].buffer), '去噪后输出应该不同');
+            assert.strictEqual(sharpened.pages[0].width, plain.pages[0].width);
+        });
+
+        it('不支持的 postProcess 应该抛出错误', async () => {
+            await assert.rejects(
+                pdf2img.convert(TEST_PDF, { postProcess: ['blur'] }),
+                /Unsupported postProcess filter: blur/
+            );
+        });
+
+        it('maxBytes 不是正整数时应该抛出错误', async () => {
+            await assert.rejects(pdf2img.convert(TEST_PDF, { maxBytes: -1 }), /Invalid maxBytes/);
+        });
+
+        it('exactWidth 应该使不同尺寸的页面输出相同宽度', async () => {
+            // A4 纵向与 Letter 横向，宽度相差较大
+            const pdf = buildPdf([[595, 842], [792, 612]]);
+            // 50pt 宽的页面放大到 300px 需要 6 倍，超过默认 maxScale（4.0）
+            const tiny = buildPdf([[50, 80]]);
+
+            const result = await pdf2img.convert(pdf, { exactWidth: 300 });
+            assert.strictEqual(result.success, true);
+            assert.deepStrictEqual(result.pages.map(p => p.width), [300, 300]);
+            assert.ok(Math.abs(result.pages[0].height - Math.round(842 * 300 / 595)) <= 1);
+            assert.ok(Math.abs(result.pages[1].height - Math.round(612 * 300 / 792)) <= 1);
+
+            const enlarged = await pdf2img.convert(tiny, { exactWidth: 300 });
+            assert.strictEqual(enlarged.pages[0].width, 300);
+        });
+
+        it('effectiveOptions 应该反映被限制后的缩放比例和降低后的质量', async () => {
+            // 50pt 宽的页面按 1280px 需要 25.6 倍，被 maxScale（4.0）限制为 200px
+            const tiny = buildPdf([[50, 80]]);
+            const clamped = await pdf2img.convert(tiny, { targetWidth: 1280, format: 'png' });
+            const [page] = clamped.pages;
+            assert.strictEqual(page.width, 200);
+            assert.deepStrictEqual(page.effectiveOptions, { format: 'png', scale: 4, dpi: 288, quality: null });
+
+            if (!fs.existsSync(TEST_PDF_1M)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF_1M}`);
+                return;
+            }
+            const limited = await pdf2img.convert(TEST_PDF_1M, { pages: [1], format: 'jpg', maxBytes: 20 * 1024 });
+            const { quality } = limited.pages[0].effectiveOptions;
+            assert.ok(quality >= 1 && quality < 85, `质量应该被降低: ${quality}`);
+        });
+
+        it('rotate 为 90 时输出宽高应该与 0 度互换', async () => {
+            const pdf = buildPdf([[595, 842]]);
+
+            const upright = await pdf2img.convert(pdf, { format: 'png' });
+            const rotated = await pdf2img.convert(pdf, { format: 'png', rotate: 90 });
+            const { width, height } = upright.pages[0];
+            assert.ok(height > width, '纵向页面高度应该大于宽度');
+            assert.strictEqual(rotated.pages[0].width, height);
+            assert.strictEqual(rotated.pages[0].height, width);
+
+            const { default: sharp } = await import('sharp');
+            const meta = await sharp(rotated.pages[0].buffer).metadata();
+            assert.strictEqual(meta.width, height, '编码后的图片应该已旋转');
+            assert.strictEqual(meta.height, width);
+
+            const flipped = await pdf2img.convert(pdf, { format: 'png', rotate: -180 });
+            assert.strictEqual(flipped.pages[0].width, width);
+        });
+
+        it('rotate 不是 90 的整数倍时应该抛出错误', async () => {
+            await assert.rejects(pdf2img.convert(TEST_PDF, { rotate: 45 }), /Invalid rotate: 45/);
+        });
+
+        it('progressive 应该输出渐进式 JPEG 和隔行扫描 PNG', async () => {
+            const { default: sharp } = await import('sharp');
+            const pdf = buildPdf([[595, 842]]);
+
+            const jpeg = await pdf2img.convert(pdf, { format: 'jpg', progressive: true });
+            assert.strictEqual((await sharp(jpeg.pages[0].buffer).metadata()).isProgressive, true);
+
+            const png = await pdf2img.convert(pdf, { format: 'png', progressive: true });
+            assert.strictEqual((await sharp(png.pages[0].buffer).metadata()).isProgressive, true);
+
+            const plain = await pdf2img.convert(pdf, { format: 'png' });
+            assert.strictEqual((await sharp(plain.pages[0].buffer).metadata()).isProgressive, false);
+        });
+
+        it('文字水印应该改变水印区域的像素', async () => {
+            const { default: sharp } = await import('sharp');
+            const pdf = buildPdf([[595, 842]]);
+
+            const plain = await pdf2img.convert(pdf, { format: 'png' });
+            const marked = await pdf2img.convert(pdf, {
+                format: 'png',
+                watermark: { text: 'CONFIDENTIAL', fontSize: 120, opacity: 0.8, color: '#000000' },
+            });
+            assert.ok(!plain.pages[0].buffer.equals(marked.pages[0].buffer), '加水印后输出应该不同');
+            assert.strictEqual(marked.pages[0].width, plain.pages[0].width);
+
+            // 居中水印：页面中部区域应该出现深色像素，四角保持空白
+            const { width, height } = marked.pages[0];
+            const region = (left, top) => sharp(marked.pages[0].buffer)
+                .extract({ left, top, width: Math.round(width / 4), height: Math.round(height / 8) })
+                .stats();
+            const center = await region(Math.round(width * 3 / 8), Math.round(height * 7 / 16));
+            assert.ok(center.channels[0].min < 128, `中部应该被水印覆盖: min=${center.channels[0].min}`);
+            const corner = await region(0, 0);
+            assert.strictEqual(corner.channels[0].min, 255, '角落不应该被水印覆盖');
+        });
+
+        it('图片水印应该按不透明度混合到指定位置', async () => {
+            const { default: sharp } = await import('sharp');
+            const logo = await sharp({
+                create: { width: 100, height: 100, channels: 3, background: '#000' },
+            }).png().toBuffer();
+
+            const result = await pdf2img.convert(buildPdf([[595, 842]]), {
+                format: 'png',
+                watermark: { image: logo, position: 'top-left', opacity: 0.5, width: 0.5 },
+            });
+            const { width } = result.pages[0];
+            const { data, info } = await sharp(result.pages[0].buffer).raw().toBuffer({ resolveWithObject: true });
+            const pixel = (x, y) => data[(y * info.width + x) * info.channels];
+            assert.ok(Math.abs(pixel(10, 10) - 128) <= 2, `左上角应该为半透明黑色: ${pixel(10, 10)}`);
+            assert.strictEqual(pixel(Math.round(width * 0.75), 10), 255, '水印右侧应该保持空白');
+        });
+
+        it('水印缺少 text 和 image 时应该抛出错误', async () => {
+            await assert.rejects(pdf2img.convert(TEST_PDF, { watermark: { opacity: 0.5 } }), /Invalid watermark/);
+            await assert.rejects(
+                pdf2img.convert(TEST_PDF, { watermark: { text: 'x', position: 'middle' } }),
+                /Invalid watermark position/,
+            );
+        });
+
+        it('fields 应该只保留指定的页面字段', async () => {
+            const result = await pdf2img.convert(buildPdf([[595, 842], [300, 300]]), {
+                fields: ['width', 'height', 'nonexistent'],
+            });
+            assert.strictEqual(result.pages.length, 2);
+            for (const page of result.pages) {
+                assert.deepStrictEqual(Object.keys(page).sort(), ['height', 'pageNum', 'width']);
+                assert.ok(page.width > 0);
+            }
+            assert.strictEqual(result.renderedPages, 2, '顶层统计不受影响');
+
+            await assert.rejects(pdf2img.convert(TEST_PDF, { fields: 'width' }), /Invalid fields/);
+        });
+
+        it('skipBlankPages 应该跳过空白页并记录页码', async () => {
+            const { default: sharp } = await import('sharp');
+            const jpeg = await sharp({
+                create: { width: 64, height: 48, channels: 3, background: '#000' },
+            }).jpeg().toBuffer();
+            // 第 1 页绘制黑色图片，第 2 页为空白
+            const pdf = buildImagePdf(jpeg, 64, 48, [100, 200, 320, 240]);
+            const pages = [];
+
+            const result = await pdf2img.convert(pdf, {
+                skipBlankPages: true,
+                onPage: page => pages.push(page.pageNum),
+            });
+            assert.deepStrictEqual(result.skippedPages, [2]);
+            assert.deepStrictEqual(result.pages.map(page => page.pageNum), [1]);
+            assert.strictEqual(result.renderedPages, 1);
+            assert.deepStrictEqual(pages, [1], '跳过的页面不应该触发 onPage');
+
+            // 阈值足够高时所有页面都视为空白
+            const all = await pdf2img.convert(pdf, { skipBlankPages: true, blankThreshold: 255 });
+            assert.deepStrictEqual(all.skippedPages, [1, 2]);
+
+            const plain = await pdf2img.convert(pdf);
+            assert.deepStrictEqual(plain.skippedPages, []);
+            assert.strictEqual(plain.pages.length, 2);
+        });
+
+        it('blankThreshold 为负数时应该抛出错误', async () => {
+            await assert.rejects(pdf2img.convert(TEST_PDF, { blankThreshold: -1 }), /Invalid blankThreshold/);
+        });
+
+        it('blockSize 不是 16KB-4MB 之间的 2 的幂时应该抛出错误', async () => {
+            for (const blockSize of [0, 8 * 1024, 100 * 1024, 8 * 1024 * 1024]) {
+                await assert.rejects(pdf2img.convert(TEST_PDF, { blockSize }), /Invalid blockSize/);
+            }
+        });
+
+        it('exactWidth 不是正整数时应该抛出错误', async () => {
+            await assert.rejects(pdf2img.convert(TEST_PDF, { exactWidth: 0 }), /Invalid exactWidth/);
+        });
+
+        it('渲染中取消应该在页面边界停止并返回部分结果', async () => {
+            const multiPagePdf = path.join(STATIC_DIR, '10M.pdf');
+            if (!fs.existsSync(multiPagePdf)) {
+                console.log(`跳过测试：测试文件不存在 ${multiPagePdf}`);
+                return;
+            }
+            const numPages = await pdf2img.getPageCount(multiPagePdf);
+            const { workers } = pdf2img.getThreadPoolStats();
+            if (numPages <= workers + 1) {
+                console.log(`跳过测试：页数 ${numPages} 不足以在线程数 ${workers} 下观察取消`);
+                return;
+            }
+
+            const controller = new AbortController();
+            const result = await pdf2img.convert(multiPagePdf, {
+                signal: controller.signal,
+                onPage: () => controller.abort(),
+            });
+
+            assert.strictEqual(result.aborted, true);
+            const rendered = result.pages.filter(p => p.success);
+            const skipped = result.pages.filter(p => p.aborted);
+            assert.ok(rendered.length >= 1, '已在渲染的页面应该正常完成');
+            // 第一页完成时释放的名额可能已被下一页占用，因此最多 workers + 1 页
+            assert.ok(rendered.length <= workers + 1, '取消后不应该再提交新页面');
+            assert.strictEqual(rendered.length + skipped.length, numPages);
+            assert.ok(
+                Math.max(...rendered.map(p => p.pageNum)) < Math.min(...skipped.map(p => p.pageNum)),
+                '应该按页面顺序停止'
+            );
+
+            await assert.rejects(
+                pdf2img.convert(multiPagePdf, { signal: AbortSignal.abort() }),
+                err => err.name === 'AbortError'
             );
         });
+
+        it('includePageColor 应该返回接近白色的平均颜色', async () => {
+            if (!fs.existsSync(TEST_PDF)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF}`);
+                return;
+            }
+
+            const result = await pdf2img.convert(TEST_PDF, { pages: [1], includePageColor: true });
+            const { avgColor } = result.pages[0];
+
+            assert.match(avgColor, /^#[0-9a-f]{6}$/);
+            const [r, g, b] = [1, 3, 5].map(i => parseInt(avgColor.slice(i, i + 2), 16));
+            assert.ok(Math.min(r, g, b) > 200, `发票页面以白色为主，实际 ${avgColor}`);
+
+            const plain = await pdf2img.convert(TEST_PDF, { pages: [1] });
+            assert.strictEqual(plain.pages[0].avgColor, undefined, '未开启时不应该计算');
+        });
+
+        it('所有渲染入口都应该按同样的 DPI 上限截断', async () => {
+            // 10pt 宽的页面：目标宽度 5000 对应 36000 DPI，应该被截断到 PDF2IMG_MAX_DPI（默认 600）
+            const pdf = buildPdf([[10, 10], [10, 10]]);
+            const expected = Math.round(10 * pdf2img.RENDER_CONFIG.MAX_DPI / 72);
+
+            const viaOptions = await pdf2img.convert(pdf, { pages: [1], targetWidth: 5000, maxScale: 100 });
+            const viaExactWidth = await pdf2img.convert(pdf, { pages: [1], exactWidth: 5000 });
+            const viaPageOptions = await pdf2img.convert(pdf, {
+                pages: [1],
+                maxScale: 100,
+                pageOptions: { 1: { targetWidth: 5000 } },
+            });
+            const viaMetadata = await pdf2img.convert(pdf, { pages: [1], metadataPages: [2], targetWidth: 5000, maxScale: 100 });
+            const viaTiff = await pdf2img.renderMultiPageTiff(pdf, { pages: [1], targetWidth: 5000, maxScale: 100 });
+
+            assert.deepStrictEqual(
+                [viaOptions.pages[0], viaExactWidth.pages[0], viaPageOptions.pages[0], viaMetadata.pages[1], viaTiff].map(r => r.width),
+                Array(5).fill(expected)
+            );
+            assert.strictEqual(viaOptions.pages[0].effectiveOptions.dpi, pdf2img.RENDER_CONFIG.MAX_DPI);
+        });
+
+        it('includePlaceholder 应该返回极小的 WebP data URI', async () => {
+            const result = await pdf2img.convert(buildPdf([[400, 600]]), {
+                includePlaceholder: true,
+                targetWidth: 800,
+            });
+            const { placeholder } = result.pages[0];
+
+            const [, base64] = placeholder.match(/^data:image\/webp;base64,([A-Za-z0-9+/=]+)$/);
+            const buffer = Buffer.from(base64, 'base64');
+            assert.ok(buffer.length < 1024, `占位图应该很小，实际 ${buffer.length} 字节`);
+
+            const metadata = await sharp(buffer).metadata();
+            assert.strictEqual(metadata.format, 'webp');
+            assert.strictEqual(metadata.width, 16);
+            assert.strictEqual(metadata.height, 24, '应该保持页面宽高比');
+
+            const plain = await pdf2img.convert(buildPdf([[400, 600]]));
+            assert.strictEqual(plain.pages[0].placeholder, undefined, '未开启时不应该生成');
+        });
+
+        it('dpis 应该为每页返回各 DPI 的输出', async () => {
+            const sizes = [[200, 300], [300, 200]];
+            const result = await pdf2img.convert(buildPdf(sizes), { dpis: [150, 300] });
+
+            assert.strictEqual(result.pages.length, 2);
+            for (const [i, [width, height]] of sizes.entries()) {
+                const page = result.pages[i];
+                assert.deepStrictEqual(page.variants.map(v => v.dpi), [150, 300]);
+                for (const variant of page.variants) {
+                    assert.strictEqual(variant.width, Math.round(width * variant.dpi / 72));
+                    assert.strictEqual(variant.height, Math.round(height * variant.dpi / 72));
+                    const metadata = await sharp(variant.buffer).metadata();
+                    assert.deepStrictEqual([metadata.width, metadata.height], [variant.width, variant.height]);
+                    assert.strictEqual(variant.size, variant.buffer.length);
+                }
+                assert.strictEqual(page.buffer, page.variants[1].buffer, '页面本身应该是最高 DPI 的输出');
+                assert.strictEqual(page.effectiveOptions.dpi, 300);
+            }
+
+            await assert.rejects(
+                pdf2img.convert(buildPdf(sizes), { dpis: [150, 0] }),
+                /Invalid dpis/
+            );
+            await assert.rejects(
+                pdf2img.convert(buildPdf(sizes), { dpis: [150], outputType: 'file', outputDir: os.tmpdir() }),
+                /only supported with outputType "buffer"/
+            );
+        });
+
+        it('dpis 应该按每档的质量编码', async () => {
+            const tiered = await pdf2img.convert(TEST_PDF, {
+                pages: [1],
+                format: 'webp',
+                dpis: [{ dpi: 150, quality: 40 }, { dpi: 300, quality: 90 }],
+            });
+            const uniform = await pdf2img.convert(TEST_PDF, { pages: [1], format: 'webp', quality: 90, dpis: [150, 300] });
+
+            const [low, high] = tiered.pages[0].variants;
+            assert.deepStrictEqual([low.quality, high.quality], [40, 90]);
+            assert.deepStrictEqual(uniform.pages[0].variants.map(v => v.quality), [90, 90]);
+
+            // 同一尺寸下，低 DPI 按较低质量编码的输出应该更小，高 DPI 仍按较高质量编码
+            const [uniformLow, uniformHigh] = uniform.pages[0].variants;
+            assert.deepStrictEqual([low.width, low.height], [uniformLow.width, uniformLow.height]);
+            assert.ok(low.size < uniformLow.size, `低 DPI：质量 40 为 ${low.size} 字节，质量 90 为 ${uniformLow.size} 字节`);
+            assert.deepStrictEqual([high.width, high.height], [uniformHigh.width, uniformHigh.height]);
+            assert.ok(high.size > low.size);
+
+            await assert.rejects(
+                pdf2img.convert(TEST_PDF, { dpis: [{ dpi: 150, quality: 0 }] }),
+                /Invalid dpis quality/
+            );
+        });
+
+        it('裁剪区域超出页面时应该抛出错误', async () => {
+            await assert.rejects(
+                pdf2img.convert(TEST_PDF, { clip: { x: 0.5, y: 0, width: 0.8, height: 0.5 } }),
+                /Invalid clip/
+            );
+        });
+
+        it('传入 tracer 时应该产生下载、打开和逐页渲染 span，并向出站请求注入追踪上下文', async () => {
+            if (!fs.existsSync(TEST_PDF_1M)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF_1M}`);
+                return;
+            }
+
+            const { server, url, size } = await startRangeServer(TEST_PDF_1M);
+            const traceparents = new Set();
+            server.prependListener('request', req => traceparents.add(req.headers.traceparent));
+            const tracer = createMemoryTracer();
+            const traceparent = '00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01';
+
+            try {
+                await pdf2img.convert(url, {
+                    pages: [1, 2],
+                    tracer,
+                    injectTraceContext: headers => { headers.traceparent = traceparent; },
+                });
+            } finally {
+                server.close();
+            }
+
+            const byName = name => tracer.spans.filter(span => span.name === name);
+            const [root] = byName('pdf2img.convert');
+            assert.ok(root, '应该有 pdf2img.convert 根 span');
+            assert.strictEqual(root.attributes['pdf2img.format'], 'webp');
+            assert.ok(root.attributes['pdf2img.page_count'] > 0);
+
+            const download = byName('pdf2img.download').concat(byName('pdf2img.stream_render'));
+            assert.strictEqual(download.length, 1);
+            assert.strictEqual(download[0].attributes['pdf2img.file_size'], size);
+            assert.ok(download[0].attributes['pdf2img.bytes_downloaded'] > 0);
+            assert.strictEqual(download[0].parent, root);
+
+            const pages = byName('pdf2img.render_page');
+            if (pages.length > 0) {
+                assert.deepStrictEqual(pages.map(span => span.attributes['pdf2img.page_num']).sort(), [1, 2]);
+                assert.ok(pages.every(span => span.parent === root && span.attributes['pdf2img.width'] > 0));
+                assert.strictEqual(byName('pdf2img.open')[0].parent, root);
+            }
+
+            assert.deepStrictEqual([...traceparents], [traceparent], '所有出站请求都应该携带追踪上下文');
+        });
+    });
+
+    describe('convertBatch', () => {
+        it('单个文档失败不应该影响其他文档', async () => {
+            if (!fs.existsSync(TEST_PDF)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF}`);
+                return;
+            }
+
+            const results = await pdf2img.convertBatch([
+                TEST_PDF,
+                '/nonexistent/file.pdf',
+                { input: TEST_PDF, format: 'png' },
+                { input: 'http://127.0.0.1:1/unreachable.pdf' },
+                { input: TEST_PDF, pages: [9999] },
+            ], { pages: [1], batchConcurrency: 2 });
+
+            assert.deepStrictEqual(results.map(r => r.index), [0, 1, 2, 3, 4]);
+            assert.deepStrictEqual(results.map(r => r.success), [true, false, true, false, false]);
+            assert.strictEqual(results[0].result.format, 'webp');
+            assert.strictEqual(results[2].result.format, 'png', '按项选项应该覆盖公共选项');
+            assert.match(results[1].error, /File not found/);
+            assert.strictEqual(results[4].code, 'ERR_PAGE_OUT_OF_RANGE');
+        });
+    });
+
+    describe('渲染缓存', () => {
+        it('第二次请求同一页面应该直接从缓存返回，不下载也不渲染', async () => {
+            if (!fs.existsSync(TEST_PDF_1M)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF_1M}`);
+                return;
+            }
+
+            const { server, url } = await startRangeServer(TEST_PDF_1M);
+            const requests = [];
+            server.prependListener('request', req => requests.push(req.method));
+            const cache = pdf2img.createRenderCache();
+            const tracer = createMemoryTracer();
+
+            try {
+                const first = await pdf2img.convert(url, { pages: [1], cache });
+                assert.strictEqual(first.pages[0].success, true);
+
+                requests.length = 0;
+                const second = await pdf2img.convert(url, { pages: [1], cache, tracer });
+
+                assert.deepStrictEqual(requests, ['HEAD'], '命中时只应该校验文件版本');
+                assert.strictEqual(tracer.spans.filter(span => span.name === 'pdf2img.render_page').length, 0);
+                assert.ok(second.pages[0].buffer.equals(first.pages[0].buffer));
+                assert.strictEqual(second.timing.render, 0);
+                assert.strictEqual(cache.getStats().hits, 2, '页数和页面各命中一次');
+
+                // 编码参数不同时不应该命中
+                await pdf2img.convert(url, { pages: [1], cache, format: 'png' });
+                assert.ok(requests.includes('GET'));
+            } finally {
+                server.close();
+            }
+        });
+
+        it('stats 应该反映缓存未命中和命中', async () => {
+            const pdf = buildPdf([[200, 300]]);
+            const cache = pdf2img.createRenderCache();
+
+            const miss = await pdf2img.convert(pdf, { cache });
+            assert.deepStrictEqual(miss.stats, { fromCache: false, backend: 'pdfium', cacheHitRatio: null });
+
+            const hit = await pdf2img.convert(pdf, { cache });
+            assert.deepStrictEqual(hit.stats, { fromCache: true, backend: 'pdfium', cacheHitRatio: null });
+            assert.ok(hit.pages[0].buffer.equals(miss.pages[0].buffer));
+        });
+    });
+
+    describe('线程池', () => {
+        /**
+         * 在子进程中以指定的环境变量加载模块（线程池配置在模块加载时确定）
+         */
+        function runWithEnv(env, script) {
+            const source = `import('${path.join(__dirname, '../src/index.js')}').then(async (pdf2img) => { ${script} })`;
+            return execFileSync(process.execPath, ['-e', source], {
+                env: { ...process.env, ...env },
+                encoding: 'utf8',
+                stdio: ['ignore', 'pipe', 'pipe'],
+            });
+        }
+
+        it('PDF2IMG_THREAD_COUNT=2 时应该使用 2 个工作线程渲染', () => {
+            if (!fs.existsSync(TEST_PDF_1M)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF_1M}`);
+                return;
+            }
+
+            const output = runWithEnv({ PDF2IMG_THREAD_COUNT: '2' }, `
+                const result = await pdf2img.convert('${TEST_PDF_1M}', { pages: [1, 2] });
+                console.log(JSON.stringify({
+                    workers: pdf2img.getThreadPoolStats().workers,
+                    success: result.pages.every(p => p.success),
+                }));
+                await pdf2img.destroyThreadPool();
+            `);
+            const stats = JSON.parse(output.trim().split('\n').pop());
+            assert.strictEqual(stats.workers, 2);
+            assert.strictEqual(stats.success, true);
+        });
+
+        it('在线程池中排队的时间不应该计入单页超时', () => {
+            const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'pdf2img-timeout-'));
+            try {
+                const pdfPath = path.join(dir, 'slow.pdf');
+                fs.writeFileSync(pdfPath, buildSlowPdf(8));
+
+                // 单线程下 8 页依次渲染，最后一页排队约 7 页的时间，超时只有单页耗时的 3 倍
+                const output = runWithEnv({ PDF2IMG_THREAD_COUNT: '1' }, `
+                    const measured = await pdf2img.convert('${pdfPath}', { pages: [1], targetWidth: 1280 });
+                    const pageTime = measured.pages[0].renderTime + measured.pages[0].encodeTime;
+                    const result = await pdf2img.convert('${pdfPath}', { targetWidth: 1280, pageTimeout: pageTime * 3 + 50 });
+                    console.log(JSON.stringify({ pageTime, pages: result.pages.map(p => [p.pageNum, p.success, p.error ?? null]) }));
+                    await pdf2img.destroyThreadPool();
+                `);
+                const { pageTime, pages } = JSON.parse(output.trim().split('\n').pop());
+                assert.ok(pageTime >= 20, `测试页面渲染过快（${pageTime}ms），无法观察排队`);
+                assert.deepStrictEqual(pages, Array.from({ length: 8 }, (_, i) => [i + 1, true, null]));
+            } finally {
+                fs.rmSync(dir, { recursive: true, force: true });
+            }
+        });
+
+        it('无效的 PDF2IMG_THREAD_COUNT 应该回退为 CPU 核心数', () => {
+            const output = runWithEnv({ PDF2IMG_THREAD_COUNT: 'abc' }, `
+                console.log(pdf2img.getThreadPoolStats().workers);
+            `);
+            assert.strictEqual(Number(output.trim().split('\n').pop()), os.cpus().length);
+        });
+
+        it('PDF2IMG_DEFAULT_PAGES=1 时未指定页码应该只渲染第一页', () => {
+            const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'pdf2img-default-pages-'));
+            try {
+                const pdfPath = path.join(dir, 'doc.pdf');
+                fs.writeFileSync(pdfPath, buildPdf([[200, 300], [300, 200], [200, 200]]));
+
+                const output = runWithEnv({ PDF2IMG_DEFAULT_PAGES: '1' }, `
+                    const rendered = [];
+                    for (const pages of [undefined, [], [2, 3], 'all']) {
+                        const result = await pdf2img.convert('${pdfPath}', { pages });
+                        rendered.push(result.pages.map(p => p.pageNum));
+                    }
+                    console.log(JSON.stringify(rendered));
+                    await pdf2img.destroyThreadPool();
+                `);
+                assert.deepStrictEqual(JSON.parse(output.trim().split('\n').pop()), [[1], [1], [2, 3], [1, 2, 3]]);
+            } finally {
+                fs.rmSync(dir, { recursive: true, force: true });
+            }
+        });
+
+        it('PDF2IMG_POOL_MAX_TASKS=2 时应该在完成 2 个页面后回收线程池', () => {
+            const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'pdf2img-recycle-'));
+            try {
+                const pdfPath = path.join(dir, 'doc.pdf');
+                fs.writeFileSync(pdfPath, buildPdf([[200, 300], [300, 200], [200, 200]]));
+
+                const output = runWithEnv({ PDF2IMG_POOL_MAX_TASKS: '2' }, `
+                    const recycled = [];
+                    let success = true;
+                    for (const pages of [[1], [2], [3], [1, 2, 3]]) {
+                        const result = await pdf2img.convert('${pdfPath}', { pages });
+                        success = success && result.pages.every(p => p.success);
+                        recycled.push(pdf2img.getThreadPoolStats().recycled);
+                    }
+                    console.log(JSON.stringify({ recycled, success }));
+                    await pdf2img.destroyThreadPool();
+                `);
+                const stats = JSON.parse(output.trim().split('\n').pop());
+                assert.strictEqual(stats.success, true);
+                // 第 3 次转换前已完成 2 个页面，换用新线程池；第 4 次转换前新线程池只完成 1 个页面
+                assert.deepStrictEqual(stats.recycled, [0, 0, 1, 1]);
+            } finally {
+                fs.rmSync(dir, { recursive: true, force: true });
+            }
+        });
+
+        it('逐步提交页面的转换进行中触发回收时应该继续完成剩余页面', () => {
+            const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'pdf2img-recycle-'));
+            try {
+                const pdfPath = path.join(dir, 'doc.pdf');
+                fs.writeFileSync(pdfPath, buildPdf([[200, 300], [300, 200], [200, 200], [300, 300]]));
+
+                const output = runWithEnv({ PDF2IMG_POOL_MAX_TASKS: '2' }, `
+                    let second;
+                    // maxPagesInFlight: 1 时页面逐个提交；第 2 页完成后旧线程池已达到回收条件，
+                    // 此时开始的转换触发回收并使用新线程池，第一个转换的第 3、4 页仍提交到旧线程池
+                    const first = await pdf2img.convert('${pdfPath}', {
+                        maxPagesInFlight: 1,
+                        onPage: async (page) => {
+                            if (page.pageNum === 2) {
+                                second = await pdf2img.convert('${pdfPath}', { pages: [1] });
+                            }
+                        },
+                    });
+                    console.log(JSON.stringify({
+                        first: first.pages.map(p => [p.pageNum, p.success]),
+                        second: second.pages.map(p => [p.pageNum, p.success]),
+                        recycled: pdf2img.getThreadPoolStats().recycled,
+                    }));
+                    await pdf2img.destroyThreadPool();
+                `);
+                const stats = JSON.parse(output.trim().split('\n').pop());
+                assert.deepStrictEqual(stats.first, [[1, true], [2, true], [3, true], [4, true]]);
+                assert.deepStrictEqual(stats.second, [[1, true]]);
+                assert.strictEqual(stats.recycled, 1);
+            } finally {
+                fs.rmSync(dir, { recursive: true, force: true });
+            }
+        });
+    });
+
+    describe('extractImages', () => {
+        it('应该提取页面内嵌的 JPEG 图片及其位置', async () => {
+            const { default: sharp } = await import('sharp');
+            const jpeg = await sharp({
+                create: { width: 64, height: 48, channels: 3, background: { r: 200, g: 30, b: 30 } },
+            }).jpeg().toBuffer();
+            const pdf = buildImagePdf(jpeg, 64, 48, [100, 200, 320, 240]);
+
+            const images = await pdf2img.extractImages(pdf, 1);
+            assert.strictEqual(images.length, 1);
+            const [image] = images;
+            assert.strictEqual(image.width, 64);
+            assert.strictEqual(image.height, 48);
+            assert.strictEqual(image.format, 'png');
+            for (const [key, expected] of Object.entries({ x: 100, y: 200, width: 320, height: 240 })) {
+                assert.ok(Math.abs(image.bounds[key] - expected) < 1, `bounds.${key} 应该约为 ${expected}`);
+            }
+
+            const { channels } = await sharp(image.buffer).stats();
+            assert.ok(Math.abs(channels[0].mean - 200) < 10, '红色通道应该与原图一致');
+            assert.ok(Math.abs(channels[1].mean - 30) < 10, '绿色通道应该与原图一致');
+        });
+
+        it('没有图片的页面应该返回空数组', async () => {
+            const { default: sharp } = await import('sharp');
+            const jpeg = await sharp({
+                create: { width: 8, height: 8, channels: 3, background: '#000' },
+            }).jpeg().toBuffer();
+            const pdf = buildImagePdf(jpeg, 8, 8, [0, 0, 10, 10]);
+
+            assert.deepStrictEqual(await pdf2img.extractImages(pdf, 2), []);
+        });
+
+        it('页码无效时应该抛出错误', async () => {
+            await assert.rejects(pdf2img.extractImages(buildPdf([[595, 842]]), 2), /Invalid page number/);
+            await assert.rejects(pdf2img.extractImages(buildPdf([[595, 842]]), 0), /Invalid pageNum/);
+        });
+    });
+
+    describe('renderMultiPageTiff', () => {
+        it('应该输出包含所有页面的多页 TIFF', async () => {
+            if (!fs.existsSync(TEST_PDF_1M)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF_1M}`);
+                return;
+            }
+
+            const { default: sharp } = await import('sharp');
+            const result = await pdf2img.renderMultiPageTiff(TEST_PDF_1M, {
+                pages: [1, 2, 3],
+                targetWidth: 600,
+                grayscale: true,
+            });
+
+            assert.strictEqual(result.pageCount, 3);
+            const metadata = await sharp(result.buffer, { pages: -1 }).metadata();
+            assert.strictEqual(metadata.format, 'tiff');
+            assert.strictEqual(metadata.pages, 3, 'TIFF 应该包含 3 页');
+            assert.strictEqual(metadata.width, result.width);
+        });
+
+        it('页数达到上限时应该输出全部页面，超过上限时应该在渲染前失败', async () => {
+            const limit = pdf2img.RENDER_CONFIG.MAX_TIFF_PAGES;
+            const pdf = buildPdf(Array(limit + 1).fill([100, 150]));
+
+            const result = await pdf2img.renderMultiPageTiff(pdf, { pages: 'all', targetWidth: 100, maxPages: limit + 1 }).catch(err => err);
+            assert.strictEqual(result.code, 'ERR_TOO_MANY_PAGES', 'maxPages 不能超过 PDF2IMG_MAX_TIFF_PAGES');
+
+            const pages = Array.from({ length: limit }, (_, i) => i + 1);
+            const tiff = await pdf2img.renderMultiPageTiff(pdf, { pages, targetWidth: 100 });
+            assert.strictEqual(tiff.pageCount, limit);
+            const metadata = await sharp(tiff.buffer, { pages: -1 }).metadata();
+            assert.strictEqual(metadata.pages, limit);
+            assert.strictEqual(metadata.pageHeight, tiff.height);
+
+            await assert.rejects(
+                pdf2img.renderMultiPageTiff(pdf, { pages, targetWidth: 100, maxPages: 2 }),
+                { code: 'ERR_TOO_MANY_PAGES' }
+            );
+        });
+
+        it('不支持的压缩方式应该抛出错误', async () => {
+            await assert.rejects(
+                pdf2img.renderMultiPageTiff(TEST_PDF, { compression: 'bogus' }),
+                /Unsupported TIFF compression/
+            );
+        });
+    });
+
+    describe('ZIP 输入', () => {
+        it('应该渲染远程 ZIP 中的条目而不下载整个归档', async () => {
+            const archive = buildZip([
+                { name: 'padding.bin', data: crypto.randomBytes(1024 * 1024) },
+                { name: 'docs/a.pdf', data: buildPdf([[200, 300], [300, 200]]), deflate: true },
+            ]);
+            let bytesSent = 0;
+            const server = http.createServer((req, res) => {
+                bytesSent += serveRange(req, res, archive);
+            });
+            await new Promise(resolve => server.listen(0, '127.0.0.1', resolve));
+            const tempDir = process.env.PDF2IMG_TEMP_DIR || os.tmpdir();
+            const listTempFiles = () => fs.readdirSync(tempDir).filter(name => name.startsWith('pdf2img_'));
+            const before = listTempFiles();
+
+            try {
+                const result = await pdf2img.convert(`zip://http://127.0.0.1:${server.address().port}/batch.zip!docs/a.pdf`, {
+                    pages: [2],
+                    exactWidth: 150,
+                });
+                assert.strictEqual(result.numPages, 2);
+                assert.deepStrictEqual(result.pages.map(p => [p.pageNum, p.width, p.height]), [[2, 150, 100]]);
+                assert.ok(bytesSent < archive.length / 4, `下载了 ${bytesSent} 字节，归档共 ${archive.length} 字节`);
+                // 解压出的临时文件在转换结束后删除
+                assert.deepStrictEqual(listTempFiles(), before);
+            } finally {
+                server.close();
+            }
+        });
+    });
+
+    describe('renderSprite', () => {
+        it('缩略图坐标应该在精灵图范围内且互不重叠', async () => {
+            const { default: sharp } = await import('sharp');
+            const result = await pdf2img.renderSprite(buildPdf([[200, 300], [300, 200], [200, 200], [200, 400]]), {
+                thumbWidth: 100,
+                maxWidth: 250,
+                gap: 4,
+                format: 'png',
+            });
+
+            const metadata = await sharp(result.buffer).metadata();
+            assert.strictEqual(metadata.format, 'png');
+            assert.strictEqual(metadata.width, result.width);
+            assert.strictEqual(metadata.height, result.height);
+            assert.strictEqual(result.numPages, 4);
+            assert.deepStrictEqual(result.pages.map(p => [p.pageNum, p.width, p.height]), [
+                [1, 100, 150],
+                [2, 100, 67],
+                [3, 100, 100],
+                [4, 100, 200],
+            ]);
+
+            for (const [i, a] of result.pages.entries()) {
+                assert.ok(a.x + a.width <= result.width && a.y + a.height <= result.height, `第 ${a.pageNum} 页应该在精灵图范围内`);
+                for (const b of result.pages.slice(i + 1)) {
+                    const overlaps = a.x < b.x + b.width && b.x < a.x + a.width
+                        && a.y < b.y + b.height && b.y < a.y + a.height;
+                    assert.ok(!overlaps, `第 ${a.pageNum} 和第 ${b.pageNum} 页不应该重叠`);
+                }
+            }
+        });
+
+        it('不支持的格式应该抛出错误', async () => {
+            await assert.rejects(
+                pdf2img.renderSprite(buildPdf([[200, 200]]), { format: 'gif' }),
+                /Unsupported format: gif/
+            );
+        });
+    });
+
+    describe('getOutline', () => {
+        const OUTLINE_PDF = path.join(STATIC_DIR, '10M.pdf');
+
+        it('没有书签时应该返回空数组', async () => {
+            if (!fs.existsSync(TEST_PDF)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF}`);
+                return;
+            }
+
+            const outline = await pdf2img.getOutline(TEST_PDF);
+            assert.deepStrictEqual(outline, []);
+        });
+
+        it('应该返回书签树', async () => {
+            if (!fs.existsSync(OUTLINE_PDF)) {
+                console.log(`跳过测试：测试文件不存在 ${OUTLINE_PDF}`);
+                return;
+            }
+
+            const numPages = await pdf2img.getPageCount(OUTLINE_PDF);
+            const outline = await pdf2img.getOutline(OUTLINE_PDF);
+            assert.ok(outline.length > 0, '应该有顶层书签');
+
+            const walk = (items) => {
+                for (const item of items) {
+                    assert.ok(typeof item.title === 'string', '标题应该是字符串');
+                    if (item.pageNum != null) {
+                        assert.ok(item.pageNum >= 1 && item.pageNum <= numPages, '书签页码应该在范围内');
+                    }
+                    assert.ok(Array.isArray(item.children), 'children 应该是数组');
+                    walk(item.children);
+                }
+            };
+            walk(outline);
+        });
+
+        it('URL 输入应该与本地文件结果一致', async () => {
+            if (!fs.existsSync(OUTLINE_PDF)) {
+                console.log(`跳过测试：测试文件不存在 ${OUTLINE_PDF}`);
+                return;
+            }
+
+            const { server, url } = await startRangeServer(OUTLINE_PDF);
+            try {
+                const remote = await pdf2img.getOutline(url);
+                const local = await pdf2img.getOutline(OUTLINE_PDF);
+                assert.deepStrictEqual(remote, local);
+            } finally {
+                server.close();
+            }
+        });
+    });
+
+    describe('线性化 PDF', () => {
+        it('应该检测线性化文件并按需加载', async () => {
+            if (!fs.existsSync(TEST_PDF_1M)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF_1M}`);
+                return;
+            }
+
+            // 使用 qpdf 生成线性化版本，未安装时跳过
+            const linearizedPdf = path.join(OUTPUT_DIR, 'linearized.pdf');
+            try {
+                execFileSync('qpdf', ['--linearize', TEST_PDF_1M, linearizedPdf]);
+            } catch {
+                console.log('跳过测试：需要 qpdf 生成线性化 PDF');
+                return;
+            }
+
+            const plain = await startRangeServer(TEST_PDF_1M);
+            const linearized = await startRangeServer(linearizedPdf);
+            try {
+                const plainResult = await pdf2img.convert(plain.url, { pages: [1] });
+                assert.strictEqual(plainResult.linearized, false, '普通文件不应该被识别为线性化');
+
+                const result = await pdf2img.convert(linearized.url, { pages: [1] });
+                assert.strictEqual(result.linearized, true, '应该识别线性化文件');
+                assert.strictEqual(result.pages[0].success, true);
+                assert.ok(
+                    result.streamStats.totalBytesFetched < linearized.size,
+                    '按需加载的下载量应该小于完整文件'
+                );
+            } finally {
+                plain.server.close();
+                linearized.server.close();
+                fs.rmSync(linearizedPdf, { force: true });
+            }
+        });
+
+        it('按需加载时应该逐页回调 onPage、响应取消，需要工作线程的选项不应该探测', async () => {
+            if (!fs.existsSync(TEST_PDF_1M)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF_1M}`);
+                return;
+            }
+
+            const linearizedPdf = path.join(OUTPUT_DIR, 'linearized-pages.pdf');
+            try {
+                execFileSync('qpdf', ['--linearize', TEST_PDF_1M, linearizedPdf]);
+            } catch {
+                console.log('跳过测试：需要 qpdf 生成线性化 PDF');
+                return;
+            }
+
+            const data = fs.readFileSync(linearizedPdf);
+            const requests = [];
+            const server = http.createServer((req, res) => {
+                requests.push({ method: req.method, range: req.headers.range });
+                serveRange(req, res, data);
+            });
+            await new Promise(resolve => server.listen(0, '127.0.0.1', resolve));
+            const url = `http://127.0.0.1:${server.address().port}/linearized.pdf`;
+            try {
+                // 第 1 页送达时后续页面的数据块尚未全部下载
+                const seen = [];
+                const result = await pdf2img.convert(url, {
+                    pages: [1, 2, 3],
+                    onPage: page => {
+                        seen.push({ pageNum: page.pageNum, requests: requests.length, hasBuffer: Buffer.isBuffer(page.buffer) });
+                    },
+                });
+                assert.strictEqual(result.linearized, true);
+                assert.deepStrictEqual(seen.map(page => page.pageNum), [1, 2, 3]);
+                assert.ok(seen.every(page => page.hasBuffer));
+                assert.ok(seen[0].requests < requests.length, '第 1 页应该在其余页面下载完成前回调');
+                assert.ok(result.pages.every(page => page.success && page.buffer.length > 0));
+
+                // 第 1 页送达后取消：以 AbortError 结束
+                const controller = new AbortController();
+                await assert.rejects(
+                    pdf2img.convert(url, { pages: [1, 2, 3], signal: controller.signal, onPage: () => controller.abort() }),
+                    err => err.name === 'AbortError'
+                );
+
+                // 旋转需要工作线程处理，直接完整下载，不发送线性化探测请求
+                requests.length = 0;
+                const rotated = await pdf2img.convert(url, { pages: [1], rotate: 90 });
+                assert.strictEqual(rotated.linearized, false);
+                assert.ok(requests.every(request => !request.range), '不应该发送探测请求');
+            } finally {
+                server.close();
+                fs.rmSync(linearizedPdf, { force: true });
+            }
+        });
+    });
+
+    describe('renderPageToRawBitmap', () => {
+        it('应该返回未编码的 RGBA 位图', async () => {
+            if (!fs.existsSync(TEST_PDF)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF}`);
+                return;
+            }
+            if (!pdf2img.isNativeAvailable()) {
+                console.log('跳过测试：原生渲染器不可用');
+                return;
+            }
+
+            const result = pdf2img.renderPageToRawBitmap(TEST_PDF, 1, { targetWidth: 400 });
+
+            assert.ok(result.success, '应该渲染成功');
+            assert.strictEqual(result.width, 400, '宽度应该是 400');
+            assert.ok(result.height > 0, '高度应该大于 0');
+            assert.strictEqual(result.channels, 4, '应该是 RGBA 四通道');
+            assert.strictEqual(result.buffer.length, result.width * result.height * 4, '像素数据长度应该与尺寸一致');
+
+            // 发票页面必然包含非白色像素（文字、线条）
+            let hasInk = false;
+            for (let i = 0; i < result.buffer.length; i += 4) {
+                if (result.buffer[i] < 200 || result.buffer[i + 1] < 200 || result.buffer[i + 2] < 200) {
+                    hasInk = true;
+                    break;
+                }
+            }
+            assert.ok(hasInk, '应该包含非白色像素');
+        });
+    });
+
+    describe('renderFromStream', () => {
+        it('应该通过 onRangeRequest 追踪每个分片请求', async () => {
+            if (!fs.existsSync(TEST_PDF_1M)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF_1M}`);
+                return;
+            }
+            if (!pdf2img.isNativeAvailable()) {
+                console.log('跳过测试：原生渲染器不可用');
+                return;
+            }
+
+            const { server, url, size } = await startRangeServer(TEST_PDF_1M);
+            const traces = [];
+
+            try {
+                const result = await pdf2img.renderFromStream(url, size, [1], {
+                    onRangeRequest: trace => traces.push(trace),
+                });
+
+                assert.ok(result.success, '应该渲染成功');
+                assert.strictEqual(traces.length, result.streamStats.totalRequests, '每个分片请求都应该被追踪');
+
+                const tracedBytes = traces.reduce((sum, t) => sum + t.bytes, 0);
+                assert.strictEqual(tracedBytes, result.streamStats.totalBytesFetched, '追踪字节数应该与统计一致');
+
+                // PDFium 打开文档时必然读取文件末尾的 xref/trailer
+                assert.ok(traces.every(t => t.status === 206 && !t.error), '所有请求都应该成功');
+                assert.ok(traces.some(t => t.offset + t.bytes === size), '应该读取文件末尾');
+
+                // 效率报告各项统计应该相互一致
+                const report = pdf2img.efficiencyReport(result.streamStats, size);
+                assert.strictEqual(report.networkBytes, tracedBytes);
+                assert.ok(report.cacheBytes <= report.requestedBytes, '缓存提供量不应该超过读取量');
+                assert.ok(report.touchedBytes <= report.networkBytes, '触及量不应该超过下载量');
+                assert.strictEqual(report.touchedBytes + report.refetchedBytes, report.networkBytes);
+                assert.ok(report.touchedRatio > 0 && report.touchedRatio <= 1);
+                const pageStats = result.pages.map(page => page.streamStats).filter(Boolean);
+                assert.strictEqual(
+                    pageStats.reduce((sum, s) => sum + s.requestedBytes, 0),
+                    result.streamStats.requestedBytes,
+                    '逐页读取量之和应该等于总读取量',
+                );
+            } finally {
+                server.close();
+            }
+        });
+
+        it('blockSize 应该决定分片请求的对齐粒度', async () => {
+            if (!fs.existsSync(TEST_PDF_1M)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF_1M}`);
+                return;
+            }
+            if (!pdf2img.isNativeAvailable()) {
+                console.log('跳过测试：原生渲染器不可用');
+                return;
+            }
+
+            const { server, url, size } = await startRangeServer(TEST_PDF_1M);
+
+            try {
+                for (const blockSize of [16 * 1024, 1024 * 1024]) {
+                    const traces = [];
+                    const result = await pdf2img.renderFromStream(url, size, [1], {
+                        blockSize,
+                        onRangeRequest: trace => traces.push(trace),
+                    });
+                    assert.ok(result.success, `blockSize=${blockSize} 应该渲染成功`);
+                    assert.ok(traces.every(t => t.offset % blockSize === 0), '分片请求起点应该按块对齐');
+                }
+
+                await assert.rejects(
+                    pdf2img.renderFromStream(url, size, [1], { blockSize: 100 * 1024 }),
+                    /Invalid block size/,
+                );
+            } finally {
+                server.close();
+            }
+        });
+
+        it('convert 的 blockSize 应该传递到按需加载', async () => {
+            if (!fs.existsSync(TEST_PDF_1M)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF_1M}`);
+                return;
+            }
+
+            const { server, url } = await startRangeServer(TEST_PDF_1M);
+            const ranges = [];
+            server.prependListener('request', req => req.headers.range && ranges.push(req.headers.range));
+
+            try {
+                const requestCounts = [];
+                for (const blockSize of [16 * 1024, 1024 * 1024]) {
+                    ranges.length = 0;
+                    const result = await pdf2img.convert(url, { pages: [1], blockSize });
+                    if (!result.linearized) {
+                        console.log('跳过测试：测试文件不是线性化文件');
+                        return;
+                    }
+                    assert.strictEqual(result.pages[0].success, true);
+
+                    // 第一个 Range 请求是线性化探测，其余为按块对齐的分片请求
+                    const offsets = ranges.slice(1).map(range => Number(/^bytes=(\d+)-/.exec(range)[1]));
+                    assert.ok(offsets.every(offset => offset % blockSize === 0), `blockSize=${blockSize} 分片请求起点应该按块对齐`);
+                    requestCounts.push(result.streamStats.totalRequests);
+                }
+                assert.ok(requestCounts[0] > requestCounts[1], `16KB 块应该比 1MB 块发出更多请求：${requestCounts}`);
+            } finally {
+                server.close();
+            }
+        });
+    });
+
+    describe('错误处理', () => {
+        it('文件不存在时应该抛出错误', async () => {
+            await assert.rejects(
+                async () => {
+                    await pdf2img.convert('/nonexistent/file.pdf');
+                },
+                /不存在|not found|ENOENT/i,
+                '应该抛出文件不存在错误'
+            );
+        });
+
+        it('无效输入应该抛出错误', async () => {
+            await assert.rejects(
+                async () => {
+                    await pdf2img.convert(null);
+                },
+                Error,
+                '应该抛出错误'
+            );
+        });
+
+        it('下载被截断时应该重新下载', async () => {
+            if (!fs.existsSync(TEST_PDF)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF}`);
+                return;
+            }
+
+            // 第一次完整下载只发送一半数据后断开连接
+            const data = fs.readFileSync(TEST_PDF);
+            let downloads = 0;
+            const server = http.createServer((req, res) => {
+                const match = /^bytes=(\d+)-(\d+)$/.exec(req.headers.range || '');
+                if (match) {
+                    const start = Number(match[1]);
+                    const end = Math.min(Number(match[2]), data.length - 1);
+                    res.writeHead(206, {
+                        'Content-Length': end - start + 1,
+                        'Content-Range': `bytes ${start}-${end}/${data.length}`,
+                    });
+                    res.end(data.subarray(start, end + 1));
+                    return;
+                }
+                res.writeHead(200, { 'Content-Length': data.length });
+                if (req.method === 'HEAD') {
+                    res.end();
+                    return;
+                }
+                if (++downloads === 1) {
+                    res.write(data.subarray(0, data.length >> 1));
+                    res.socket.destroy();
+                    return;
+                }
+                res.end(data);
+            });
+            await new Promise(resolve => server.listen(0, '127.0.0.1', resolve));
+            try {
+                const result = await pdf2img.convert(`http://127.0.0.1:${server.address().port}/flaky.pdf`, { pages: [1] });
+                assert.strictEqual(result.pages[0].success, true);
+                assert.strictEqual(downloads, 2, '应该重新下载一次');
+            } finally {
+                server.close();
+            }
+        });
+
+        it('下载数据超过预期大小时应该立即中止', async () => {
+            // HEAD 返回 4KB，完整下载却持续发送数据（分块编码，没有 Content-Length）
+            let written = 0;
+            let closed = 0;
+            const server = http.createServer((req, res) => {
+                if (req.method === 'HEAD' || req.headers.range) {
+                    res.writeHead(req.headers.range ? 416 : 200, { 'Content-Length': req.headers.range ? 0 : 4096 });
+                    res.end();
+                    return;
+                }
+                res.writeHead(200);
+                const timer = setInterval(() => {
+                    res.write(Buffer.alloc(64 * 1024, 0x20));
+                    written += 64 * 1024;
+                }, 5);
+                res.on('close', () => {
+                    clearInterval(timer);
+                    closed++;
+                });
+            });
+            await new Promise(resolve => server.listen(0, '127.0.0.1', resolve));
+            try {
+                await assert.rejects(
+                    pdf2img.convert(`http://127.0.0.1:${server.address().port}/growing.pdf`),
+                    { code: 'ERR_DOWNLOAD_TRUNCATED', message: /exceeds expected size/ }
+                );
+                await new Promise(resolve => setTimeout(resolve, 50));
+                assert.ok(closed > 0, '下载连接应该被中止');
+                assert.ok(written < 16 * 1024 * 1024, `超出预期大小后仍发送了 ${written} 字节`);
+            } finally {
+                server.closeAllConnections();
+                server.close();
+            }
+        });
+
+        it('下载超过总超时时应该失败且不重试', async () => {
+            // 持续有数据（不会触发停滞超时）但总时长超过 DOWNLOAD_TIMEOUT 的下载
+            const size = 64 * 1024 * 1024;
+            let downloads = 0;
+            const server = http.createServer((req, res) => {
+                if (req.method === 'HEAD' || req.headers.range) {
+                    // 不支持 Range：线性化探测失败后直接完整下载
+                    res.writeHead(req.headers.range ? 416 : 200, { 'Content-Length': req.headers.range ? 0 : size });
+                    res.end();
+                    return;
+                }
+                downloads++;
+                res.writeHead(200, { 'Content-Length': size });
+                res.write('%PDF-1.4\n');
+                const timer = setInterval(() => res.write(Buffer.alloc(1024, 0x20)), 20);
+                res.on('close', () => clearInterval(timer));
+            });
+            await new Promise(resolve => server.listen(0, '127.0.0.1', resolve));
+
+            // 总超时在模块加载时确定，在子进程中以环境变量设置
+            const url = `http://127.0.0.1:${server.address().port}/slow.pdf`;
+            const source = `import('${path.join(__dirname, '../src/index.js')}').then(async (pdf2img) => {
+                const err = await pdf2img.convert('${url}').catch(err => err);
+                console.log(JSON.stringify({ name: err.name, code: err.code ?? null }));
+            })`;
+            try {
+                const { stdout } = await promisify(execFile)(process.execPath, ['-e', source], {
+                    env: { ...process.env, DOWNLOAD_TIMEOUT: '300', PDF2IMG_DOWNLOAD_RETRIES: '2' },
+                    encoding: 'utf8',
+                });
+                const result = JSON.parse(stdout.trim().split('\n').pop());
+                assert.strictEqual(result.name, 'TimeoutError');
+                assert.notStrictEqual(result.code, 'ERR_DOWNLOAD_TRUNCATED');
+                assert.strictEqual(downloads, 1, '总超时不应该重试');
+            } finally {
+                server.closeAllConnections();
+                server.close();
+            }
+        });
+
+        it('远程文件超过 maxFileSize 时应该在下载前失败', async () => {
+            let downloads = 0;
+            const server = http.createServer((req, res) => {
+                if (req.method === 'HEAD') {
+                    res.writeHead(200, { 'Content-Length': 10 * 1024 * 1024 * 1024 });
+                    res.end();
+                    return;
+                }
+                downloads++;
+                res.writeHead(500);
+                res.end();
+            });
+            await new Promise(resolve => server.listen(0, '127.0.0.1', resolve));
+            const url = `http://127.0.0.1:${server.address().port}/huge.pdf`;
+            try {
+                await assert.rejects(pdf2img.convert(url, { maxFileSize: 100 * 1024 * 1024 }), err => {
+                    assert.strictEqual(err.code, 'ERR_FILE_TOO_LARGE');
+                    assert.strictEqual(err.size, 10 * 1024 * 1024 * 1024);
+                    return true;
+                });
+                await assert.rejects(pdf2img.getPageCount(url, { maxFileSize: 1024 }), { code: 'ERR_FILE_TOO_LARGE' });
+                assert.strictEqual(downloads, 0, '不应该下载任何数据');
+            } finally {
+                server.close();
+            }
+        });
+
+        it('获取远程文件大小时取消应该立即结束', async () => {
+            // 不响应的服务器，模拟缓慢的 HEAD 请求
+            const server = http.createServer(() => {});
+            await new Promise(resolve => server.listen(0, '127.0.0.1', resolve));
+            try {
+                const controller = new AbortController();
+                const start = Date.now();
+                setTimeout(() => controller.abort(), 50);
+
+                await assert.rejects(
+                    pdf2img.convert(`http://127.0.0.1:${server.address().port}/slow.pdf`, { signal: controller.signal }),
+                    err => err.name === 'AbortError'
+                );
+                assert.ok(Date.now() - start < 1000, '应该在取消后立即返回');
+            } finally {
+                server.closeAllConnections();
+                server.close();
+            }
+        });
+
+        it('下载中途取消应该立即中止连接并删除部分文件', async () => {
+            // 非线性化文件：完整下载时每 20ms 发送 64KB，直到连接关闭
+            const size = 64 * 1024 * 1024;
+            const header = Buffer.from('%PDF-1.4\n' + ' '.repeat(1024));
+            let downloadClosed = false;
+            const server = http.createServer((req, res) => {
+                if (req.method === 'HEAD') {
+                    res.writeHead(200, { 'Content-Length': size, 'Accept-Ranges': 'bytes' });
+                    res.end();
+                } else if (req.headers.range) {
+                    const body = header.subarray(0, 1024);
+                    res.writeHead(206, { 'Content-Length': body.length, 'Content-Range': `bytes 0-1023/${size}` });
+                    res.end(body);
+                } else {
+                    res.writeHead(200, { 'Content-Length': size });
+                    res.write(header);
+                    const timer = setInterval(() => res.write(Buffer.alloc(64 * 1024, 0x20)), 20);
+                    res.on('close', () => {
+                        clearInterval(timer);
+                        downloadClosed = true;
+                    });
+                }
+            });
+            await new Promise(resolve => server.listen(0, '127.0.0.1', resolve));
+            const tempDir = process.env.PDF2IMG_TEMP_DIR || os.tmpdir();
+            const listTempFiles = () => fs.readdirSync(tempDir).filter(name => name.startsWith('pdf2img_'));
+            const before = listTempFiles();
+
+            try {
+                const controller = new AbortController();
+                const progress = [];
+                let abortedAt = 0;
+                const conversion = pdf2img.convert(`http://127.0.0.1:${server.address().port}/large.pdf`, {
+                    signal: controller.signal,
+                    onDownloadProgress: (event) => {
+                        progress.push(event);
+                        if (event.bytes > 256 * 1024 && !abortedAt) {
+                            abortedAt = Date.now();
+                            controller.abort();
+                        }
+                    },
+                });
+
+                await assert.rejects(conversion, err => err.name === 'AbortError');
+                assert.ok(Date.now() - abortedAt < 500, `取消后 ${Date.now() - abortedAt}ms 才返回`);
+                assert.ok(progress.length > 1 && progress.every(event => event.total === size));
+                assert.ok(progress.every((event, i) => i === 0 || event.bytes > progress[i - 1].bytes), '进度应该递增');
+
+                await new Promise(resolve => setTimeout(resolve, 50));
+                assert.ok(downloadClosed, '下载连接应该被中止');
+                assert.deepStrictEqual(listTempFiles(), before, '部分下载的临时文件应该被删除');
+                const { downloads } = pdf2img.getFetchStats();
+                assert.deepStrictEqual([downloads.active, downloads.pending], [0, 0]);
+            } finally {
+                server.closeAllConnections();
+                server.close();
+            }
+        });
     });
 });
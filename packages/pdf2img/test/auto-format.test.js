@@ -0,0 +1,87 @@
+/**
+ * PDF2IMG 自动输出格式测试
+ *
+ * 运行方式：
+ *   node --test test/auto-format.test.js
+ */
+
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+
+import { chooseAutoFormat, dominantColorCoverage } from '../src/utils/auto-format.js';
+
+const WIDTH = 400;
+const HEIGHT = 300;
+
+function createBitmap(pixel) {
+    const bitmap = Buffer.alloc(WIDTH * HEIGHT * 4);
+    for (let y = 0; y < HEIGHT; y++) {
+        for (let x = 0; x < WIDTH; x++) {
+            const [r, g, b, a = 255] = pixel(x, y);
+            bitmap.set([r, g, b, a], (y * WIDTH + x) * 4);
+        }
+    }
+    return bitmap;
+}
+
+/**
+ * 白底黑字的文字页：逐行排列的字形块，边缘带灰色抗锯齿
+ */
+function textPage() {
+    return createBitmap((x, y) => {
+        const line = y % 20;
+        const glyph = x % 12;
+        if (line >= 4 && line < 14 && glyph >= 2 && glyph < 9) {
+            const edge = line === 4 || line === 13 || glyph === 2 || glyph === 8;
+            return edge ? [128, 128, 128] : [20, 20, 20];
+        }
+        return [255, 255, 255];
+    });
+}
+
+/**
+ * 照片页：平滑渐变叠加伪随机噪声
+ */
+function photoPage() {
+    let seed = 1;
+    const noise = () => {
+        seed = (seed * 1103515245 + 12345) % 2147483648;
+        return (seed % 64) - 32;
+    };
+    const clamp = value => Math.max(0, Math.min(255, value));
+    return createBitmap((x, y) => [
+        clamp(Math.round((x / WIDTH) * 255) + noise()),
+        clamp(Math.round((y / HEIGHT) * 255) + noise()),
+        clamp(128 + noise()),
+    ]);
+}
+
+describe('PDF2IMG 自动输出格式测试', () => {
+    it('文字页面和照片页面应该选择不同的格式', () => {
+        assert.strictEqual(chooseAutoFormat(textPage(), WIDTH, HEIGHT), 'png');
+        assert.strictEqual(chooseAutoFormat(photoPage(), WIDTH, HEIGHT), 'webp');
+    });
+
+    it('透明像素应该按白色背景统计', () => {
+        const transparent = createBitmap(() => [0, 0, 0, 0]);
+        assert.strictEqual(dominantColorCoverage(transparent, WIDTH, HEIGHT), 1);
+        assert.strictEqual(chooseAutoFormat(transparent, WIDTH, HEIGHT), 'png');
+    });
+
+    it('应该只统计裁剪区域内的像素', () => {
+        // 左半页为照片、右半页为空白
+        const photo = photoPage();
+        const bitmap = createBitmap((x, y) => {
+            if (x >= WIDTH / 2) {
+                return [255, 255, 255];
+            }
+            const i = (y * WIDTH + x) * 4;
+            return [photo[i], photo[i + 1], photo[i + 2]];
+        });
+
+        const left = { left: 0, top: 0, width: WIDTH / 2, height: HEIGHT };
+        const right = { left: WIDTH / 2, top: 0, width: WIDTH / 2, height: HEIGHT };
+        assert.strictEqual(chooseAutoFormat(bitmap, WIDTH, HEIGHT, left), 'webp');
+        assert.strictEqual(chooseAutoFormat(bitmap, WIDTH, HEIGHT, right), 'png');
+    });
+});
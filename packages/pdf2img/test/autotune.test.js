@@ -0,0 +1,97 @@
+/**
+ * PDF2IMG 块大小自动调优测试
+ *
+ * 运行方式：
+ *   node --test test/autotune.test.js
+ */
+
+import { describe, it, after } from 'node:test';
+import assert from 'node:assert';
+import http from 'http';
+import { setTimeout as sleep } from 'timers/promises';
+
+import { calibrate, chooseBlockSize, clearCalibrationCache } from '../src/utils/autotune.js';
+
+const FILE_SIZE = 4 * 1024 * 1024;
+
+/**
+ * 启动模拟源站
+ *
+ * @param {number} latency - 每个请求的首字节延迟（毫秒）
+ * @param {number} [chunkDelay] - 每发送 64KB 后的等待（毫秒），用于限制吞吐
+ */
+async function startOrigin(latency, chunkDelay = 0) {
+    let requests = 0;
+    const server = http.createServer(async (req, res) => {
+        requests++;
+        const [, start, end] = req.headers.range.match(/bytes=(\d+)-(\d+)/).map(Number);
+        const length = end - start + 1;
+        await sleep(latency);
+        res.writeHead(206, {
+            'Content-Length': length,
+            'Content-Range': `bytes ${start}-${end}/${FILE_SIZE}`,
+        });
+        const chunk = Buffer.alloc(64 * 1024);
+        for (let sent = 0; sent < length; sent += chunk.length) {
+            res.write(chunk.subarray(0, Math.min(chunk.length, length - sent)));
+            if (chunkDelay) {
+                await sleep(chunkDelay);
+            }
+        }
+        res.end();
+    });
+    await new Promise(resolve => server.listen(0, '127.0.0.1', resolve));
+    return { server, url: `http://127.0.0.1:${server.address().port}/a.pdf`, requests: () => requests };
+}
+
+describe('PDF2IMG 块大小自动调优测试', () => {
+    const servers = [];
+
+    after(() => {
+        servers.forEach(server => server.close());
+    });
+
+    it('应该按带宽时延积选择 2 的幂并限制在 16KB-4MB', () => {
+        assert.strictEqual(chooseBlockSize({ latency: 1, throughput: 1024 * 1024 }), 16 * 1024);
+        assert.strictEqual(chooseBlockSize({ latency: 100, throughput: 5 * 1024 * 1024 }), 512 * 1024);
+        assert.strictEqual(chooseBlockSize({ latency: 500, throughput: 100 * 1024 * 1024 }), 4 * 1024 * 1024);
+        assert.strictEqual(chooseBlockSize({ latency: 0, throughput: Infinity }), 16 * 1024);
+    });
+
+    it('高延迟、高吞吐的源站应该选择更大的块', async () => {
+        const slowStart = await startOrigin(150);
+        const throttled = await startOrigin(0, 20);
+        servers.push(slowStart.server, throttled.server);
+
+        const highLatency = await calibrate(slowStart.url, FILE_SIZE);
+        const lowThroughput = await calibrate(throttled.url, FILE_SIZE);
+
+        assert.strictEqual(highLatency.requests, 3);
+        assert.strictEqual(highLatency.bytes, 2 * 16 * 1024 + 1024 * 1024);
+        assert.ok(highLatency.latency >= 150, `延迟估算应该包含首字节延迟: ${highLatency.latency}`);
+        assert.ok(highLatency.blockSize >= 1024 * 1024, `高延迟源站应该选择大块: ${highLatency.blockSize}`);
+        assert.ok(lowThroughput.blockSize <= 64 * 1024, `低吞吐源站应该选择小块: ${lowThroughput.blockSize}`);
+    });
+
+    it('同一源站的第二次校准应该使用缓存，不发送请求', async () => {
+        const origin = await startOrigin(20);
+        servers.push(origin.server);
+
+        const first = await calibrate(origin.url, FILE_SIZE);
+        assert.strictEqual(origin.requests(), 3);
+        assert.strictEqual(first.cached, false);
+
+        // 同一 origin 的其他文件同样命中缓存
+        const second = await calibrate(origin.url.replace('a.pdf', 'b.pdf'), FILE_SIZE);
+        assert.strictEqual(origin.requests(), 3, '第二次校准不应该发送请求');
+        assert.deepStrictEqual(second, { ...first, requests: 0, bytes: 0, cached: true });
+
+        clearCalibrationCache();
+        await calibrate(origin.url, FILE_SIZE);
+        assert.strictEqual(origin.requests(), 6, '清空缓存后应该重新校准');
+    });
+
+    it('小文件不应该校准', async () => {
+        assert.strictEqual(await calibrate('http://127.0.0.1:1/a.pdf', 1024 * 1024), null);
+    });
+});
@@ -0,0 +1,121 @@
+/**
+ * PDF2IMG 分片下载缓冲区复用测试
+ *
+ * 运行方式：
+ *   node --test test/buffer-pool.test.js
+ */
+
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import http from 'http';
+import pLimit from 'p-limit';
+
+import { BufferPool, readIntoPool } from '../src/utils/buffer-pool.js';
+import { serveRange } from './helpers/range.js';
+
+/**
+ * 把数据块列表包装成可异步迭代的响应体，可选在读到一半时出错
+ */
+async function* chunks(list, failAfter) {
+    for (const [i, chunk] of list.entries()) {
+        if (i === failAfter) {
+            throw new Error('connection reset');
+        }
+        yield Buffer.from(chunk);
+    }
+}
+
+describe('PDF2IMG 分片下载缓冲区复用测试', () => {
+    describe('BufferPool', () => {
+        it('归还的缓冲区应该被同样大小的请求复用', () => {
+            const pool = new BufferPool();
+            const first = pool.acquire(1024);
+            pool.release(first);
+            assert.strictEqual(pool.acquire(1024), first);
+            assert.notStrictEqual(pool.acquire(2048), first);
+            assert.deepStrictEqual(pool.getStats(), { allocated: 2, reused: 1, pooled: 0 });
+        });
+
+        it('空闲缓冲区超过上限时应该丢弃', () => {
+            const pool = new BufferPool({ maxBuffers: 2 });
+            const buffers = [pool.acquire(16), pool.acquire(16), pool.acquire(16)];
+            buffers.forEach(buffer => pool.release(buffer));
+            assert.strictEqual(pool.getStats().pooled, 2);
+        });
+    });
+
+    describe('readIntoPool', () => {
+        it('应该按顺序读入缓冲区并在 release 后归还', async () => {
+            const pool = new BufferPool();
+            const { data, release } = await readIntoPool(chunks(['%PDF', '-1.', '7']), pool, 16);
+            assert.strictEqual(data.toString(), '%PDF-1.7');
+            assert.strictEqual(pool.getStats().pooled, 0);
+            release();
+            assert.strictEqual(pool.getStats().pooled, 1);
+        });
+
+        it('响应体超过预期大小时应该返回完整数据', async () => {
+            const pool = new BufferPool();
+            const { data, release } = await readIntoPool(chunks(['0123', '4567', '89']), pool, 6);
+            assert.strictEqual(data.toString(), '0123456789');
+            release();
+            assert.strictEqual(pool.getStats().pooled, 1, '缓冲区应该已归还');
+        });
+
+        it('读取出错时应该归还缓冲区', async () => {
+            const pool = new BufferPool();
+            await assert.rejects(readIntoPool(chunks(['ab', 'cd'], 1), pool, 16), /connection reset/);
+            assert.strictEqual(pool.getStats().pooled, 1);
+        });
+    });
+
+    it('并发分片下载时分配次数应该不超过并发数', async () => {
+        const BLOCK = 256 * 1024;
+        const BLOCKS = 64;
+        const CONCURRENCY = 4;
+        const file = Buffer.alloc(BLOCK * 8, 7);
+        const server = http.createServer((req, res) => serveRange(req, res, file));
+        await new Promise(resolve => server.listen(0, '127.0.0.1', resolve));
+        const url = `http://127.0.0.1:${server.address().port}/doc.pdf`;
+
+        /**
+         * 以固定并发下载 BLOCKS 个分片，read 负责读取响应体并在使用后释放
+         */
+        async function download(read) {
+            const limit = pLimit(CONCURRENCY);
+            const start = process.hrtime.bigint();
+            await Promise.all(Array.from({ length: BLOCKS }, (_, i) => limit(async () => {
+                const offset = (i % 8) * BLOCK;
+                const response = await fetch(url, { headers: { Range: `bytes=${offset}-${offset + BLOCK - 1}` } });
+                await read(response);
+            })));
+            return Number(process.hrtime.bigint() - start) / 1e6;
+        }
+
+        try {
+            let naiveAllocations = 0;
+            const naiveMs = await download(async (response) => {
+                const data = Buffer.from(await response.arrayBuffer());
+                assert.strictEqual(data.length, BLOCK);
+                naiveAllocations++;
+            });
+
+            const pool = new BufferPool({ maxBuffers: CONCURRENCY });
+            const pooledMs = await download(async (response) => {
+                const { data, release } = await readIntoPool(response.body, pool, BLOCK);
+                assert.strictEqual(data.length, BLOCK);
+                assert.strictEqual(data[BLOCK - 1], 7);
+                release();
+            });
+
+            const { allocated, reused } = pool.getStats();
+            console.log(`${BLOCKS} 个 ${BLOCK / 1024}KB 分片：arrayBuffer 分配 ${naiveAllocations} 次（${naiveMs.toFixed(1)}ms），`
+                + `缓冲区池分配 ${allocated} 次、复用 ${reused} 次（${pooledMs.toFixed(1)}ms）`);
+            assert.ok(allocated <= CONCURRENCY, `分配了 ${allocated} 次`);
+            assert.strictEqual(allocated + reused, BLOCKS);
+            assert.ok(allocated < naiveAllocations);
+        } finally {
+            server.close();
+        }
+    });
+});
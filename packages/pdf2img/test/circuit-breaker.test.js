@@ -0,0 +1,118 @@
+/**
+ * PDF2IMG 按主机熔断测试
+ *
+ * 运行方式：
+ *   node --test test/circuit-breaker.test.js
+ */
+
+import { describe, it, before, after } from 'node:test';
+import assert from 'node:assert';
+import http from 'http';
+import { setTimeout as sleep } from 'timers/promises';
+
+import { CircuitBreaker, ERR_CIRCUIT_OPEN } from '../src/utils/circuit-breaker.js';
+import { fetchWithPolicy } from '../src/utils/http.js';
+
+describe('PDF2IMG 按主机熔断测试', () => {
+    let server;
+    let baseUrl;
+    let host;
+    let status = 503;
+    let requests = 0;
+
+    before(async () => {
+        server = http.createServer((req, res) => {
+            requests++;
+            res.writeHead(status);
+            res.end();
+        });
+        await new Promise(resolve => server.listen(0, '127.0.0.1', resolve));
+        host = `127.0.0.1:${server.address().port}`;
+        baseUrl = `http://${host}`;
+    });
+
+    after(() => {
+        server.close();
+    });
+
+    it('连续失败达到阈值后应该快速失败，冷却后探测成功即恢复', async () => {
+        const circuitBreaker = new CircuitBreaker({ threshold: 3, cooldown: 100 });
+        const request = () => fetchWithPolicy(`${baseUrl}/a.pdf`, {}, { circuitBreaker });
+        status = 503;
+        requests = 0;
+
+        for (let i = 0; i < 3; i++) {
+            const response = await request();
+            assert.strictEqual(response.status, 503);
+        }
+        assert.strictEqual(circuitBreaker.getStats()[host].state, 'open');
+
+        await assert.rejects(request(), err => {
+            assert.strictEqual(err.code, ERR_CIRCUIT_OPEN);
+            assert.ok(err.retryAfter > 0 && err.retryAfter <= 100);
+            return true;
+        });
+        assert.strictEqual(requests, 3, '熔断期间不应该发出请求');
+
+        await sleep(120);
+        assert.strictEqual(circuitBreaker.getStats()[host].state, 'half-open');
+
+        status = 200;
+        const response = await request();
+        assert.strictEqual(response.status, 200);
+        assert.deepStrictEqual(circuitBreaker.getStats(), {}, '探测成功后应该恢复');
+    });
+
+    it('探测失败应该重新熔断', async () => {
+        const circuitBreaker = new CircuitBreaker({ threshold: 2, cooldown: 50 });
+        const request = () => fetchWithPolicy(`${baseUrl}/a.pdf`, {}, { circuitBreaker });
+        status = 500;
+
+        await request();
+        await request();
+        await sleep(60);
+
+        await request();
+        assert.strictEqual(circuitBreaker.getStats()[host].state, 'open');
+        await assert.rejects(request(), { code: ERR_CIRCUIT_OPEN });
+    });
+
+    it('half-open 时只放行一个探测请求', () => {
+        const circuitBreaker = new CircuitBreaker({ threshold: 1, cooldown: 0 });
+        circuitBreaker.onFailure('example.com');
+
+        circuitBreaker.acquire('example.com');
+        assert.throws(() => circuitBreaker.acquire('example.com'), { code: ERR_CIRCUIT_OPEN });
+
+        // 探测请求被取消后允许下一个请求继续探测
+        circuitBreaker.onCancel('example.com');
+        circuitBreaker.acquire('example.com');
+    });
+
+    it('连接错误应该计为失败，不同主机互不影响', async () => {
+        const circuitBreaker = new CircuitBreaker({ threshold: 1, cooldown: 1000 });
+        const closed = http.createServer();
+        await new Promise(resolve => closed.listen(0, '127.0.0.1', resolve));
+        const { port } = closed.address();
+        await new Promise(resolve => closed.close(resolve));
+
+        await assert.rejects(fetchWithPolicy(`http://127.0.0.1:${port}/a.pdf`, {}, { circuitBreaker }));
+        await assert.rejects(
+            fetchWithPolicy(`http://127.0.0.1:${port}/a.pdf`, {}, { circuitBreaker }),
+            { code: ERR_CIRCUIT_OPEN },
+        );
+
+        status = 200;
+        const response = await fetchWithPolicy(`${baseUrl}/a.pdf`, {}, { circuitBreaker });
+        assert.strictEqual(response.status, 200);
+    });
+
+    it('阈值为 0 时应该关闭熔断', async () => {
+        const circuitBreaker = new CircuitBreaker({ threshold: 0 });
+        status = 503;
+        for (let i = 0; i < 3; i++) {
+            await fetchWithPolicy(`${baseUrl}/a.pdf`, {}, { circuitBreaker });
+        }
+        assert.deepStrictEqual(circuitBreaker.getStats(), {});
+    });
+});
@@ -11,6 +11,7 @@ import { spawn } from 'child_process';
 import path from 'path';
 import fs from 'fs';
 import { fileURLToPath } from 'url';
+import sharp from 'sharp';
 
 const __dirname = path.dirname(fileURLToPath(import.meta.url));
 const PROJECT_ROOT = path.join(__dirname, '../../..');
@@ -23,8 +24,11 @@ const TEST_PDF = path.join(STATIC_DIR, '1M.pdf');
 
 /**
  * 执行 CLI 命令
+ *
+ * @param {string[]} args - 命令行参数
+ * @param {Buffer} [stdin] - 写入标准输入的数据
  */
-function runCli(args) {
+function runCli(args, stdin) {
     return new Promise((resolve, reject) => {
         const proc = spawn('node', [CLI_PATH, ...args], {
             cwd: PROJECT_ROOT,
@@ -47,6 +51,8 @@ function runCli(args) {
         });
 
         proc.on('error', reject);
+
+        proc.stdin.end(stdin);
     });
 }
 
@@ -101,6 +107,7 @@ describe('PDF2IMG CLI 测试', () => {
             const { code, stdout } = await runCli([TEST_PDF, '--info']);
             assert.strictEqual(code, 0, '退出码应该是 0');
             assert.ok(stdout.includes('页数') || stdout.includes('Pages'), '应该包含页数信息');
+            assert.match(stdout, /页数: \d+/, '页数应该是数字');
         });
     });
 
@@ -122,6 +129,38 @@ describe('PDF2IMG CLI 测试', () => {
             assert.ok(files.some(f => f.endsWith('.webp')), '应该生成 WebP 文件');
         });
 
+        it('输入为 - 时应该从标准输入读取 PDF', async () => {
+            if (!fs.existsSync(TEST_PDF)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF}`);
+                return;
+            }
+
+            const { code } = await runCli(['-', '-o', OUTPUT_DIR, '-p', '1', '--prefix', 'stdin'], fs.readFileSync(TEST_PDF));
+            assert.strictEqual(code, 0, '退出码应该是 0');
+
+            const files = fs.readdirSync(OUTPUT_DIR);
+            assert.ok(files.includes('stdin_1.webp'), '应该生成第 1 页的图片');
+        });
+
+        it('--max-scale 应该与库调用按同样的 DPI 上限截断', async () => {
+            if (!fs.existsSync(TEST_PDF)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF}`);
+                return;
+            }
+
+            const args = [TEST_PDF, '-o', OUTPUT_DIR, '-p', '1', '-w', '20000', '--max-scale', '100', '--prefix', 'dpi'];
+            const { code } = await runCli(args);
+            assert.strictEqual(code, 0, '退出码应该是 0');
+
+            const { convert, destroyThreadPool, RENDER_CONFIG } = await import('../src/index.js');
+            const result = await convert(TEST_PDF, { pages: [1], targetWidth: 20000, maxScale: 100 });
+            await destroyThreadPool();
+            assert.strictEqual(result.pages[0].effectiveOptions.dpi, RENDER_CONFIG.MAX_DPI);
+
+            const { width } = await sharp(path.join(OUTPUT_DIR, 'dpi_1.webp')).metadata();
+            assert.strictEqual(width, result.pages[0].width, 'CLI 与库调用的截断结果应该一致');
+        });
+
         it('应该支持自定义前缀', async () => {
             if (!fs.existsSync(TEST_PDF)) {
                 console.log(`跳过测试：测试文件不存在 ${TEST_PDF}`);
@@ -137,12 +176,49 @@ describe('PDF2IMG CLI 测试', () => {
     });
 
     describe('错误处理', () => {
+        it('部分页面失败时退出码应该是 2', async () => {
+            if (!fs.existsSync(TEST_PDF)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF}`);
+                return;
+            }
+
+            const { code, stdout } = await runCli([TEST_PDF, '-o', OUTPUT_DIR, '-p', '1,99999', '--prefix', 'partial']);
+            assert.strictEqual(code, 2, '部分失败时退出码应该是 2');
+            assert.ok(stdout.includes('Page 99999 out of range'), '应该显示越界页码');
+            assert.ok(stdout.includes('平均每页耗时'));
+        });
+
+        it('全部页面失败时退出码应该是 1 且不输出平均耗时', async () => {
+            if (!fs.existsSync(TEST_PDF)) {
+                console.log(`跳过测试：测试文件不存在 ${TEST_PDF}`);
+                return;
+            }
+
+            // 与输出文件同名的目录使写入失败
+            const blocked = path.join(OUTPUT_DIR, 'blocked_1.webp');
+            fs.mkdirSync(blocked, { recursive: true });
+            try {
+                const { code, stdout } = await runCli([TEST_PDF, '-o', OUTPUT_DIR, '-p', '1', '--prefix', 'blocked']);
+                assert.strictEqual(code, 1, '全部失败时退出码应该是 1');
+                assert.ok(!stdout.includes('Infinity'), '不应该输出 Infinity');
+                assert.ok(!stdout.includes('平均每页耗时'));
+            } finally {
+                fs.rmdirSync(blocked);
+            }
+        });
+
         it('文件不存在时应该报错', async () => {
             const { code, stderr } = await runCli(['/nonexistent/file.pdf', '-o', OUTPUT_DIR]);
             assert.notStrictEqual(code, 0, '退出码不应该是 0');
             assert.ok(stderr.includes('错误') || stderr.includes('Error'), '应该显示错误信息');
         });
 
+        it('标准输入为空时应该报错', async () => {
+            const { code, stderr } = await runCli(['-', '-o', OUTPUT_DIR], Buffer.alloc(0));
+            assert.notStrictEqual(code, 0, '退出码不应该是 0');
+            assert.ok(stderr.includes('错误'), '应该显示错误信息');
+        });
+
         it('缺少输入参数时应该报错', async () => {
             const { code, stderr } = await runCli([]);
             assert.notStrictEqual(code, 0, '退出码不应该是 0');
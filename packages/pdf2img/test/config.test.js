@@ -0,0 +1,88 @@
+/**
+ * PDF2IMG 配置解析测试
+ *
+ * 运行方式：
+ *   node --test test/config.test.js
+ */
+
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+
+import { normalizeRenderOptions, parseBlockSize, parseThreadCount, RENDER_CONFIG } from '../src/core/config.js';
+
+describe('PDF2IMG 配置解析测试', () => {
+    describe('parseThreadCount', () => {
+        it('未设置时应该使用 CPU 核心数', () => {
+            assert.deepStrictEqual(parseThreadCount(undefined, 8), { threadCount: 8, valid: true });
+            assert.deepStrictEqual(parseThreadCount('', 8), { threadCount: 8, valid: true });
+        });
+
+        it('应该接受合法的线程数', () => {
+            assert.deepStrictEqual(parseThreadCount('2', 8), { threadCount: 2, valid: true });
+            assert.deepStrictEqual(parseThreadCount('32', 8), { threadCount: 32, valid: true });
+        });
+
+        it('无效值应该回退为 CPU 核心数', () => {
+            for (const value of ['0', '-1', 'abc', '1.5', '33']) {
+                assert.deepStrictEqual(parseThreadCount(value, 8), { threadCount: 8, valid: false }, value);
+            }
+        });
+    });
+
+    describe('parseBlockSize', () => {
+        it('未设置时应该使用 256KB', () => {
+            assert.deepStrictEqual(parseBlockSize(undefined), { blockSize: 256 * 1024, valid: true });
+            assert.deepStrictEqual(parseBlockSize(''), { blockSize: 256 * 1024, valid: true });
+        });
+
+        it('应该接受 16KB-4MB 之间的 2 的幂', () => {
+            assert.deepStrictEqual(parseBlockSize('16384'), { blockSize: 16384, valid: true });
+            assert.deepStrictEqual(parseBlockSize('4194304'), { blockSize: 4194304, valid: true });
+        });
+
+        it('无效值应该回退为 256KB', () => {
+            for (const value of ['1000', '8192', '8388608', '65537', 'abc', '-65536', '65536.5']) {
+                assert.deepStrictEqual(parseBlockSize(value), { blockSize: 256 * 1024, valid: false }, value);
+            }
+        });
+    });
+
+    describe('normalizeRenderOptions', () => {
+        const LIMITS = { maxDpi: 144, maxWidth: 4000 };
+
+        it('未超出上限时应该保留原值并补齐默认 maxScale', () => {
+            assert.deepStrictEqual(
+                normalizeRenderOptions({ targetWidth: 1280, format: 'png' }),
+                { targetWidth: 1280, format: 'png', maxScale: RENDER_CONFIG.MAX_RENDER_SCALE }
+            );
+        });
+
+        it('maxScale 超出 DPI 上限时应该截断', () => {
+            assert.strictEqual(normalizeRenderOptions({ maxScale: 100 }, LIMITS).maxScale, 2);
+            assert.strictEqual(normalizeRenderOptions({}, LIMITS).maxScale, 2, '默认缩放比例同样受上限约束');
+            assert.strictEqual(normalizeRenderOptions({ maxScale: 1.5 }, LIMITS).maxScale, 1.5);
+        });
+
+        it('exactWidth 不受 maxScale 限制但应该受 DPI 上限约束', () => {
+            const normalized = normalizeRenderOptions({ exactWidth: 300, maxScale: 1 }, LIMITS);
+            assert.strictEqual(normalized.exactWidth, 300);
+            assert.strictEqual(normalized.maxScale, 2);
+        });
+
+        it('宽度超出上限时应该截断', () => {
+            assert.deepStrictEqual(
+                normalizeRenderOptions({ targetWidth: 50000, exactWidth: 9000, imageHeavyWidth: 5000 }, LIMITS),
+                { targetWidth: 4000, exactWidth: 4000, imageHeavyWidth: 4000, maxScale: 2 }
+            );
+        });
+
+        it('非法值应该抛出异常', () => {
+            for (const options of [{ targetWidth: 0 }, { targetWidth: NaN }, { exactWidth: 1.5 }, { imageHeavyWidth: -1 }]) {
+                assert.throws(() => normalizeRenderOptions(options), /Invalid \w+Width/, JSON.stringify(options));
+            }
+            for (const maxScale of [0, -1, '4', NaN]) {
+                assert.throws(() => normalizeRenderOptions({ maxScale }), /Invalid maxScale/, String(maxScale));
+            }
+        });
+    });
+});
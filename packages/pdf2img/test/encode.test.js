@@ -0,0 +1,138 @@
+/**
+ * PDF2IMG 图像编码测试
+ *
+ * 运行方式：
+ *   node --test test/encode.test.js
+ */
+
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import sharp from 'sharp';
+
+import { encodeImage, encodeLossy } from '../src/utils/encode.js';
+
+const WIDTH = 64;
+const HEIGHT = 48;
+
+/**
+ * 半透明彩色渐变，覆盖透明通道和颜色信息
+ */
+function createImage() {
+    const bitmap = Buffer.alloc(WIDTH * HEIGHT * 4);
+    for (let y = 0; y < HEIGHT; y++) {
+        for (let x = 0; x < WIDTH; x++) {
+            bitmap.set([x * 4, y * 5, 200, x < WIDTH / 2 ? 255 : 0], (y * WIDTH + x) * 4);
+        }
+    }
+    return sharp(bitmap, { raw: { width: WIDTH, height: HEIGHT, channels: 4 } });
+}
+
+describe('PDF2IMG 图像编码测试', () => {
+    describe('encodeLossy', () => {
+        it('未超出上限时应该直接使用初始质量', async () => {
+            const calls = [];
+            const result = await encodeLossy(async quality => {
+                calls.push(quality);
+                return Buffer.alloc(quality);
+            }, 80, 100);
+            assert.strictEqual(result.quality, 80);
+            assert.deepStrictEqual(calls, [80]);
+        });
+
+        it('超出上限时应该选择满足上限的最高质量', async () => {
+            const result = await encodeLossy(async quality => Buffer.alloc(quality * 10), 80, 455);
+            assert.strictEqual(result.quality, 45);
+            assert.strictEqual(result.buffer.length, 450);
+        });
+
+        it('最低质量仍超出上限时应该返回最低质量的结果', async () => {
+            const result = await encodeLossy(async quality => Buffer.alloc(1000 + quality), 80, 10);
+            assert.strictEqual(result.quality, 1);
+        });
+    });
+
+    describe('WebP', () => {
+        it('默认应该使用质量 80 的有损编码', async () => {
+            const { buffer, quality } = await encodeImage(createImage(), 'webp');
+            const metadata = await sharp(buffer).metadata();
+            assert.strictEqual(metadata.format, 'webp');
+            assert.strictEqual(quality, 80);
+            assert.strictEqual(metadata.hasAlpha, true);
+        });
+
+        it('webpQuality 应该优先于 quality', async () => {
+            const { quality } = await encodeImage(createImage(), 'webp', { quality: 50, webpQuality: 60 });
+            assert.strictEqual(quality, 60);
+        });
+
+        it('确定性模式应该使用无损编码且输出一致', async () => {
+            const first = await encodeImage(createImage(), 'webp', { deterministic: true });
+            const second = await encodeImage(createImage(), 'webp', { deterministic: true });
+            assert.strictEqual(first.quality, null);
+            assert.ok(first.buffer.equals(second.buffer));
+        });
+    });
+
+    describe('PNG', () => {
+        it('默认不应该隔行扫描', async () => {
+            const { buffer, quality } = await encodeImage(createImage(), 'png');
+            const metadata = await sharp(buffer).metadata();
+            assert.strictEqual(metadata.format, 'png');
+            assert.strictEqual(metadata.isProgressive, false);
+            assert.strictEqual(quality, null);
+        });
+
+        it('progressive 应该输出 Adam7 隔行 PNG', async () => {
+            const { buffer } = await encodeImage(createImage(), 'png', { progressive: true });
+            assert.strictEqual((await sharp(buffer).metadata()).isProgressive, true);
+        });
+    });
+
+    describe('JPEG', () => {
+        it('应该与白色背景混合并去掉透明通道', async () => {
+            for (const format of ['jpg', 'jpeg']) {
+                const { buffer, quality } = await encodeImage(createImage(), format);
+                const metadata = await sharp(buffer).metadata();
+                assert.strictEqual(metadata.format, 'jpeg');
+                assert.strictEqual(metadata.hasAlpha, false);
+                assert.strictEqual(quality, 85);
+
+                // 右半部分全透明，应该接近白色
+                const { data } = await sharp(buffer).extract({ left: WIDTH - 4, top: 0, width: 4, height: 4 }).raw().toBuffer({ resolveWithObject: true });
+                assert.ok(data.every(value => value > 240));
+            }
+        });
+
+        it('默认输出基线 JPEG，progressive 时输出渐进式 JPEG', async () => {
+            const { buffer: baseline } = await encodeImage(createImage(), 'jpg');
+            assert.strictEqual((await sharp(baseline).metadata()).isProgressive, false);
+
+            const { buffer: progressive } = await encodeImage(createImage(), 'jpg', { progressive: true });
+            assert.strictEqual((await sharp(progressive).metadata()).isProgressive, true);
+        });
+
+        it('maxBytes 应该降低质量以满足上限', async () => {
+            const { buffer: full } = await encodeImage(createImage(), 'jpg', { jpegQuality: 95 });
+            const { buffer, quality } = await encodeImage(createImage(), 'jpg', { jpegQuality: 95, maxBytes: Math.floor(full.length * 0.8) });
+            assert.ok(quality < 95);
+            assert.ok(buffer.length <= full.length * 0.8);
+        });
+    });
+
+    describe('灰度', () => {
+        it('各格式都应该输出单色图像', async () => {
+            for (const format of ['webp', 'png', 'jpg']) {
+                const { buffer } = await encodeImage(createImage(), format, { grayscale: true });
+                const { data, info } = await sharp(buffer).removeAlpha().raw().toBuffer({ resolveWithObject: true });
+                for (let i = 0; i < data.length; i += info.channels) {
+                    // 有损格式解码后允许少量色度误差
+                    assert.ok(info.channels === 1 || (Math.abs(data[i] - data[i + 1]) <= 2 && Math.abs(data[i] - data[i + 2]) <= 2), `${format} 应该为灰度`);
+                }
+            }
+        });
+    });
+
+    it('不支持的格式应该报错', async () => {
+        await assert.rejects(encodeImage(createImage(), 'gif'), /Unsupported format: gif/);
+    });
+});
@@ -0,0 +1,124 @@
+/**
+ * PDF2IMG 解析函数模糊测试
+ *
+ * 对手写字符串解析的函数输入随机拼接的片段，断言不抛出异常且返回值满足约束。
+ * 随机数使用固定种子，失败可复现；可通过 PDF2IMG_FUZZ_ITERATIONS 增加迭代次数。
+ *
+ * 运行方式：
+ *   node --test test/fuzz.test.js
+ */
+
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+
+import { parsePages } from '../src/utils/pages.js';
+import { parseContentRange } from '../src/utils/http.js';
+import { parseRange } from './helpers/range.js';
+
+const ITERATIONS = parseInt(process.env.PDF2IMG_FUZZ_ITERATIONS) || 5000;
+
+/**
+ * 拼接输入用的片段：分隔符、边界数值和常见的畸形写法
+ */
+const FRAGMENTS = [
+    'bytes', 'bytes=', 'bytes ', '=', '-', '--', ',', ' ', '/', '*', '.', 'e', '+',
+    '0', '1', '2', '5', '9', '10', '42', '007', '-0',
+    '4294967295', '9007199254740991', '9007199254740993', '99999999999999999999999',
+    '1e3', '0x10', 'NaN', 'Infinity', 'abc', '\t', '\n', '\u0000', '１', '٣',
+];
+
+/**
+ * 固定种子的伪随机数生成器（mulberry32）
+ */
+function createRandom(seed) {
+    return () => {
+        seed = (seed + 0x6d2b79f5) | 0;
+        let t = Math.imul(seed ^ (seed >>> 15), 1 | seed);
+        t = (t + Math.imul(t ^ (t >>> 7), 61 | t)) ^ t;
+        return ((t ^ (t >>> 14)) >>> 0) / 4294967296;
+    };
+}
+
+/**
+ * 生成随机输入：大部分由片段拼接，少部分为任意字符
+ */
+function* fuzzInputs(seed, corpus) {
+    yield* corpus;
+    const random = createRandom(seed);
+    for (let i = 0; i < ITERATIONS; i++) {
+        const length = Math.floor(random() * 8);
+        let input = '';
+        for (let j = 0; j < length; j++) {
+            input += random() < 0.85
+                ? FRAGMENTS[Math.floor(random() * FRAGMENTS.length)]
+                : String.fromCharCode(Math.floor(random() * 0x3000));
+        }
+        yield input;
+    }
+}
+
+describe('PDF2IMG 解析函数模糊测试', () => {
+    it('parsePages 应该只返回非零的安全整数', () => {
+        const corpus = ['', ',', ',,,', '-', '--1', '1-2', '1.5', '2abc', '1e3', ' 3 ', '-0', '0x10', '99999999999999999999'];
+        for (const input of fuzzInputs(1, corpus)) {
+            let pages;
+            assert.doesNotThrow(() => { pages = parsePages(input); }, `输入 ${JSON.stringify(input)}`);
+            assert.ok(Array.isArray(pages));
+            for (const page of pages) {
+                assert.ok(Number.isSafeInteger(page) && page !== 0, `输入 ${JSON.stringify(input)} 得到 ${page}`);
+            }
+        }
+
+        assert.deepStrictEqual(parsePages('1.5,2abc,1e3,3'), [3], '部分可解析的写法应该被忽略');
+        assert.deepStrictEqual(parsePages('99999999999999999999,-2'), [-2], '超出安全整数的页码应该被忽略');
+    });
+
+    it('parseContentRange 应该只返回可用于计算偏移的值', () => {
+        const corpus = ['bytes 0-0/1', 'bytes */10', 'bytes 0-9/*', 'bytes */*', 'bytes 5-2/10', 'bytes 0-10/10',
+            'bytes -/10', 'bytes 0-99999999999999999999/100000000000000000000', 'bytes 1-2-3/10', 'items 0-1/2'];
+        for (const input of fuzzInputs(2, corpus)) {
+            let range;
+            assert.doesNotThrow(() => { range = parseContentRange(input); }, `输入 ${JSON.stringify(input)}`);
+            if (range === null) {
+                continue;
+            }
+            const { start, end, size } = range;
+            for (const value of [start, end, size]) {
+                assert.ok(value === null || (Number.isSafeInteger(value) && value >= 0), `输入 ${JSON.stringify(input)}`);
+            }
+            assert.ok(start !== null || size !== null, '范围和大小不应该同时未知');
+            assert.strictEqual(start === null, end === null);
+            if (start !== null) {
+                assert.ok(start <= end && (size === null || end < size), `输入 ${JSON.stringify(input)}`);
+            }
+        }
+
+        assert.deepStrictEqual(parseContentRange('bytes 0-0/12345'), { start: 0, end: 0, size: 12345 });
+        assert.deepStrictEqual(parseContentRange('bytes */12345'), { start: null, end: null, size: 12345 });
+        assert.deepStrictEqual(parseContentRange('bytes 0-9/*'), { start: 0, end: 9, size: null });
+        assert.strictEqual(parseContentRange('bytes 5-2/10'), null);
+        assert.strictEqual(parseContentRange('bytes 0-10/10'), null);
+    });
+
+    it('测试服务器的 Range 解析应该只返回文件范围内的区间', () => {
+        const corpus = ['bytes=-', 'bytes=5-2-3', 'bytes=5-2', 'bytes=-0', 'bytes=0-', 'bytes=99999999999999999999-',
+            'bytes=0-99999999999999999999', 'bytes=-99999999999999999999', 'bytes=0-1,3-4', 'bytes = 0-1', 'bytes=100-'];
+        for (const size of [0, 1, 100]) {
+            for (const input of fuzzInputs(3 + size, corpus)) {
+                let range;
+                assert.doesNotThrow(() => { range = parseRange(input, size); }, `输入 ${JSON.stringify(input)}`);
+                if (range !== null) {
+                    const { start, end } = range;
+                    assert.ok(Number.isSafeInteger(start) && Number.isSafeInteger(end), `输入 ${JSON.stringify(input)}`);
+                    assert.ok(start >= 0 && start <= end && end < size, `输入 ${JSON.stringify(input)}，大小 ${size}`);
+                }
+            }
+        }
+
+        assert.deepStrictEqual(parseRange('bytes=-10', 100), { start: 90, end: 99 });
+        assert.deepStrictEqual(parseRange('bytes=-1000', 100), { start: 0, end: 99 });
+        assert.deepStrictEqual(parseRange('bytes=10-1000', 100), { start: 10, end: 99 });
+        assert.strictEqual(parseRange('bytes=-', 100), null);
+        assert.strictEqual(parseRange('bytes=5-2-3', 100), null);
+    });
+});
@@ -0,0 +1,42 @@
+/**
+ * 内存 tracer，实现 OpenTelemetry Tracer 接口的子集，用于断言产生的 span
+ *
+ * 通过 AsyncLocalStorage 维护活动 span，与 OpenTelemetry 的上下文传播行为一致。
+ */
+
+import { AsyncLocalStorage } from 'async_hooks';
+
+export function createMemoryTracer() {
+    const storage = new AsyncLocalStorage();
+    const spans = [];
+
+    const startSpan = (name, options = {}) => {
+        const span = {
+            name,
+            parent: storage.getStore(),
+            attributes: { ...options.attributes },
+            status: null,
+            exceptions: [],
+            ended: false,
+            setAttribute(key, value) { this.attributes[key] = value; return this; },
+            setAttributes(attributes) { Object.assign(this.attributes, attributes); return this; },
+            recordException(err) { this.exceptions.push(err); },
+            setStatus(status) { this.status = status; return this; },
+            end() {
+                this.ended = true;
+                spans.push(this);
+            },
+        };
+        return span;
+    };
+
+    return {
+        // 已结束的 span，按结束顺序排列
+        spans,
+        startSpan,
+        startActiveSpan(name, options, fn) {
+            const span = startSpan(name, options);
+            return storage.run(span, () => fn(span));
+        },
+    };
+}
@@ -0,0 +1,74 @@
+/**
+ * 测试服务器使用的 Range 请求头解析
+ */
+
+/**
+ * 解析单个字节范围的 Range 请求头
+ *
+ * 支持 `bytes=start-end`、`bytes=start-` 和后缀形式 `bytes=-length`。
+ * 格式错误、多个范围、超出安全整数的数值、起点不小于文件大小或起点大于终点时返回 null，
+ * 服务器应响应 416。终点超出文件末尾时截断到最后一个字节。
+ *
+ * @param {string|undefined} header - Range 请求头
+ * @param {number} size - 文件大小
+ * @returns {{ start: number, end: number }|null} 闭区间 [start, end]
+ */
+export function parseRange(header, size) {
+    const match = /^bytes=(\d*)-(\d*)$/.exec(header ?? '');
+    if (!match || (match[1] === '' && match[2] === '') || size <= 0) {
+        return null;
+    }
+
+    const first = match[1] === '' ? null : Number(match[1]);
+    const last = match[2] === '' ? null : Number(match[2]);
+
+    if (first === null) {
+        if (!Number.isSafeInteger(last) || last === 0) {
+            return null;
+        }
+        return { start: Math.max(0, size - last), end: size - 1 };
+    }
+    if (!Number.isSafeInteger(first) || first >= size) {
+        return null;
+    }
+    if (last === null) {
+        return { start: first, end: size - 1 };
+    }
+    if (!Number.isSafeInteger(last) || last < first) {
+        return null;
+    }
+    return { start: first, end: Math.min(last, size - 1) };
+}
+
+/**
+ * 按 Range 请求头响应文件内容
+ *
+ * 没有 Range 请求头时返回完整内容（200），范围无效时返回 416。
+ *
+ * @param {http.IncomingMessage} req
+ * @param {http.ServerResponse} res
+ * @param {Buffer} data - 文件内容
+ * @returns {number} 发送的字节数
+ */
+export function serveRange(req, res, data) {
+    if (!req.headers.range) {
+        res.writeHead(200, { 'Content-Length': data.length, 'Accept-Ranges': 'bytes' });
+        res.end(data);
+        return data.length;
+    }
+
+    const range = parseRange(req.headers.range, data.length);
+    if (!range) {
+        res.writeHead(416, { 'Content-Range': `bytes */${data.length}` });
+        res.end();
+        return 0;
+    }
+
+    const body = data.subarray(range.start, range.end + 1);
+    res.writeHead(206, {
+        'Content-Length': body.length,
+        'Content-Range': `bytes ${range.start}-${range.end}/${data.length}`,
+    });
+    res.end(body);
+    return body.length;
+}
@@ -0,0 +1,74 @@
+/**
+ * 构造测试用 ZIP 归档（不依赖第三方库）
+ */
+
+import zlib from 'zlib';
+
+/**
+ * CRC-32（ZIP 使用的 IEEE 多项式）
+ */
+function crc32(data) {
+    let crc = 0xffffffff;
+    for (const byte of data) {
+        crc ^= byte;
+        for (let k = 0; k < 8; k++) {
+            crc = (crc >>> 1) ^ (0xedb88320 & -(crc & 1));
+        }
+    }
+    return (crc ^ 0xffffffff) >>> 0;
+}
+
+/**
+ * 构造 ZIP 归档
+ *
+ * @param {Array<{ name: string, data: Buffer, deflate?: boolean, extra?: Buffer }>} files
+ *   extra 只写入本地文件头，用于验证数据起点按本地文件头计算
+ */
+export function buildZip(files) {
+    const locals = [];
+    const centrals = [];
+    let offset = 0;
+
+    for (const file of files) {
+        const name = Buffer.from(file.name);
+        const extra = file.extra ?? Buffer.alloc(0);
+        const body = file.deflate ? zlib.deflateRawSync(file.data) : file.data;
+        const crc = crc32(file.data);
+        const method = file.deflate ? 8 : 0;
+
+        const local = Buffer.alloc(30);
+        local.writeUInt32LE(0x04034b50, 0);
+        local.writeUInt16LE(20, 4);
+        local.writeUInt16LE(method, 8);
+        local.writeUInt32LE(crc, 14);
+        local.writeUInt32LE(body.length, 18);
+        local.writeUInt32LE(file.data.length, 22);
+        local.writeUInt16LE(name.length, 26);
+        local.writeUInt16LE(extra.length, 28);
+        locals.push(local, name, extra, body);
+
+        const central = Buffer.alloc(46);
+        central.writeUInt32LE(0x02014b50, 0);
+        central.writeUInt16LE(20, 4);
+        central.writeUInt16LE(20, 6);
+        central.writeUInt16LE(method, 10);
+        central.writeUInt32LE(crc, 16);
+        central.writeUInt32LE(body.length, 20);
+        central.writeUInt32LE(file.data.length, 24);
+        central.writeUInt16LE(name.length, 28);
+        central.writeUInt32LE(offset, 42);
+        centrals.push(central, name);
+
+        offset += local.length + name.length + extra.length + body.length;
+    }
+
+    const centralDirectory = Buffer.concat(centrals);
+    const eocd = Buffer.alloc(22);
+    eocd.writeUInt32LE(0x06054b50, 0);
+    eocd.writeUInt16LE(files.length, 8);
+    eocd.writeUInt16LE(files.length, 10);
+    eocd.writeUInt32LE(centralDirectory.length, 12);
+    eocd.writeUInt32LE(offset, 16);
+
+    return Buffer.concat([...locals, centralDirectory, eocd]);
+}
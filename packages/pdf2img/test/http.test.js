@@ -0,0 +1,463 @@
+/**
+ * PDF2IMG HTTP 工具测试
+ *
+ * 运行方式：
+ *   node --test test/http.test.js
+ */
+
+import { describe, it, before, after, mock } from 'node:test';
+import assert from 'node:assert';
+import dns from 'dns';
+import http from 'http';
+import zlib from 'zlib';
+import { Writable } from 'stream';
+import { pipeline } from 'stream/promises';
+
+import { assertUrlAllowed, ERR_RANGE_MISMATCH, ERR_STALLED, fetchWithPolicy, isPrivateAddress, pickNetworkPolicy, privateNetworkLookup, stallSignal, validateContentRange } from '../src/utils/http.js';
+
+describe('PDF2IMG HTTP 工具测试', () => {
+    let server;
+    let baseUrl;
+
+    before(async () => {
+        server = http.createServer((req, res) => {
+            // /gzip：客户端接受 gzip 时压缩响应，Content-Length 为压缩后的大小
+            if (req.url === '/gzip') {
+                const body = Buffer.from('%PDF-1.4 ' + '0123456789'.repeat(100));
+                const gzip = /gzip/.test(req.headers['accept-encoding'] || '');
+                const payload = gzip ? zlib.gzipSync(body) : body;
+                res.writeHead(200, {
+                    'Content-Length': payload.length,
+                    ...(gzip ? { 'Content-Encoding': 'gzip' } : {}),
+                });
+                res.end(req.method === 'HEAD' ? undefined : payload);
+                return;
+            }
+            // /slow：每 50ms 发送一块数据，共 8 块，整体耗时远超停滞超时
+            if (req.url === '/slow') {
+                res.writeHead(200, { 'Content-Type': 'application/pdf' });
+                let sent = 0;
+                const timer = setInterval(() => {
+                    res.write(Buffer.alloc(1024, sent));
+                    if (++sent === 8) {
+                        clearInterval(timer);
+                        res.end();
+                    }
+                }, 50);
+                return;
+            }
+            // /stall：发送响应头和一块数据后不再响应
+            if (req.url === '/stall') {
+                res.writeHead(200, { 'Content-Type': 'application/pdf' });
+                res.write('%PDF-1.4');
+                return;
+            }
+            // /no-headers：接受连接但不返回响应头
+            if (req.url === '/no-headers') {
+                return;
+            }
+            if (req.url === '/redirect-out') {
+                res.writeHead(302, { Location: 'http://evil.example.com/secret' });
+                res.end();
+                return;
+            }
+            // /chain/N 依次重定向到 /chain/N-1，直到 /chain/0
+            const chain = req.url.match(/^\/chain\/(\d+)$/);
+            if (chain && chain[1] !== '0') {
+                res.writeHead(302, { Location: `/chain/${Number(chain[1]) - 1}` });
+                res.end();
+                return;
+            }
+            if (req.url === '/cross-origin') {
+                res.writeHead(307, { Location: `http://localhost:${server.address().port}/echo` });
+                res.end();
+                return;
+            }
+            if (chain || req.url === '/echo') {
+                res.writeHead(200, { 'Content-Type': 'application/json' });
+                res.end(JSON.stringify({
+                    range: req.headers.range || null,
+                    authorization: req.headers.authorization || null,
+                    traceparent: req.headers.traceparent || null,
+                }));
+                return;
+            }
+            res.writeHead(200, { 'Content-Type': 'application/pdf' });
+            res.end('%PDF-1.4');
+        });
+        await new Promise(resolve => server.listen(0, '127.0.0.1', resolve));
+        baseUrl = `http://127.0.0.1:${server.address().port}`;
+    });
+
+    after(() => {
+        server.closeAllConnections();
+        server.close();
+    });
+
+    describe('内网地址识别', () => {
+        it('应该识别内网、回环和链路本地地址', () => {
+            for (const address of ['127.0.0.1', '10.1.2.3', '172.16.0.1', '192.168.1.1', '169.254.169.254', '::1', 'fe80::1', '::ffff:127.0.0.1']) {
+                assert.ok(isPrivateAddress(address), `${address} 应该是内网地址`);
+            }
+        });
+
+        it('应该识别基准测试、组播、保留地址和 NAT64 内嵌的内网地址', () => {
+            for (const address of ['198.18.0.1', '198.19.255.255', '224.0.0.1', '239.255.255.250', '240.0.0.1', '255.255.255.255', '64:ff9b::a00:1', '64:ff9b::127.0.0.1', '64:ff9b::a9fe:a9fe']) {
+                assert.ok(isPrivateAddress(address), `${address} 应该被拦截`);
+            }
+            assert.ok(!isPrivateAddress('198.20.0.1'));
+        });
+
+        it('公网地址不应该被识别为内网地址', () => {
+            for (const address of ['8.8.8.8', '1.1.1.1', '2606:4700:4700::1111']) {
+                assert.ok(!isPrivateAddress(address), `${address} 不应该是内网地址`);
+            }
+        });
+    });
+
+    describe('地址校验', () => {
+        it('开启内网拦截时应该拒绝元数据地址', async () => {
+            await assert.rejects(
+                assertUrlAllowed('http://169.254.169.254/latest/meta-data/', { blockPrivateNetwork: true }),
+                err => err.code === 'ERR_URL_NOT_ALLOWED'
+            );
+        });
+
+        it('应该拒绝白名单之外的主机', async () => {
+            await assert.rejects(
+                assertUrlAllowed('https://other.example.org/a.pdf', { allowedHosts: ['cdn.example.com'] }),
+                err => err.code === 'ERR_URL_NOT_ALLOWED'
+            );
+        });
+
+        it('应该允许白名单内的主机（含通配）', async () => {
+            await assertUrlAllowed('https://cdn.example.com/a.pdf', { allowedHosts: ['cdn.example.com'] });
+            await assertUrlAllowed('https://img.static.example.com/a.pdf', { allowedHosts: ['*.example.com'] });
+        });
+
+        it('连接时解析到内网地址应该被拒绝（DNS rebinding）', async () => {
+            // 第一次解析（请求前校验）返回公网地址，之后（建立连接时）返回回环地址
+            let resolutions = 0;
+            const resolve = () => (resolutions++ === 0 ? '93.184.216.34' : '127.0.0.1');
+            mock.method(dns.promises, 'lookup', async () => [{ address: resolve(), family: 4 }]);
+            mock.method(dns, 'lookup', (hostname, options, callback) => {
+                const address = resolve();
+                if (options.all) {
+                    callback(null, [{ address, family: 4 }]);
+                } else {
+                    callback(null, address, 4);
+                }
+            });
+            let requests = 0;
+            server.once('request', () => requests++);
+
+            try {
+                await assert.rejects(
+                    fetchWithPolicy(`http://rebind.test:${server.address().port}/a.pdf`, {}, { blockPrivateNetwork: true }),
+                    err => err.code === 'ERR_URL_NOT_ALLOWED' && /127\.0\.0\.1/.test(err.message)
+                );
+                assert.ok(resolutions >= 2, '连接时应该再次解析');
+                assert.strictEqual(requests, 0);
+            } finally {
+                mock.restoreAll();
+            }
+        });
+
+        it('privateNetworkLookup 应该校验所有解析结果', async () => {
+            mock.method(dns, 'lookup', (hostname, options, callback) => {
+                callback(null, [{ address: '93.184.216.34', family: 4 }, { address: '::1', family: 6 }]);
+            });
+            try {
+                const err = await new Promise(resolve => privateNetworkLookup('mixed.test', { all: true }, resolve));
+                assert.strictEqual(err?.code, 'ERR_URL_NOT_ALLOWED');
+            } finally {
+                mock.restoreAll();
+            }
+        });
+
+        it('应该拒绝非 http(s) 协议', async () => {
+            await assert.rejects(
+                assertUrlAllowed('file:///etc/passwd'),
+                err => err.code === 'ERR_URL_NOT_ALLOWED'
+            );
+        });
+    });
+
+    describe('访问策略', () => {
+        it('pickNetworkPolicy 应该只取出访问策略字段', () => {
+            const signRequest = () => {};
+            const policy = pickNetworkPolicy({
+                allowedHosts: ['cdn.example.com'],
+                blockPrivateNetwork: true,
+                maxFileSize: 1024,
+                headers: { traceparent: '00-abc' },
+                signRequest,
+                targetWidth: 1280,
+                onRangeRequest: () => {},
+            });
+            assert.deepStrictEqual(policy, {
+                allowedHosts: ['cdn.example.com'],
+                blockPrivateNetwork: true,
+                maxFileSize: 1024,
+                headers: { traceparent: '00-abc' },
+                signRequest,
+                dispatcher: undefined,
+            });
+        });
+    });
+
+    describe('fetchWithPolicy', () => {
+        it('应该请求白名单内的地址', async () => {
+            const response = await fetchWithPolicy(`${baseUrl}/a.pdf`, {}, { allowedHosts: ['127.0.0.1'] });
+            assert.strictEqual(response.status, 200);
+            assert.strictEqual(await response.text(), '%PDF-1.4');
+        });
+
+        it('应该附加策略中的请求头且不覆盖调用方的同名头', async () => {
+            const traceparent = '00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01';
+            const response = await fetchWithPolicy(`${baseUrl}/chain/1`, {
+                headers: { Range: 'bytes=0-9' },
+            }, { headers: { traceparent, Range: 'bytes=5-9' } });
+            const body = await response.json();
+            assert.strictEqual(body.traceparent, traceparent, '重定向后也应该保留');
+            assert.strictEqual(body.range, 'bytes=0-9');
+        });
+
+        it('应该请求未压缩的原始字节，Content-Length 与文件大小一致', async () => {
+            const response = await fetchWithPolicy(`${baseUrl}/gzip`, { method: 'HEAD' });
+            assert.strictEqual(response.headers.get('content-encoding'), null);
+            assert.strictEqual(Number(response.headers.get('content-length')), 1009);
+        });
+
+        it('重定向到不允许的主机时应该被拒绝', async () => {
+            await assert.rejects(
+                fetchWithPolicy(`${baseUrl}/redirect-out`, {}, { allowedHosts: ['127.0.0.1'] }),
+                err => err.code === 'ERR_URL_NOT_ALLOWED'
+            );
+        });
+    });
+
+    describe('分片响应校验', () => {
+        const isMismatch = err => err.code === ERR_RANGE_MISMATCH;
+
+        it('应该接受与请求一致的范围', () => {
+            assert.deepStrictEqual(validateContentRange('bytes 100-199/1000', 100, 100, 199), { start: 100, end: 199, size: 1000 });
+            // 请求超出文件末尾时，响应到文件末尾为止
+            assert.deepStrictEqual(validateContentRange('bytes 900-999/1000', 100, 900, 1023), { start: 900, end: 999, size: 1000 });
+            // 后缀范围请求只校验长度
+            assert.deepStrictEqual(validateContentRange('bytes 990-999/1000', 10), { start: 990, end: 999, size: 1000 });
+        });
+
+        it('范围不一致时应该报错', () => {
+            assert.throws(() => validateContentRange('bytes 0-99/1000', 100, 100, 199), isMismatch);
+            assert.throws(() => validateContentRange('bytes 100-149/1000', 50, 100, 199), isMismatch, '未到文件末尾的短范围');
+            assert.throws(() => validateContentRange('bytes 100-149/*', 50, 100, 199), isMismatch, '总大小未知时无法确认到达文件末尾');
+        });
+
+        it('响应体长度与 Content-Range 不一致时应该报错', () => {
+            assert.throws(() => validateContentRange('bytes 100-199/1000', 60, 100, 199), isMismatch);
+            assert.throws(() => validateContentRange('bytes 990-999/1000', 12), isMismatch);
+        });
+
+        it('缺少或无法解析 Content-Range 时应该报错', () => {
+            assert.throws(() => validateContentRange(null, 100, 100, 199), isMismatch);
+            assert.throws(() => validateContentRange('bytes */1000', 0, 100, 199), isMismatch);
+        });
+    });
+
+    describe('连接停滞检测', () => {
+        /**
+         * 按停滞超时下载，返回收到的字节数
+         */
+        async function download(path, timeout) {
+            const stall = stallSignal(timeout);
+            try {
+                const response = await fetchWithPolicy(`${baseUrl}${path}`, { signal: stall.signal });
+                stall.touch();
+                let received = 0;
+                await pipeline(response.body, stall.pipe, new Writable({
+                    write(chunk, encoding, callback) {
+                        received += chunk.length;
+                        callback();
+                    },
+                }));
+                return received;
+            } finally {
+                stall.clear();
+            }
+        }
+
+        it('慢速但持续有数据的下载不应该超时', async () => {
+            // 总耗时约 400ms，超过停滞超时，但数据块间隔只有 50ms
+            assert.strictEqual(await download('/slow', 150), 8 * 1024);
+        });
+
+        it('收到部分数据后停滞应该超时', async () => {
+            const start = Date.now();
+            await assert.rejects(download('/stall', 150), err => err.code === ERR_STALLED);
+            assert.ok(Date.now() - start < 1000);
+        });
+
+        it('迟迟没有响应头应该超时', async () => {
+            await assert.rejects(download('/no-headers', 150), err => err.code === ERR_STALLED);
+        });
+    });
+
+    describe('连接复用', () => {
+        let rangeServer;
+        let rangeUrl;
+        let connections = 0;
+        const file = Buffer.alloc(64 * 1024, 7);
+
+        before(async () => {
+            rangeServer = http.createServer((req, res) => {
+                const [, start, end] = req.headers.range.match(/bytes=(\d+)-(\d+)/);
+                res.writeHead(206, {
+                    'Content-Range': `bytes ${start}-${end}/${file.length}`,
+                    'Content-Length': end - start + 1,
+                });
+                res.end(file.subarray(Number(start), Number(end) + 1));
+            });
+            rangeServer.on('connection', () => connections++);
+            await new Promise(resolve => rangeServer.listen(0, '127.0.0.1', resolve));
+            rangeUrl = `http://127.0.0.1:${rangeServer.address().port}/doc.pdf`;
+        });
+
+        after(() => {
+            rangeServer.closeAllConnections();
+            rangeServer.close();
+        });
+
+        /**
+         * 模拟按需加载：4 个并发，依次读取 32 个 2KB 分片
+         */
+        async function loadRanges(policy) {
+            const offsets = Array.from({ length: 32 }, (_, i) => i * 2048);
+            const workers = Array.from({ length: 4 }, async () => {
+                while (offsets.length > 0) {
+                    const offset = offsets.shift();
+                    const response = await fetchWithPolicy(rangeUrl, {
+                        headers: { Range: `bytes=${offset}-${offset + 2047}` },
+                    }, policy);
+                    assert.strictEqual((await response.arrayBuffer()).byteLength, 2048);
+                }
+            });
+            await Promise.all(workers);
+        }
+
+        it('分片请求应该复用连接而不是每次新建', async () => {
+            connections = 0;
+            await loadRanges({});
+            // 连接在响应体读完后才归还连接池，下一个请求可能抢先新建连接，允许并发数的 2 倍
+            assert.ok(connections <= 8, `32 个分片请求新建了 ${connections} 个连接`);
+        });
+
+        it('应该通过自定义 dispatcher 发出请求', async () => {
+            // fetch 首次调用后才会创建全局 Agent，包装它统计经过的请求
+            await (await fetch(rangeUrl, { headers: { Range: 'bytes=0-0' } })).arrayBuffer();
+            const agent = globalThis[Symbol.for('undici.globalDispatcher.1')];
+            let dispatched = 0;
+            const dispatcher = {
+                dispatch(options, handler) {
+                    dispatched++;
+                    return agent.dispatch(options, handler);
+                },
+            };
+
+            connections = 0;
+            await loadRanges({ dispatcher });
+            assert.strictEqual(dispatched, 32);
+            assert.ok(connections <= 8, `32 个分片请求新建了 ${connections} 个连接`);
+        });
+    });
+
+    describe('重定向策略', () => {
+        it('应该跟随重定向并保留 Range 请求头', async () => {
+            const response = await fetchWithPolicy(`${baseUrl}/chain/3`, {
+                headers: { Range: 'bytes=0-99', Authorization: 'Bearer token' },
+            }, { maxRedirects: 3 });
+            const body = await response.json();
+            assert.strictEqual(body.range, 'bytes=0-99');
+            assert.strictEqual(body.authorization, 'Bearer token', '同源重定向应该保留凭证');
+        });
+
+        it('超过最大重定向次数应该报错', async () => {
+            await assert.rejects(
+                fetchWithPolicy(`${baseUrl}/chain/4`, {}, { maxRedirects: 3 }),
+                /Too many redirects/
+            );
+        });
+
+        it('跨域重定向应该移除凭证但保留 Range', async () => {
+            const response = await fetchWithPolicy(`${baseUrl}/cross-origin`, {
+                headers: { Range: 'bytes=10-19', Authorization: 'Bearer token' },
+            });
+            const body = await response.json();
+            assert.strictEqual(body.range, 'bytes=10-19');
+            assert.strictEqual(body.authorization, null);
+        });
+
+        it('onRedirect 钩子可以拒绝重定向', async () => {
+            const hops = [];
+            await assert.rejects(
+                fetchWithPolicy(`${baseUrl}/chain/2`, {}, {
+                    onRedirect: (from, to) => {
+                        hops.push(to.pathname);
+                        if (to.pathname === '/chain/0') {
+                            throw new Error('blocked');
+                        }
+                    },
+                }),
+                /blocked/
+            );
+            assert.deepStrictEqual(hops, ['/chain/1', '/chain/0']);
+        });
+    });
+
+    describe('请求签名', () => {
+        it('每个请求和同源重定向后都应该重新签名', async () => {
+            const calls = [];
+            const signRequest = ({ method, url, headers }) => {
+                calls.push({ method, path: url.pathname, range: headers.get('range'), signed: headers.has('authorization') });
+                headers.set('authorization', `Signed ${url.pathname} ${headers.get('range')}`);
+            };
+
+            for (const range of ['bytes=0-9', 'bytes=10-19']) {
+                const response = await fetchWithPolicy(`${baseUrl}/chain/1`, { headers: { Range: range } }, { signRequest });
+                const body = await response.json();
+                assert.strictEqual(body.authorization, `Signed /chain/0 ${range}`, '最终请求应该携带按该跳计算的签名');
+            }
+
+            assert.deepStrictEqual(calls, [
+                { method: 'GET', path: '/chain/1', range: 'bytes=0-9', signed: false },
+                { method: 'GET', path: '/chain/0', range: 'bytes=0-9', signed: false },
+                { method: 'GET', path: '/chain/1', range: 'bytes=10-19', signed: false },
+                { method: 'GET', path: '/chain/0', range: 'bytes=10-19', signed: false },
+            ]);
+        });
+
+        it('跳转到其他源后不应该签名', async () => {
+            const signed = [];
+            const response = await fetchWithPolicy(`${baseUrl}/cross-origin`, {}, {
+                signRequest: ({ url, headers }) => {
+                    signed.push(url.host);
+                    headers.set('authorization', 'Signed');
+                },
+            });
+            const body = await response.json();
+            assert.strictEqual(body.authorization, null);
+            assert.deepStrictEqual(signed, [new URL(baseUrl).host]);
+        });
+
+        it('签名钩子抛出异常时应该中止请求', async () => {
+            await assert.rejects(
+                fetchWithPolicy(`${baseUrl}/echo`, { method: 'HEAD' }, {
+                    signRequest: () => {
+                        throw new Error('no credentials');
+                    },
+                }),
+                /no credentials/
+            );
+        });
+    });
+});
@@ -0,0 +1,90 @@
+/**
+ * PDF2IMG 全局并发限制测试
+ *
+ * 运行方式：
+ *   node --test test/limiter.test.js
+ */
+
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+
+import { limitFetch, limitDownload, getFetchStats } from '../src/utils/limiter.js';
+import { NETWORK_CONFIG } from '../src/core/config.js';
+
+describe('PDF2IMG 全局并发限制测试', () => {
+    it('同时进行的请求数不应该超过上限', async () => {
+        const limit = NETWORK_CONFIG.MAX_CONCURRENT_FETCHES;
+        let inFlight = 0;
+        let maxInFlight = 0;
+
+        const request = () => limitFetch(async () => {
+            inFlight++;
+            maxInFlight = Math.max(maxInFlight, inFlight);
+            await new Promise(resolve => setTimeout(resolve, 5));
+            inFlight--;
+        });
+
+        // 模拟多个转换同时发起请求
+        const conversions = Array.from({ length: 8 }, () =>
+            Promise.all(Array.from({ length: limit }, request))
+        );
+
+        await new Promise(resolve => setImmediate(resolve));
+        const stats = getFetchStats();
+        assert.strictEqual(stats.limit, limit);
+        assert.ok(stats.active <= limit, '进行中的请求数不应该超过上限');
+        assert.ok(stats.pending > 0, '超出上限的请求应该排队');
+
+        await Promise.all(conversions);
+        assert.strictEqual(maxInFlight, limit, '并发应该达到但不超过上限');
+        assert.strictEqual(getFetchStats().active, 0);
+    });
+
+    it('排队期间取消应该立即结束且不再执行', async () => {
+        const limit = NETWORK_CONFIG.MAX_CONCURRENT_FETCHES;
+        let releaseSlots;
+        const slotsHeld = new Promise(resolve => { releaseSlots = resolve; });
+        const holders = Array.from({ length: limit }, () => limitFetch(() => slotsHeld));
+
+        const controller = new AbortController();
+        let started = false;
+        const queued = limitFetch(async () => { started = true; }, controller.signal);
+        controller.abort();
+
+        await assert.rejects(queued, err => err.name === 'AbortError');
+        releaseSlots();
+        await Promise.all(holders);
+        await new Promise(resolve => setImmediate(resolve));
+
+        assert.strictEqual(started, false, '取消的请求轮到时不应该执行');
+        assert.deepStrictEqual([getFetchStats().active, getFetchStats().pending], [0, 0]);
+        await assert.rejects(limitFetch(async () => {}, controller.signal), err => err.name === 'AbortError');
+    });
+
+    it('进行中的完整下载不应该占用分片请求的额度', async () => {
+        const limit = NETWORK_CONFIG.MAX_CONCURRENT_FETCHES;
+        let finishDownloads;
+        const downloading = new Promise(resolve => { finishDownloads = resolve; });
+        // 占满下载额度的长时间下载
+        const downloads = Array.from({ length: NETWORK_CONFIG.MAX_CONCURRENT_DOWNLOADS }, () => limitDownload(() => downloading));
+
+        let inFlight = 0;
+        let maxInFlight = 0;
+        const ranges = Array.from({ length: limit * 2 }, () => limitFetch(async () => {
+            inFlight++;
+            maxInFlight = Math.max(maxInFlight, inFlight);
+            await new Promise(resolve => setTimeout(resolve, 5));
+            inFlight--;
+        }));
+
+        await new Promise(resolve => setImmediate(resolve));
+        assert.strictEqual(getFetchStats().downloads.active, NETWORK_CONFIG.MAX_CONCURRENT_DOWNLOADS);
+        await Promise.all(ranges);
+        assert.strictEqual(maxInFlight, limit, '分片请求应该能用满全部额度');
+        assert.strictEqual(getFetchStats().downloads.active, NETWORK_CONFIG.MAX_CONCURRENT_DOWNLOADS, '下载仍在进行');
+
+        finishDownloads();
+        await Promise.all(downloads);
+        assert.deepStrictEqual(getFetchStats().downloads, { limit: NETWORK_CONFIG.MAX_CONCURRENT_DOWNLOADS, active: 0, pending: 0 });
+    });
+});
@@ -0,0 +1,67 @@
+/**
+ * PDF2IMG 输出清单测试
+ *
+ * 运行方式：
+ *   node --test test/manifest.test.js
+ */
+
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+
+import { buildManifest, MANIFEST_VERSION } from '../src/utils/manifest.js';
+
+describe('PDF2IMG 输出清单测试', () => {
+    it('文件输出应该记录相对文件名', () => {
+        const manifest = buildManifest({
+            numPages: 3,
+            format: 'auto',
+            pages: [
+                { pageNum: 2, success: true, width: 800, height: 600, format: 'png', size: 1234, outputPath: '/out/page_2.png' },
+                { pageNum: 1, success: true, width: 800, height: 1100, format: 'webp', size: 5678, outputPath: '/out/page_1.webp' },
+            ],
+        });
+
+        assert.strictEqual(manifest.version, MANIFEST_VERSION);
+        assert.strictEqual(manifest.numPages, 3);
+        assert.strictEqual(manifest.format, 'auto');
+        assert.deepStrictEqual(manifest.pages.map(p => [p.pageNum, p.format, p.file]), [
+            [2, 'png', 'page_2.png'],
+            [1, 'webp', 'page_1.webp'],
+        ]);
+    });
+
+    it('Buffer 输出应该按 buffer 计算大小，失败页面记录错误', () => {
+        const manifest = buildManifest({
+            numPages: 2,
+            format: 'webp',
+            pages: [
+                { pageNum: 1, success: true, width: 10, height: 20, format: 'webp', buffer: Buffer.alloc(42) },
+                { pageNum: 2, success: false, width: 0, height: 0, buffer: null, error: 'Render failed' },
+            ],
+        });
+
+        const [ok, failed] = JSON.parse(JSON.stringify(manifest)).pages;
+        assert.deepStrictEqual(ok, { pageNum: 1, success: true, width: 10, height: 20, format: 'webp', size: 42 });
+        assert.deepStrictEqual(failed, { pageNum: 2, success: false, width: 0, height: 0, error: 'Render failed' });
+    });
+
+    it('COS 输出应该记录 cosKey', () => {
+        const manifest = buildManifest({
+            numPages: 1,
+            format: 'jpg',
+            pages: [{ pageNum: 1, success: true, width: 10, height: 20, format: 'jpg', size: 7, cosKey: 'docs/a/page_1.jpg' }],
+        });
+        assert.strictEqual(manifest.pages[0].cosKey, 'docs/a/page_1.jpg');
+        assert.strictEqual(manifest.pages[0].file, undefined);
+    });
+
+    it('应该记录占位图', () => {
+        const placeholder = 'data:image/webp;base64,UklGRg==';
+        const manifest = buildManifest({
+            numPages: 1,
+            format: 'webp',
+            pages: [{ pageNum: 1, success: true, width: 10, height: 20, format: 'webp', size: 7, cosKey: 'a/page_1.webp', placeholder }],
+        });
+        assert.strictEqual(manifest.pages[0].placeholder, placeholder);
+    });
+});
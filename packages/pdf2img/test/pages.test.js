@@ -0,0 +1,176 @@
+/**
+ * PDF2IMG 页码工具测试
+ *
+ * 运行方式：
+ *   node --test test/pages.test.js
+ */
+
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+
+import { parsePages, applyDefaultPages, resolvePages, needsPageCount, assertHasPages, assertPagesInRange, assertPageLimit, orderByRequest, expandToRequest, outOfRangePages } from '../src/utils/pages.js';
+import { setTimeout as sleep } from 'node:timers/promises';
+
+describe('PDF2IMG 页码工具测试', () => {
+    describe('parsePages', () => {
+        it('应该解析逗号分隔的页码', () => {
+            assert.deepStrictEqual(parsePages('1, 2,3'), [1, 2, 3]);
+        });
+
+        it('应该保留负数页码并忽略 0 和非法值', () => {
+            assert.deepStrictEqual(parsePages('-1,0,abc,-2'), [-1, -2]);
+        });
+
+        it('空参数应该返回空数组', () => {
+            assert.deepStrictEqual(parsePages(undefined), []);
+            assert.deepStrictEqual(parsePages(''), []);
+        });
+    });
+
+    describe('applyDefaultPages', () => {
+        it('未设置默认页数时空数组应该表示全部页面', () => {
+            assert.deepStrictEqual(applyDefaultPages(undefined, 0), []);
+            assert.deepStrictEqual(applyDefaultPages([], 0), []);
+        });
+
+        it('设置默认页数时未指定页码应该只取前 N 页', () => {
+            assert.deepStrictEqual(applyDefaultPages(undefined, 1), [1]);
+            assert.deepStrictEqual(applyDefaultPages([], 3), [1, 2, 3]);
+        });
+
+        it('显式指定的页码和 all 不应该受默认页数影响', () => {
+            assert.deepStrictEqual(applyDefaultPages([4, -1], 1), [4, -1]);
+            assert.deepStrictEqual(applyDefaultPages('all', 1), []);
+        });
+
+        it('非法取值应该报错', () => {
+            assert.throws(() => applyDefaultPages('1,2', 0), /Invalid pages/);
+        });
+    });
+
+    describe('resolvePages', () => {
+        it('空数组应该返回全部页面', () => {
+            assert.deepStrictEqual(resolvePages([], 3), [1, 2, 3]);
+        });
+
+        it('负数应该从末尾倒数', () => {
+            assert.deepStrictEqual(resolvePages([-1], 5), [5]);
+            assert.deepStrictEqual(resolvePages([1, -2], 5), [1, 4]);
+        });
+
+        it('应该过滤超出范围的页码', () => {
+            assert.deepStrictEqual(resolvePages([0, 6, -6, 2], 5), [2]);
+        });
+
+        it('重复的页码应该只保留第一次出现', () => {
+            assert.deepStrictEqual(resolvePages([2, 1, 2, 1], 5), [2, 1]);
+            assert.deepStrictEqual(resolvePages([5, 3, -1], 5), [5, 3], '负数换算后重复的页码也应该去重');
+        });
+    });
+
+    describe('assertPagesInRange', () => {
+        it('请求的页码全部越界时应该抛出错误', () => {
+            assert.throws(
+                () => assertPagesInRange([9999], resolvePages([9999], 3), 3),
+                err => err.code === 'ERR_PAGE_OUT_OF_RANGE' && /9999/.test(err.message) && /3 pages/.test(err.message)
+            );
+        });
+
+        it('部分越界或请求全部页面时不应该抛出', () => {
+            assertPagesInRange([1, 9999], resolvePages([1, 9999], 3), 3);
+            assertPagesInRange([], resolvePages([], 3), 3);
+        });
+    });
+
+    describe('outOfRangePages', () => {
+        it('应该为越界页码生成失败结果，按请求的写法且不重复', () => {
+            const failed = outOfRangePages([1, 5, -9, 5, -1], 3);
+            assert.deepStrictEqual(failed.map(p => p.pageNum), [5, -9]);
+            assert.ok(failed.every(p => p.success === false && p.buffer === null));
+            assert.strictEqual(failed[0].error, 'Page 5 out of range');
+        });
+
+        it('没有越界页码或请求全部页面时应该返回空数组', () => {
+            assert.deepStrictEqual(outOfRangePages([1, -1], 3), []);
+            assert.deepStrictEqual(outOfRangePages([], 3), []);
+        });
+    });
+
+    describe('assertPageLimit', () => {
+        it('超过上限时应该抛出错误并包含总页数', () => {
+            assert.throws(
+                () => assertPageLimit(resolvePages([], 2000), 2000, 100),
+                err => err.code === 'ERR_TOO_MANY_PAGES' && /2000 pages/.test(err.message)
+            );
+        });
+
+        it('未超过上限或不限制时不应该抛出', () => {
+            assertPageLimit([1, 2, 3], 10, 3);
+            assertPageLimit(resolvePages([], 2000), 2000, 0);
+        });
+    });
+
+    describe('assertHasPages', () => {
+        it('没有页面的文档应该抛出 ERR_NO_PAGES', () => {
+            assert.throws(() => assertHasPages(0), err => err.code === 'ERR_NO_PAGES');
+            assertHasPages(1);
+        });
+    });
+
+    describe('needsPageCount', () => {
+        it('全部页面或包含负数时需要总页数', () => {
+            assert.strictEqual(needsPageCount([]), true);
+            assert.strictEqual(needsPageCount([1, -1]), true);
+            assert.strictEqual(needsPageCount([1, 2]), false);
+        });
+    });
+
+    describe('orderByRequest', () => {
+        it('并发渲染时结果应该按请求顺序排列', async () => {
+            const requested = [5, 1, 4, 2, 3];
+            // 越靠前的页面渲染越慢，完成顺序与请求顺序相反
+            const completed = [];
+            await Promise.all(requested.map(async (pageNum, i) => {
+                await sleep((requested.length - i) * 10);
+                completed.push({ pageNum });
+            }));
+
+            assert.deepStrictEqual(completed.map(p => p.pageNum), [...requested].reverse());
+            assert.deepStrictEqual(orderByRequest(completed, requested).map(p => p.pageNum), requested);
+        });
+
+        it('应该按请求顺序合并缓存命中和新渲染的页面', () => {
+            const cached = [{ pageNum: 3, cached: true }, { pageNum: 1, cached: true }];
+            const rendered = [{ pageNum: 2 }];
+            const ordered = orderByRequest([...cached, ...rendered], resolvePages([-1, 2, 1], 3));
+            assert.deepStrictEqual(ordered.map(p => p.pageNum), [3, 2, 1]);
+        });
+
+        it('不在请求中的页面应该按页码排在最后', () => {
+            const ordered = orderByRequest([{ pageNum: 9 }, { pageNum: 7 }, { pageNum: 2 }], [2]);
+            assert.deepStrictEqual(ordered.map(p => p.pageNum), [2, 7, 9]);
+        });
+    });
+
+    describe('expandToRequest', () => {
+        it('重复请求的页码应该出现在所有请求位置', () => {
+            const results = [{ pageNum: 1, buffer: 'a' }, { pageNum: 2, buffer: 'b' }];
+            const expanded = expandToRequest(results, [1, 1, 2, -2], 3);
+            assert.deepStrictEqual(expanded.map(p => p.pageNum), [1, 1, 2, 2]);
+            assert.strictEqual(expanded[0], results[0]);
+            assert.notStrictEqual(expanded[1], results[0], '重复位置应该是独立的结果对象');
+            assert.strictEqual(expanded[1].buffer, 'a');
+        });
+
+        it('没有重复页码时应该原样返回', () => {
+            const results = [{ pageNum: 2 }, { pageNum: 1 }];
+            assert.strictEqual(expandToRequest(results, [2, 1], 3), results);
+            assert.strictEqual(expandToRequest(results, [], 3), results);
+        });
+
+        it('没有结果的页码不应该占位', () => {
+            const expanded = expandToRequest([{ pageNum: 2 }], [1, 2, 1, 2], 3);
+            assert.deepStrictEqual(expanded.map(p => p.pageNum), [2, 2]);
+        });
+    });
+});
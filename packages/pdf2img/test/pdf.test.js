@@ -0,0 +1,42 @@
+/**
+ * PDF2IMG PDF 结构工具测试
+ *
+ * 运行方式：
+ *   node --test test/pdf.test.js
+ */
+
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+
+import { isLinearized } from '../src/utils/pdf.js';
+
+const LINEARIZED_HEADER = Buffer.from(
+    '%PDF-1.7\n%\xe2\xe3\xcf\xd3\n1 0 obj\n<</Linearized 1/L 40087/O 3/E 35000/N 1/T 39800/H [ 500 150]>>\nendobj\n',
+    'latin1'
+);
+
+describe('PDF2IMG PDF 结构工具测试', () => {
+    describe('isLinearized', () => {
+        it('应该识别线性化参数字典', () => {
+            assert.strictEqual(isLinearized(LINEARIZED_HEADER), true);
+            assert.strictEqual(isLinearized(LINEARIZED_HEADER, 40087), true);
+        });
+
+        it('文件长度与 /L 不符时应该视为非线性化', () => {
+            assert.strictEqual(isLinearized(LINEARIZED_HEADER, 50000), false);
+        });
+
+        it('普通 PDF 应该返回 false', () => {
+            const header = Buffer.from('%PDF-1.4\n1 0 obj\n<</Type/Catalog/Pages 2 0 R>>\nendobj\n', 'latin1');
+            assert.strictEqual(isLinearized(header, 1000), false);
+        });
+
+        it('只检查前 1024 字节', () => {
+            const header = Buffer.concat([
+                Buffer.alloc(1024, 0x20),
+                Buffer.from('<</Linearized 1>>', 'latin1'),
+            ]);
+            assert.strictEqual(isLinearized(header), false);
+        });
+    });
+});
@@ -0,0 +1,67 @@
+/**
+ * PDF2IMG 线程池饱和度监控测试
+ *
+ * 运行方式：
+ *   node --test test/pool-monitor.test.js
+ */
+
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { setTimeout as sleep } from 'timers/promises';
+
+import { PoolMonitor } from '../src/utils/pool-monitor.js';
+
+describe('PDF2IMG 线程池饱和度监控测试', () => {
+    it('所有线程忙碌时应该报告饱和与排队数', () => {
+        const monitor = new PoolMonitor({ maxThreads: 2, degradedAfter: 1000 });
+        monitor.start();
+        assert.deepStrictEqual(
+            pick(monitor.getStats()),
+            { active: 1, idle: 1, queued: 0, saturated: false, status: 'ok' },
+        );
+
+        monitor.start();
+        monitor.start();
+        assert.deepStrictEqual(
+            pick(monitor.getStats()),
+            { active: 2, idle: 0, queued: 1, saturated: true, status: 'ok' },
+        );
+    });
+
+    it('持续饱和超过阈值后应该报告 degraded，释放后恢复', async () => {
+        const monitor = new PoolMonitor({ maxThreads: 2, degradedAfter: 50 });
+        for (let i = 0; i < 4; i++) {
+            monitor.start();
+        }
+
+        await sleep(60);
+        const saturated = monitor.getStats();
+        assert.strictEqual(saturated.status, 'degraded');
+        assert.ok(saturated.saturatedFor >= 50);
+
+        // 排队任务开始执行，仍然饱和
+        monitor.finish();
+        monitor.finish();
+        assert.strictEqual(monitor.getStats().status, 'degraded');
+
+        monitor.finish();
+        const recovered = monitor.getStats();
+        assert.strictEqual(recovered.status, 'ok');
+        assert.strictEqual(recovered.saturatedFor, 0);
+        assert.strictEqual(recovered.idle, 1);
+    });
+
+    it('短暂饱和不应该报告 degraded', async () => {
+        const monitor = new PoolMonitor({ maxThreads: 1, degradedAfter: 50 });
+        monitor.start();
+        await sleep(20);
+        monitor.finish();
+        monitor.start();
+        await sleep(40);
+        assert.strictEqual(monitor.getStats().status, 'ok', '饱和计时应该在释放后重新开始');
+    });
+});
+
+function pick({ active, idle, queued, saturated, status }) {
+    return { active, idle, queued, saturated, status };
+}
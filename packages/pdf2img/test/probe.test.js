@@ -0,0 +1,118 @@
+/**
+ * PDF2IMG 远程预检测试
+ *
+ * 运行方式：
+ *   node --test test/probe.test.js
+ */
+
+import { describe, it, before, after } from 'node:test';
+import assert from 'node:assert';
+import http from 'http';
+
+import { probeUrl } from '../src/utils/probe.js';
+
+const BODY = Buffer.from('%PDF-1.4\n' + 'x'.repeat(1000));
+
+describe('PDF2IMG 远程预检测试', () => {
+    let server;
+    let baseUrl;
+    const requests = [];
+
+    before(async () => {
+        server = http.createServer((req, res) => {
+            requests.push(`${req.method} ${req.url}`);
+            const headers = { 'Content-Type': 'application/pdf', 'ETag': '"v1"' };
+            const range = /^bytes=(\d+)-(\d+)$/.exec(req.headers.range || '');
+
+            // /ranges：HEAD 声明 Accept-Ranges
+            // /no-ranges：忽略 Range，总是返回完整文件
+            // /no-head：HEAD 返回 405，GET 支持 Range
+            if (req.url === '/no-head' && req.method === 'HEAD') {
+                res.writeHead(405);
+                res.end();
+                return;
+            }
+            if (req.url === '/slow') {
+                // 不响应，等待客户端取消
+                return;
+            }
+            if (req.url === '/missing') {
+                res.writeHead(404);
+                res.end();
+                return;
+            }
+            if (req.url !== '/no-ranges' && range) {
+                const start = Number(range[1]);
+                const end = Math.min(Number(range[2]), BODY.length - 1);
+                res.writeHead(206, { ...headers, 'Content-Range': `bytes ${start}-${end}/${BODY.length}` });
+                res.end(BODY.subarray(start, end + 1));
+                return;
+            }
+            if (req.url === '/ranges') {
+                headers['Accept-Ranges'] = 'bytes';
+            }
+            res.writeHead(200, { ...headers, 'Content-Length': BODY.length });
+            res.end(req.method === 'HEAD' ? undefined : BODY);
+        });
+        await new Promise(resolve => server.listen(0, '127.0.0.1', resolve));
+        baseUrl = `http://127.0.0.1:${server.address().port}`;
+    });
+
+    after(() => {
+        server.closeAllConnections();
+        server.close();
+    });
+
+    it('声明 Accept-Ranges 的服务器只需要一次 HEAD 请求', async () => {
+        requests.length = 0;
+        const result = await probeUrl(`${baseUrl}/ranges`);
+        assert.deepStrictEqual(result, {
+            statusCode: 200,
+            size: BODY.length,
+            acceptsRanges: true,
+            contentType: 'application/pdf',
+            etag: '"v1"',
+        });
+        assert.deepStrictEqual(requests, ['HEAD /ranges']);
+    });
+
+    it('不支持 Range 的服务器应该识别为 acceptsRanges=false', async () => {
+        const result = await probeUrl(`${baseUrl}/no-ranges`);
+        assert.strictEqual(result.acceptsRanges, false);
+        assert.strictEqual(result.size, BODY.length);
+        assert.strictEqual(result.statusCode, 200);
+    });
+
+    it('HEAD 不可用时应该改用 Range 请求并从 Content-Range 解析大小', async () => {
+        const result = await probeUrl(`${baseUrl}/no-head`);
+        assert.strictEqual(result.acceptsRanges, true);
+        assert.strictEqual(result.size, BODY.length);
+        assert.strictEqual(result.statusCode, 206);
+    });
+
+    it('服务器返回错误状态时应该返回状态码而不是抛出异常', async () => {
+        const result = await probeUrl(`${baseUrl}/missing`);
+        assert.strictEqual(result.statusCode, 404);
+        assert.strictEqual(result.acceptsRanges, false);
+        assert.strictEqual(result.size, null);
+    });
+
+    it('应该遵守访问策略', async () => {
+        await assert.rejects(
+            probeUrl(`${baseUrl}/ranges`, { allowedHosts: ['cdn.example.com'] }),
+            err => err.code === 'ERR_URL_NOT_ALLOWED'
+        );
+    });
+
+    it('取消信号应该立即结束预检', async () => {
+        const controller = new AbortController();
+        const start = Date.now();
+        setTimeout(() => controller.abort(), 50);
+
+        await assert.rejects(
+            probeUrl(`${baseUrl}/slow`, { signal: controller.signal, timeout: 10000 }),
+            err => err.name === 'AbortError'
+        );
+        assert.ok(Date.now() - start < 1000, '应该在取消后立即返回');
+    });
+});
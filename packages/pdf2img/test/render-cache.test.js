@@ -0,0 +1,69 @@
+/**
+ * PDF2IMG 渲染缓存测试
+ *
+ * 运行方式：
+ *   node --test test/render-cache.test.js
+ */
+
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+
+import { createRenderCache, cacheKey } from '../src/utils/render-cache.js';
+
+describe('PDF2IMG 渲染缓存测试', () => {
+    it('应该记录命中和未命中次数', () => {
+        const cache = createRenderCache();
+        assert.strictEqual(cache.get('a'), undefined);
+        cache.set('a', 'value', 5);
+        assert.strictEqual(cache.get('a'), 'value');
+
+        assert.deepStrictEqual(cache.getStats(), {
+            hits: 1, misses: 1, evictions: 0, entries: 1, bytes: 5, maxBytes: 256 * 1024 * 1024,
+        });
+    });
+
+    it('超出容量时应该淘汰最久未使用的条目', () => {
+        const cache = createRenderCache({ maxBytes: 10 });
+        cache.set('a', 1, 4);
+        cache.set('b', 2, 4);
+        cache.get('a'); // a 变为最近使用
+        cache.set('c', 3, 4);
+
+        assert.strictEqual(cache.get('b'), undefined, 'b 应该被淘汰');
+        assert.strictEqual(cache.get('a'), 1);
+        assert.strictEqual(cache.get('c'), 3);
+        assert.strictEqual(cache.getStats().evictions, 1);
+        assert.strictEqual(cache.getStats().bytes, 8);
+    });
+
+    it('超过容量上限的单个条目不应该被缓存', () => {
+        const cache = createRenderCache({ maxBytes: 10 });
+        cache.set('a', 1, 4);
+        cache.set('big', 2, 11);
+        assert.strictEqual(cache.get('big'), undefined);
+        assert.strictEqual(cache.get('a'), 1, '已有条目不应该被挤掉');
+    });
+
+    it('覆盖写入时应该更新占用字节数', () => {
+        const cache = createRenderCache();
+        cache.set('a', 1, 4);
+        cache.set('a', 2, 6);
+        assert.strictEqual(cache.get('a'), 2);
+        assert.strictEqual(cache.getStats().bytes, 6);
+    });
+
+    it('条目超过 TTL 后应该失效', async () => {
+        const cache = createRenderCache({ ttl: 20 });
+        cache.set('a', 1, 1);
+        assert.strictEqual(cache.get('a'), 1);
+        await new Promise(resolve => setTimeout(resolve, 30));
+        assert.strictEqual(cache.get('a'), undefined);
+        assert.strictEqual(cache.getStats().entries, 0);
+    });
+
+    it('相同参数应该生成相同的 key', () => {
+        assert.strictEqual(cacheKey('doc', 1, { format: 'webp' }), cacheKey('doc', 1, { format: 'webp' }));
+        assert.notStrictEqual(cacheKey('doc', 1, { format: 'webp' }), cacheKey('doc', 1, { format: 'png' }));
+        assert.notStrictEqual(cacheKey('doc', 1, {}), cacheKey('doc', 2, {}));
+    });
+});
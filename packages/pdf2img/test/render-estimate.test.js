@@ -0,0 +1,83 @@
+/**
+ * PDF2IMG 渲染耗时估算测试
+ *
+ * 运行方式：
+ *   node --test test/render-estimate.test.js
+ */
+
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+
+import { RenderRate, buildCalibrationPdf, estimateRenderMs, outputMegapixels } from '../src/utils/render-estimate.js';
+
+const A4 = { width: 595, height: 842 };
+const RENDER = { targetWidth: 1280, maxScale: 4 };
+
+describe('PDF2IMG 渲染耗时估算测试', () => {
+    it('输出像素应该遵循 targetWidth、maxScale 和 exactWidth', () => {
+        assert.strictEqual(outputMegapixels(A4, RENDER), 1280 * (842 * 1280 / 595) / 1e6);
+        // 小页面受 maxScale 限制
+        assert.strictEqual(outputMegapixels({ width: 100, height: 100 }, RENDER), 400 * 400 / 1e6);
+        assert.strictEqual(outputMegapixels({ width: 100, height: 100 }, { ...RENDER, exactWidth: 1000 }), 1);
+    });
+
+    it('估算耗时应该随页数增长', () => {
+        const estimate = numPages => estimateRenderMs({
+            numPages, pageSizes: [A4], msPerMegapixel: 50, threads: 4, render: RENDER,
+        });
+
+        assert.strictEqual(estimate(0), 0);
+        assert.strictEqual(estimate(1), estimate(4), '页数不超过线程数时并行渲染');
+        // 结果取整到毫秒，允许 1% 误差
+        const assertScaled = (actual, base, factor) => assert.ok(
+            Math.abs(actual / (base * factor) - 1) < 0.01,
+            `${actual} 应该约为 ${base} 的 ${factor} 倍`,
+        );
+        assertScaled(estimate(8), estimate(4), 2);
+        assertScaled(estimate(400), estimate(4), 100);
+        assert.ok(estimate(5) > estimate(4));
+    });
+
+    it('估算耗时应该随输出像素增长，缺少页面尺寸时按 A4 估算', () => {
+        const estimate = (pageSizes, render = RENDER) => estimateRenderMs({
+            numPages: 10, pageSizes, msPerMegapixel: 50, threads: 1, render,
+        });
+
+        assert.strictEqual(estimate([]), estimate([A4]));
+        assert.ok(estimate([A4], { ...RENDER, targetWidth: 2560 }) > 3 * estimate([A4]));
+        assert.ok(estimate([{ width: 595, height: 1684 }]) > estimate([A4]), '长页面输出像素更多');
+    });
+
+    it('渲染速率应该只校准一次，并由实际渲染结果更新', async () => {
+        let calibrations = 0;
+        const rate = new RenderRate(async () => {
+            calibrations++;
+            await new Promise(resolve => setTimeout(resolve, 5));
+            return 100;
+        }, 0.5);
+
+        // 并发读取共享同一次校准
+        assert.deepStrictEqual(await Promise.all([rate.get(), rate.get()]), [100, 100]);
+        assert.strictEqual(await rate.get(), 100);
+        assert.strictEqual(calibrations, 1);
+
+        rate.record(100, 2);
+        assert.strictEqual(await rate.get(), 75);
+        rate.record(0, 2);
+        assert.strictEqual(await rate.get(), 75, '无效样本应该被忽略');
+
+        // 已有实际渲染样本时不再校准
+        const observed = new RenderRate(() => assert.fail('不应该校准'));
+        observed.record(30, 1);
+        assert.strictEqual(await observed.get(), 30);
+    });
+
+    it('校准用 PDF 的交叉引用表应该指向各个对象', () => {
+        const pdf = buildCalibrationPdf().toString('latin1');
+        const startxref = Number(pdf.match(/startxref\n(\d+)/)[1]);
+        assert.ok(pdf.startsWith('xref', startxref));
+        const offsets = [...pdf.slice(startxref).matchAll(/^(\d{10}) 00000 n $/gm)].map(m => Number(m[1]));
+        assert.strictEqual(offsets.length, 4);
+        offsets.forEach((offset, i) => assert.ok(pdf.startsWith(`${i + 1} 0 obj`, offset)));
+    });
+});
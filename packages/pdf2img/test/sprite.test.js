@@ -0,0 +1,84 @@
+/**
+ * PDF2IMG 精灵图布局测试
+ *
+ * 运行方式：
+ *   node --test test/sprite.test.js
+ */
+
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+
+import { packShelves } from '../src/utils/sprite.js';
+
+/**
+ * 断言所有位置都在精灵图范围内且互不重叠
+ */
+function assertValidLayout({ width, height, rects }) {
+    for (const rect of rects) {
+        assert.ok(rect.x >= 0 && rect.y >= 0, '坐标不应该为负');
+        assert.ok(rect.x + rect.width <= width, '不应该超出精灵图宽度');
+        assert.ok(rect.y + rect.height <= height, '不应该超出精灵图高度');
+    }
+    for (let i = 0; i < rects.length; i++) {
+        for (let j = i + 1; j < rects.length; j++) {
+            const a = rects[i];
+            const b = rects[j];
+            const overlaps = a.x < b.x + b.width && b.x < a.x + a.width
+                && a.y < b.y + b.height && b.y < a.y + a.height;
+            assert.ok(!overlaps, `第 ${i + 1} 和第 ${j + 1} 个位置不应该重叠`);
+        }
+    }
+}
+
+describe('PDF2IMG 精灵图布局测试', () => {
+    it('应该按顺序逐行排列', () => {
+        const layout = packShelves([
+            { width: 100, height: 140 },
+            { width: 100, height: 70 },
+            { width: 100, height: 140 },
+            { width: 100, height: 100 },
+        ], 250, 10);
+
+        assert.deepStrictEqual(layout.rects, [
+            { x: 0, y: 0, width: 100, height: 140 },
+            { x: 110, y: 0, width: 100, height: 70 },
+            { x: 0, y: 150, width: 100, height: 140 },
+            { x: 110, y: 150, width: 100, height: 100 },
+        ]);
+        assert.strictEqual(layout.width, 210);
+        assert.strictEqual(layout.height, 290);
+        assertValidLayout(layout);
+    });
+
+    it('宽于上限的缩略图应该单独占一行', () => {
+        const layout = packShelves([
+            { width: 50, height: 50 },
+            { width: 300, height: 20 },
+            { width: 50, height: 50 },
+        ], 200);
+
+        assert.deepStrictEqual(layout.rects.map(r => [r.x, r.y]), [[0, 0], [0, 50], [0, 70]]);
+        assert.strictEqual(layout.width, 300);
+        assertValidLayout(layout);
+    });
+
+    it('随机尺寸的布局应该在范围内且互不重叠', () => {
+        let seed = 42;
+        const random = () => (seed = (seed * 1103515245 + 12345) % 2147483648) / 2147483648;
+
+        for (let round = 0; round < 20; round++) {
+            const sizes = Array.from({ length: 1 + Math.floor(random() * 40) }, () => ({
+                width: 20 + Math.floor(random() * 200),
+                height: 20 + Math.floor(random() * 300),
+            }));
+            const layout = packShelves(sizes, 512, Math.floor(random() * 8));
+            assert.strictEqual(layout.rects.length, sizes.length);
+            assert.ok(layout.width <= Math.max(512, ...sizes.map(s => s.width)));
+            assertValidLayout(layout);
+        }
+    });
+
+    it('没有缩略图时精灵图尺寸应该为 0', () => {
+        assert.deepStrictEqual(packShelves([], 512), { width: 0, height: 0, rects: [] });
+    });
+});
@@ -0,0 +1,40 @@
+/**
+ * PDF2IMG 按需加载效率报告测试
+ *
+ * 运行方式：
+ *   node --test test/stream-stats.test.js
+ */
+
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+
+import { efficiencyReport } from '../src/utils/stream-stats.js';
+
+describe('PDF2IMG 按需加载效率报告测试', () => {
+    it('应该区分读取量、下载量和触及量', () => {
+        const report = efficiencyReport({
+            totalRequests: 5,
+            cacheHits: 40,
+            cacheMisses: 5,
+            totalBytesFetched: 5 * 262144,
+            cacheBytes: 600000,
+            requestedBytes: 800000,
+            touchedBytes: 4 * 262144,
+        }, 10 * 262144);
+
+        assert.strictEqual(report.requestedBytes, 800000);
+        assert.strictEqual(report.networkBytes, 5 * 262144);
+        assert.strictEqual(report.refetchedBytes, 262144, '淘汰后重新下载的块应该计为重复下载');
+        assert.strictEqual(report.touchedRatio, 0.4);
+        assert.strictEqual(report.networkRatio, 0.5);
+        assert.strictEqual(report.cacheHitRatio, 0.75);
+        assert.strictEqual(report.requests, 5);
+    });
+
+    it('没有统计数据时各项应该为 0', () => {
+        const report = efficiencyReport(undefined, 0);
+        assert.strictEqual(report.networkBytes, 0);
+        assert.strictEqual(report.touchedRatio, 0);
+        assert.strictEqual(report.cacheHitRatio, 0);
+    });
+});
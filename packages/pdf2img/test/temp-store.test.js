@@ -0,0 +1,97 @@
+/**
+ * PDF2IMG 临时文件管理测试
+ *
+ * 运行方式：
+ *   node --test test/temp-store.test.js
+ */
+
+import { describe, it, beforeEach, afterEach } from 'node:test';
+import assert from 'node:assert';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+
+import { createTempStore } from '../src/utils/temp-store.js';
+
+const HOUR = 60 * 60 * 1000;
+
+describe('PDF2IMG 临时文件管理测试', () => {
+    let dir;
+
+    beforeEach(async () => {
+        dir = await fs.promises.mkdtemp(path.join(os.tmpdir(), 'pdf2img-temp-store-'));
+    });
+
+    afterEach(async () => {
+        await fs.promises.rm(dir, { recursive: true, force: true });
+    });
+
+    /**
+     * 写入文件并把修改时间设为 ageMs 之前
+     */
+    async function writeAged(name, ageMs) {
+        const file = path.join(dir, name);
+        await fs.promises.writeFile(file, 'x');
+        const time = new Date(Date.now() - ageMs);
+        await fs.promises.utimes(file, time, time);
+        return file;
+    }
+
+    it('释放后应该删除文件', async () => {
+        const store = createTempStore({ dir, staleAfter: HOUR });
+        const file = await store.create('.pdf');
+        assert.ok(file.startsWith(dir) && file.endsWith('.pdf'));
+
+        await fs.promises.writeFile(file, 'pdf');
+        await store.release(file);
+        assert.strictEqual(fs.existsSync(file), false);
+    });
+
+    it('最后一个引用释放时才删除文件', async () => {
+        const store = createTempStore({ dir, staleAfter: HOUR });
+        const file = await store.create();
+        await fs.promises.writeFile(file, 'pdf');
+
+        store.acquire(file);
+        await store.release(file);
+        assert.strictEqual(fs.existsSync(file), true, '仍有引用时不应该删除');
+
+        await store.release(file);
+        assert.strictEqual(fs.existsSync(file), false);
+    });
+
+    it('初始化时应该清理残留文件', async () => {
+        const stale = await writeAged('pdf2img_1_stale.pdf', 2 * HOUR);
+        const fresh = await writeAged('pdf2img_2_fresh.pdf', 0);
+        const foreign = await writeAged('other.pdf', 2 * HOUR);
+
+        const store = createTempStore({ dir, staleAfter: HOUR });
+        await store.init();
+
+        assert.strictEqual(fs.existsSync(stale), false, '过期的残留文件应该被清理');
+        assert.strictEqual(fs.existsSync(fresh), true, '未过期的文件可能属于其他进程，不应该清理');
+        assert.strictEqual(fs.existsSync(foreign), true, '不应该清理其他程序的文件');
+    });
+
+    it('定时清理应该跳过正在使用的文件', async () => {
+        const store = createTempStore({ dir, staleAfter: HOUR });
+        const file = await store.create('.pdf');
+        await fs.promises.writeFile(file, 'pdf');
+        const time = new Date(Date.now() - 2 * HOUR);
+        await fs.promises.utimes(file, time, time);
+
+        assert.strictEqual(await store.sweep(), 0);
+        assert.strictEqual(fs.existsSync(file), true);
+
+        await store.release(file);
+        assert.strictEqual(fs.existsSync(file), false);
+    });
+
+    it('目录不存在时应该自动创建', async () => {
+        const nested = path.join(dir, 'a', 'b');
+        const store = createTempStore({ dir: nested, staleAfter: HOUR });
+        const file = await store.create('.pdf');
+        assert.strictEqual(path.dirname(file), nested);
+        assert.ok(fs.statSync(nested).isDirectory());
+    });
+});
@@ -0,0 +1,69 @@
+/**
+ * PDF2IMG 追踪工具测试
+ *
+ * 运行方式：
+ *   node --test test/tracing.test.js
+ */
+
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+
+import { withSpan, collectTraceHeaders, SPAN_STATUS_ERROR } from '../src/utils/tracing.js';
+import { createMemoryTracer } from './helpers/memory-tracer.js';
+
+describe('PDF2IMG 追踪工具测试', () => {
+    it('未传入 tracer 时应该直接执行', async () => {
+        const result = await withSpan(undefined, 'noop', {}, async (span) => {
+            span.setAttribute('a', 1).setAttributes({ b: 2 });
+            return 42;
+        });
+        assert.strictEqual(result, 42);
+    });
+
+    it('应该记录属性并结束 span，子 span 关联到父 span', async () => {
+        const tracer = createMemoryTracer();
+        await withSpan(tracer, 'parent', { 'pdf2img.format': 'webp' }, async (span) => {
+            span.setAttribute('pdf2img.page_count', 3);
+            await withSpan(tracer, 'child', {}, async () => {});
+        });
+
+        const [child, parent] = tracer.spans;
+        assert.strictEqual(parent.name, 'parent');
+        assert.deepStrictEqual(parent.attributes, { 'pdf2img.format': 'webp', 'pdf2img.page_count': 3 });
+        assert.ok(parent.ended);
+        assert.strictEqual(child.parent, parent);
+    });
+
+    it('异常时应该记录错误状态并继续抛出', async () => {
+        const tracer = createMemoryTracer();
+        await assert.rejects(
+            withSpan(tracer, 'failing', {}, async () => { throw new Error('boom'); }),
+            /boom/
+        );
+        const [span] = tracer.spans;
+        assert.strictEqual(span.status.code, SPAN_STATUS_ERROR);
+        assert.strictEqual(span.exceptions[0].message, 'boom');
+        assert.ok(span.ended);
+    });
+
+    it('只实现 startSpan 的 tracer 也应该可用', async () => {
+        const ended = [];
+        const tracer = {
+            startSpan: (name) => ({
+                setAttribute() { return this; },
+                setAttributes() { return this; },
+                recordException() {},
+                setStatus() { return this; },
+                end: () => ended.push(name),
+            }),
+        };
+        await withSpan(tracer, 'plain', {}, async () => {});
+        assert.deepStrictEqual(ended, ['plain']);
+    });
+
+    it('应该通过钩子收集追踪请求头', () => {
+        assert.strictEqual(collectTraceHeaders(undefined), undefined);
+        const headers = collectTraceHeaders(h => { h.traceparent = '00-abc-def-01'; });
+        assert.deepStrictEqual(headers, { traceparent: '00-abc-def-01' });
+    });
+});
@@ -0,0 +1,187 @@
+/**
+ * PDF2IMG 远程 ZIP 条目读取测试
+ *
+ * 运行方式：
+ *   node --test test/zip.test.js
+ */
+
+import { describe, it, before, after } from 'node:test';
+import assert from 'node:assert';
+import http from 'http';
+import fs from 'fs';
+import os from 'os';
+import path from 'path';
+import crypto from 'crypto';
+
+import { isZipUrl, parseZipUrl, readZipEntry } from '../src/utils/zip.js';
+import { SECURITY_CONFIG } from '../src/core/config.js';
+import { buildZip } from './helpers/zip-archive.js';
+import { serveRange } from './helpers/range.js';
+
+describe('PDF2IMG 远程 ZIP 条目读取测试', () => {
+    const pdf = Buffer.from('%PDF-1.4\n' + 'stream content '.repeat(200) + '\n%%EOF');
+    const stored = Buffer.from('%PDF-1.7 stored entry');
+    // 不可压缩的大条目：只读取其他条目时不应该被下载
+    const large = crypto.randomBytes(512 * 1024);
+    // 可压缩的大条目：解压后的数据分多个数据块写入文件
+    const big = Buffer.from('0 0 612 792 re f\n'.repeat(256 * 1024));
+    const archive = buildZip([
+        { name: 'large.bin', data: large },
+        { name: 'docs/a.pdf', data: pdf, deflate: true, extra: Buffer.alloc(12) },
+        { name: 'docs/b.pdf', data: stored },
+        { name: 'docs/big.pdf', data: big, deflate: true },
+    ]);
+
+    // 压缩炸弹：中央目录记录的大小远小于实际解压后的数据
+    const bomb = buildZip([{ name: 'bomb.pdf', data: Buffer.alloc(4 * 1024 * 1024), deflate: true }]);
+    const bombCentral = bomb.lastIndexOf(Buffer.from([0x50, 0x4b, 0x01, 0x02]));
+    bomb.writeUInt32LE(1000, bombCentral + 24);
+
+    let server;
+    let baseUrl;
+    let bytesSent = 0;
+    let dir;
+
+    // 读取条目到临时文件并返回其内容
+    async function readEntry(input, network) {
+        const file = path.join(dir, `${crypto.randomUUID()}.pdf`);
+        const entry = await readZipEntry(input, file, network);
+        const data = fs.readFileSync(file);
+        assert.strictEqual(entry.size, data.length);
+        return data;
+    }
+
+    before(async () => {
+        dir = fs.mkdtempSync(path.join(os.tmpdir(), 'pdf2img-zip-'));
+        server = http.createServer((req, res) => {
+            if (req.url === '/bomb.zip') {
+                serveRange(req, res, bomb);
+                return;
+            }
+
+            if (req.url === '/no-range.zip') {
+                res.writeHead(200, { 'Content-Length': archive.length });
+                res.end(archive);
+                return;
+            }
+
+            // 有问题的源站：显式范围请求返回错位一个字节的数据
+            if (req.url === '/wrong-range.zip' && /^bytes=[1-9]\d*-\d+$/.test(req.headers.range)) {
+                const [start, end] = req.headers.range.slice(6).split('-').map(Number);
+                const body = archive.subarray(start - 1, end);
+                res.writeHead(206, {
+                    'Content-Length': body.length,
+                    'Content-Range': `bytes ${start - 1}-${end - 1}/${archive.length}`,
+                });
+                res.end(body);
+                return;
+            }
+
+            bytesSent += serveRange(req, res, archive);
+        });
+        await new Promise(resolve => server.listen(0, '127.0.0.1', resolve));
+        baseUrl = `http://127.0.0.1:${server.address().port}`;
+    });
+
+    after(() => {
+        server.close();
+        fs.rmSync(dir, { recursive: true, force: true });
+    });
+
+    describe('parseZipUrl', () => {
+        it('应该以最后一个感叹号分隔归档地址和条目路径', () => {
+            assert.ok(isZipUrl('zip://https://example.com/a.zip!b.pdf'));
+            assert.ok(!isZipUrl('https://example.com/a.pdf'));
+            assert.deepStrictEqual(parseZipUrl('zip://https://example.com/a.zip?sig=x!y!docs/b.pdf'), {
+                archiveUrl: 'https://example.com/a.zip?sig=x!y',
+                entryPath: 'docs/b.pdf',
+            });
+        });
+
+        it('格式错误时应该报错', () => {
+            assert.throws(() => parseZipUrl('zip://https://example.com/a.zip'), /Invalid zip input/);
+            assert.throws(() => parseZipUrl('zip://file.zip!a.pdf'), /Invalid zip input/);
+            assert.throws(() => parseZipUrl('zip://https://example.com/a.zip!'), /Invalid zip input/);
+        });
+    });
+
+    describe('readZipEntry', () => {
+        it('应该只下载目标条目而不是整个归档', async () => {
+            bytesSent = 0;
+            const data = await readEntry(`zip://${baseUrl}/batch.zip!docs/a.pdf`);
+            assert.ok(data.equals(pdf));
+            assert.ok(bytesSent < large.length / 4, `下载了 ${bytesSent} 字节，归档共 ${archive.length} 字节`);
+        });
+
+        it('应该读取不压缩的条目', async () => {
+            const data = await readEntry(`zip://${baseUrl}/batch.zip!/docs/b.pdf`);
+            assert.ok(data.equals(stored));
+        });
+
+        it('应该边下载边解压大条目', async () => {
+            const data = await readEntry(`zip://${baseUrl}/batch.zip!docs/big.pdf`);
+            assert.ok(data.equals(big));
+        });
+
+        it('解压后的数据超过记录的大小时应该中止', async () => {
+            const file = path.join(dir, 'bomb.pdf');
+            await assert.rejects(
+                readZipEntry(`zip://${baseUrl}/bomb.zip!bomb.pdf`, file),
+                err => err.code === 'ERR_ZIP_INVALID'
+            );
+            // 管道在写入流打开文件前失败时不会创建文件
+            const written = fs.existsSync(file) ? fs.statSync(file).size : 0;
+            assert.ok(written <= 1000, `${written} > 1000`);
+        });
+
+        it('条目超过默认大小上限时应该在下载条目数据前报错', async () => {
+            const { MAX_ZIP_ENTRY_SIZE } = SECURITY_CONFIG;
+            SECURITY_CONFIG.MAX_ZIP_ENTRY_SIZE = 1024;
+            try {
+                bytesSent = 0;
+                await assert.rejects(
+                    readEntry(`zip://${baseUrl}/batch.zip!docs/big.pdf`),
+                    err => err.code === 'ERR_FILE_TOO_LARGE' && err.size === big.length
+                );
+                assert.ok(bytesSent < large.length / 4, `下载了 ${bytesSent} 字节`);
+            } finally {
+                SECURITY_CONFIG.MAX_ZIP_ENTRY_SIZE = MAX_ZIP_ENTRY_SIZE;
+            }
+        });
+
+        it('条目不存在时应该报错', async () => {
+            await assert.rejects(
+                readEntry(`zip://${baseUrl}/batch.zip!docs/missing.pdf`),
+                err => err.code === 'ERR_ZIP_ENTRY_NOT_FOUND'
+            );
+        });
+
+        it('条目超过大小上限时应该报错', async () => {
+            await assert.rejects(
+                readEntry(`zip://${baseUrl}/batch.zip!docs/a.pdf`, { maxFileSize: 100 }),
+                err => err.code === 'ERR_FILE_TOO_LARGE' && err.size === pdf.length
+            );
+        });
+
+        it('服务器不支持 Range 请求时应该报错', async () => {
+            await assert.rejects(
+                readEntry(`zip://${baseUrl}/no-range.zip!docs/a.pdf`),
+                err => err.code === 'ERR_ZIP_RANGE_UNSUPPORTED'
+            );
+        });
+
+        it('源站返回的范围与请求不一致时应该报错', async () => {
+            await assert.rejects(
+                readEntry(`zip://${baseUrl}/wrong-range.zip!docs/a.pdf`),
+                err => err.code === 'ERR_RANGE_MISMATCH'
+            );
+        });
+
+        it('应该遵守访问策略', async () => {
+            await assert.rejects(
+                readEntry(`zip://${baseUrl}/batch.zip!docs/a.pdf`, { allowedHosts: ['example.com'] }),
+                err => err.code === 'ERR_URL_NOT_ALLOWED'
+            );
+        });
+    });
+});